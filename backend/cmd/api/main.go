@@ -1,10 +1,18 @@
 package main
 
 import (
+	"context"
 	"log"
 	"os"
+	"os/signal"
+	"syscall"
+	"time"
+	_ "time/tzdata"
 
 	httpTransport "familybudget/internal/http"
+	"familybudget/internal/jobs"
+	"familybudget/internal/notify"
+	"familybudget/internal/rates"
 	"familybudget/internal/store"
 )
 
@@ -22,6 +30,53 @@ func main() {
 
 	st := store.New(db)
 
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	jobsInterval := time.Minute
+	if env := os.Getenv("BUDGET_JOBS_INTERVAL"); env != "" {
+		parsed, err := time.ParseDuration(env)
+		if err != nil {
+			log.Fatalf("invalid BUDGET_JOBS_INTERVAL: %v", err)
+		}
+		jobsInterval = parsed
+	}
+	notifiers := map[string]notify.Notifier{
+		store.ReminderChannelInbox: notify.NewInbox(st),
+	}
+	if addr := os.Getenv("BUDGET_SMTP_ADDR"); addr != "" {
+		notifiers[store.ReminderChannelEmail] = notify.NewSMTP(notify.SMTPConfig{
+			Addr:     addr,
+			From:     os.Getenv("BUDGET_SMTP_FROM"),
+			Username: os.Getenv("BUDGET_SMTP_USERNAME"),
+			Password: os.Getenv("BUDGET_SMTP_PASSWORD"),
+		})
+	}
+	var rateProviders []rates.Provider
+	if source := os.Getenv("BUDGET_ECB_SOURCE"); source != "off" {
+		if source == "" {
+			source = rates.ECBDailyURL
+		}
+		rateProviders = append(rateProviders, rates.NewECB(source, nil))
+	}
+	ratesInterval := 24 * time.Hour
+	if env := os.Getenv("BUDGET_RATES_INTERVAL"); env != "" {
+		parsed, err := time.ParseDuration(env)
+		if err != nil {
+			log.Fatalf("invalid BUDGET_RATES_INTERVAL: %v", err)
+		}
+		ratesInterval = parsed
+	}
+	jobs.NewRunner(jobsInterval,
+		jobs.NewDebtDueSoon(st),
+		jobs.NewAllowancePosting(st),
+		jobs.NewPlannedOperationPosting(st),
+		jobs.NewPlannedOccurrenceMissed(st),
+		jobs.NewReminderDispatch(st, notifiers),
+		jobs.NewExchangeRateFetch(st, ratesInterval, rateProviders...),
+		jobs.NewTransactionBaseAmounts(st),
+	).Start(ctx)
+
 	server := httpTransport.New()
 	handlers := httpTransport.NewHandlers(st)
 	httpTransport.RegisterHealth(server.Echo())
@@ -0,0 +1,233 @@
+package domain
+
+import (
+	"sort"
+	"strings"
+)
+
+// Currency is an ISO 4217 currency. Exponent is the number of minor-unit
+// digits: amounts are stored as amount * 10^Exponent, so 100 JPY is 100 and
+// 1 KWD is 1000. Names are keyed by language ("en", "ru").
+type Currency struct {
+	Code     string            `json:"code"`
+	Exponent int               `json:"exponent"`
+	Symbol   string            `json:"symbol"`
+	Names    map[string]string `json:"names"`
+}
+
+// Name returns the currency name in the language of locale (ru-RU, en_US or
+// ru), falling back to English.
+func (c Currency) Name(locale string) string {
+	lang := strings.ToLower(strings.TrimSpace(locale))
+	if i := strings.IndexAny(lang, "-_"); i >= 0 {
+		lang = lang[:i]
+	}
+	if name, ok := c.Names[lang]; ok {
+		return name
+	}
+	return c.Names["en"]
+}
+
+// DefaultCurrencies are enabled for every family; others have to be enabled
+// in the family settings.
+var DefaultCurrencies = []string{"RUB", "USD", "EUR", "KZT", "BYN", "UAH", "GBP"}
+
+// currencies lists the circulating ISO 4217 currencies.
+var currencies = []Currency{
+	{"AED", 2, "د.إ", map[string]string{"en": "UAE Dirham", "ru": "Дирхам ОАЭ"}},
+	{"AFN", 2, "؋", map[string]string{"en": "Afghani", "ru": "Афгани"}},
+	{"ALL", 2, "L", map[string]string{"en": "Lek", "ru": "Лек"}},
+	{"AMD", 2, "֏", map[string]string{"en": "Armenian Dram", "ru": "Армянский драм"}},
+	{"ANG", 2, "ƒ", map[string]string{"en": "Netherlands Antillean Guilder", "ru": "Нидерландский антильский гульден"}},
+	{"AOA", 2, "Kz", map[string]string{"en": "Kwanza", "ru": "Кванза"}},
+	{"ARS", 2, "$", map[string]string{"en": "Argentine Peso", "ru": "Аргентинское песо"}},
+	{"AUD", 2, "A$", map[string]string{"en": "Australian Dollar", "ru": "Австралийский доллар"}},
+	{"AWG", 2, "ƒ", map[string]string{"en": "Aruban Florin", "ru": "Арубанский флорин"}},
+	{"AZN", 2, "₼", map[string]string{"en": "Azerbaijan Manat", "ru": "Азербайджанский манат"}},
+	{"BAM", 2, "KM", map[string]string{"en": "Convertible Mark", "ru": "Конвертируемая марка"}},
+	{"BBD", 2, "$", map[string]string{"en": "Barbados Dollar", "ru": "Барбадосский доллар"}},
+	{"BDT", 2, "৳", map[string]string{"en": "Taka", "ru": "Така"}},
+	{"BGN", 2, "лв", map[string]string{"en": "Bulgarian Lev", "ru": "Болгарский лев"}},
+	{"BHD", 3, ".د.ب", map[string]string{"en": "Bahraini Dinar", "ru": "Бахрейнский динар"}},
+	{"BIF", 0, "FBu", map[string]string{"en": "Burundi Franc", "ru": "Франк Бурунди"}},
+	{"BMD", 2, "$", map[string]string{"en": "Bermudian Dollar", "ru": "Бермудский доллар"}},
+	{"BND", 2, "$", map[string]string{"en": "Brunei Dollar", "ru": "Брунейский доллар"}},
+	{"BOB", 2, "Bs", map[string]string{"en": "Boliviano", "ru": "Боливиано"}},
+	{"BRL", 2, "R$", map[string]string{"en": "Brazilian Real", "ru": "Бразильский реал"}},
+	{"BSD", 2, "$", map[string]string{"en": "Bahamian Dollar", "ru": "Багамский доллар"}},
+	{"BTN", 2, "Nu.", map[string]string{"en": "Ngultrum", "ru": "Нгултрум"}},
+	{"BWP", 2, "P", map[string]string{"en": "Pula", "ru": "Пула"}},
+	{"BYN", 2, "Br", map[string]string{"en": "Belarusian Ruble", "ru": "Белорусский рубль"}},
+	{"BZD", 2, "$", map[string]string{"en": "Belize Dollar", "ru": "Белизский доллар"}},
+	{"CAD", 2, "C$", map[string]string{"en": "Canadian Dollar", "ru": "Канадский доллар"}},
+	{"CDF", 2, "FC", map[string]string{"en": "Congolese Franc", "ru": "Конголезский франк"}},
+	{"CHF", 2, "CHF", map[string]string{"en": "Swiss Franc", "ru": "Швейцарский франк"}},
+	{"CLP", 0, "$", map[string]string{"en": "Chilean Peso", "ru": "Чилийское песо"}},
+	{"CNY", 2, "¥", map[string]string{"en": "Yuan Renminbi", "ru": "Китайский юань"}},
+	{"COP", 2, "$", map[string]string{"en": "Colombian Peso", "ru": "Колумбийское песо"}},
+	{"CRC", 2, "₡", map[string]string{"en": "Costa Rican Colon", "ru": "Коста-риканский колон"}},
+	{"CUP", 2, "$", map[string]string{"en": "Cuban Peso", "ru": "Кубинское песо"}},
+	{"CVE", 2, "$", map[string]string{"en": "Cabo Verde Escudo", "ru": "Эскудо Кабо-Верде"}},
+	{"CZK", 2, "Kč", map[string]string{"en": "Czech Koruna", "ru": "Чешская крона"}},
+	{"DJF", 0, "Fdj", map[string]string{"en": "Djibouti Franc", "ru": "Франк Джибути"}},
+	{"DKK", 2, "kr", map[string]string{"en": "Danish Krone", "ru": "Датская крона"}},
+	{"DOP", 2, "$", map[string]string{"en": "Dominican Peso", "ru": "Доминиканское песо"}},
+	{"DZD", 2, "د.ج", map[string]string{"en": "Algerian Dinar", "ru": "Алжирский динар"}},
+	{"EGP", 2, "E£", map[string]string{"en": "Egyptian Pound", "ru": "Египетский фунт"}},
+	{"ERN", 2, "Nfk", map[string]string{"en": "Nakfa", "ru": "Накфа"}},
+	{"ETB", 2, "Br", map[string]string{"en": "Ethiopian Birr", "ru": "Эфиопский быр"}},
+	{"EUR", 2, "€", map[string]string{"en": "Euro", "ru": "Евро"}},
+	{"FJD", 2, "$", map[string]string{"en": "Fiji Dollar", "ru": "Доллар Фиджи"}},
+	{"FKP", 2, "£", map[string]string{"en": "Falkland Islands Pound", "ru": "Фунт Фолклендских островов"}},
+	{"GBP", 2, "£", map[string]string{"en": "Pound Sterling", "ru": "Фунт стерлингов"}},
+	{"GEL", 2, "₾", map[string]string{"en": "Lari", "ru": "Лари"}},
+	{"GHS", 2, "GH₵", map[string]string{"en": "Ghana Cedi", "ru": "Ганский седи"}},
+	{"GIP", 2, "£", map[string]string{"en": "Gibraltar Pound", "ru": "Гибралтарский фунт"}},
+	{"GMD", 2, "D", map[string]string{"en": "Dalasi", "ru": "Даласи"}},
+	{"GNF", 0, "FG", map[string]string{"en": "Guinean Franc", "ru": "Гвинейский франк"}},
+	{"GTQ", 2, "Q", map[string]string{"en": "Quetzal", "ru": "Кетсаль"}},
+	{"GYD", 2, "$", map[string]string{"en": "Guyana Dollar", "ru": "Гайанский доллар"}},
+	{"HKD", 2, "HK$", map[string]string{"en": "Hong Kong Dollar", "ru": "Гонконгский доллар"}},
+	{"HNL", 2, "L", map[string]string{"en": "Lempira", "ru": "Лемпира"}},
+	{"HTG", 2, "G", map[string]string{"en": "Gourde", "ru": "Гурд"}},
+	{"HUF", 2, "Ft", map[string]string{"en": "Forint", "ru": "Форинт"}},
+	{"IDR", 2, "Rp", map[string]string{"en": "Rupiah", "ru": "Индонезийская рупия"}},
+	{"ILS", 2, "₪", map[string]string{"en": "New Israeli Sheqel", "ru": "Новый израильский шекель"}},
+	{"INR", 2, "₹", map[string]string{"en": "Indian Rupee", "ru": "Индийская рупия"}},
+	{"IQD", 3, "ع.د", map[string]string{"en": "Iraqi Dinar", "ru": "Иракский динар"}},
+	{"IRR", 2, "﷼", map[string]string{"en": "Iranian Rial", "ru": "Иранский риал"}},
+	{"ISK", 0, "kr", map[string]string{"en": "Iceland Krona", "ru": "Исландская крона"}},
+	{"JMD", 2, "$", map[string]string{"en": "Jamaican Dollar", "ru": "Ямайский доллар"}},
+	{"JOD", 3, "د.ا", map[string]string{"en": "Jordanian Dinar", "ru": "Иорданский динар"}},
+	{"JPY", 0, "¥", map[string]string{"en": "Yen", "ru": "Иена"}},
+	{"KES", 2, "KSh", map[string]string{"en": "Kenyan Shilling", "ru": "Кенийский шиллинг"}},
+	{"KGS", 2, "сом", map[string]string{"en": "Som", "ru": "Сом"}},
+	{"KHR", 2, "៛", map[string]string{"en": "Riel", "ru": "Риель"}},
+	{"KMF", 0, "CF", map[string]string{"en": "Comorian Franc", "ru": "Франк Комор"}},
+	{"KPW", 2, "₩", map[string]string{"en": "North Korean Won", "ru": "Северокорейская вона"}},
+	{"KRW", 0, "₩", map[string]string{"en": "Won", "ru": "Вона"}},
+	{"KWD", 3, "د.ك", map[string]string{"en": "Kuwaiti Dinar", "ru": "Кувейтский динар"}},
+	{"KYD", 2, "$", map[string]string{"en": "Cayman Islands Dollar", "ru": "Доллар Островов Кайман"}},
+	{"KZT", 2, "₸", map[string]string{"en": "Tenge", "ru": "Тенге"}},
+	{"LAK", 2, "₭", map[string]string{"en": "Lao Kip", "ru": "Кип"}},
+	{"LBP", 2, "ل.ل", map[string]string{"en": "Lebanese Pound", "ru": "Ливанский фунт"}},
+	{"LKR", 2, "Rs", map[string]string{"en": "Sri Lanka Rupee", "ru": "Шри-ланкийская рупия"}},
+	{"LRD", 2, "$", map[string]string{"en": "Liberian Dollar", "ru": "Либерийский доллар"}},
+	{"LSL", 2, "L", map[string]string{"en": "Loti", "ru": "Лоти"}},
+	{"LYD", 3, "ل.د", map[string]string{"en": "Libyan Dinar", "ru": "Ливийский динар"}},
+	{"MAD", 2, "د.م.", map[string]string{"en": "Moroccan Dirham", "ru": "Марокканский дирхам"}},
+	{"MDL", 2, "L", map[string]string{"en": "Moldovan Leu", "ru": "Молдавский лей"}},
+	{"MGA", 2, "Ar", map[string]string{"en": "Malagasy Ariary", "ru": "Малагасийский ариари"}},
+	{"MKD", 2, "ден", map[string]string{"en": "Denar", "ru": "Денар"}},
+	{"MMK", 2, "K", map[string]string{"en": "Kyat", "ru": "Кьят"}},
+	{"MNT", 2, "₮", map[string]string{"en": "Tugrik", "ru": "Тугрик"}},
+	{"MOP", 2, "MOP$", map[string]string{"en": "Pataca", "ru": "Патака"}},
+	{"MRU", 2, "UM", map[string]string{"en": "Ouguiya", "ru": "Угия"}},
+	{"MUR", 2, "₨", map[string]string{"en": "Mauritius Rupee", "ru": "Маврикийская рупия"}},
+	{"MVR", 2, "Rf", map[string]string{"en": "Rufiyaa", "ru": "Руфия"}},
+	{"MWK", 2, "MK", map[string]string{"en": "Malawi Kwacha", "ru": "Квача"}},
+	{"MXN", 2, "$", map[string]string{"en": "Mexican Peso", "ru": "Мексиканское песо"}},
+	{"MYR", 2, "RM", map[string]string{"en": "Malaysian Ringgit", "ru": "Малайзийский ринггит"}},
+	{"MZN", 2, "MT", map[string]string{"en": "Mozambique Metical", "ru": "Мозамбикский метикал"}},
+	{"NAD", 2, "$", map[string]string{"en": "Namibia Dollar", "ru": "Доллар Намибии"}},
+	{"NGN", 2, "₦", map[string]string{"en": "Naira", "ru": "Найра"}},
+	{"NIO", 2, "C$", map[string]string{"en": "Cordoba Oro", "ru": "Золотая кордоба"}},
+	{"NOK", 2, "kr", map[string]string{"en": "Norwegian Krone", "ru": "Норвежская крона"}},
+	{"NPR", 2, "₨", map[string]string{"en": "Nepalese Rupee", "ru": "Непальская рупия"}},
+	{"NZD", 2, "NZ$", map[string]string{"en": "New Zealand Dollar", "ru": "Новозеландский доллар"}},
+	{"OMR", 3, "ر.ع.", map[string]string{"en": "Rial Omani", "ru": "Оманский риал"}},
+	{"PAB", 2, "B/.", map[string]string{"en": "Balboa", "ru": "Бальбоа"}},
+	{"PEN", 2, "S/", map[string]string{"en": "Sol", "ru": "Соль"}},
+	{"PGK", 2, "K", map[string]string{"en": "Kina", "ru": "Кина"}},
+	{"PHP", 2, "₱", map[string]string{"en": "Philippine Peso", "ru": "Филиппинское песо"}},
+	{"PKR", 2, "₨", map[string]string{"en": "Pakistan Rupee", "ru": "Пакистанская рупия"}},
+	{"PLN", 2, "zł", map[string]string{"en": "Zloty", "ru": "Злотый"}},
+	{"PYG", 0, "₲", map[string]string{"en": "Guarani", "ru": "Гуарани"}},
+	{"QAR", 2, "ر.ق", map[string]string{"en": "Qatari Rial", "ru": "Катарский риал"}},
+	{"RON", 2, "lei", map[string]string{"en": "Romanian Leu", "ru": "Румынский лей"}},
+	{"RSD", 2, "дин.", map[string]string{"en": "Serbian Dinar", "ru": "Сербский динар"}},
+	{"RUB", 2, "₽", map[string]string{"en": "Russian Ruble", "ru": "Российский рубль"}},
+	{"RWF", 0, "FRw", map[string]string{"en": "Rwanda Franc", "ru": "Франк Руанды"}},
+	{"SAR", 2, "ر.س", map[string]string{"en": "Saudi Riyal", "ru": "Саудовский риял"}},
+	{"SBD", 2, "$", map[string]string{"en": "Solomon Islands Dollar", "ru": "Доллар Соломоновых Островов"}},
+	{"SCR", 2, "₨", map[string]string{"en": "Seychelles Rupee", "ru": "Сейшельская рупия"}},
+	{"SDG", 2, "ج.س.", map[string]string{"en": "Sudanese Pound", "ru": "Суданский фунт"}},
+	{"SEK", 2, "kr", map[string]string{"en": "Swedish Krona", "ru": "Шведская крона"}},
+	{"SGD", 2, "S$", map[string]string{"en": "Singapore Dollar", "ru": "Сингапурский доллар"}},
+	{"SHP", 2, "£", map[string]string{"en": "Saint Helena Pound", "ru": "Фунт Святой Елены"}},
+	{"SLE", 2, "Le", map[string]string{"en": "Leone", "ru": "Леоне"}},
+	{"SOS", 2, "Sh", map[string]string{"en": "Somali Shilling", "ru": "Сомалийский шиллинг"}},
+	{"SRD", 2, "$", map[string]string{"en": "Surinam Dollar", "ru": "Суринамский доллар"}},
+	{"SSP", 2, "£", map[string]string{"en": "South Sudanese Pound", "ru": "Южносуданский фунт"}},
+	{"STN", 2, "Db", map[string]string{"en": "Dobra", "ru": "Добра"}},
+	{"SVC", 2, "₡", map[string]string{"en": "El Salvador Colon", "ru": "Сальвадорский колон"}},
+	{"SYP", 2, "£S", map[string]string{"en": "Syrian Pound", "ru": "Сирийский фунт"}},
+	{"SZL", 2, "L", map[string]string{"en": "Lilangeni", "ru": "Лилангени"}},
+	{"THB", 2, "฿", map[string]string{"en": "Baht", "ru": "Бат"}},
+	{"TJS", 2, "SM", map[string]string{"en": "Somoni", "ru": "Сомони"}},
+	{"TMT", 2, "m", map[string]string{"en": "Turkmenistan New Manat", "ru": "Новый туркменский манат"}},
+	{"TND", 3, "د.ت", map[string]string{"en": "Tunisian Dinar", "ru": "Тунисский динар"}},
+	{"TOP", 2, "T$", map[string]string{"en": "Pa’anga", "ru": "Паанга"}},
+	{"TRY", 2, "₺", map[string]string{"en": "Turkish Lira", "ru": "Турецкая лира"}},
+	{"TTD", 2, "$", map[string]string{"en": "Trinidad and Tobago Dollar", "ru": "Доллар Тринидада и Тобаго"}},
+	{"TWD", 2, "NT$", map[string]string{"en": "New Taiwan Dollar", "ru": "Новый тайваньский доллар"}},
+	{"TZS", 2, "TSh", map[string]string{"en": "Tanzanian Shilling", "ru": "Танзанийский шиллинг"}},
+	{"UAH", 2, "₴", map[string]string{"en": "Hryvnia", "ru": "Гривна"}},
+	{"UGX", 0, "USh", map[string]string{"en": "Uganda Shilling", "ru": "Угандийский шиллинг"}},
+	{"USD", 2, "$", map[string]string{"en": "US Dollar", "ru": "Доллар США"}},
+	{"UYU", 2, "$U", map[string]string{"en": "Peso Uruguayo", "ru": "Уругвайское песо"}},
+	{"UZS", 2, "сўм", map[string]string{"en": "Uzbekistan Sum", "ru": "Узбекский сум"}},
+	{"VES", 2, "Bs.", map[string]string{"en": "Bolívar Soberano", "ru": "Суверенный боливар"}},
+	{"VND", 0, "₫", map[string]string{"en": "Dong", "ru": "Донг"}},
+	{"VUV", 0, "VT", map[string]string{"en": "Vatu", "ru": "Вату"}},
+	{"WST", 2, "WS$", map[string]string{"en": "Tala", "ru": "Тала"}},
+	{"XAF", 0, "FCFA", map[string]string{"en": "CFA Franc BEAC", "ru": "Франк КФА BEAC"}},
+	{"XCD", 2, "EC$", map[string]string{"en": "East Caribbean Dollar", "ru": "Восточно-карибский доллар"}},
+	{"XOF", 0, "CFA", map[string]string{"en": "CFA Franc BCEAO", "ru": "Франк КФА BCEAO"}},
+	{"XPF", 0, "₣", map[string]string{"en": "CFP Franc", "ru": "Франк КФП"}},
+	{"YER", 2, "﷼", map[string]string{"en": "Yemeni Rial", "ru": "Йеменский риал"}},
+	{"ZAR", 2, "R", map[string]string{"en": "Rand", "ru": "Рэнд"}},
+	{"ZMW", 2, "ZK", map[string]string{"en": "Zambian Kwacha", "ru": "Замбийская квача"}},
+	{"ZWG", 2, "ZiG", map[string]string{"en": "Zimbabwe Gold", "ru": "Зимбабвийский золотой"}},
+}
+
+var currencyIndex = func() map[string]Currency {
+	index := make(map[string]Currency, len(currencies))
+	for _, currency := range currencies {
+		index[currency.Code] = currency
+	}
+	return index
+}()
+
+// Currencies returns the registry ordered by code.
+func Currencies() []Currency {
+	list := make([]Currency, len(currencies))
+	copy(list, currencies)
+	sort.Slice(list, func(i, j int) bool { return list[i].Code < list[j].Code })
+	return list
+}
+
+// LookupCurrency returns the registry entry of code, case-insensitively.
+func LookupCurrency(code string) (Currency, bool) {
+	currency, ok := currencyIndex[strings.ToUpper(strings.TrimSpace(code))]
+	return currency, ok
+}
+
+// CurrencyExponent returns the minor-unit digits of code, or 2 for codes
+// outside the registry such as those only known from manual exchange rates.
+func CurrencyExponent(code string) int {
+	if currency, ok := LookupCurrency(code); ok {
+		return currency.Exponent
+	}
+	return 2
+}
+
+// Holds reports whether the account keeps amounts in currency: its own
+// currency or, for multi-currency accounts, any currency of the registry.
+func (a *Account) Holds(currency string) bool {
+	if strings.EqualFold(a.Currency, currency) {
+		return true
+	}
+	_, ok := LookupCurrency(currency)
+	return a.MultiCurrency && ok
+}
@@ -1,12 +1,17 @@
 package domain
 
-import "time"
+import (
+	"encoding/json"
+	"time"
+)
 
 type Family struct {
-	ID           string    `json:"id"`
-	Name         string    `json:"name"`
-	CurrencyBase string    `json:"currency_base"`
-	CreatedAt    time.Time `json:"created_at"`
+	ID               string    `json:"id"`
+	Name             string    `json:"name"`
+	CurrencyBase     string    `json:"currency_base"`
+	Timezone         string    `json:"timezone"`
+	ApprovalRequired bool      `json:"approval_required"`
+	CreatedAt        time.Time `json:"created_at"`
 }
 
 type FamilyMember struct {
@@ -45,17 +50,46 @@ func DefaultDisplaySettings() DisplaySettings {
 	}
 }
 
+// Account holds money in Currency. A MultiCurrency account also holds other
+// currencies in pockets: Currency and BalanceMinor are then its primary
+// pocket, and Pockets lists every pocket, the primary one first.
 type Account struct {
-	ID           string    `json:"id"`
-	FamilyID     string    `json:"family_id"`
-	Name         string    `json:"name"`
-	Type         string    `json:"type"`
-	Currency     string    `json:"currency"`
-	BalanceMinor int64     `json:"balance_minor"`
-	IsShared     bool      `json:"is_shared"`
-	IsArchived   bool      `json:"is_archived"`
-	CreatedAt    time.Time `json:"created_at"`
-	UpdatedAt    time.Time `json:"updated_at"`
+	ID            string          `json:"id"`
+	FamilyID      string          `json:"family_id"`
+	Name          string          `json:"name"`
+	Type          string          `json:"type"`
+	Currency      string          `json:"currency"`
+	BalanceMinor  int64           `json:"balance_minor"`
+	MultiCurrency bool            `json:"multi_currency"`
+	Pockets       []AccountPocket `json:"pockets,omitempty"`
+	IsShared      bool            `json:"is_shared"`
+	IsArchived    bool            `json:"is_archived"`
+	CreatedAt     time.Time       `json:"created_at"`
+	UpdatedAt     time.Time       `json:"updated_at"`
+}
+
+// AccountPocket is the balance of one currency of a multi-currency account.
+type AccountPocket struct {
+	Currency     string `json:"currency"`
+	BalanceMinor int64  `json:"balance_minor"`
+}
+
+// AccountExchange moves money between two pockets of a multi-currency
+// account: FromAmountMinor of FromCurrency became ToAmountMinor of
+// ToCurrency at Rate, the price of one unit of FromCurrency.
+type AccountExchange struct {
+	ID              string    `json:"id"`
+	FamilyID        string    `json:"family_id"`
+	AccountID       string    `json:"account_id"`
+	UserID          string    `json:"user_id"`
+	FromCurrency    string    `json:"from_currency"`
+	FromAmountMinor int64     `json:"from_amount_minor"`
+	ToCurrency      string    `json:"to_currency"`
+	ToAmountMinor   int64     `json:"to_amount_minor"`
+	Rate            float64   `json:"rate"`
+	Comment         string    `json:"comment,omitempty"`
+	OccurredAt      time.Time `json:"occurred_at"`
+	CreatedAt       time.Time `json:"created_at"`
 }
 
 type Category struct {
@@ -83,6 +117,7 @@ type Transaction struct {
 	UserID      string    `json:"user_id"`
 	AccountID   string    `json:"account_id"`
 	CategoryID  string    `json:"category_id"`
+	EnvelopeID  *string   `json:"envelope_id,omitempty"`
 	Type        string    `json:"type"`
 	AmountMinor int64     `json:"amount_minor"`
 	Currency    string    `json:"currency"`
@@ -90,6 +125,18 @@ type Transaction struct {
 	OccurredAt  time.Time `json:"occurred_at"`
 	CreatedAt   time.Time `json:"created_at"`
 	UpdatedAt   time.Time `json:"updated_at"`
+	// PlannedOperationID links a transaction posted for a planned operation.
+	PlannedOperationID *string `json:"planned_operation_id,omitempty"`
+	// AmountMinor and Currency are always in the account currency. A
+	// transaction paid in another currency keeps the paid amount and the
+	// rate charged for it.
+	OriginalAmountMinor *int64   `json:"original_amount_minor,omitempty"`
+	OriginalCurrency    string   `json:"original_currency,omitempty"`
+	ExchangeRate        *float64 `json:"exchange_rate,omitempty"`
+	// AmountBaseMinor is the amount in the family currency, fixed when the
+	// transaction is stored and used by reports. It stays empty until a rate
+	// is known.
+	AmountBaseMinor *int64 `json:"amount_base_minor,omitempty"`
 }
 
 type PlannedOperation struct {
@@ -104,7 +151,10 @@ type PlannedOperation struct {
 	Currency        string     `json:"currency"`
 	Comment         string     `json:"comment,omitempty"`
 	DueAt           time.Time  `json:"due_at"`
+	StartsAt        time.Time  `json:"starts_at"`
+	SnoozedFrom     *time.Time `json:"snoozed_from,omitempty"`
 	Recurrence      string     `json:"recurrence,omitempty"`
+	AutoPost        bool       `json:"auto_post"`
 	IsCompleted     bool       `json:"is_completed"`
 	LastCompletedAt *time.Time `json:"last_completed_at,omitempty"`
 	CreatedAt       time.Time  `json:"created_at"`
@@ -116,6 +166,22 @@ type PlannedOperationWithCreator struct {
 	Creator FamilyMember `json:"creator"`
 }
 
+// PlannedOccurrence records how one due date of a planned operation was
+// resolved: paid with a transaction, skipped, or missed when a later payment
+// moved the series past it.
+type PlannedOccurrence struct {
+	ID                 string    `json:"id"`
+	FamilyID           string    `json:"family_id"`
+	PlannedOperationID string    `json:"planned_operation_id"`
+	DueAt              time.Time `json:"due_at"`
+	Status             string    `json:"status"`
+	TransactionID      *string   `json:"transaction_id,omitempty"`
+	AmountMinor        int64     `json:"amount_minor"`
+	Currency           string    `json:"currency"`
+	UserID             *string   `json:"user_id,omitempty"`
+	RecordedAt         time.Time `json:"recorded_at"`
+}
+
 type ReportPeriod struct {
 	Start *time.Time `json:"start_date,omitempty"`
 	End   *time.Time `json:"end_date,omitempty"`
@@ -124,6 +190,71 @@ type ReportPeriod struct {
 type CurrencyAmount struct {
 	Currency    string `json:"currency"`
 	AmountMinor int64  `json:"amount_minor"`
+	BaseAmount
+}
+
+// BaseAmount is a report amount converted into the family currency at the
+// rate of each transaction's date. Unconverted is set when rates were missing
+// for some transactions; BaseAmountMinor then covers only the others.
+type BaseAmount struct {
+	BaseAmountMinor *int64 `json:"base_amount_minor,omitempty"`
+	Unconverted     bool   `json:"unconverted,omitempty"`
+}
+
+// ExchangeRate says that one unit of Base costs Rate units of Quote on AsOf
+// (YYYY-MM-DD).
+type ExchangeRate struct {
+	ID        string    `json:"id"`
+	Base      string    `json:"base"`
+	Quote     string    `json:"quote"`
+	Rate      float64   `json:"rate"`
+	AsOf      string    `json:"as_of"`
+	Source    string    `json:"source"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// RateUsage records a rate a report used to convert Base into Quote. The
+// rate is Inverted when it was stored the other way round and goes Via a
+// third currency when it is a cross rate of two stored rates; AsOf is then
+// the older of their dates.
+type RateUsage struct {
+	Base         string  `json:"base"`
+	Quote        string  `json:"quote"`
+	Rate         float64 `json:"rate"`
+	AsOf         string  `json:"as_of"`
+	Source       string  `json:"source"`
+	Inverted     bool    `json:"inverted,omitempty"`
+	Via          string  `json:"via,omitempty"`
+	Transactions int     `json:"transactions"`
+}
+
+// StaleRateWarning flags a currency the family uses whose rate into the
+// family currency is missing or older than the allowed age.
+type StaleRateWarning struct {
+	Currency   string  `json:"currency"`
+	Quote      string  `json:"quote"`
+	LatestAsOf *string `json:"latest_as_of,omitempty"`
+	AgeDays    int     `json:"age_days,omitempty"`
+	Message    string  `json:"message"`
+}
+
+// MissingRate lists transactions and account balances in Currency between
+// From and To that could not be converted.
+type MissingRate struct {
+	Currency     string `json:"currency"`
+	From         string `json:"from"`
+	To           string `json:"to"`
+	Transactions int    `json:"transactions"`
+	AmountMinor  int64  `json:"amount_minor"`
+}
+
+// ReportConversion describes how report amounts were converted into the
+// family currency.
+type ReportConversion struct {
+	Currency string        `json:"currency"`
+	Complete bool          `json:"complete"`
+	Rates    []RateUsage   `json:"rates"`
+	Missing  []MissingRate `json:"missing,omitempty"`
 }
 
 type CategoryReportItem struct {
@@ -132,21 +263,103 @@ type CategoryReportItem struct {
 	CategoryColor string `json:"category_color"`
 	Currency      string `json:"currency"`
 	AmountMinor   int64  `json:"amount_minor"`
+	BaseAmount
+}
+
+type MemberReportItem struct {
+	UserID      string `json:"user_id"`
+	UserName    string `json:"user_name"`
+	Currency    string `json:"currency"`
+	AmountMinor int64  `json:"amount_minor"`
+	BaseAmount
+}
+
+type AccountReportItem struct {
+	AccountID   string `json:"account_id"`
+	AccountName string `json:"account_name"`
+	Currency    string `json:"currency"`
+	AmountMinor int64  `json:"amount_minor"`
+	BaseAmount
+}
+
+type CrossTabHeader struct {
+	Key  string `json:"key"`
+	Name string `json:"name"`
+}
+
+type CrossTabCell struct {
+	Row         string `json:"row"`
+	Column      string `json:"column"`
+	Currency    string `json:"currency"`
+	AmountMinor int64  `json:"amount_minor"`
+	BaseAmount
+}
+
+// CrossTabReport sums amounts by two dimensions, for example member by
+// category. Only non-empty cells are listed.
+type CrossTabReport struct {
+	Rows    string           `json:"rows"`
+	Columns string           `json:"columns"`
+	RowKeys []CrossTabHeader `json:"row_keys"`
+	ColKeys []CrossTabHeader `json:"column_keys"`
+	Cells   []CrossTabCell   `json:"cells"`
 }
 
 type MovementReport struct {
-	Totals     []CurrencyAmount     `json:"totals"`
-	ByCategory []CategoryReportItem `json:"by_category"`
+	Totals       []CurrencyAmount     `json:"totals"`
+	ByCategory   []CategoryReportItem `json:"by_category"`
+	ByMember     []MemberReportItem   `json:"by_member"`
+	ByAccount    []AccountReportItem  `json:"by_account"`
+	CategoryTree []CategoryTreeNode   `json:"category_tree"`
+	CrossTab     *CrossTabReport      `json:"cross_tab,omitempty"`
+	// BaseTotal sums all currencies in the family currency.
+	BaseTotal *CurrencyAmount `json:"base_total,omitempty"`
 }
 
+// CategoryNodeAmount is the activity of a category in one currency: OwnMinor
+// is booked on the category itself, TotalMinor includes its subcategories.
+// The base amount converts TotalMinor.
+type CategoryNodeAmount struct {
+	Currency   string `json:"currency"`
+	OwnMinor   int64  `json:"own_minor"`
+	TotalMinor int64  `json:"total_minor"`
+	BaseAmount
+}
+
+// CategoryTreeNode is a category with activity in its subtree. Collapsed
+// nodes have subcategories that were cut off by the requested depth; their
+// totals still include them.
+type CategoryTreeNode struct {
+	CategoryID    string               `json:"category_id"`
+	CategoryName  string               `json:"category_name"`
+	CategoryColor string               `json:"category_color"`
+	ParentID      *string              `json:"parent_id,omitempty"`
+	IsArchived    bool                 `json:"is_archived"`
+	Depth         int                  `json:"depth"`
+	Amounts       []CategoryNodeAmount `json:"amounts"`
+	Collapsed     bool                 `json:"collapsed,omitempty"`
+	Children      []CategoryTreeNode   `json:"children,omitempty"`
+}
+
+// AccountBalanceReport is the balance of an account. Multi-currency
+// accounts list every pocket; the account's BaseAmount is then the sum of
+// its converted pockets.
 type AccountBalanceReport struct {
-	AccountID    string `json:"account_id"`
-	AccountName  string `json:"account_name"`
-	AccountType  string `json:"account_type"`
+	AccountID    string                `json:"account_id"`
+	AccountName  string                `json:"account_name"`
+	AccountType  string                `json:"account_type"`
+	Currency     string                `json:"currency"`
+	BalanceMinor int64                 `json:"balance_minor"`
+	Pockets      []AccountPocketReport `json:"pockets,omitempty"`
+	IsShared     bool                  `json:"is_shared"`
+	IsArchived   bool                  `json:"is_archived"`
+	BaseAmount
+}
+
+type AccountPocketReport struct {
 	Currency     string `json:"currency"`
 	BalanceMinor int64  `json:"balance_minor"`
-	IsShared     bool   `json:"is_shared"`
-	IsArchived   bool   `json:"is_archived"`
+	BaseAmount
 }
 
 type ReportsOverview struct {
@@ -154,4 +367,494 @@ type ReportsOverview struct {
 	Expenses        MovementReport         `json:"expenses"`
 	Incomes         MovementReport         `json:"incomes"`
 	AccountBalances []AccountBalanceReport `json:"account_balances"`
+	Comparisons     []ReportComparison     `json:"comparisons,omitempty"`
+	Conversion      *ReportConversion      `json:"conversion,omitempty"`
+}
+
+// AmountDelta compares a currency total with the same total of another
+// period. DeltaPercent is nil when the other period had nothing to compare
+// with.
+type AmountDelta struct {
+	Currency      string   `json:"currency"`
+	CurrentMinor  int64    `json:"current_minor"`
+	PreviousMinor int64    `json:"previous_minor"`
+	DeltaMinor    int64    `json:"delta_minor"`
+	DeltaPercent  *float64 `json:"delta_percent"`
+}
+
+// CategoryDelta compares a category in one currency. Presence is both,
+// current_only or previous_only.
+type CategoryDelta struct {
+	CategoryID   string `json:"category_id"`
+	CategoryName string `json:"category_name"`
+	Presence     string `json:"presence"`
+	AmountDelta
+}
+
+type MovementDelta struct {
+	Totals []AmountDelta `json:"totals"`
+	// BaseTotal compares the totals in the family currency when both
+	// periods were converted.
+	BaseTotal  *AmountDelta    `json:"base_total,omitempty"`
+	ByCategory []CategoryDelta `json:"by_category"`
+	// Categories with activity in only one of the periods.
+	OnlyInCurrent  []CategoryReportItem `json:"only_in_current"`
+	OnlyInPrevious []CategoryReportItem `json:"only_in_previous"`
+}
+
+// ReportComparison is the overview of an earlier period with the deltas of
+// the current period against it.
+type ReportComparison struct {
+	Mode          string         `json:"mode"`
+	Period        ReportPeriod   `json:"period"`
+	Expenses      MovementReport `json:"expenses"`
+	Incomes       MovementReport `json:"incomes"`
+	ExpenseDeltas MovementDelta  `json:"expense_deltas"`
+	IncomeDeltas  MovementDelta  `json:"income_deltas"`
+}
+
+// SeriesAmount is the income, expense and net of one currency in a report
+// bucket.
+type SeriesAmount struct {
+	Currency     string `json:"currency"`
+	IncomeMinor  int64  `json:"income_minor"`
+	ExpenseMinor int64  `json:"expense_minor"`
+	NetMinor     int64  `json:"net_minor"`
+	Unconverted  bool   `json:"unconverted,omitempty"`
+}
+
+// SeriesGroup is the part of a bucket that belongs to one category, account
+// or member when a time series is split.
+type SeriesGroup struct {
+	Key    string         `json:"key"`
+	Name   string         `json:"name"`
+	Totals []SeriesAmount `json:"totals"`
+	Base   *SeriesAmount  `json:"base,omitempty"`
+}
+
+// SeriesBucket covers [Start, End) in the family time zone.
+type SeriesBucket struct {
+	Label  string         `json:"label"`
+	Start  time.Time      `json:"start"`
+	End    time.Time      `json:"end"`
+	Totals []SeriesAmount `json:"totals"`
+	Base   *SeriesAmount  `json:"base,omitempty"`
+	Groups []SeriesGroup  `json:"groups,omitempty"`
+}
+
+// TimeSeriesReport lists every bucket of the period, including empty ones,
+// and every currency and group in each bucket so that charts do not skip
+// points.
+type TimeSeriesReport struct {
+	Interval   string            `json:"interval"`
+	SplitBy    string            `json:"split_by,omitempty"`
+	Timezone   string            `json:"timezone"`
+	WeekStart  string            `json:"week_start"`
+	Period     ReportPeriod      `json:"period"`
+	Buckets    []SeriesBucket    `json:"buckets"`
+	Conversion *ReportConversion `json:"conversion,omitempty"`
+}
+
+type EnvelopeAutoFillRule struct {
+	Mode         string `json:"mode"`
+	AmountMinor  int64  `json:"amount_minor,omitempty"`
+	Percent      int    `json:"percent,omitempty"`
+	Priority     int    `json:"priority"`
+	StopAtTarget bool   `json:"stop_at_target"`
+}
+
+type Envelope struct {
+	ID                 string                `json:"id"`
+	FamilyID           string                `json:"family_id"`
+	Name               string                `json:"name"`
+	Currency           string                `json:"currency"`
+	TargetAmountMinor  int64                 `json:"target_amount_minor"`
+	CurrentAmountMinor int64                 `json:"current_amount_minor"`
+	AutoFillRule       *EnvelopeAutoFillRule `json:"auto_fill_rule,omitempty"`
+	IsArchived         bool                  `json:"is_archived"`
+	CreatedAt          time.Time             `json:"created_at"`
+	UpdatedAt          time.Time             `json:"updated_at"`
+}
+
+type EnvelopeMovement struct {
+	ID             string    `json:"id"`
+	FamilyID       string    `json:"family_id"`
+	UserID         string    `json:"user_id"`
+	Kind           string    `json:"kind"`
+	FromEnvelopeID *string   `json:"from_envelope_id,omitempty"`
+	ToEnvelopeID   *string   `json:"to_envelope_id,omitempty"`
+	TransactionID  *string   `json:"transaction_id,omitempty"`
+	AmountMinor    int64     `json:"amount_minor"`
+	Currency       string    `json:"currency"`
+	CreatedAt      time.Time `json:"created_at"`
+}
+
+type EnvelopeOverview struct {
+	Envelopes  []Envelope       `json:"envelopes"`
+	Unassigned []CurrencyAmount `json:"unassigned"`
+}
+
+const (
+	EventGoalCompleted = "goal.completed"
+	EventDebtDueSoon   = "debt.due_soon"
+
+	EventAllowancePosted        = "allowance.posted"
+	EventApprovalRequested      = "approval.requested"
+	EventPlannedOperationPosted = "planned_operation.posted"
+	EventAccountExchanged       = "account.exchanged"
+	EventImportCompleted        = "import.completed"
+)
+
+type Event struct {
+	ID        string          `json:"id"`
+	FamilyID  string          `json:"family_id"`
+	Event     string          `json:"event"`
+	Version   string          `json:"version"`
+	Payload   json.RawMessage `json:"payload"`
+	Timestamp time.Time       `json:"timestamp"`
+}
+
+type Goal struct {
+	ID                string       `json:"id"`
+	FamilyID          string       `json:"family_id"`
+	Name              string       `json:"name"`
+	Currency          string       `json:"currency"`
+	TargetAmountMinor int64        `json:"target_amount_minor"`
+	DueDate           *time.Time   `json:"due_date,omitempty"`
+	Priority          int          `json:"priority"`
+	FundingMode       string       `json:"funding_mode"`
+	AccountIDs        []string     `json:"account_ids"`
+	CompletedAt       *time.Time   `json:"completed_at,omitempty"`
+	IsArchived        bool         `json:"is_archived"`
+	Progress          GoalProgress `json:"progress"`
+	CreatedAt         time.Time    `json:"created_at"`
+	UpdatedAt         time.Time    `json:"updated_at"`
+}
+
+type GoalProgress struct {
+	SavedMinor           int64      `json:"saved_minor"`
+	RemainingMinor       int64      `json:"remaining_minor"`
+	Percent              int        `json:"percent"`
+	RequiredMonthlyMinor *int64     `json:"required_monthly_minor,omitempty"`
+	MonthlyPaceMinor     int64      `json:"monthly_pace_minor"`
+	ProjectedCompletion  *time.Time `json:"projected_completion,omitempty"`
+}
+
+type GoalContribution struct {
+	ID            string    `json:"id"`
+	GoalID        string    `json:"goal_id"`
+	FamilyID      string    `json:"family_id"`
+	UserID        string    `json:"user_id"`
+	AmountMinor   int64     `json:"amount_minor"`
+	Comment       string    `json:"comment,omitempty"`
+	ContributedAt time.Time `json:"contributed_at"`
+	CreatedAt     time.Time `json:"created_at"`
+}
+
+type Debt struct {
+	ID               string     `json:"id"`
+	FamilyID         string     `json:"family_id"`
+	Counterparty     string     `json:"counterparty"`
+	Direction        string     `json:"direction"`
+	AmountMinor      int64      `json:"amount_minor"`
+	Currency         string     `json:"currency"`
+	DueDate          *time.Time `json:"due_date,omitempty"`
+	Notes            string     `json:"notes,omitempty"`
+	RepaidMinor      int64      `json:"repaid_minor"`
+	OutstandingMinor int64      `json:"outstanding_minor"`
+	IsOverdue        bool       `json:"is_overdue"`
+	ClosedAt         *time.Time `json:"closed_at,omitempty"`
+	CreatedAt        time.Time  `json:"created_at"`
+	UpdatedAt        time.Time  `json:"updated_at"`
+}
+
+type DebtRepayment struct {
+	ID            string    `json:"id"`
+	DebtID        string    `json:"debt_id"`
+	FamilyID      string    `json:"family_id"`
+	UserID        string    `json:"user_id"`
+	TransactionID string    `json:"transaction_id"`
+	AmountMinor   int64     `json:"amount_minor"`
+	CreatedAt     time.Time `json:"created_at"`
+}
+
+type CounterpartyBalance struct {
+	Counterparty string `json:"counterparty"`
+	Currency     string `json:"currency"`
+	TheyOweMinor int64  `json:"they_owe_minor"`
+	WeOweMinor   int64  `json:"we_owe_minor"`
+	NetMinor     int64  `json:"net_minor"`
+	OverdueCount int    `json:"overdue_count"`
+}
+
+type SplitShare struct {
+	UserID      string `json:"user_id"`
+	AmountMinor int64  `json:"amount_minor"`
+}
+
+type ExpenseSplit struct {
+	ID            string       `json:"id"`
+	FamilyID      string       `json:"family_id"`
+	TransactionID string       `json:"transaction_id"`
+	PayerID       string       `json:"payer_id"`
+	Method        string       `json:"method"`
+	Currency      string       `json:"currency"`
+	TotalMinor    int64        `json:"total_minor"`
+	Shares        []SplitShare `json:"shares"`
+	CreatedBy     string       `json:"created_by"`
+	CreatedAt     time.Time    `json:"created_at"`
+}
+
+type Settlement struct {
+	ID          string    `json:"id"`
+	FamilyID    string    `json:"family_id"`
+	FromUserID  string    `json:"from_user_id"`
+	ToUserID    string    `json:"to_user_id"`
+	Currency    string    `json:"currency"`
+	AmountMinor int64     `json:"amount_minor"`
+	CreatedBy   string    `json:"created_by"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+type SettlementTransfer struct {
+	FromUserID  string `json:"from_user_id"`
+	ToUserID    string `json:"to_user_id"`
+	Currency    string `json:"currency"`
+	AmountMinor int64  `json:"amount_minor"`
+}
+
+type MemberBalance struct {
+	UserID   string `json:"user_id"`
+	Currency string `json:"currency"`
+	NetMinor int64  `json:"net_minor"`
+}
+
+type SplitBalances struct {
+	Members   []MemberBalance      `json:"members"`
+	Pairs     []SettlementTransfer `json:"pairs"`
+	Suggested []SettlementTransfer `json:"suggested"`
+}
+
+// Allowance is a recurring income posted to a junior member's account.
+type Allowance struct {
+	ID          string `json:"id"`
+	FamilyID    string `json:"family_id"`
+	UserID      string `json:"user_id"`
+	AccountID   string `json:"account_id"`
+	CategoryID  string `json:"category_id"`
+	AmountMinor int64  `json:"amount_minor"`
+	Currency    string `json:"currency"`
+	Recurrence  string `json:"recurrence"`
+	// StartsAt is the first occurrence of the series; NextRunAt follows it
+	// in the family time zone until COUNT or UNTIL ends the series.
+	StartsAt     time.Time  `json:"starts_at"`
+	NextRunAt    time.Time  `json:"next_run_at"`
+	IsActive     bool       `json:"is_active"`
+	LastPostedAt *time.Time `json:"last_posted_at,omitempty"`
+	CreatedBy    string     `json:"created_by"`
+	CreatedAt    time.Time  `json:"created_at"`
+	UpdatedAt    time.Time  `json:"updated_at"`
+}
+
+// SpendingCap limits how much a junior member may spend in a category per
+// period before expenses require a parent's approval.
+type SpendingCap struct {
+	FamilyID    string    `json:"family_id"`
+	UserID      string    `json:"user_id"`
+	CategoryID  string    `json:"category_id"`
+	Period      string    `json:"period"`
+	AmountMinor int64     `json:"amount_minor"`
+	Currency    string    `json:"currency"`
+	SpentMinor  int64     `json:"spent_minor"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
+
+// TransactionApproval holds a transaction that waits for a parent's decision.
+// The transaction keeps its ID once approved.
+type TransactionApproval struct {
+	ID          string      `json:"id"`
+	FamilyID    string      `json:"family_id"`
+	Status      string      `json:"status"`
+	Reason      string      `json:"reason"`
+	Transaction Transaction `json:"transaction"`
+	ReviewerID  *string     `json:"reviewer_id,omitempty"`
+	ReviewNote  string      `json:"review_note,omitempty"`
+	CreatedAt   time.Time   `json:"created_at"`
+	ReviewedAt  *time.Time  `json:"reviewed_at,omitempty"`
+}
+
+// ForecastDay is the projected end-of-day balance of an account or of all
+// accounts in one currency.
+type ForecastDay struct {
+	Date         string `json:"date"`
+	InflowMinor  int64  `json:"inflow_minor"`
+	OutflowMinor int64  `json:"outflow_minor"`
+	BalanceMinor int64  `json:"balance_minor"`
+	Negative     bool   `json:"negative"`
+}
+
+type AccountForecast struct {
+	AccountID           string        `json:"account_id"`
+	Name                string        `json:"name"`
+	Currency            string        `json:"currency"`
+	OpeningBalanceMinor int64         `json:"opening_balance_minor"`
+	MinBalanceMinor     int64         `json:"min_balance_minor"`
+	FirstNegativeDate   *string       `json:"first_negative_date,omitempty"`
+	Days                []ForecastDay `json:"days"`
+}
+
+type CurrencyForecast struct {
+	Currency            string        `json:"currency"`
+	OpeningBalanceMinor int64         `json:"opening_balance_minor"`
+	MinBalanceMinor     int64         `json:"min_balance_minor"`
+	FirstNegativeDate   *string       `json:"first_negative_date,omitempty"`
+	Days                []ForecastDay `json:"days"`
+}
+
+// ForecastBaseline is the average daily spending of a category from an
+// account that the forecast expects to continue.
+type ForecastBaseline struct {
+	AccountID         string `json:"account_id"`
+	CategoryID        string `json:"category_id"`
+	Currency          string `json:"currency"`
+	SpentMinor        int64  `json:"spent_minor"`
+	DailyAverageMinor int64  `json:"daily_average_minor"`
+}
+
+type Forecast struct {
+	From         string             `json:"from"`
+	Until        string             `json:"until"`
+	Timezone     string             `json:"timezone"`
+	BaselineDays int                `json:"baseline_days,omitempty"`
+	Baseline     []ForecastBaseline `json:"baseline,omitempty"`
+	Accounts     []AccountForecast  `json:"accounts"`
+	Totals       []CurrencyForecast `json:"totals"`
+}
+
+// CalendarFeed is the access token of a member's iCalendar feed. Only a hash
+// of the token is stored; the token itself is shown once when issued.
+type CalendarFeed struct {
+	UserID    string    `json:"user_id"`
+	FamilyID  string    `json:"family_id"`
+	Token     string    `json:"token,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// ReminderRule asks for a reminder OffsetDays before the due date of planned
+// operations; zero is the due date itself and negative values remind about
+// overdue operations. Rules without PlannedOperationID apply to every
+// operation of the family that has no rules of its own.
+type ReminderRule struct {
+	ID                 string    `json:"id"`
+	FamilyID           string    `json:"family_id"`
+	PlannedOperationID *string   `json:"planned_operation_id,omitempty"`
+	OffsetDays         int       `json:"offset_days"`
+	Channels           []string  `json:"channels"`
+	CreatedBy          string    `json:"created_by"`
+	CreatedAt          time.Time `json:"created_at"`
+}
+
+// InboxMessage is an in-app notification of a member.
+type InboxMessage struct {
+	ID                 string     `json:"id"`
+	FamilyID           string     `json:"family_id"`
+	UserID             string     `json:"user_id"`
+	Kind               string     `json:"kind"`
+	Subject            string     `json:"subject"`
+	Body               string     `json:"body"`
+	PlannedOperationID *string    `json:"planned_operation_id,omitempty"`
+	CreatedAt          time.Time  `json:"created_at"`
+	ReadAt             *time.Time `json:"read_at,omitempty"`
+}
+
+// SubscriptionSuggestion is a periodic charge detected in the transaction
+// history that can be turned into a recurring planned operation.
+type SubscriptionSuggestion struct {
+	ID                 string    `json:"id"`
+	Title              string    `json:"title"`
+	AccountID          string    `json:"account_id"`
+	CategoryID         string    `json:"category_id"`
+	Type               string    `json:"type"`
+	Currency           string    `json:"currency"`
+	Period             string    `json:"period"`
+	Recurrence         string    `json:"recurrence"`
+	AverageAmountMinor int64     `json:"average_amount_minor"`
+	LastAmountMinor    int64     `json:"last_amount_minor"`
+	Occurrences        int       `json:"occurrences"`
+	FirstSeenAt        time.Time `json:"first_seen_at"`
+	LastSeenAt         time.Time `json:"last_seen_at"`
+	NextExpectedAt     time.Time `json:"next_expected_at"`
+	TransactionIDs     []string  `json:"transaction_ids"`
+}
+
+// ImportProfile describes the layout of a bank statement: the file format,
+// the CSV delimiter or workbook sheet, how many rows precede the header,
+// the date format (dd.MM.yyyy style) and decimal separator, and which
+// header holds each field. Columns is keyed by occurred_at, amount, currency, description,
+// category and account_amount, the amount charged in the account currency.
+// Built-in profiles have no FamilyID.
+type ImportProfile struct {
+	ID               string            `json:"id"`
+	FamilyID         string            `json:"family_id,omitempty"`
+	Name             string            `json:"name"`
+	Format           string            `json:"format"`
+	Delimiter        string            `json:"delimiter,omitempty"`
+	Sheet            string            `json:"sheet,omitempty"`
+	SkipRows         int               `json:"skip_rows"`
+	DateFormat       string            `json:"date_format"`
+	DecimalSeparator string            `json:"decimal_separator"`
+	Timezone         string            `json:"timezone,omitempty"`
+	Columns          map[string]string `json:"columns"`
+	Builtin          bool              `json:"builtin"`
+	CreatedAt        *time.Time        `json:"created_at,omitempty"`
+}
+
+// Import is an uploaded statement waiting to be committed into an account.
+type Import struct {
+	ID           string        `json:"id"`
+	FamilyID     string        `json:"family_id"`
+	UserID       string        `json:"user_id"`
+	AccountID    *string       `json:"account_id,omitempty"`
+	FileName     string        `json:"file_name"`
+	Status       string        `json:"status"`
+	Profile      ImportProfile `json:"profile"`
+	Transactions int           `json:"transactions"`
+	CreatedAt    time.Time     `json:"created_at"`
+	CommittedAt  *time.Time    `json:"committed_at,omitempty"`
+}
+
+// ImportRow is a parsed statement row. AmountMinor is positive; Type comes
+// from the sign in the file. Row is the line of the file, counting from 1.
+type ImportRow struct {
+	Row                int       `json:"row"`
+	OccurredAt         time.Time `json:"occurred_at"`
+	Type               string    `json:"type"`
+	AmountMinor        int64     `json:"amount_minor"`
+	Currency           string    `json:"currency"`
+	AccountAmountMinor *int64    `json:"account_amount_minor,omitempty"`
+	Description        string    `json:"description"`
+	Category           string    `json:"category,omitempty"`
+	CategoryID         string    `json:"category_id,omitempty"`
+	Hash               string    `json:"hash"`
+	Duplicate          bool      `json:"duplicate,omitempty"`
+}
+
+// ImportIssue is a row that cannot be imported (an error) or needs a look
+// before committing (a warning). Row is zero for problems of the whole file.
+type ImportIssue struct {
+	Row     int               `json:"row"`
+	Code    string            `json:"code"`
+	Message string            `json:"message"`
+	Details map[string]string `json:"details,omitempty"`
+}
+
+// ImportPreview is an import with its parsed rows and the problems found.
+type ImportPreview struct {
+	Import     Import        `json:"import"`
+	TotalRows  int           `json:"total_rows"`
+	ValidRows  int           `json:"valid_rows"`
+	Duplicates int           `json:"duplicates"`
+	Rows       []ImportRow   `json:"rows"`
+	Errors     []ImportIssue `json:"errors"`
+	Warnings   []ImportIssue `json:"warnings"`
 }
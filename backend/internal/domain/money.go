@@ -0,0 +1,56 @@
+package domain
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// rateDigits is how many significant digits of an exchange rate are kept.
+// A fixed number of decimals would distort small rates such as VND to USD.
+const rateDigits = 10
+
+// FormatAmount renders an amount in minor units of currency with the
+// currency's decimals and code, e.g. -4500000 RUB as "-45000.00 RUB" and
+// 1500 JPY as "1500 JPY".
+func FormatAmount(amount int64, currency string) string {
+	currency = strings.ToUpper(currency)
+	exponent := CurrencyExponent(currency)
+	sign := ""
+	if amount < 0 {
+		sign = "-"
+		amount = -amount
+	}
+	if exponent == 0 {
+		return fmt.Sprintf("%s%d %s", sign, amount, currency)
+	}
+	scale := int64(math.Pow10(exponent))
+	return fmt.Sprintf("%s%d.%0*d %s", sign, amount/scale, exponent, amount%scale, currency)
+}
+
+// ConvertAmount converts an amount in minor units of from into minor units
+// of to at rate, the price of one unit of from in units of to, with banker's
+// rounding as docs/currency.md prescribes. The exponents of both currencies
+// are taken into account, so 1000 JPY at 0.0067 is 670 USD cents.
+func ConvertAmount(amount int64, from, to string, rate float64) int64 {
+	shift := CurrencyExponent(to) - CurrencyExponent(from)
+	return int64(math.RoundToEven(float64(amount) * rate * math.Pow10(shift)))
+}
+
+// ImpliedRate returns the rate at which amount of from was exchanged for
+// converted of to, rounded by RoundRate.
+func ImpliedRate(amount int64, from string, converted int64, to string) float64 {
+	shift := CurrencyExponent(from) - CurrencyExponent(to)
+	return RoundRate(float64(converted) / float64(amount) * math.Pow10(shift))
+}
+
+// RoundRate rounds an exchange rate to rateDigits significant digits,
+// dropping only the binary noise of the computation that produced it.
+func RoundRate(rate float64) float64 {
+	rounded, err := strconv.ParseFloat(strconv.FormatFloat(rate, 'g', rateDigits, 64), 64)
+	if err != nil {
+		return rate
+	}
+	return rounded
+}
@@ -0,0 +1,18 @@
+package domain
+
+import "testing"
+
+func TestRoundRate(t *testing.T) {
+	tests := []struct{ rate, want float64 }{
+		// A manual VND to USD rate kept to six decimals was 0.8% off.
+		{0.0000393, 0.0000393},
+		{92.50000000000001, 92.5},
+		{1.0 / 3.0, 0.3333333333},
+		{12345.678901234, 12345.67890},
+	}
+	for _, tt := range tests {
+		if got := RoundRate(tt.rate); got != tt.want {
+			t.Errorf("RoundRate(%v) = %v, want %v", tt.rate, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,173 @@
+package http
+
+import (
+	"database/sql"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"
+
+	"familybudget/internal/domain"
+	"familybudget/internal/store"
+)
+
+// AccountExchangeRequest exchanges amount_minor of from_currency into
+// to_currency within a multi-currency account. The received amount is
+// to_amount_minor when given, otherwise amount_minor at exchange_rate.
+type AccountExchangeRequest struct {
+	FromCurrency  string   `json:"from_currency"`
+	ToCurrency    string   `json:"to_currency"`
+	AmountMinor   int64    `json:"amount_minor"`
+	ToAmountMinor *int64   `json:"to_amount_minor"`
+	ExchangeRate  *float64 `json:"exchange_rate"`
+	Comment       string   `json:"comment"`
+	OccurredAt    string   `json:"occurred_at"`
+}
+
+// ListAccountExchanges returns the currency exchanges within an account,
+// newest first.
+func (h *Handlers) ListAccountExchanges(c echo.Context) error {
+	user, err := h.resolvePathUser(c)
+	if err != nil {
+		return h.handleUserAccessError(c, err)
+	}
+	account, err := h.store.GetAccount(c.Request().Context(), c.Param("accountId"))
+	if err != nil {
+		return err
+	}
+	if account == nil || account.FamilyID != user.FamilyID {
+		return c.JSON(http.StatusNotFound, map[string]string{"error": "account not found"})
+	}
+	exchanges, err := h.store.ListAccountExchanges(c.Request().Context(), account.ID)
+	if err != nil {
+		return err
+	}
+	if exchanges == nil {
+		exchanges = []domain.AccountExchange{}
+	}
+	return c.JSON(http.StatusOK, map[string]interface{}{"exchanges": exchanges})
+}
+
+// CreateAccountExchange moves money between two pockets of a multi-currency
+// account. Exchanges are not income or expense and stay out of the reports.
+func (h *Handlers) CreateAccountExchange(c echo.Context) error {
+	user, err := h.resolvePathUser(c)
+	if err != nil {
+		return h.handleUserAccessError(c, err)
+	}
+	if !canManageReferenceData(user) {
+		return c.JSON(http.StatusForbidden, map[string]string{"error": "only owner or adult can exchange currencies"})
+	}
+	ctx := c.Request().Context()
+
+	account, err := h.store.GetAccount(ctx, c.Param("accountId"))
+	if err != nil {
+		return err
+	}
+	if account == nil || account.FamilyID != user.FamilyID {
+		return c.JSON(http.StatusNotFound, map[string]string{"error": "account not found"})
+	}
+	if !account.MultiCurrency {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": store.ErrAccountNotMultiCurrency.Error()})
+	}
+	if account.IsArchived {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "account is archived"})
+	}
+
+	var req AccountExchangeRequest
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "invalid payload"})
+	}
+	from := strings.ToUpper(strings.TrimSpace(req.FromCurrency))
+	to := strings.ToUpper(strings.TrimSpace(req.ToCurrency))
+	if from == "" {
+		from = account.Currency
+	}
+	if to == "" {
+		to = account.Currency
+	}
+	if from == to {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "from_currency and to_currency must differ"})
+	}
+	for _, code := range []string{from, to} {
+		if !isSupportedCurrency(code) {
+			return c.JSON(http.StatusBadRequest, map[string]string{"error": "unsupported currency"})
+		}
+		enabled, err := h.currencyEnabled(ctx, user.FamilyID, code)
+		if err != nil {
+			return err
+		}
+		if !enabled {
+			return currencyNotEnabled(c, code)
+		}
+	}
+	if req.AmountMinor <= 0 {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "amount_minor must be positive"})
+	}
+
+	var toAmount int64
+	var rate float64
+	switch {
+	case req.ToAmountMinor != nil:
+		if *req.ToAmountMinor <= 0 {
+			return c.JSON(http.StatusBadRequest, map[string]string{"error": "to_amount_minor must be positive"})
+		}
+		toAmount = *req.ToAmountMinor
+		rate = domain.ImpliedRate(req.AmountMinor, from, toAmount, to)
+		if req.ExchangeRate != nil {
+			rate = *req.ExchangeRate
+		}
+	case req.ExchangeRate != nil:
+		if *req.ExchangeRate <= 0 {
+			return c.JSON(http.StatusBadRequest, map[string]string{"error": "exchange_rate must be positive"})
+		}
+		rate = *req.ExchangeRate
+		toAmount = domain.ConvertAmount(req.AmountMinor, from, to, rate)
+		if toAmount == 0 {
+			return c.JSON(http.StatusBadRequest, map[string]string{"error": "amount_minor is too small at exchange_rate"})
+		}
+	default:
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "to_amount_minor or exchange_rate is required"})
+	}
+
+	now := time.Now().UTC()
+	occurredAt := now
+	if trimmed := strings.TrimSpace(req.OccurredAt); trimmed != "" {
+		if occurredAt, err = time.Parse(time.RFC3339, trimmed); err != nil {
+			return c.JSON(http.StatusBadRequest, map[string]string{"error": "occurred_at must be RFC3339"})
+		}
+	}
+
+	exchange := &domain.AccountExchange{
+		ID:              uuid.NewString(),
+		FamilyID:        user.FamilyID,
+		AccountID:       account.ID,
+		UserID:          user.ID,
+		FromCurrency:    from,
+		FromAmountMinor: req.AmountMinor,
+		ToCurrency:      to,
+		ToAmountMinor:   toAmount,
+		Rate:            rate,
+		Comment:         strings.TrimSpace(req.Comment),
+		OccurredAt:      occurredAt,
+		CreatedAt:       now,
+	}
+	if err := h.store.CreateAccountExchange(ctx, exchange); err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return c.JSON(http.StatusNotFound, map[string]string{"error": "account not found"})
+		case errors.Is(err, store.ErrAccountArchived), errors.Is(err, store.ErrAccountNotMultiCurrency):
+			return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+		}
+		return err
+	}
+
+	updated, err := h.store.GetAccount(ctx, account.ID)
+	if err != nil {
+		return err
+	}
+	return c.JSON(http.StatusCreated, map[string]interface{}{"exchange": exchange, "account": updated})
+}
@@ -0,0 +1,401 @@
+package http
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"
+
+	"familybudget/internal/domain"
+	"familybudget/internal/recurrence"
+	"familybudget/internal/store"
+)
+
+const roleJunior = "junior"
+
+type MemberRoleRequest struct {
+	Role string `json:"role"`
+}
+
+type AllowanceRequest struct {
+	UserID      string `json:"user_id"`
+	AccountID   string `json:"account_id"`
+	CategoryID  string `json:"category_id"`
+	AmountMinor int64  `json:"amount_minor"`
+	Recurrence  string `json:"recurrence"`
+	StartAt     string `json:"start_at"`
+	Active      *bool  `json:"active"`
+}
+
+type allowanceResponse struct {
+	Allowance domain.Allowance `json:"allowance"`
+}
+
+type SpendingCapRequest struct {
+	AmountMinor int64  `json:"amount_minor"`
+	Currency    string `json:"currency"`
+	Period      string `json:"period"`
+}
+
+var (
+	errAllowanceMember   = errors.New("allowances can only be scheduled for junior members")
+	errAllowanceAccount  = errors.New("allowance account must be an active personal account of the family")
+	errAllowanceCategory = errors.New("allowance category must be an active income category")
+)
+
+// UpdateMemberRole lets the family owner switch members between the adult,
+// junior and guest roles.
+func (h *Handlers) UpdateMemberRole(c echo.Context) error {
+	user, err := h.resolvePathUser(c)
+	if err != nil {
+		return h.handleUserAccessError(c, err)
+	}
+	if user.Role != "owner" {
+		return c.JSON(http.StatusForbidden, map[string]string{"error": "only owner can change member roles"})
+	}
+
+	member, err := h.store.GetUser(c.Request().Context(), c.Param("memberId"))
+	if err != nil {
+		return err
+	}
+	if member == nil || member.FamilyID != user.FamilyID {
+		return c.JSON(http.StatusNotFound, map[string]string{"error": "member not found"})
+	}
+	if member.Role == "owner" {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "owner role cannot be changed"})
+	}
+
+	var req MemberRoleRequest
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "invalid payload"})
+	}
+	role := strings.ToLower(strings.TrimSpace(req.Role))
+	if role != "adult" && role != roleJunior && role != roleGuest {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "role must be adult, junior or guest"})
+	}
+
+	updated, err := h.store.UpdateUserRole(c.Request().Context(), member.ID, role)
+	if err != nil {
+		return err
+	}
+	return c.JSON(http.StatusOK, map[string]interface{}{"member": domain.FamilyMember{ID: updated.ID, Name: updated.Name, Email: updated.Email, Role: updated.Role}})
+}
+
+func (h *Handlers) ListAllowances(c echo.Context) error {
+	user, err := h.resolvePathUser(c)
+	if err != nil {
+		return h.handleUserAccessError(c, err)
+	}
+	allowances, err := h.store.ListAllowancesByFamily(c.Request().Context(), user.FamilyID)
+	if err != nil {
+		return err
+	}
+	if !canManageReferenceData(user) {
+		own := allowances[:0]
+		for _, allowance := range allowances {
+			if allowance.UserID == user.ID {
+				own = append(own, allowance)
+			}
+		}
+		allowances = own
+	}
+	return c.JSON(http.StatusOK, map[string]interface{}{"allowances": allowances})
+}
+
+func (h *Handlers) CreateAllowance(c echo.Context) error {
+	user, err := h.resolvePathUser(c)
+	if err != nil {
+		return h.handleUserAccessError(c, err)
+	}
+	if !canManageReferenceData(user) {
+		return c.JSON(http.StatusForbidden, map[string]string{"error": "only administrative users can manage allowances"})
+	}
+
+	var req AllowanceRequest
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "invalid payload"})
+	}
+	if req.AmountMinor <= 0 {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "amount_minor must be positive"})
+	}
+	rule, err := recurrence.Normalize(req.Recurrence)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+	}
+	if rule == "" {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "recurrence is required"})
+	}
+
+	now := time.Now().UTC()
+	startAt := now
+	if parsed, err := parseOptionalTime(req.StartAt); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "start_at must be RFC3339"})
+	} else if parsed != nil {
+		startAt = *parsed
+	}
+
+	allowance := &domain.Allowance{
+		ID:          uuid.NewString(),
+		FamilyID:    user.FamilyID,
+		UserID:      strings.TrimSpace(req.UserID),
+		AccountID:   strings.TrimSpace(req.AccountID),
+		CategoryID:  strings.TrimSpace(req.CategoryID),
+		AmountMinor: req.AmountMinor,
+		Recurrence:  rule,
+		StartsAt:    startAt,
+		NextRunAt:   startAt,
+		IsActive:    req.Active == nil || *req.Active,
+		CreatedBy:   user.ID,
+		CreatedAt:   now,
+		UpdatedAt:   now,
+	}
+	if err := h.validateAllowance(c.Request().Context(), allowance); err != nil {
+		return h.handleAllowanceError(c, err)
+	}
+	if err := h.store.CreateAllowance(c.Request().Context(), allowance); err != nil {
+		return err
+	}
+	return c.JSON(http.StatusCreated, allowanceResponse{Allowance: *allowance})
+}
+
+func (h *Handlers) UpdateAllowance(c echo.Context) error {
+	user, err := h.resolvePathUser(c)
+	if err != nil {
+		return h.handleUserAccessError(c, err)
+	}
+	if !canManageReferenceData(user) {
+		return c.JSON(http.StatusForbidden, map[string]string{"error": "only administrative users can manage allowances"})
+	}
+
+	allowance, err := h.store.GetAllowance(c.Request().Context(), c.Param("allowanceId"))
+	if err != nil {
+		return err
+	}
+	if allowance == nil || allowance.FamilyID != user.FamilyID {
+		return c.JSON(http.StatusNotFound, map[string]string{"error": "allowance not found"})
+	}
+
+	var req AllowanceRequest
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "invalid payload"})
+	}
+	if trimmed := strings.TrimSpace(req.AccountID); trimmed != "" {
+		allowance.AccountID = trimmed
+	}
+	if trimmed := strings.TrimSpace(req.CategoryID); trimmed != "" {
+		allowance.CategoryID = trimmed
+	}
+	if req.AmountMinor != 0 {
+		if req.AmountMinor < 0 {
+			return c.JSON(http.StatusBadRequest, map[string]string{"error": "amount_minor must be positive"})
+		}
+		allowance.AmountMinor = req.AmountMinor
+	}
+	restarted := false
+	if strings.TrimSpace(req.Recurrence) != "" {
+		rule, err := recurrence.Normalize(req.Recurrence)
+		if err != nil {
+			return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+		}
+		if rule == "" {
+			return c.JSON(http.StatusBadRequest, map[string]string{"error": "recurrence is required"})
+		}
+		// A new rule starts its series at the next payout.
+		if rule != allowance.Recurrence {
+			allowance.Recurrence = rule
+			allowance.StartsAt = allowance.NextRunAt
+			restarted = true
+		}
+	}
+	if parsed, err := parseOptionalTime(req.StartAt); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "start_at must be RFC3339"})
+	} else if parsed != nil {
+		allowance.StartsAt = *parsed
+		allowance.NextRunAt = *parsed
+		restarted = true
+	}
+	if req.Active != nil {
+		// A series that ended with COUNT or UNTIL keeps its last, already
+		// posted occurrence as next_run_at; it needs a new start.
+		ended := allowance.LastPostedAt != nil && !allowance.LastPostedAt.Before(allowance.NextRunAt)
+		if *req.Active && !allowance.IsActive && ended && !restarted {
+			return c.JSON(http.StatusBadRequest, map[string]string{"error": "the allowance schedule has ended, set start_at or recurrence to restart it"})
+		}
+		allowance.IsActive = *req.Active
+	}
+	allowance.UpdatedAt = time.Now().UTC()
+
+	if err := h.validateAllowance(c.Request().Context(), allowance); err != nil {
+		return h.handleAllowanceError(c, err)
+	}
+	if err := h.store.UpdateAllowance(c.Request().Context(), allowance); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return c.JSON(http.StatusNotFound, map[string]string{"error": "allowance not found"})
+		}
+		return err
+	}
+	return c.JSON(http.StatusOK, allowanceResponse{Allowance: *allowance})
+}
+
+func (h *Handlers) ListSpendingCaps(c echo.Context) error {
+	user, err := h.resolvePathUser(c)
+	if err != nil {
+		return h.handleUserAccessError(c, err)
+	}
+	caps, err := h.store.ListSpendingCaps(c.Request().Context(), user.ID, time.Now().UTC())
+	if err != nil {
+		return err
+	}
+	return c.JSON(http.StatusOK, map[string]interface{}{"spending_caps": caps})
+}
+
+// PutSpendingCap sets the cap of the junior member from the path for one
+// expense category.
+func (h *Handlers) PutSpendingCap(c echo.Context) error {
+	junior, err := h.resolvePathUser(c)
+	if err != nil {
+		return h.handleUserAccessError(c, err)
+	}
+	if !canManageReferenceData(currentUserFromContext(c)) {
+		return c.JSON(http.StatusForbidden, map[string]string{"error": "only administrative users can manage spending caps"})
+	}
+	if junior.Role != roleJunior {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "spending caps can only be set for junior members"})
+	}
+
+	category, err := h.store.GetCategory(c.Request().Context(), c.Param("categoryId"))
+	if err != nil {
+		return err
+	}
+	if category == nil || category.FamilyID != junior.FamilyID {
+		return c.JSON(http.StatusNotFound, map[string]string{"error": "category not found"})
+	}
+	if category.Type != "expense" {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "spending caps apply to expense categories"})
+	}
+
+	var req SpendingCapRequest
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "invalid payload"})
+	}
+	if req.AmountMinor < 0 {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "amount_minor must not be negative"})
+	}
+	req.Currency = strings.ToUpper(strings.TrimSpace(req.Currency))
+	if req.Currency == "" {
+		req.Currency = junior.CurrencyDefault
+	}
+	if !isSupportedCurrency(req.Currency) {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "unsupported currency"})
+	}
+	enabled, err := h.currencyEnabled(c.Request().Context(), junior.FamilyID, req.Currency)
+	if err != nil {
+		return err
+	}
+	if !enabled {
+		return currencyNotEnabled(c, req.Currency)
+	}
+	req.Period = strings.ToLower(strings.TrimSpace(req.Period))
+	if req.Period == "" {
+		req.Period = store.CapPeriodMonthly
+	}
+	if req.Period != store.CapPeriodMonthly && req.Period != store.CapPeriodWeekly {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "period must be weekly or monthly"})
+	}
+
+	now := time.Now().UTC()
+	spendingCap := &domain.SpendingCap{
+		FamilyID:    junior.FamilyID,
+		UserID:      junior.ID,
+		CategoryID:  category.ID,
+		Period:      req.Period,
+		AmountMinor: req.AmountMinor,
+		Currency:    req.Currency,
+		UpdatedAt:   now,
+	}
+	if err := h.store.UpsertSpendingCap(c.Request().Context(), spendingCap); err != nil {
+		return err
+	}
+	saved, err := h.store.GetSpendingCap(c.Request().Context(), junior.ID, category.ID, now)
+	if err != nil {
+		return err
+	}
+	return c.JSON(http.StatusOK, map[string]interface{}{"spending_cap": saved})
+}
+
+func (h *Handlers) DeleteSpendingCap(c echo.Context) error {
+	junior, err := h.resolvePathUser(c)
+	if err != nil {
+		return h.handleUserAccessError(c, err)
+	}
+	if !canManageReferenceData(currentUserFromContext(c)) {
+		return c.JSON(http.StatusForbidden, map[string]string{"error": "only administrative users can manage spending caps"})
+	}
+	if err := h.store.DeleteSpendingCap(c.Request().Context(), junior.ID, c.Param("categoryId")); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return c.JSON(http.StatusNotFound, map[string]string{"error": "spending cap not found"})
+		}
+		return err
+	}
+	return c.NoContent(http.StatusNoContent)
+}
+
+// exceedsSpendingCap reports whether a junior's expense would go over the cap
+// set for its category in the current period. An expense in another
+// currency than the cap cannot be measured against it and is treated as
+// going over, so that it waits for review.
+func (h *Handlers) exceedsSpendingCap(ctx context.Context, user *domain.User, txn *domain.Transaction) (bool, error) {
+	if user.Role != roleJunior || txn.Type != "expense" {
+		return false, nil
+	}
+	spendingCap, err := h.store.GetSpendingCap(ctx, user.ID, txn.CategoryID, time.Now().UTC())
+	if err != nil || spendingCap == nil {
+		return false, err
+	}
+	if spendingCap.Currency != txn.Currency {
+		return true, nil
+	}
+	return spendingCap.SpentMinor+txn.AmountMinor > spendingCap.AmountMinor, nil
+}
+
+func (h *Handlers) validateAllowance(ctx context.Context, allowance *domain.Allowance) error {
+	member, err := h.store.GetUser(ctx, allowance.UserID)
+	if err != nil {
+		return err
+	}
+	if member == nil || member.FamilyID != allowance.FamilyID || member.Role != roleJunior {
+		return errAllowanceMember
+	}
+
+	account, err := h.store.GetAccount(ctx, allowance.AccountID)
+	if err != nil {
+		return err
+	}
+	if account == nil || account.FamilyID != allowance.FamilyID || account.IsArchived || account.IsShared {
+		return errAllowanceAccount
+	}
+	allowance.Currency = account.Currency
+
+	category, err := h.store.GetCategory(ctx, allowance.CategoryID)
+	if err != nil {
+		return err
+	}
+	if category == nil || category.FamilyID != allowance.FamilyID || category.IsArchived || category.Type != "income" {
+		return errAllowanceCategory
+	}
+	return nil
+}
+
+func (h *Handlers) handleAllowanceError(c echo.Context, err error) error {
+	switch {
+	case errors.Is(err, errAllowanceMember), errors.Is(err, errAllowanceAccount), errors.Is(err, errAllowanceCategory):
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+	default:
+		return err
+	}
+}
@@ -0,0 +1,263 @@
+package http
+
+import (
+	"database/sql"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/labstack/echo/v4"
+
+	"familybudget/internal/domain"
+	"familybudget/internal/store"
+)
+
+const roleGuest = "guest"
+
+type approvalResponse struct {
+	Approval domain.TransactionApproval `json:"approval"`
+}
+
+type ApprovalPolicyRequest struct {
+	ApprovalRequired bool `json:"approval_required"`
+}
+
+// ApproveTransactionRequest carries optional reviewer edits. Empty fields keep
+// the values submitted by the member.
+type ApproveTransactionRequest struct {
+	AccountID   string  `json:"account_id"`
+	CategoryID  string  `json:"category_id"`
+	AmountMinor int64   `json:"amount_minor"`
+	Comment     *string `json:"comment"`
+	OccurredAt  string  `json:"occurred_at"`
+	Note        string  `json:"note"`
+}
+
+type RejectTransactionRequest struct {
+	Reason string `json:"reason"`
+}
+
+func isRestrictedRole(role string) bool {
+	role = strings.ToLower(strings.TrimSpace(role))
+	return role == roleJunior || role == roleGuest
+}
+
+// ListApprovals returns the approval queue of the family. Administrative
+// callers see every request, other members only their own.
+func (h *Handlers) ListApprovals(c echo.Context) error {
+	if _, err := h.resolvePathUser(c); err != nil {
+		return h.handleUserAccessError(c, err)
+	}
+	user := currentUserFromContext(c)
+
+	status := strings.ToLower(strings.TrimSpace(c.QueryParam("status")))
+	switch status {
+	case "":
+		status = store.ApprovalStatusPending
+	case "all":
+		status = ""
+	case store.ApprovalStatusPending, store.ApprovalStatusApproved, store.ApprovalStatusRejected:
+	default:
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "status must be pending_approval, approved, rejected or all"})
+	}
+
+	approvals, err := h.store.ListTransactionApprovals(c.Request().Context(), user.FamilyID, status)
+	if err != nil {
+		return err
+	}
+	if !canManageReferenceData(user) {
+		own := approvals[:0]
+		for _, approval := range approvals {
+			if approval.Transaction.UserID == user.ID {
+				own = append(own, approval)
+			}
+		}
+		approvals = own
+	}
+	return c.JSON(http.StatusOK, map[string]interface{}{"approvals": approvals})
+}
+
+func (h *Handlers) GetApprovalPolicy(c echo.Context) error {
+	user, err := h.resolvePathUser(c)
+	if err != nil {
+		return h.handleUserAccessError(c, err)
+	}
+	family, err := h.store.GetFamily(c.Request().Context(), user.FamilyID)
+	if err != nil {
+		return err
+	}
+	if family == nil {
+		return c.JSON(http.StatusNotFound, map[string]string{"error": "family not found"})
+	}
+	return c.JSON(http.StatusOK, ApprovalPolicyRequest{ApprovalRequired: family.ApprovalRequired})
+}
+
+// UpdateApprovalPolicy turns on or off the review of every transaction
+// submitted by junior and guest members.
+func (h *Handlers) UpdateApprovalPolicy(c echo.Context) error {
+	if _, err := h.resolvePathUser(c); err != nil {
+		return h.handleUserAccessError(c, err)
+	}
+	user := currentUserFromContext(c)
+	if !canManageReferenceData(user) {
+		return c.JSON(http.StatusForbidden, map[string]string{"error": "only administrative users can change the approval policy"})
+	}
+
+	var req ApprovalPolicyRequest
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "invalid payload"})
+	}
+	family, err := h.store.UpdateFamilyApprovalRequired(c.Request().Context(), user.FamilyID, req.ApprovalRequired)
+	if err != nil {
+		return err
+	}
+	if family == nil {
+		return c.JSON(http.StatusNotFound, map[string]string{"error": "family not found"})
+	}
+	return c.JSON(http.StatusOK, ApprovalPolicyRequest{ApprovalRequired: family.ApprovalRequired})
+}
+
+func (h *Handlers) ApproveTransaction(c echo.Context) error {
+	if _, err := h.resolvePathUser(c); err != nil {
+		return h.handleUserAccessError(c, err)
+	}
+	// The reviewer is the caller, whoever the path names.
+	reviewer := currentUserFromContext(c)
+	if !canManageReferenceData(reviewer) {
+		return c.JSON(http.StatusForbidden, map[string]string{"error": "only administrative users can review transactions"})
+	}
+	ctx := c.Request().Context()
+
+	approval, err := h.store.GetTransactionApproval(ctx, c.Param("approvalId"))
+	if err != nil {
+		return err
+	}
+	if approval == nil || approval.FamilyID != reviewer.FamilyID {
+		return c.JSON(http.StatusNotFound, map[string]string{"error": "approval not found"})
+	}
+	if approval.Status != store.ApprovalStatusPending {
+		return c.JSON(http.StatusConflict, map[string]string{"error": "approval is already resolved"})
+	}
+
+	var req ApproveTransactionRequest
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "invalid payload"})
+	}
+
+	txn := approval.Transaction
+	if trimmed := strings.TrimSpace(req.AccountID); trimmed != "" {
+		txn.AccountID = trimmed
+	}
+	if trimmed := strings.TrimSpace(req.CategoryID); trimmed != "" {
+		txn.CategoryID = trimmed
+	}
+	if req.AmountMinor != 0 {
+		txn.AmountMinor = req.AmountMinor
+		// The original amount was paid; a corrected account amount implies
+		// a different rate.
+		if txn.OriginalAmountMinor != nil {
+			if _, txn.ExchangeRate, err = accountAmount(*txn.OriginalAmountMinor, txn.OriginalCurrency, txn.Currency, &txn.AmountMinor, nil); err != nil {
+				return c.JSON(http.StatusBadRequest, map[string]string{"error": "amount_minor must have the sign of the original amount"})
+			}
+		}
+	}
+	if req.Comment != nil {
+		txn.Comment = strings.TrimSpace(*req.Comment)
+	}
+	if trimmed := strings.TrimSpace(req.OccurredAt); trimmed != "" {
+		parsed, err := time.Parse(time.RFC3339, trimmed)
+		if err != nil {
+			return c.JSON(http.StatusBadRequest, map[string]string{"error": "occurred_at must be RFC3339"})
+		}
+		txn.OccurredAt = parsed.UTC()
+	}
+
+	account, err := h.store.GetAccount(ctx, txn.AccountID)
+	if err != nil {
+		return err
+	}
+	if account == nil || account.FamilyID != reviewer.FamilyID {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "account not found"})
+	}
+	if account.IsArchived {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "account is archived"})
+	}
+	if !account.Holds(txn.Currency) {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "currency must match account currency"})
+	}
+
+	category, err := h.store.GetCategory(ctx, txn.CategoryID)
+	if err != nil {
+		return err
+	}
+	if category == nil || category.FamilyID != reviewer.FamilyID {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "category not found"})
+	}
+	if category.IsArchived {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "category is archived"})
+	}
+
+	now := time.Now().UTC()
+	txn.CreatedAt = now
+	txn.UpdatedAt = now
+	if err := h.store.ApproveTransaction(ctx, approval.ID, reviewer.ID, strings.TrimSpace(req.Note), &txn, now); err != nil {
+		switch {
+		case errors.Is(err, store.ErrApprovalResolved):
+			return c.JSON(http.StatusConflict, map[string]string{"error": "approval is already resolved"})
+		case errors.Is(err, sql.ErrNoRows):
+			return c.JSON(http.StatusBadRequest, map[string]string{"error": "account not found"})
+		case errors.Is(err, store.ErrAccountArchived):
+			return c.JSON(http.StatusBadRequest, map[string]string{"error": "account is archived"})
+		}
+		return h.handleEnvelopeError(c, err)
+	}
+
+	resolved, err := h.store.GetTransactionApproval(ctx, approval.ID)
+	if err != nil {
+		return err
+	}
+	return c.JSON(http.StatusOK, approvalResponse{Approval: *resolved})
+}
+
+func (h *Handlers) RejectTransaction(c echo.Context) error {
+	if _, err := h.resolvePathUser(c); err != nil {
+		return h.handleUserAccessError(c, err)
+	}
+	// The reviewer is the caller, whoever the path names.
+	reviewer := currentUserFromContext(c)
+	if !canManageReferenceData(reviewer) {
+		return c.JSON(http.StatusForbidden, map[string]string{"error": "only administrative users can review transactions"})
+	}
+	ctx := c.Request().Context()
+
+	approval, err := h.store.GetTransactionApproval(ctx, c.Param("approvalId"))
+	if err != nil {
+		return err
+	}
+	if approval == nil || approval.FamilyID != reviewer.FamilyID {
+		return c.JSON(http.StatusNotFound, map[string]string{"error": "approval not found"})
+	}
+
+	var req RejectTransactionRequest
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "invalid payload"})
+	}
+	req.Reason = strings.TrimSpace(req.Reason)
+	if req.Reason == "" {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "reason is required"})
+	}
+
+	if err := h.store.RejectTransaction(ctx, approval.ID, reviewer.ID, req.Reason, time.Now().UTC()); err != nil {
+		if errors.Is(err, store.ErrApprovalResolved) {
+			return c.JSON(http.StatusConflict, map[string]string{"error": "approval is already resolved"})
+		}
+		return err
+	}
+
+	resolved, err := h.store.GetTransactionApproval(ctx, approval.ID)
+	if err != nil {
+		return err
+	}
+	return c.JSON(http.StatusOK, approvalResponse{Approval: *resolved})
+}
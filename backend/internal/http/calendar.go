@@ -0,0 +1,264 @@
+package http
+
+import (
+	"crypto/rand"
+	"database/sql"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/labstack/echo/v4"
+
+	"familybudget/internal/domain"
+	"familybudget/internal/ical"
+	"familybudget/internal/recurrence"
+	"familybudget/internal/store"
+)
+
+type calendarFeedResponse struct {
+	Feed *domain.CalendarFeed `json:"feed"`
+	URL  string               `json:"url,omitempty"`
+}
+
+func calendarFeedPath(token string) string {
+	return "/api/v1/calendar/" + token + ".ics"
+}
+
+func newCalendarToken() (string, error) {
+	raw := make([]byte, 32)
+	if _, err := rand.Read(raw); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(raw), nil
+}
+
+// calendarFeedForbidden is the response to a member managing someone
+// else's feed: only the member can issue theirs, and the owner can also
+// look at and revoke the feeds of others.
+func calendarFeedForbidden(c echo.Context) error {
+	return c.JSON(http.StatusForbidden, map[string]string{"error": "members can only manage their own calendar feed"})
+}
+
+// GetCalendarFeed reports whether the member has an active feed. The token is
+// only returned when it is issued.
+func (h *Handlers) GetCalendarFeed(c echo.Context) error {
+	user, err := h.resolvePathUser(c)
+	if err != nil {
+		return h.handleUserAccessError(c, err)
+	}
+	if current := currentUserFromContext(c); user.ID != current.ID && current.Role != "owner" {
+		return calendarFeedForbidden(c)
+	}
+	feed, err := h.store.GetCalendarFeed(c.Request().Context(), user.ID)
+	if err != nil {
+		return err
+	}
+	if feed == nil {
+		return c.JSON(http.StatusNotFound, map[string]string{"error": "calendar feed is not enabled"})
+	}
+	return c.JSON(http.StatusOK, calendarFeedResponse{Feed: feed})
+}
+
+// IssueCalendarFeed creates the member's feed token, replacing and thereby
+// revoking any previous one. Members only issue their own feed, since the
+// token reads the operations the member can see.
+func (h *Handlers) IssueCalendarFeed(c echo.Context) error {
+	user, err := h.resolvePathUser(c)
+	if err != nil {
+		return h.handleUserAccessError(c, err)
+	}
+	if user.ID != currentUserFromContext(c).ID {
+		return calendarFeedForbidden(c)
+	}
+	token, err := newCalendarToken()
+	if err != nil {
+		return err
+	}
+	feed, err := h.store.IssueCalendarFeed(c.Request().Context(), user.ID, user.FamilyID, token, time.Now().UTC())
+	if err != nil {
+		return err
+	}
+	return c.JSON(http.StatusCreated, calendarFeedResponse{Feed: feed, URL: calendarFeedPath(token)})
+}
+
+// RevokeCalendarFeed disables the member's feed; the owner can revoke the
+// feed of any member.
+func (h *Handlers) RevokeCalendarFeed(c echo.Context) error {
+	user, err := h.resolvePathUser(c)
+	if err != nil {
+		return h.handleUserAccessError(c, err)
+	}
+	if current := currentUserFromContext(c); user.ID != current.ID && current.Role != "owner" {
+		return calendarFeedForbidden(c)
+	}
+	if err := h.store.RevokeCalendarFeed(c.Request().Context(), user.ID); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return c.JSON(http.StatusNotFound, map[string]string{"error": "calendar feed is not enabled"})
+		}
+		return err
+	}
+	return c.NoContent(http.StatusNoContent)
+}
+
+// plannedOperationVisible applies account visibility to a member: operations
+// on personal accounts are shown only to their author, and junior and guest
+// members see only their own operations.
+func plannedOperationVisible(user *domain.User, op *domain.PlannedOperation, account *domain.Account) bool {
+	if op.UserID == user.ID {
+		return true
+	}
+	if isRestrictedRole(user.Role) {
+		return false
+	}
+	return account.IsShared
+}
+
+// CalendarFeed serves the pending planned operations visible to the feed
+// owner as an iCalendar feed. It is authenticated by the token in the path so
+// that calendar apps can subscribe to it.
+func (h *Handlers) CalendarFeed(c echo.Context) error {
+	ctx := c.Request().Context()
+	token := strings.TrimSuffix(c.Param("token"), ".ics")
+
+	feed, err := h.store.GetCalendarFeedByToken(ctx, token)
+	if err != nil {
+		return err
+	}
+	if feed == nil {
+		return c.JSON(http.StatusNotFound, map[string]string{"error": "calendar feed not found"})
+	}
+	user, err := h.store.GetUser(ctx, feed.UserID)
+	if err != nil {
+		return err
+	}
+	if user == nil || user.FamilyID != feed.FamilyID {
+		return c.JSON(http.StatusNotFound, map[string]string{"error": "calendar feed not found"})
+	}
+	family, err := h.store.GetFamily(ctx, user.FamilyID)
+	if err != nil {
+		return err
+	}
+	if family == nil {
+		return c.JSON(http.StatusNotFound, map[string]string{"error": "calendar feed not found"})
+	}
+	loc, err := recurrence.LoadLocation(family.Timezone)
+	if err != nil {
+		loc = time.UTC
+	}
+
+	accounts, err := h.store.ListAccountsByFamily(ctx, user.FamilyID)
+	if err != nil {
+		return err
+	}
+	accountsByID := make(map[string]*domain.Account, len(accounts))
+	for i := range accounts {
+		accountsByID[accounts[i].ID] = &accounts[i]
+	}
+	categories, err := h.store.ListCategoriesByFamily(ctx, user.FamilyID)
+	if err != nil {
+		return err
+	}
+	categoryNames := make(map[string]string, len(categories))
+	for _, category := range categories {
+		categoryNames[category.ID] = category.Name
+	}
+	ops, err := h.store.ListPlannedOperationsByFamily(ctx, user.FamilyID, store.PlannedOperationStatusPending)
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	calendar := ical.Calendar{Name: family.Name, Timezone: loc.String()}
+	for i := range ops {
+		op := &ops[i].PlannedOperation
+		account, ok := accountsByID[op.AccountID]
+		if !ok || !plannedOperationVisible(user, op, account) {
+			continue
+		}
+		events, err := plannedOperationEvents(op, account.Name, categoryNames[op.CategoryID], loc, now)
+		if err != nil {
+			return err
+		}
+		calendar.Events = append(calendar.Events, events...)
+	}
+
+	// The server defaults every response to JSON; Blob keeps an existing type.
+	c.Response().Header().Set(echo.HeaderContentType, "text/calendar; charset=utf-8")
+	c.Response().Header().Set(echo.HeaderCacheControl, "private, max-age=300")
+	return c.Blob(http.StatusOK, "text/calendar; charset=utf-8", calendar.Encode())
+}
+
+// plannedOperationEvents returns the current occurrence of op as a single
+// event, marked when overdue, followed by the rest of a recurring series as
+// one RRULE event starting at the next occurrence.
+func plannedOperationEvents(op *domain.PlannedOperation, accountName, categoryName string, loc *time.Location, now time.Time) ([]ical.Event, error) {
+	sign := "-"
+	if op.Type == "income" {
+		sign = "+"
+	}
+	amount := sign + domain.FormatAmount(op.AmountMinor, op.Currency)
+	description := fmt.Sprintf("Amount: %s\nAccount: %s\nCategory: %s", amount, accountName, categoryName)
+	if op.Comment != "" {
+		description += "\n" + op.Comment
+	}
+	summary := op.Title + " " + amount
+	var categories []string
+	if categoryName != "" {
+		categories = []string{categoryName}
+	}
+
+	current := ical.Event{
+		UID:         op.ID + "-" + op.DueAt.UTC().Format("20060102") + "@familybudget",
+		Stamp:       op.UpdatedAt,
+		Date:        op.DueAt.In(loc),
+		Summary:     summary,
+		Description: description,
+		Categories:  categories,
+	}
+	if op.DueAt.Before(now) {
+		current.Summary = "Overdue: " + summary
+		current.Description = fmt.Sprintf("Overdue since %s\n%s", op.DueAt.In(loc).Format("2006-01-02"), description)
+	}
+	events := []ical.Event{current}
+
+	rule := strings.TrimSpace(op.Recurrence)
+	if rule == "" {
+		return events, nil
+	}
+	parsed, err := recurrence.Parse(rule)
+	if err != nil {
+		return nil, err
+	}
+	after := op.DueAt
+	if op.SnoozedFrom != nil && op.SnoozedFrom.After(after) {
+		after = *op.SnoozedFrom
+	}
+	next, err := parsed.Next(op.StartsAt, after, loc)
+	if errors.Is(err, recurrence.ErrSeriesEnded) {
+		return events, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	consumed := 0
+	if parsed.Count > 0 {
+		past, err := recurrence.Between(rule, op.StartsAt, op.StartsAt, next, loc)
+		if err != nil {
+			return nil, err
+		}
+		consumed = len(past)
+	}
+	events = append(events, ical.Event{
+		UID:         op.ID + "@familybudget",
+		Stamp:       op.UpdatedAt,
+		Date:        next.In(loc),
+		Summary:     summary,
+		Description: description,
+		Categories:  categories,
+		RRule:       parsed.RRule(op.StartsAt, consumed, loc),
+	})
+	return events, nil
+}
@@ -0,0 +1,167 @@
+package http
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/labstack/echo/v4"
+
+	"familybudget/internal/domain"
+)
+
+// CurrencyResponse is a registry entry with its name in the requested
+// locale. Enabled is set in family listings.
+type CurrencyResponse struct {
+	domain.Currency
+	Name    string `json:"name"`
+	Enabled *bool  `json:"enabled,omitempty"`
+	Default bool   `json:"default"`
+}
+
+func currencyResponse(currency domain.Currency, locale string) CurrencyResponse {
+	response := CurrencyResponse{Currency: currency, Name: currency.Name(locale)}
+	for _, code := range domain.DefaultCurrencies {
+		if code == currency.Code {
+			response.Default = true
+		}
+	}
+	return response
+}
+
+// isSupportedCurrency reports whether code is an ISO 4217 currency of the
+// registry.
+func isSupportedCurrency(code string) bool {
+	_, ok := domain.LookupCurrency(code)
+	return ok
+}
+
+// currencyEnabled reports whether the family can use code: a default
+// currency, the family currency or one the family enabled.
+func (h *Handlers) currencyEnabled(ctx context.Context, familyID, code string) (bool, error) {
+	code = strings.ToUpper(strings.TrimSpace(code))
+	enabled, err := h.store.FamilyCurrencies(ctx, familyID)
+	if err != nil {
+		return false, err
+	}
+	for _, item := range enabled {
+		if item == code {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func currencyNotEnabled(c echo.Context, code string) error {
+	return c.JSON(http.StatusBadRequest, map[string]string{"error": "currency " + strings.ToUpper(strings.TrimSpace(code)) + " is not enabled for the family"})
+}
+
+// ListCurrencies returns the currency registry with names in ?locale=
+// (ru-RU by default). It needs no authentication so that registration can
+// offer the family currencies.
+func (h *Handlers) ListCurrencies(c echo.Context) error {
+	locale := defaultLocale(c.QueryParam("locale"))
+	registry := domain.Currencies()
+	currencies := make([]CurrencyResponse, 0, len(registry))
+	for _, currency := range registry {
+		currencies = append(currencies, currencyResponse(currency, locale))
+	}
+	return c.JSON(http.StatusOK, map[string]interface{}{"currencies": currencies})
+}
+
+// ListFamilyCurrencies returns the registry in the member's locale, marking
+// the currencies the family can use; ?enabled=true lists only those.
+func (h *Handlers) ListFamilyCurrencies(c echo.Context) error {
+	user, err := h.resolvePathUser(c)
+	if err != nil {
+		return h.handleUserAccessError(c, err)
+	}
+	enabled, err := h.store.FamilyCurrencies(c.Request().Context(), user.FamilyID)
+	if err != nil {
+		return err
+	}
+	enabledSet := make(map[string]bool, len(enabled))
+	for _, code := range enabled {
+		enabledSet[code] = true
+	}
+	onlyEnabled := strings.EqualFold(strings.TrimSpace(c.QueryParam("enabled")), "true")
+
+	locale := defaultLocale(user.Locale)
+	currencies := []CurrencyResponse{}
+	for _, currency := range domain.Currencies() {
+		isEnabled := enabledSet[currency.Code]
+		if onlyEnabled && !isEnabled {
+			continue
+		}
+		response := currencyResponse(currency, locale)
+		response.Enabled = &isEnabled
+		currencies = append(currencies, response)
+	}
+	return c.JSON(http.StatusOK, map[string]interface{}{"currencies": currencies, "enabled": enabled})
+}
+
+// EnableFamilyCurrency lets the family use another registry currency for
+// accounts, transactions and the other money records.
+func (h *Handlers) EnableFamilyCurrency(c echo.Context) error {
+	user, err := h.resolvePathUser(c)
+	if err != nil {
+		return h.handleUserAccessError(c, err)
+	}
+	if !canManageReferenceData(user) {
+		return c.JSON(http.StatusForbidden, map[string]string{"error": "only owner or adult can enable currencies"})
+	}
+	ctx := c.Request().Context()
+
+	currency, ok := domain.LookupCurrency(c.Param("code"))
+	if !ok {
+		return c.JSON(http.StatusNotFound, map[string]string{"error": "unknown currency"})
+	}
+	if err := h.store.EnableFamilyCurrency(ctx, user.FamilyID, currency.Code, time.Now().UTC()); err != nil {
+		return err
+	}
+	enabled, err := h.store.FamilyCurrencies(ctx, user.FamilyID)
+	if err != nil {
+		return err
+	}
+	return c.JSON(http.StatusOK, map[string]interface{}{"enabled": enabled})
+}
+
+// DisableFamilyCurrency stops offering an enabled currency. Default
+// currencies and the family currency are always enabled; records already
+// kept in the currency stay as they are.
+func (h *Handlers) DisableFamilyCurrency(c echo.Context) error {
+	user, err := h.resolvePathUser(c)
+	if err != nil {
+		return h.handleUserAccessError(c, err)
+	}
+	if !canManageReferenceData(user) {
+		return c.JSON(http.StatusForbidden, map[string]string{"error": "only owner or adult can disable currencies"})
+	}
+	ctx := c.Request().Context()
+
+	code := strings.ToUpper(strings.TrimSpace(c.Param("code")))
+	family, err := h.store.GetFamily(ctx, user.FamilyID)
+	if err != nil {
+		return err
+	}
+	if family == nil {
+		return c.JSON(http.StatusNotFound, map[string]string{"error": "family not found"})
+	}
+	if strings.EqualFold(family.CurrencyBase, code) {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "the family currency cannot be disabled"})
+	}
+	for _, item := range domain.DefaultCurrencies {
+		if item == code {
+			return c.JSON(http.StatusBadRequest, map[string]string{"error": "default currencies cannot be disabled"})
+		}
+	}
+	disabled, err := h.store.DisableFamilyCurrency(ctx, user.FamilyID, code)
+	if err != nil {
+		return err
+	}
+	if !disabled {
+		return c.JSON(http.StatusNotFound, map[string]string{"error": "currency is not enabled"})
+	}
+	return c.NoContent(http.StatusNoContent)
+}
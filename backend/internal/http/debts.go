@@ -0,0 +1,401 @@
+package http
+
+import (
+	"database/sql"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"
+
+	"familybudget/internal/domain"
+	"familybudget/internal/store"
+)
+
+type DebtRequest struct {
+	Counterparty string `json:"counterparty"`
+	Direction    string `json:"direction"`
+	AmountMinor  int64  `json:"amount_minor"`
+	Currency     string `json:"currency"`
+	DueDate      string `json:"due_date"`
+	Notes        string `json:"notes"`
+	Closed       *bool  `json:"closed"`
+}
+
+type debtResponse struct {
+	Debt domain.Debt `json:"debt"`
+}
+
+// DebtRepaymentRequest either links an existing transaction or describes a new
+// one that is posted to the given account together with the repayment.
+type DebtRepaymentRequest struct {
+	TransactionID string `json:"transaction_id"`
+	AccountID     string `json:"account_id"`
+	CategoryID    string `json:"category_id"`
+	AmountMinor   int64  `json:"amount_minor"`
+	Comment       string `json:"comment"`
+	OccurredAt    string `json:"occurred_at"`
+}
+
+func (h *Handlers) ListDebts(c echo.Context) error {
+	user, err := h.resolvePathUser(c)
+	if err != nil {
+		return h.handleUserAccessError(c, err)
+	}
+
+	filters := store.DebtListFilters{Counterparty: strings.TrimSpace(c.QueryParam("counterparty"))}
+	switch strings.ToLower(strings.TrimSpace(c.QueryParam("status"))) {
+	case "", "all":
+	case "open":
+		filters.OpenOnly = true
+	case "overdue":
+		filters.OverdueOnly = true
+	default:
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "status must be all, open or overdue"})
+	}
+
+	debts, err := h.store.ListDebtsByFamily(c.Request().Context(), user.FamilyID, filters)
+	if err != nil {
+		return err
+	}
+	return c.JSON(http.StatusOK, map[string]interface{}{"debts": debts})
+}
+
+func (h *Handlers) ListDebtCounterparties(c echo.Context) error {
+	user, err := h.resolvePathUser(c)
+	if err != nil {
+		return h.handleUserAccessError(c, err)
+	}
+	balances, err := h.store.ListCounterpartyBalances(c.Request().Context(), user.FamilyID)
+	if err != nil {
+		return err
+	}
+	return c.JSON(http.StatusOK, map[string]interface{}{"counterparties": balances})
+}
+
+func (h *Handlers) GetDebt(c echo.Context) error {
+	user, err := h.resolvePathUser(c)
+	if err != nil {
+		return h.handleUserAccessError(c, err)
+	}
+	debt, err := h.store.GetDebt(c.Request().Context(), c.Param("debtId"))
+	if err != nil {
+		return err
+	}
+	if debt == nil || debt.FamilyID != user.FamilyID {
+		return c.JSON(http.StatusNotFound, map[string]string{"error": "debt not found"})
+	}
+	repayments, err := h.store.ListDebtRepayments(c.Request().Context(), debt.ID)
+	if err != nil {
+		return err
+	}
+	return c.JSON(http.StatusOK, map[string]interface{}{"debt": debt, "repayments": repayments})
+}
+
+func (h *Handlers) CreateDebt(c echo.Context) error {
+	user, err := h.resolvePathUser(c)
+	if err != nil {
+		return h.handleUserAccessError(c, err)
+	}
+	if !canManageReferenceData(user) {
+		return c.JSON(http.StatusForbidden, map[string]string{"error": "only administrative users can manage debts"})
+	}
+
+	var req DebtRequest
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "invalid payload"})
+	}
+	req.Currency = strings.ToUpper(strings.TrimSpace(req.Currency))
+	if req.Currency == "" {
+		req.Currency = user.CurrencyDefault
+	}
+	dueDate, err := sanitizeDebtRequest(&req)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+	}
+	enabled, err := h.currencyEnabled(c.Request().Context(), user.FamilyID, req.Currency)
+	if err != nil {
+		return err
+	}
+	if !enabled {
+		return currencyNotEnabled(c, req.Currency)
+	}
+
+	now := time.Now().UTC()
+	debt := &domain.Debt{
+		ID:           uuid.NewString(),
+		FamilyID:     user.FamilyID,
+		Counterparty: req.Counterparty,
+		Direction:    req.Direction,
+		AmountMinor:  req.AmountMinor,
+		Currency:     req.Currency,
+		DueDate:      dueDate,
+		Notes:        req.Notes,
+		CreatedAt:    now,
+		UpdatedAt:    now,
+	}
+	if err := h.store.CreateDebt(c.Request().Context(), debt); err != nil {
+		return err
+	}
+
+	created, err := h.store.GetDebt(c.Request().Context(), debt.ID)
+	if err != nil {
+		return err
+	}
+	if created == nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "debt not found after create"})
+	}
+	return c.JSON(http.StatusCreated, debtResponse{Debt: *created})
+}
+
+func (h *Handlers) UpdateDebt(c echo.Context) error {
+	user, err := h.resolvePathUser(c)
+	if err != nil {
+		return h.handleUserAccessError(c, err)
+	}
+	if !canManageReferenceData(user) {
+		return c.JSON(http.StatusForbidden, map[string]string{"error": "only administrative users can manage debts"})
+	}
+
+	debt, err := h.store.GetDebt(c.Request().Context(), c.Param("debtId"))
+	if err != nil {
+		return err
+	}
+	if debt == nil || debt.FamilyID != user.FamilyID {
+		return c.JSON(http.StatusNotFound, map[string]string{"error": "debt not found"})
+	}
+
+	var req DebtRequest
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "invalid payload"})
+	}
+	req.Currency = strings.ToUpper(strings.TrimSpace(req.Currency))
+	if req.Currency == "" {
+		req.Currency = debt.Currency
+	}
+	if strings.TrimSpace(req.Direction) == "" {
+		req.Direction = debt.Direction
+	}
+	if req.Currency != debt.Currency || strings.ToLower(strings.TrimSpace(req.Direction)) != debt.Direction {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "debt currency and direction cannot be changed"})
+	}
+	dueDate, err := sanitizeDebtRequest(&req)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+	}
+	if req.AmountMinor < debt.RepaidMinor {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "amount_minor cannot be lower than the repaid amount"})
+	}
+
+	dueDateChanged := !sameOptionalTime(debt.DueDate, dueDate)
+	now := time.Now().UTC()
+	debt.Counterparty = req.Counterparty
+	debt.AmountMinor = req.AmountMinor
+	debt.DueDate = dueDate
+	debt.Notes = req.Notes
+	debt.UpdatedAt = now
+	switch {
+	case req.Closed != nil && *req.Closed && debt.ClosedAt == nil:
+		debt.ClosedAt = &now
+	case req.Closed != nil && !*req.Closed:
+		debt.ClosedAt = nil
+	case req.AmountMinor == debt.RepaidMinor && debt.ClosedAt == nil:
+		debt.ClosedAt = &now
+	case req.AmountMinor > debt.RepaidMinor && req.Closed == nil:
+		debt.ClosedAt = nil
+	}
+
+	if err := h.store.UpdateDebt(c.Request().Context(), debt, dueDateChanged); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return c.JSON(http.StatusNotFound, map[string]string{"error": "debt not found"})
+		}
+		return err
+	}
+
+	updated, err := h.store.GetDebt(c.Request().Context(), debt.ID)
+	if err != nil {
+		return err
+	}
+	if updated == nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "debt not found after update"})
+	}
+	return c.JSON(http.StatusOK, debtResponse{Debt: *updated})
+}
+
+func (h *Handlers) AddDebtRepayment(c echo.Context) error {
+	user, err := h.resolvePathUser(c)
+	if err != nil {
+		return h.handleUserAccessError(c, err)
+	}
+
+	debt, err := h.store.GetDebt(c.Request().Context(), c.Param("debtId"))
+	if err != nil {
+		return err
+	}
+	if debt == nil || debt.FamilyID != user.FamilyID {
+		return c.JSON(http.StatusNotFound, map[string]string{"error": "debt not found"})
+	}
+	if debt.ClosedAt != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "debt is closed"})
+	}
+
+	var req DebtRepaymentRequest
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "invalid payload"})
+	}
+
+	// Money flows out when we pay our debt and comes in when we are paid back.
+	expectedType := "income"
+	if debt.Direction == store.DebtDirectionWeOwe {
+		expectedType = "expense"
+	}
+
+	now := time.Now().UTC()
+	repayment := &domain.DebtRepayment{
+		ID:        uuid.NewString(),
+		DebtID:    debt.ID,
+		FamilyID:  debt.FamilyID,
+		UserID:    user.ID,
+		CreatedAt: now,
+	}
+	var txn *domain.Transaction
+
+	if trimmed := strings.TrimSpace(req.TransactionID); trimmed != "" {
+		existing, err := h.store.GetTransaction(c.Request().Context(), trimmed)
+		if err != nil {
+			return err
+		}
+		if existing == nil || existing.FamilyID != user.FamilyID {
+			return c.JSON(http.StatusBadRequest, map[string]string{"error": "transaction not found"})
+		}
+		if existing.Currency != debt.Currency {
+			return c.JSON(http.StatusBadRequest, map[string]string{"error": "transaction currency must match debt currency"})
+		}
+		if existing.Type != expectedType {
+			return c.JSON(http.StatusBadRequest, map[string]string{"error": "transaction must be of type " + expectedType})
+		}
+		repayment.TransactionID = existing.ID
+		repayment.AmountMinor = existing.AmountMinor
+	} else {
+		if req.AccountID == "" || req.CategoryID == "" || req.AmountMinor <= 0 {
+			return c.JSON(http.StatusBadRequest, map[string]string{"error": "transaction_id or account_id, category_id and positive amount_minor are required"})
+		}
+		account, err := h.store.GetAccount(c.Request().Context(), req.AccountID)
+		if err != nil {
+			return err
+		}
+		if account == nil || account.FamilyID != user.FamilyID {
+			return c.JSON(http.StatusBadRequest, map[string]string{"error": "account not found"})
+		}
+		if account.IsArchived {
+			return c.JSON(http.StatusBadRequest, map[string]string{"error": "account is archived"})
+		}
+		if !account.Holds(debt.Currency) {
+			return c.JSON(http.StatusBadRequest, map[string]string{"error": "account currency must match debt currency"})
+		}
+		category, err := h.store.GetCategory(c.Request().Context(), req.CategoryID)
+		if err != nil {
+			return err
+		}
+		if category == nil || category.FamilyID != user.FamilyID {
+			return c.JSON(http.StatusBadRequest, map[string]string{"error": "category not found"})
+		}
+		if category.IsArchived {
+			return c.JSON(http.StatusBadRequest, map[string]string{"error": "category is archived"})
+		}
+		if strings.ToLower(category.Type) != expectedType {
+			return c.JSON(http.StatusBadRequest, map[string]string{"error": "category type mismatch"})
+		}
+
+		occurredAt := now
+		if trimmed := strings.TrimSpace(req.OccurredAt); trimmed != "" {
+			parsed, err := time.Parse(time.RFC3339, trimmed)
+			if err != nil {
+				return c.JSON(http.StatusBadRequest, map[string]string{"error": "occurred_at must be RFC3339"})
+			}
+			occurredAt = parsed.UTC()
+		}
+		comment := strings.TrimSpace(req.Comment)
+		if comment == "" {
+			comment = debt.Counterparty
+		}
+
+		txn = &domain.Transaction{
+			ID:          uuid.NewString(),
+			FamilyID:    user.FamilyID,
+			UserID:      user.ID,
+			AccountID:   account.ID,
+			CategoryID:  category.ID,
+			Type:        expectedType,
+			AmountMinor: req.AmountMinor,
+			Currency:    debt.Currency,
+			Comment:     comment,
+			OccurredAt:  occurredAt,
+			CreatedAt:   now,
+			UpdatedAt:   now,
+		}
+		repayment.AmountMinor = req.AmountMinor
+	}
+
+	if err := h.store.AddDebtRepayment(c.Request().Context(), repayment, txn); err != nil {
+		switch {
+		case errors.Is(err, store.ErrRepaymentExceedsDebt):
+			return c.JSON(http.StatusBadRequest, map[string]string{"error": "repayment exceeds outstanding amount"})
+		case errors.Is(err, store.ErrTransactionLinked):
+			return c.JSON(http.StatusConflict, map[string]string{"error": "transaction is already linked to a debt"})
+		case errors.Is(err, store.ErrDebtClosed):
+			return c.JSON(http.StatusBadRequest, map[string]string{"error": "debt is closed"})
+		case errors.Is(err, store.ErrAccountArchived):
+			return c.JSON(http.StatusBadRequest, map[string]string{"error": "account is archived"})
+		case errors.Is(err, sql.ErrNoRows):
+			return c.JSON(http.StatusBadRequest, map[string]string{"error": "account not found"})
+		}
+		return err
+	}
+
+	updated, err := h.store.GetDebt(c.Request().Context(), debt.ID)
+	if err != nil {
+		return err
+	}
+	if updated == nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "debt not found after update"})
+	}
+	response := map[string]interface{}{"debt": updated, "repayment": repayment}
+	if txn != nil {
+		response["transaction"] = domain.TransactionWithAuthor{Transaction: *txn, Author: domain.FamilyMember{ID: user.ID, Name: user.Name, Email: user.Email, Role: user.Role}}
+	}
+	return c.JSON(http.StatusCreated, response)
+}
+
+func sanitizeDebtRequest(req *DebtRequest) (*time.Time, error) {
+	req.Counterparty = strings.TrimSpace(req.Counterparty)
+	req.Direction = strings.ToLower(strings.TrimSpace(req.Direction))
+	req.Notes = strings.TrimSpace(req.Notes)
+
+	if req.Counterparty == "" {
+		return nil, errors.New("counterparty is required")
+	}
+	if req.Direction != store.DebtDirectionWeOwe && req.Direction != store.DebtDirectionTheyOwe {
+		return nil, errors.New("direction must be we_owe or they_owe")
+	}
+	if req.AmountMinor <= 0 {
+		return nil, errors.New("amount_minor must be positive")
+	}
+	if !isSupportedCurrency(req.Currency) {
+		return nil, errors.New("unsupported currency")
+	}
+	dueDate, err := parseOptionalTime(req.DueDate)
+	if err != nil {
+		return nil, errors.New("due_date must be RFC3339")
+	}
+	return dueDate, nil
+}
+
+func sameOptionalTime(a, b *time.Time) bool {
+	if a == nil || b == nil {
+		return a == nil && b == nil
+	}
+	return a.Equal(*b)
+}
@@ -0,0 +1,291 @@
+package http
+
+import (
+	"database/sql"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"
+
+	"familybudget/internal/domain"
+	"familybudget/internal/store"
+)
+
+type EnvelopeRequest struct {
+	Name              string                       `json:"name"`
+	Currency          string                       `json:"currency"`
+	TargetAmountMinor int64                        `json:"target_amount_minor"`
+	AutoFillRule      *domain.EnvelopeAutoFillRule `json:"auto_fill_rule"`
+	Archived          *bool                        `json:"archived"`
+}
+
+type envelopeResponse struct {
+	Envelope domain.Envelope `json:"envelope"`
+}
+
+type allocateEnvelopeRequest struct {
+	AmountMinor int64 `json:"amount_minor"`
+}
+
+type moveEnvelopeFundsRequest struct {
+	FromEnvelopeID string `json:"from_envelope_id"`
+	ToEnvelopeID   string `json:"to_envelope_id"`
+	AmountMinor    int64  `json:"amount_minor"`
+}
+
+func (h *Handlers) ListEnvelopes(c echo.Context) error {
+	user, err := h.resolvePathUser(c)
+	if err != nil {
+		return h.handleUserAccessError(c, err)
+	}
+	overview, err := h.envelopeOverview(c, user.FamilyID)
+	if err != nil {
+		return err
+	}
+	return c.JSON(http.StatusOK, overview)
+}
+
+func (h *Handlers) CreateEnvelope(c echo.Context) error {
+	user, err := h.resolvePathUser(c)
+	if err != nil {
+		return h.handleUserAccessError(c, err)
+	}
+	if !canManageReferenceData(user) {
+		return c.JSON(http.StatusForbidden, map[string]string{"error": "only administrative users can manage envelopes"})
+	}
+
+	var req EnvelopeRequest
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "invalid payload"})
+	}
+	req.Name = strings.TrimSpace(req.Name)
+	req.Currency = strings.ToUpper(strings.TrimSpace(req.Currency))
+	if req.Currency == "" {
+		req.Currency = user.CurrencyDefault
+	}
+	if err := validateEnvelopePayload(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+	}
+	enabled, err := h.currencyEnabled(c.Request().Context(), user.FamilyID, req.Currency)
+	if err != nil {
+		return err
+	}
+	if !enabled {
+		return currencyNotEnabled(c, req.Currency)
+	}
+
+	now := time.Now().UTC()
+	envelope := &domain.Envelope{
+		ID:                uuid.NewString(),
+		FamilyID:          user.FamilyID,
+		Name:              req.Name,
+		Currency:          req.Currency,
+		TargetAmountMinor: req.TargetAmountMinor,
+		AutoFillRule:      req.AutoFillRule,
+		IsArchived:        false,
+		CreatedAt:         now,
+		UpdatedAt:         now,
+	}
+	if err := h.store.CreateEnvelope(c.Request().Context(), envelope); err != nil {
+		return err
+	}
+	return c.JSON(http.StatusCreated, envelopeResponse{Envelope: *envelope})
+}
+
+func (h *Handlers) UpdateEnvelope(c echo.Context) error {
+	envelopeID := c.Param("envelopeId")
+
+	user, err := h.resolvePathUser(c)
+	if err != nil {
+		return h.handleUserAccessError(c, err)
+	}
+	if !canManageReferenceData(user) {
+		return c.JSON(http.StatusForbidden, map[string]string{"error": "only administrative users can manage envelopes"})
+	}
+
+	envelope, err := h.store.GetEnvelope(c.Request().Context(), envelopeID)
+	if err != nil {
+		return err
+	}
+	if envelope == nil || envelope.FamilyID != user.FamilyID {
+		return c.JSON(http.StatusNotFound, map[string]string{"error": "envelope not found"})
+	}
+
+	var req EnvelopeRequest
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "invalid payload"})
+	}
+	req.Name = strings.TrimSpace(req.Name)
+	req.Currency = strings.ToUpper(strings.TrimSpace(req.Currency))
+	if req.Currency == "" {
+		req.Currency = envelope.Currency
+	}
+	if req.Currency != envelope.Currency {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "envelope currency cannot be changed"})
+	}
+	if err := validateEnvelopePayload(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+	}
+
+	envelope.Name = req.Name
+	envelope.TargetAmountMinor = req.TargetAmountMinor
+	envelope.AutoFillRule = req.AutoFillRule
+	if req.Archived != nil {
+		envelope.IsArchived = *req.Archived
+	}
+	envelope.UpdatedAt = time.Now().UTC()
+
+	if err := h.store.UpdateEnvelope(c.Request().Context(), envelope); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return c.JSON(http.StatusNotFound, map[string]string{"error": "envelope not found"})
+		}
+		return err
+	}
+	return c.JSON(http.StatusOK, envelopeResponse{Envelope: *envelope})
+}
+
+func (h *Handlers) AllocateEnvelope(c echo.Context) error {
+	envelopeID := c.Param("envelopeId")
+
+	user, err := h.resolvePathUser(c)
+	if err != nil {
+		return h.handleUserAccessError(c, err)
+	}
+	if !canManageReferenceData(user) {
+		return c.JSON(http.StatusForbidden, map[string]string{"error": "only administrative users can manage envelopes"})
+	}
+
+	var req allocateEnvelopeRequest
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "invalid payload"})
+	}
+	if req.AmountMinor == 0 {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "amount_minor must not be zero"})
+	}
+
+	if err := h.store.AllocateToEnvelope(c.Request().Context(), user.FamilyID, user.ID, envelopeID, req.AmountMinor); err != nil {
+		return h.handleEnvelopeError(c, err)
+	}
+
+	overview, err := h.envelopeOverview(c, user.FamilyID)
+	if err != nil {
+		return err
+	}
+	return c.JSON(http.StatusOK, overview)
+}
+
+func (h *Handlers) MoveEnvelopeFunds(c echo.Context) error {
+	user, err := h.resolvePathUser(c)
+	if err != nil {
+		return h.handleUserAccessError(c, err)
+	}
+	if !canManageReferenceData(user) {
+		return c.JSON(http.StatusForbidden, map[string]string{"error": "only administrative users can manage envelopes"})
+	}
+
+	var req moveEnvelopeFundsRequest
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "invalid payload"})
+	}
+	req.FromEnvelopeID = strings.TrimSpace(req.FromEnvelopeID)
+	req.ToEnvelopeID = strings.TrimSpace(req.ToEnvelopeID)
+	if req.FromEnvelopeID == "" || req.ToEnvelopeID == "" || req.AmountMinor <= 0 {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "from_envelope_id, to_envelope_id and positive amount_minor are required"})
+	}
+	if req.FromEnvelopeID == req.ToEnvelopeID {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "envelopes must differ"})
+	}
+
+	if err := h.store.MoveBetweenEnvelopes(c.Request().Context(), user.FamilyID, user.ID, req.FromEnvelopeID, req.ToEnvelopeID, req.AmountMinor); err != nil {
+		return h.handleEnvelopeError(c, err)
+	}
+
+	overview, err := h.envelopeOverview(c, user.FamilyID)
+	if err != nil {
+		return err
+	}
+	return c.JSON(http.StatusOK, overview)
+}
+
+func (h *Handlers) ListEnvelopeMovements(c echo.Context) error {
+	envelopeID := c.Param("envelopeId")
+
+	user, err := h.resolvePathUser(c)
+	if err != nil {
+		return h.handleUserAccessError(c, err)
+	}
+
+	envelope, err := h.store.GetEnvelope(c.Request().Context(), envelopeID)
+	if err != nil {
+		return err
+	}
+	if envelope == nil || envelope.FamilyID != user.FamilyID {
+		return c.JSON(http.StatusNotFound, map[string]string{"error": "envelope not found"})
+	}
+
+	movements, err := h.store.ListEnvelopeMovements(c.Request().Context(), envelope.ID)
+	if err != nil {
+		return err
+	}
+	return c.JSON(http.StatusOK, map[string]interface{}{"envelope": envelope, "movements": movements})
+}
+
+func (h *Handlers) envelopeOverview(c echo.Context, familyID string) (domain.EnvelopeOverview, error) {
+	envelopes, err := h.store.ListEnvelopesByFamily(c.Request().Context(), familyID)
+	if err != nil {
+		return domain.EnvelopeOverview{}, err
+	}
+	unassigned, err := h.store.ListUnassignedByFamily(c.Request().Context(), familyID)
+	if err != nil {
+		return domain.EnvelopeOverview{}, err
+	}
+	return domain.EnvelopeOverview{Envelopes: envelopes, Unassigned: unassigned}, nil
+}
+
+func (h *Handlers) handleEnvelopeError(c echo.Context, err error) error {
+	switch {
+	case errors.Is(err, store.ErrEnvelopeNotFound):
+		return c.JSON(http.StatusNotFound, map[string]string{"error": "envelope not found"})
+	case errors.Is(err, store.ErrEnvelopeArchived):
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "envelope is archived"})
+	case errors.Is(err, store.ErrInsufficientFunds):
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "insufficient funds"})
+	case errors.Is(err, store.ErrCurrencyMismatch):
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "envelope currencies must match"})
+	default:
+		return err
+	}
+}
+
+func validateEnvelopePayload(req *EnvelopeRequest) error {
+	if req.Name == "" {
+		return errors.New("name is required")
+	}
+	if !isSupportedCurrency(req.Currency) {
+		return errors.New("unsupported currency")
+	}
+	if req.TargetAmountMinor < 0 {
+		return errors.New("target_amount_minor must not be negative")
+	}
+	if rule := req.AutoFillRule; rule != nil {
+		rule.Mode = strings.ToLower(strings.TrimSpace(rule.Mode))
+		switch rule.Mode {
+		case "fixed":
+			if rule.AmountMinor <= 0 {
+				return errors.New("auto_fill_rule.amount_minor must be positive")
+			}
+			rule.Percent = 0
+		case "percent":
+			if rule.Percent <= 0 || rule.Percent > 100 {
+				return errors.New("auto_fill_rule.percent must be between 1 and 100")
+			}
+			rule.AmountMinor = 0
+		default:
+			return errors.New("auto_fill_rule.mode must be fixed or percent")
+		}
+	}
+	return nil
+}
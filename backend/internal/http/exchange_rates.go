@@ -0,0 +1,213 @@
+package http
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/labstack/echo/v4"
+
+	"familybudget/internal/domain"
+	"familybudget/internal/store"
+)
+
+// maxListedExchangeRates caps GET /exchange-rates; narrow the dates to see
+// older rates.
+const maxListedExchangeRates = 500
+
+// ExchangeRateRequest enters a rate by hand: one unit of base costs rate
+// units of quote on as_of (YYYY-MM-DD, today in the family time zone by
+// default). Manual rates are kept when providers publish the same pair and
+// date.
+type ExchangeRateRequest struct {
+	Base  string  `json:"base"`
+	Quote string  `json:"quote"`
+	Rate  float64 `json:"rate"`
+	AsOf  string  `json:"as_of"`
+}
+
+// isCurrencyCode accepts any three-letter code, so that manual rates can
+// cover currencies no provider publishes.
+func isCurrencyCode(code string) bool {
+	if len(code) != 3 {
+		return false
+	}
+	for _, r := range code {
+		if r < 'A' || r > 'Z' {
+			return false
+		}
+	}
+	return true
+}
+
+// ListExchangeRates returns stored rates filtered by ?base=, ?quote=,
+// ?source= and the inclusive ?from= and ?to= dates. Administrative users
+// also get warnings about missing and stale rates of the family currencies.
+func (h *Handlers) ListExchangeRates(c echo.Context) error {
+	user, err := h.resolvePathUser(c)
+	if err != nil {
+		return h.handleUserAccessError(c, err)
+	}
+	ctx := c.Request().Context()
+
+	filter := store.ExchangeRateFilter{
+		Base:   strings.ToUpper(strings.TrimSpace(c.QueryParam("base"))),
+		Quote:  strings.ToUpper(strings.TrimSpace(c.QueryParam("quote"))),
+		Source: strings.TrimSpace(c.QueryParam("source")),
+		From:   strings.TrimSpace(c.QueryParam("from")),
+		To:     strings.TrimSpace(c.QueryParam("to")),
+		Limit:  maxListedExchangeRates,
+	}
+	if _, err := time.Parse("2006-01-02", filter.From); filter.From != "" && err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "from must be a date (YYYY-MM-DD)"})
+	}
+	if _, err := time.Parse("2006-01-02", filter.To); filter.To != "" && err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "to must be a date (YYYY-MM-DD)"})
+	}
+
+	rates, err := h.store.ListExchangeRates(ctx, filter)
+	if err != nil {
+		return err
+	}
+	if rates == nil {
+		rates = []domain.ExchangeRate{}
+	}
+	response := map[string]interface{}{"rates": rates}
+	if isAdministrativeRole(user.Role) {
+		loc, err := h.store.FamilyLocation(ctx, user.FamilyID)
+		if err != nil {
+			return err
+		}
+		warnings, err := h.store.StaleExchangeRates(ctx, user.FamilyID, time.Now(), loc)
+		if err != nil {
+			return err
+		}
+		if warnings == nil {
+			warnings = []domain.StaleRateWarning{}
+		}
+		response["warnings"] = warnings
+	}
+	return c.JSON(http.StatusOK, response)
+}
+
+// LookupExchangeRate returns the rate converting ?from= into ?to= (the family
+// currency by default) on ?date=, falling back to the nearest earlier rate.
+func (h *Handlers) LookupExchangeRate(c echo.Context) error {
+	user, err := h.resolvePathUser(c)
+	if err != nil {
+		return h.handleUserAccessError(c, err)
+	}
+	ctx := c.Request().Context()
+
+	from := strings.ToUpper(strings.TrimSpace(c.QueryParam("from")))
+	to := strings.ToUpper(strings.TrimSpace(c.QueryParam("to")))
+	if to == "" {
+		family, err := h.store.GetFamily(ctx, user.FamilyID)
+		if err != nil {
+			return err
+		}
+		if family == nil {
+			return c.JSON(http.StatusNotFound, map[string]string{"error": "family not found"})
+		}
+		to = strings.ToUpper(family.CurrencyBase)
+	}
+	if !isCurrencyCode(from) || !isCurrencyCode(to) {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "from and to must be three-letter currency codes"})
+	}
+	date := strings.TrimSpace(c.QueryParam("date"))
+	if date == "" {
+		loc, err := h.store.FamilyLocation(ctx, user.FamilyID)
+		if err != nil {
+			return err
+		}
+		date = time.Now().In(loc).Format("2006-01-02")
+	} else if _, err := time.Parse("2006-01-02", date); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "date must be a date (YYYY-MM-DD)"})
+	}
+
+	rate, err := h.store.LookupExchangeRate(ctx, from, to, date)
+	if err != nil {
+		return err
+	}
+	if rate == nil {
+		return c.JSON(http.StatusNotFound, map[string]string{"error": "no exchange rate from " + from + " to " + to + " on or before " + date})
+	}
+	return c.JSON(http.StatusOK, map[string]interface{}{"rate": rate})
+}
+
+// CreateExchangeRate stores a manual rate, replacing any rate of the same
+// pair and date.
+func (h *Handlers) CreateExchangeRate(c echo.Context) error {
+	user, err := h.resolvePathUser(c)
+	if err != nil {
+		return h.handleUserAccessError(c, err)
+	}
+	if !canManageReferenceData(user) {
+		return c.JSON(http.StatusForbidden, map[string]string{"error": "only owner or adult can enter exchange rates"})
+	}
+	ctx := c.Request().Context()
+
+	var req ExchangeRateRequest
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "invalid payload"})
+	}
+	rate := &domain.ExchangeRate{
+		Base:      strings.ToUpper(strings.TrimSpace(req.Base)),
+		Quote:     strings.ToUpper(strings.TrimSpace(req.Quote)),
+		Rate:      req.Rate,
+		AsOf:      strings.TrimSpace(req.AsOf),
+		Source:    store.ExchangeRateSourceManual,
+		CreatedAt: time.Now().UTC(),
+	}
+	if !isCurrencyCode(rate.Base) || !isCurrencyCode(rate.Quote) {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "base and quote must be three-letter currency codes"})
+	}
+	if rate.Base == rate.Quote {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "base and quote must differ"})
+	}
+	if rate.Rate <= 0 {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "rate must be positive"})
+	}
+	if rate.AsOf == "" {
+		loc, err := h.store.FamilyLocation(ctx, user.FamilyID)
+		if err != nil {
+			return err
+		}
+		rate.AsOf = time.Now().In(loc).Format("2006-01-02")
+	} else if _, err := time.Parse("2006-01-02", rate.AsOf); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "as_of must be a date (YYYY-MM-DD)"})
+	}
+
+	if _, err := h.store.SaveExchangeRate(ctx, rate); err != nil {
+		return err
+	}
+	saved, err := h.store.ExchangeRateOn(ctx, rate.Base, rate.Quote, rate.AsOf)
+	if err != nil {
+		return err
+	}
+	return c.JSON(http.StatusCreated, map[string]interface{}{"rate": saved})
+}
+
+// DeleteExchangeRate removes a manual rate; provider rates cannot be deleted.
+func (h *Handlers) DeleteExchangeRate(c echo.Context) error {
+	user, err := h.resolvePathUser(c)
+	if err != nil {
+		return h.handleUserAccessError(c, err)
+	}
+	if !canManageReferenceData(user) {
+		return c.JSON(http.StatusForbidden, map[string]string{"error": "only owner or adult can delete exchange rates"})
+	}
+
+	deleted, err := h.store.DeleteExchangeRate(c.Request().Context(), c.Param("rateId"))
+	if err != nil {
+		if errors.Is(err, store.ErrExchangeRateNotManual) {
+			return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+		}
+		return err
+	}
+	if !deleted {
+		return c.JSON(http.StatusNotFound, map[string]string{"error": "exchange rate not found"})
+	}
+	return c.NoContent(http.StatusNoContent)
+}
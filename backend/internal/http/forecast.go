@@ -0,0 +1,79 @@
+package http
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/labstack/echo/v4"
+
+	"familybudget/internal/store"
+)
+
+const (
+	defaultBaselineDays = 90
+	maxForecastDays     = 731
+)
+
+// Forecast projects daily account balances up to ?until= (YYYY-MM-DD or
+// RFC3339) from current balances and pending planned operations. ?baseline=true
+// or ?baseline_days=N adds the average daily spending of the past N days.
+func (h *Handlers) Forecast(c echo.Context) error {
+	user, err := h.resolvePathUser(c)
+	if err != nil {
+		return h.handleUserAccessError(c, err)
+	}
+	ctx := c.Request().Context()
+
+	loc, err := h.store.FamilyLocation(ctx, user.FamilyID)
+	if err != nil {
+		return err
+	}
+	now := time.Now().In(loc)
+
+	value := strings.TrimSpace(c.QueryParam("until"))
+	if value == "" {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "until is required"})
+	}
+	until, err := time.ParseInLocation("2006-01-02", value, loc)
+	if err != nil {
+		if until, err = time.Parse(time.RFC3339, value); err != nil {
+			return c.JSON(http.StatusBadRequest, map[string]string{"error": "until must be a date (YYYY-MM-DD) or RFC3339"})
+		}
+	}
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, loc)
+	if until.Before(today) {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "until must not be in the past"})
+	}
+	if until.After(today.AddDate(0, 0, maxForecastDays)) {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "until must be within " + strconv.Itoa(maxForecastDays) + " days"})
+	}
+
+	baselineDays := 0
+	if raw := strings.TrimSpace(c.QueryParam("baseline_days")); raw != "" {
+		baselineDays, err = strconv.Atoi(raw)
+		if err != nil || baselineDays < 0 || baselineDays > 366 {
+			return c.JSON(http.StatusBadRequest, map[string]string{"error": "baseline_days must be between 0 and 366"})
+		}
+	} else if raw := strings.TrimSpace(c.QueryParam("baseline")); raw != "" {
+		enabled, err := strconv.ParseBool(raw)
+		if err != nil {
+			return c.JSON(http.StatusBadRequest, map[string]string{"error": "baseline must be true or false"})
+		}
+		if enabled {
+			baselineDays = defaultBaselineDays
+		}
+	}
+
+	forecast, err := h.store.Forecast(ctx, user.FamilyID, store.ForecastOptions{
+		Now:          now,
+		Until:        until,
+		Location:     loc,
+		BaselineDays: baselineDays,
+	})
+	if err != nil {
+		return err
+	}
+	return c.JSON(http.StatusOK, forecast)
+}
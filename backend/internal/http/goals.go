@@ -0,0 +1,350 @@
+package http
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"
+
+	"familybudget/internal/domain"
+	"familybudget/internal/store"
+)
+
+type GoalRequest struct {
+	Name              string   `json:"name"`
+	Currency          string   `json:"currency"`
+	TargetAmountMinor int64    `json:"target_amount_minor"`
+	DueDate           string   `json:"due_date"`
+	Priority          int      `json:"priority"`
+	FundingMode       string   `json:"funding_mode"`
+	AccountIDs        []string `json:"account_ids"`
+	Archived          *bool    `json:"archived"`
+}
+
+type goalResponse struct {
+	Goal domain.Goal `json:"goal"`
+}
+
+type GoalContributionRequest struct {
+	AmountMinor   int64  `json:"amount_minor"`
+	Comment       string `json:"comment"`
+	ContributedAt string `json:"contributed_at"`
+}
+
+var errGoalAccountInvalid = errors.New("linked accounts must belong to the family and use the goal currency")
+
+func (h *Handlers) ListGoals(c echo.Context) error {
+	user, err := h.resolvePathUser(c)
+	if err != nil {
+		return h.handleUserAccessError(c, err)
+	}
+	goals, err := h.store.ListGoalsByFamily(c.Request().Context(), user.FamilyID)
+	if err != nil {
+		return err
+	}
+	return c.JSON(http.StatusOK, map[string]interface{}{"goals": goals})
+}
+
+func (h *Handlers) GetGoal(c echo.Context) error {
+	user, err := h.resolvePathUser(c)
+	if err != nil {
+		return h.handleUserAccessError(c, err)
+	}
+	goal, err := h.store.GetGoal(c.Request().Context(), c.Param("goalId"))
+	if err != nil {
+		return err
+	}
+	if goal == nil || goal.FamilyID != user.FamilyID {
+		return c.JSON(http.StatusNotFound, map[string]string{"error": "goal not found"})
+	}
+	contributions, err := h.store.ListGoalContributions(c.Request().Context(), goal.ID)
+	if err != nil {
+		return err
+	}
+	return c.JSON(http.StatusOK, map[string]interface{}{"goal": goal, "contributions": contributions})
+}
+
+func (h *Handlers) CreateGoal(c echo.Context) error {
+	user, err := h.resolvePathUser(c)
+	if err != nil {
+		return h.handleUserAccessError(c, err)
+	}
+	if !canManageReferenceData(user) {
+		return c.JSON(http.StatusForbidden, map[string]string{"error": "only administrative users can manage goals"})
+	}
+
+	var req GoalRequest
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "invalid payload"})
+	}
+	req.Currency = strings.ToUpper(strings.TrimSpace(req.Currency))
+	if req.Currency == "" {
+		req.Currency = user.CurrencyDefault
+	}
+	dueDate, err := sanitizeGoalRequest(&req)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+	}
+	enabled, err := h.currencyEnabled(c.Request().Context(), user.FamilyID, req.Currency)
+	if err != nil {
+		return err
+	}
+	if !enabled {
+		return currencyNotEnabled(c, req.Currency)
+	}
+	if err := h.ensureGoalAccounts(c.Request().Context(), user.FamilyID, req.Currency, req.AccountIDs); err != nil {
+		if errors.Is(err, errGoalAccountInvalid) {
+			return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+		}
+		return err
+	}
+
+	now := time.Now().UTC()
+	goal := &domain.Goal{
+		ID:                uuid.NewString(),
+		FamilyID:          user.FamilyID,
+		Name:              req.Name,
+		Currency:          req.Currency,
+		TargetAmountMinor: req.TargetAmountMinor,
+		DueDate:           dueDate,
+		Priority:          req.Priority,
+		FundingMode:       req.FundingMode,
+		AccountIDs:        req.AccountIDs,
+		CreatedAt:         now,
+		UpdatedAt:         now,
+	}
+	if err := h.store.CreateGoal(c.Request().Context(), goal); err != nil {
+		return err
+	}
+
+	created, err := h.store.GetGoal(c.Request().Context(), goal.ID)
+	if err != nil {
+		return err
+	}
+	if created == nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "goal not found after create"})
+	}
+	return c.JSON(http.StatusCreated, goalResponse{Goal: *created})
+}
+
+func (h *Handlers) UpdateGoal(c echo.Context) error {
+	user, err := h.resolvePathUser(c)
+	if err != nil {
+		return h.handleUserAccessError(c, err)
+	}
+	if !canManageReferenceData(user) {
+		return c.JSON(http.StatusForbidden, map[string]string{"error": "only administrative users can manage goals"})
+	}
+
+	goal, err := h.store.GetGoal(c.Request().Context(), c.Param("goalId"))
+	if err != nil {
+		return err
+	}
+	if goal == nil || goal.FamilyID != user.FamilyID {
+		return c.JSON(http.StatusNotFound, map[string]string{"error": "goal not found"})
+	}
+
+	var req GoalRequest
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "invalid payload"})
+	}
+	req.Currency = strings.ToUpper(strings.TrimSpace(req.Currency))
+	if req.Currency == "" {
+		req.Currency = goal.Currency
+	}
+	if req.Currency != goal.Currency {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "goal currency cannot be changed"})
+	}
+	dueDate, err := sanitizeGoalRequest(&req)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+	}
+	if err := h.ensureGoalAccounts(c.Request().Context(), user.FamilyID, goal.Currency, req.AccountIDs); err != nil {
+		if errors.Is(err, errGoalAccountInvalid) {
+			return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+		}
+		return err
+	}
+
+	// Raising the target above what is already saved reopens a completed goal.
+	if req.TargetAmountMinor > goal.TargetAmountMinor && goal.Progress.SavedMinor < req.TargetAmountMinor {
+		goal.CompletedAt = nil
+	}
+	if req.FundingMode != goal.FundingMode {
+		goal.CompletedAt = nil
+	}
+	goal.Name = req.Name
+	goal.TargetAmountMinor = req.TargetAmountMinor
+	goal.DueDate = dueDate
+	goal.Priority = req.Priority
+	goal.FundingMode = req.FundingMode
+	goal.AccountIDs = req.AccountIDs
+	if req.Archived != nil {
+		goal.IsArchived = *req.Archived
+	}
+	goal.UpdatedAt = time.Now().UTC()
+
+	if err := h.store.UpdateGoal(c.Request().Context(), goal); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return c.JSON(http.StatusNotFound, map[string]string{"error": "goal not found"})
+		}
+		return err
+	}
+
+	updated, err := h.store.GetGoal(c.Request().Context(), goal.ID)
+	if err != nil {
+		return err
+	}
+	if updated == nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "goal not found after update"})
+	}
+	return c.JSON(http.StatusOK, goalResponse{Goal: *updated})
+}
+
+func (h *Handlers) AddGoalContribution(c echo.Context) error {
+	user, err := h.resolvePathUser(c)
+	if err != nil {
+		return h.handleUserAccessError(c, err)
+	}
+
+	goal, err := h.store.GetGoal(c.Request().Context(), c.Param("goalId"))
+	if err != nil {
+		return err
+	}
+	if goal == nil || goal.FamilyID != user.FamilyID {
+		return c.JSON(http.StatusNotFound, map[string]string{"error": "goal not found"})
+	}
+	if goal.IsArchived {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "goal is archived"})
+	}
+	if goal.FundingMode != store.GoalFundingContributions {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "goal is funded by linked accounts"})
+	}
+
+	var req GoalContributionRequest
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "invalid payload"})
+	}
+	if req.AmountMinor == 0 {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "amount_minor must not be zero"})
+	}
+
+	now := time.Now().UTC()
+	contributedAt := now
+	if trimmed := strings.TrimSpace(req.ContributedAt); trimmed != "" {
+		parsed, err := time.Parse(time.RFC3339, trimmed)
+		if err != nil {
+			return c.JSON(http.StatusBadRequest, map[string]string{"error": "contributed_at must be RFC3339"})
+		}
+		contributedAt = parsed.UTC()
+	}
+
+	contribution := &domain.GoalContribution{
+		ID:            uuid.NewString(),
+		GoalID:        goal.ID,
+		FamilyID:      goal.FamilyID,
+		UserID:        user.ID,
+		AmountMinor:   req.AmountMinor,
+		Comment:       strings.TrimSpace(req.Comment),
+		ContributedAt: contributedAt,
+		CreatedAt:     now,
+	}
+	if err := h.store.AddGoalContribution(c.Request().Context(), contribution); err != nil {
+		return err
+	}
+
+	updated, err := h.store.GetGoal(c.Request().Context(), goal.ID)
+	if err != nil {
+		return err
+	}
+	if updated == nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "goal not found after update"})
+	}
+	return c.JSON(http.StatusCreated, map[string]interface{}{"goal": updated, "contribution": contribution})
+}
+
+func (h *Handlers) ListEvents(c echo.Context) error {
+	user, err := h.resolvePathUser(c)
+	if err != nil {
+		return h.handleUserAccessError(c, err)
+	}
+	since, err := parseOptionalTime(c.QueryParam("since"))
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "since must be RFC3339"})
+	}
+	events, err := h.store.ListEvents(c.Request().Context(), user.FamilyID, since)
+	if err != nil {
+		return err
+	}
+	return c.JSON(http.StatusOK, map[string]interface{}{"events": events})
+}
+
+func sanitizeGoalRequest(req *GoalRequest) (*time.Time, error) {
+	req.Name = strings.TrimSpace(req.Name)
+	req.FundingMode = strings.ToLower(strings.TrimSpace(req.FundingMode))
+	if req.FundingMode == "" {
+		req.FundingMode = store.GoalFundingContributions
+		if len(req.AccountIDs) > 0 {
+			req.FundingMode = store.GoalFundingAccounts
+		}
+	}
+
+	if req.Name == "" {
+		return nil, errors.New("name is required")
+	}
+	if !isSupportedCurrency(req.Currency) {
+		return nil, errors.New("unsupported currency")
+	}
+	if req.TargetAmountMinor <= 0 {
+		return nil, errors.New("target_amount_minor must be positive")
+	}
+	switch req.FundingMode {
+	case store.GoalFundingAccounts:
+		if len(req.AccountIDs) == 0 {
+			return nil, errors.New("account_ids are required for account funded goals")
+		}
+	case store.GoalFundingContributions:
+		req.AccountIDs = nil
+	default:
+		return nil, errors.New("funding_mode must be accounts or contributions")
+	}
+
+	seen := make(map[string]struct{}, len(req.AccountIDs))
+	accountIDs := make([]string, 0, len(req.AccountIDs))
+	for _, id := range req.AccountIDs {
+		id = strings.TrimSpace(id)
+		if id == "" {
+			continue
+		}
+		if _, ok := seen[id]; ok {
+			continue
+		}
+		seen[id] = struct{}{}
+		accountIDs = append(accountIDs, id)
+	}
+	req.AccountIDs = accountIDs
+
+	dueDate, err := parseOptionalTime(req.DueDate)
+	if err != nil {
+		return nil, errors.New("due_date must be RFC3339")
+	}
+	return dueDate, nil
+}
+
+func (h *Handlers) ensureGoalAccounts(ctx context.Context, familyID, currency string, accountIDs []string) error {
+	for _, id := range accountIDs {
+		account, err := h.store.GetAccount(ctx, id)
+		if err != nil {
+			return err
+		}
+		if account == nil || account.FamilyID != familyID || account.Currency != currency {
+			return errGoalAccountInvalid
+		}
+	}
+	return nil
+}
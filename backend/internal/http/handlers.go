@@ -6,6 +6,7 @@ import (
 	"errors"
 	"fmt"
 	"net/http"
+	"strconv"
 	"strings"
 	"time"
 
@@ -14,6 +15,7 @@ import (
 	"golang.org/x/crypto/bcrypt"
 
 	"familybudget/internal/domain"
+	"familybudget/internal/recurrence"
 	"familybudget/internal/store"
 )
 
@@ -44,8 +46,6 @@ func buildFamilyScope(family *domain.Family) accessScope {
 	}
 }
 
-var supportedCurrencies = []string{"RUB", "USD", "EUR", "KZT", "BYN", "UAH", "GBP"}
-
 const currentUserContextKey = "currentUser"
 
 func isAdministrativeRole(role string) bool {
@@ -103,6 +103,7 @@ type familySettings struct {
 	ID           string `json:"id"`
 	Name         string `json:"name"`
 	CurrencyBase string `json:"currency_base"`
+	Timezone     string `json:"timezone"`
 }
 
 type userSettings struct {
@@ -114,6 +115,7 @@ type userSettings struct {
 
 type UpdateUserSettingsRequest struct {
 	FamilyCurrency string                 `json:"family_currency"`
+	FamilyTimezone string                 `json:"family_timezone"`
 	UserCurrency   string                 `json:"user_currency"`
 	Locale         string                 `json:"locale"`
 	Display        DisplaySettingsPayload `json:"display"`
@@ -135,15 +137,22 @@ type categoryResponse struct {
 	Category domain.Category `json:"category"`
 }
 
+// TransactionRequest records a transaction of amount_minor in currency. When
+// currency differs from the account currency, the amount charged to the
+// account is account_amount_minor or, without it, amount_minor at
+// exchange_rate (account currency units per unit of currency).
 type TransactionRequest struct {
-	UserID      string `json:"user_id"`
-	AccountID   string `json:"account_id"`
-	CategoryID  string `json:"category_id"`
-	Type        string `json:"type"`
-	AmountMinor int64  `json:"amount_minor"`
-	Currency    string `json:"currency"`
-	Comment     string `json:"comment"`
-	OccurredAt  string `json:"occurred_at"`
+	UserID             string   `json:"user_id"`
+	AccountID          string   `json:"account_id"`
+	CategoryID         string   `json:"category_id"`
+	EnvelopeID         string   `json:"envelope_id"`
+	Type               string   `json:"type"`
+	AmountMinor        int64    `json:"amount_minor"`
+	Currency           string   `json:"currency"`
+	AccountAmountMinor *int64   `json:"account_amount_minor"`
+	ExchangeRate       *float64 `json:"exchange_rate"`
+	Comment            string   `json:"comment"`
+	OccurredAt         string   `json:"occurred_at"`
 }
 
 type transactionResponse struct {
@@ -156,6 +165,9 @@ type AccountRequest struct {
 	Currency            string `json:"currency"`
 	InitialBalanceMinor int64  `json:"initial_balance_minor"`
 	Shared              *bool  `json:"shared"`
+	// MultiCurrency lets the account hold other currencies in pockets
+	// besides Currency.
+	MultiCurrency bool `json:"multi_currency"`
 }
 
 type accountResponse struct {
@@ -172,6 +184,7 @@ type PlannedOperationRequest struct {
 	Comment     string `json:"comment"`
 	DueAt       string `json:"due_at"`
 	Recurrence  string `json:"recurrence"`
+	AutoPost    bool   `json:"auto_post"`
 }
 
 type plannedOperationResponse struct {
@@ -276,6 +289,9 @@ func (h *Handlers) RegisterUser(c echo.Context) error {
 	creatingNewFamily := strings.TrimSpace(req.FamilyID) == ""
 
 	if creatingNewFamily {
+		if !isSupportedCurrency(req.Currency) {
+			return c.JSON(http.StatusBadRequest, map[string]string{"error": "unsupported currency"})
+		}
 		familyName := req.FamilyName
 		if strings.TrimSpace(familyName) == "" {
 			familyName = req.Name + " family"
@@ -411,13 +427,18 @@ func (h *Handlers) GetUserSettings(c echo.Context) error {
 	if err != nil {
 		return err
 	}
+	currencies, err := h.store.FamilyCurrencies(c.Request().Context(), family.ID)
+	if err != nil {
+		return err
+	}
 
 	return c.JSON(http.StatusOK, UserSettingsResponse{
-		SupportedCurrencies: supportedCurrencies,
+		SupportedCurrencies: currencies,
 		Family: familySettings{
 			ID:           family.ID,
 			Name:         family.Name,
 			CurrencyBase: family.CurrencyBase,
+			Timezone:     family.Timezone,
 		},
 		User: userSettings{
 			ID:              user.ID,
@@ -475,6 +496,24 @@ func (h *Handlers) UpdateUserSettings(c echo.Context) error {
 	if req.FamilyCurrency != "" && !isSupportedCurrency(req.FamilyCurrency) {
 		return c.JSON(http.StatusBadRequest, map[string]string{"error": "unsupported family currency"})
 	}
+	for _, code := range []string{req.UserCurrency, req.FamilyCurrency} {
+		if code == "" || code == user.CurrencyDefault || code == family.CurrencyBase {
+			continue
+		}
+		enabled, err := h.currencyEnabled(c.Request().Context(), family.ID, code)
+		if err != nil {
+			return err
+		}
+		if !enabled {
+			return currencyNotEnabled(c, code)
+		}
+	}
+	req.FamilyTimezone = strings.TrimSpace(req.FamilyTimezone)
+	if req.FamilyTimezone != "" {
+		if _, err := recurrence.LoadLocation(req.FamilyTimezone); err != nil {
+			return c.JSON(http.StatusBadRequest, map[string]string{"error": "unknown family time zone"})
+		}
+	}
 
 	display, err := normalizeDisplaySettings(req.Display)
 	if err != nil {
@@ -492,6 +531,17 @@ func (h *Handlers) UpdateUserSettings(c echo.Context) error {
 		family = updatedFamily
 	}
 
+	if req.FamilyTimezone != "" && req.FamilyTimezone != family.Timezone {
+		if current.Role != "owner" {
+			return c.JSON(http.StatusForbidden, map[string]string{"error": "only owner can change family time zone"})
+		}
+		updatedFamily, err := h.store.UpdateFamilyTimezone(c.Request().Context(), family.ID, req.FamilyTimezone)
+		if err != nil {
+			return err
+		}
+		family = updatedFamily
+	}
+
 	updatedUser, err := h.store.UpdateUserSettings(c.Request().Context(), user.ID, req.Locale, req.UserCurrency, display)
 	if err != nil {
 		return err
@@ -505,13 +555,18 @@ func (h *Handlers) UpdateUserSettings(c echo.Context) error {
 	if err != nil {
 		return err
 	}
+	currencies, err := h.store.FamilyCurrencies(c.Request().Context(), family.ID)
+	if err != nil {
+		return err
+	}
 
 	return c.JSON(http.StatusOK, UserSettingsResponse{
-		SupportedCurrencies: supportedCurrencies,
+		SupportedCurrencies: currencies,
 		Family: familySettings{
 			ID:           family.ID,
 			Name:         family.Name,
 			CurrencyBase: family.CurrencyBase,
+			Timezone:     family.Timezone,
 		},
 		User: userSettings{
 			ID:              updatedUser.ID,
@@ -553,19 +608,6 @@ func normalizeDisplaySettings(payload DisplaySettingsPayload) (domain.DisplaySet
 	}, nil
 }
 
-func isSupportedCurrency(code string) bool {
-	code = strings.ToUpper(strings.TrimSpace(code))
-	if code == "" {
-		return false
-	}
-	for _, item := range supportedCurrencies {
-		if item == code {
-			return true
-		}
-	}
-	return false
-}
-
 func (h *Handlers) ListCategories(c echo.Context) error {
 	user, err := h.resolvePathUser(c)
 	if err != nil {
@@ -671,19 +713,27 @@ func (h *Handlers) CreateAccount(c echo.Context) error {
 	if err := validateAccountPayload(&req); err != nil {
 		return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
 	}
+	enabled, err := h.currencyEnabled(c.Request().Context(), user.FamilyID, req.Currency)
+	if err != nil {
+		return err
+	}
+	if !enabled {
+		return currencyNotEnabled(c, req.Currency)
+	}
 
 	now := time.Now().UTC()
 	account := &domain.Account{
-		ID:           uuid.NewString(),
-		FamilyID:     user.FamilyID,
-		Name:         req.Name,
-		Type:         req.Type,
-		Currency:     req.Currency,
-		BalanceMinor: req.InitialBalanceMinor,
-		IsShared:     true,
-		IsArchived:   false,
-		CreatedAt:    now,
-		UpdatedAt:    now,
+		ID:            uuid.NewString(),
+		FamilyID:      user.FamilyID,
+		Name:          req.Name,
+		Type:          req.Type,
+		Currency:      req.Currency,
+		BalanceMinor:  req.InitialBalanceMinor,
+		MultiCurrency: req.MultiCurrency,
+		IsShared:      true,
+		IsArchived:    false,
+		CreatedAt:     now,
+		UpdatedAt:     now,
 	}
 	if req.Shared != nil {
 		account.IsShared = *req.Shared
@@ -802,6 +852,34 @@ func (h *Handlers) ToggleCategoryArchive(c echo.Context) error {
 	return c.JSON(http.StatusOK, categoryResponse{Category: *updated})
 }
 
+// accountAmount returns the amount in currency to charged to the account for
+// amount paid in currency from and the rate it implies: accountMinor when
+// given, otherwise amount at rate.
+func accountAmount(amount int64, from, to string, accountMinor *int64, rate *float64) (int64, *float64, error) {
+	switch {
+	case accountMinor != nil:
+		if *accountMinor == 0 || (*accountMinor < 0) != (amount < 0) {
+			return 0, nil, errors.New("account_amount_minor must have the sign of amount_minor")
+		}
+		effective := domain.ImpliedRate(amount, from, *accountMinor, to)
+		if rate != nil {
+			effective = *rate
+		}
+		return *accountMinor, &effective, nil
+	case rate != nil:
+		if *rate <= 0 {
+			return 0, nil, errors.New("exchange_rate must be positive")
+		}
+		converted := domain.ConvertAmount(amount, from, to, *rate)
+		if converted == 0 {
+			return 0, nil, errors.New("amount_minor is too small at exchange_rate")
+		}
+		return converted, rate, nil
+	default:
+		return 0, nil, errors.New("account_amount_minor or exchange_rate is required when currency differs from account currency")
+	}
+}
+
 func (h *Handlers) CreateTransaction(c echo.Context) error {
 	current := currentUserFromContext(c)
 	if current == nil {
@@ -829,6 +907,10 @@ func (h *Handlers) CreateTransaction(c echo.Context) error {
 	if user.FamilyID != current.FamilyID {
 		return c.JSON(http.StatusForbidden, map[string]string{"error": "forbidden"})
 	}
+	// Otherwise a junior could post on behalf of an adult past the review.
+	if isRestrictedRole(current.Role) && user.ID != current.ID {
+		return c.JSON(http.StatusForbidden, map[string]string{"error": "junior and guest members can only record their own transactions"})
+	}
 
 	category, err := h.store.GetCategory(c.Request().Context(), req.CategoryID)
 	if err != nil {
@@ -856,8 +938,59 @@ func (h *Handlers) CreateTransaction(c echo.Context) error {
 	if currency == "" {
 		currency = account.Currency
 	}
+	amountMinor := req.AmountMinor
+	var originalAmount *int64
+	var originalCurrency string
+	var exchangeRate *float64
 	if currency != account.Currency {
-		return c.JSON(http.StatusBadRequest, map[string]string{"error": "currency must match account currency"})
+		if !isSupportedCurrency(currency) {
+			return c.JSON(http.StatusBadRequest, map[string]string{"error": "unsupported currency"})
+		}
+		enabled, err := h.currencyEnabled(c.Request().Context(), user.FamilyID, currency)
+		if err != nil {
+			return err
+		}
+		if !enabled {
+			return currencyNotEnabled(c, currency)
+		}
+		// Multi-currency accounts keep the amount in the pocket of its
+		// currency; other accounts are charged the converted amount.
+		if account.MultiCurrency {
+			if req.AccountAmountMinor != nil || req.ExchangeRate != nil {
+				return c.JSON(http.StatusBadRequest, map[string]string{"error": "multi-currency accounts keep amounts in their own currency, exchange them within the account instead"})
+			}
+		} else {
+			amountMinor, exchangeRate, err = accountAmount(req.AmountMinor, currency, account.Currency, req.AccountAmountMinor, req.ExchangeRate)
+			if err != nil {
+				return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+			}
+			original := req.AmountMinor
+			originalAmount, originalCurrency = &original, currency
+			currency = account.Currency
+		}
+	} else if req.AccountAmountMinor != nil || req.ExchangeRate != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "account_amount_minor and exchange_rate apply only to transactions in another currency"})
+	}
+
+	var envelopeID *string
+	if trimmed := strings.TrimSpace(req.EnvelopeID); trimmed != "" {
+		if req.Type != "expense" {
+			return c.JSON(http.StatusBadRequest, map[string]string{"error": "only expenses can be spent from an envelope"})
+		}
+		envelope, err := h.store.GetEnvelope(c.Request().Context(), trimmed)
+		if err != nil {
+			return err
+		}
+		if envelope == nil || envelope.FamilyID != user.FamilyID {
+			return c.JSON(http.StatusBadRequest, map[string]string{"error": "envelope not found"})
+		}
+		if envelope.IsArchived {
+			return c.JSON(http.StatusBadRequest, map[string]string{"error": "envelope is archived"})
+		}
+		if envelope.Currency != currency {
+			return c.JSON(http.StatusBadRequest, map[string]string{"error": "currency must match envelope currency"})
+		}
+		envelopeID = &envelope.ID
 	}
 
 	occurredAt, err := time.Parse(time.RFC3339, req.OccurredAt)
@@ -867,18 +1000,60 @@ func (h *Handlers) CreateTransaction(c echo.Context) error {
 
 	now := time.Now().UTC()
 	txn := &domain.Transaction{
-		ID:          uuid.NewString(),
-		FamilyID:    user.FamilyID,
-		UserID:      user.ID,
-		AccountID:   account.ID,
-		CategoryID:  category.ID,
-		Type:        req.Type,
-		AmountMinor: req.AmountMinor,
-		Currency:    currency,
-		Comment:     strings.TrimSpace(req.Comment),
-		OccurredAt:  occurredAt.UTC(),
-		CreatedAt:   now,
-		UpdatedAt:   now,
+		ID:                  uuid.NewString(),
+		FamilyID:            user.FamilyID,
+		UserID:              user.ID,
+		AccountID:           account.ID,
+		CategoryID:          category.ID,
+		EnvelopeID:          envelopeID,
+		Type:                req.Type,
+		AmountMinor:         amountMinor,
+		Currency:            currency,
+		Comment:             strings.TrimSpace(req.Comment),
+		OccurredAt:          occurredAt.UTC(),
+		CreatedAt:           now,
+		UpdatedAt:           now,
+		OriginalAmountMinor: originalAmount,
+		OriginalCurrency:    originalCurrency,
+		ExchangeRate:        exchangeRate,
+	}
+
+	// Transactions of restricted members wait for an owner or adult when the
+	// family requires it, and junior expenses over a category cap always do.
+	if !canManageReferenceData(current) {
+		reason := ""
+		if isRestrictedRole(current.Role) {
+			family, err := h.store.GetFamily(c.Request().Context(), user.FamilyID)
+			if err != nil {
+				return err
+			}
+			if family != nil && family.ApprovalRequired {
+				reason = store.ApprovalReasonRestrictedMember
+			}
+		}
+		if reason == "" {
+			overCap, err := h.exceedsSpendingCap(c.Request().Context(), current, txn)
+			if err != nil {
+				return err
+			}
+			if overCap {
+				reason = store.ApprovalReasonCategoryCap
+			}
+		}
+		if reason != "" {
+			approval := &domain.TransactionApproval{
+				ID:          uuid.NewString(),
+				FamilyID:    user.FamilyID,
+				Status:      store.ApprovalStatusPending,
+				Reason:      reason,
+				Transaction: *txn,
+				CreatedAt:   now,
+			}
+			if err := h.store.CreateTransactionApproval(c.Request().Context(), approval); err != nil {
+				return err
+			}
+			return c.JSON(http.StatusAccepted, approvalResponse{Approval: *approval})
+		}
 	}
 
 	if err := h.store.CreateTransaction(c.Request().Context(), txn); err != nil {
@@ -888,7 +1063,7 @@ func (h *Handlers) CreateTransaction(c echo.Context) error {
 		if errors.Is(err, store.ErrAccountArchived) {
 			return c.JSON(http.StatusBadRequest, map[string]string{"error": "account is archived"})
 		}
-		return err
+		return h.handleEnvelopeError(c, err)
 	}
 
 	return c.JSON(http.StatusCreated, transactionResponse{Transaction: domain.TransactionWithAuthor{Transaction: *txn, Author: domain.FamilyMember{ID: user.ID, Name: user.Name, Email: user.Email, Role: user.Role}}})
@@ -984,10 +1159,61 @@ func (h *Handlers) GetReportsOverview(c echo.Context) error {
 		return c.JSON(http.StatusBadRequest, map[string]string{"error": "start_date must be before end_date"})
 	}
 
-	reports, err := h.store.GetReportsOverview(c.Request().Context(), user.FamilyID, startDate, endDate)
+	modes, err := reportComparisonModes(c.QueryParam("compare"))
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+	}
+	if len(modes) > 0 && (startDate == nil || endDate == nil) {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "compare requires start_date and end_date"})
+	}
+
+	opts := store.ReportOptions{Start: startDate, End: endDate}
+	if opts.Amounts, err = reportAmounts(c.QueryParam("amounts")); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+	}
+	if raw := strings.TrimSpace(c.QueryParam("category_depth")); raw != "" {
+		opts.TreeDepth, err = strconv.Atoi(raw)
+		if err != nil || opts.TreeDepth < 1 {
+			return c.JSON(http.StatusBadRequest, map[string]string{"error": "category_depth must be a positive integer"})
+		}
+	}
+
+	if raw := strings.TrimSpace(c.QueryParam("cross_tab")); raw != "" {
+		parts := strings.Split(strings.ToLower(raw), ",")
+		if len(parts) != 2 {
+			return c.JSON(http.StatusBadRequest, map[string]string{"error": "cross_tab must name two dimensions, e.g. member,category"})
+		}
+		opts.CrossTabRows, opts.CrossTabColumns = strings.TrimSpace(parts[0]), strings.TrimSpace(parts[1])
+		if !store.ValidReportSplit(opts.CrossTabRows) || !store.ValidReportSplit(opts.CrossTabColumns) || opts.CrossTabRows == opts.CrossTabColumns {
+			return c.JSON(http.StatusBadRequest, map[string]string{"error": "cross_tab dimensions must be two of category, account and member"})
+		}
+	}
+
+	ctx := c.Request().Context()
+	loc, err := h.store.FamilyLocation(ctx, user.FamilyID)
 	if err != nil {
 		return err
 	}
+	opts.Converter, err = h.reportConverter(ctx, user, c.QueryParam("convert"), loc)
+	if err != nil {
+		if errors.Is(err, errInvalidConvert) {
+			return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+		}
+		return err
+	}
+
+	reports, err := h.store.GetReportsOverview(ctx, user.FamilyID, opts)
+	if err != nil {
+		return err
+	}
+	for _, mode := range modes {
+		if err := h.store.CompareReports(ctx, user.FamilyID, &reports, opts, mode, loc); err != nil {
+			return err
+		}
+	}
+	if opts.Converter != nil {
+		reports.Conversion = opts.Converter.Summary()
+	}
 
 	return c.JSON(http.StatusOK, map[string]interface{}{"reports": reports})
 }
@@ -1012,7 +1238,7 @@ func (h *Handlers) CreatePlannedOperation(c echo.Context) error {
 		return c.JSON(http.StatusBadRequest, map[string]string{"error": "type must be income or expense"})
 	}
 
-	recurrence, err := normalizeRecurrence(req.Recurrence)
+	rule, err := recurrence.Normalize(req.Recurrence)
 	if err != nil {
 		return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
 	}
@@ -1046,7 +1272,7 @@ func (h *Handlers) CreatePlannedOperation(c echo.Context) error {
 	if currency == "" {
 		currency = account.Currency
 	}
-	if currency != account.Currency {
+	if !account.Holds(currency) {
 		return c.JSON(http.StatusBadRequest, map[string]string{"error": "currency must match account currency"})
 	}
 
@@ -1068,7 +1294,9 @@ func (h *Handlers) CreatePlannedOperation(c echo.Context) error {
 		Currency:    currency,
 		Comment:     strings.TrimSpace(req.Comment),
 		DueAt:       dueAt.UTC(),
-		Recurrence:  recurrence,
+		StartsAt:    dueAt.UTC(),
+		Recurrence:  rule,
+		AutoPost:    req.AutoPost,
 		IsCompleted: false,
 		CreatedAt:   now,
 		UpdatedAt:   now,
@@ -1129,8 +1357,7 @@ func (h *Handlers) CompletePlannedOperation(c echo.Context) error {
 		return c.JSON(http.StatusNotFound, map[string]string{"error": "planned operation not found"})
 	}
 
-	recurrence := strings.TrimSpace(plan.Recurrence)
-	if plan.IsCompleted && recurrence == "" {
+	if plan.IsCompleted {
 		return c.JSON(http.StatusBadRequest, map[string]string{"error": "operation already completed"})
 	}
 
@@ -1158,7 +1385,7 @@ func (h *Handlers) CompletePlannedOperation(c echo.Context) error {
 	if account.IsArchived {
 		return c.JSON(http.StatusBadRequest, map[string]string{"error": "account is archived"})
 	}
-	if account.Currency != plan.Currency {
+	if !account.Holds(plan.Currency) {
 		return c.JSON(http.StatusBadRequest, map[string]string{"error": "account currency changed"})
 	}
 
@@ -1173,63 +1400,25 @@ func (h *Handlers) CompletePlannedOperation(c echo.Context) error {
 		return c.JSON(http.StatusBadRequest, map[string]string{"error": "category is archived"})
 	}
 
-	comment := plan.Comment
-	if strings.TrimSpace(comment) == "" {
-		comment = plan.Title
+	loc, err := h.store.FamilyLocation(c.Request().Context(), plan.FamilyID)
+	if err != nil {
+		return err
 	}
 
 	now := time.Now().UTC()
-	txn := &domain.Transaction{
-		ID:          uuid.NewString(),
-		FamilyID:    plan.FamilyID,
-		UserID:      actor.ID,
-		AccountID:   plan.AccountID,
-		CategoryID:  plan.CategoryID,
-		Type:        plan.Type,
-		AmountMinor: plan.AmountMinor,
-		Currency:    plan.Currency,
-		Comment:     comment,
-		OccurredAt:  occurredAt,
-		CreatedAt:   now,
-		UpdatedAt:   now,
-	}
-
-	if err := h.store.CreateTransaction(c.Request().Context(), txn); err != nil {
-		if errors.Is(err, sql.ErrNoRows) {
+	txn := store.PlannedOperationTransaction(plan, actor.ID, occurredAt, now)
+	if err := h.store.CompletePlannedOperation(c.Request().Context(), plan, txn, loc, now); err != nil {
+		switch {
+		case errors.Is(err, store.ErrPlannedOperationChanged):
+			return c.JSON(http.StatusConflict, map[string]string{"error": "planned operation was already completed"})
+		case errors.Is(err, sql.ErrNoRows):
 			return c.JSON(http.StatusBadRequest, map[string]string{"error": "account not found"})
-		}
-		if errors.Is(err, store.ErrAccountArchived) {
+		case errors.Is(err, store.ErrAccountArchived):
 			return c.JSON(http.StatusBadRequest, map[string]string{"error": "account is archived"})
 		}
 		return err
 	}
 
-	plan.LastCompletedAt = &now
-	plan.UpdatedAt = now
-	if recurrence == "" {
-		plan.IsCompleted = true
-	} else {
-		nextDue, nextErr := advanceRecurrence(plan.DueAt, recurrence)
-		if nextErr != nil {
-			return c.JSON(http.StatusBadRequest, map[string]string{"error": nextErr.Error()})
-		}
-		for !nextDue.After(occurredAt) {
-			nextDue, nextErr = advanceRecurrence(nextDue, recurrence)
-			if nextErr != nil {
-				return c.JSON(http.StatusBadRequest, map[string]string{"error": nextErr.Error()})
-			}
-		}
-		plan.DueAt = nextDue
-		plan.IsCompleted = false
-	}
-
-	if err := h.store.UpdatePlannedOperationStatus(c.Request().Context(), plan); err != nil {
-		if errors.Is(err, sql.ErrNoRows) {
-			return c.JSON(http.StatusNotFound, map[string]string{"error": "planned operation not found"})
-		}
-		return err
-	}
-
 	updatedPlan, err := h.store.GetPlannedOperationWithCreator(c.Request().Context(), plan.ID)
 	if err != nil {
 		return err
@@ -1255,32 +1444,6 @@ func parseOptionalTime(value string) (*time.Time, error) {
 	return &t, nil
 }
 
-func normalizeRecurrence(value string) (string, error) {
-	trimmed := strings.ToLower(strings.TrimSpace(value))
-	if trimmed == "" || trimmed == "none" {
-		return "", nil
-	}
-	switch trimmed {
-	case "weekly", "monthly", "yearly":
-		return trimmed, nil
-	default:
-		return "", fmt.Errorf("recurrence must be one of weekly, monthly, yearly or none")
-	}
-}
-
-func advanceRecurrence(current time.Time, recurrence string) (time.Time, error) {
-	switch strings.ToLower(strings.TrimSpace(recurrence)) {
-	case "weekly":
-		return current.AddDate(0, 0, 7), nil
-	case "monthly":
-		return current.AddDate(0, 1, 0), nil
-	case "yearly":
-		return current.AddDate(1, 0, 0), nil
-	default:
-		return time.Time{}, fmt.Errorf("unknown recurrence: %s", recurrence)
-	}
-}
-
 func (h *Handlers) bootstrapCategories(ctx context.Context, familyID string) error {
 	defaults := []domain.Category{
 		{Name: "Базовый доход семьи", Type: "income", Color: "#22c55e", Description: "Регулярные поступления (зарплаты, стипендии)"},
@@ -1447,5 +1610,8 @@ func validateAccountPayload(req *AccountRequest) error {
 	if strings.TrimSpace(req.Currency) == "" {
 		return errors.New("currency is required")
 	}
+	if !isSupportedCurrency(req.Currency) {
+		return errors.New("unsupported currency")
+	}
 	return nil
 }
@@ -0,0 +1,609 @@
+package http
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"
+
+	"familybudget/internal/domain"
+	"familybudget/internal/imports"
+	"familybudget/internal/store"
+)
+
+// maxImportSize is the largest statement accepted, see docs/imports.md.
+const maxImportSize = 10 << 20
+
+// importExtensions maps the accepted file extensions to their format.
+var importExtensions = map[string]string{
+	".csv":  imports.FormatCSV,
+	".txt":  imports.FormatCSV,
+	".xlsx": imports.FormatXLSX,
+}
+
+// ImportUpdateRequest selects another profile, by profile_id or as a
+// mapping entered in the wizard, or the account of a pending import.
+type ImportUpdateRequest struct {
+	ProfileID string                `json:"profile_id"`
+	Profile   *domain.ImportProfile `json:"profile"`
+	AccountID *string               `json:"account_id"`
+}
+
+// ImportCommitRequest commits an import into account_id. Rows whose
+// category is not recognised get income_category_id or
+// expense_category_id. Duplicates are skipped unless skip_duplicates is
+// false; rows with errors block the commit unless skip_invalid is true.
+type ImportCommitRequest struct {
+	AccountID         string `json:"account_id"`
+	IncomeCategoryID  string `json:"income_category_id"`
+	ExpenseCategoryID string `json:"expense_category_id"`
+	SkipDuplicates    *bool  `json:"skip_duplicates"`
+	SkipInvalid       bool   `json:"skip_invalid"`
+}
+
+// ListImportProfiles returns the built-in profiles followed by those the
+// family saved.
+func (h *Handlers) ListImportProfiles(c echo.Context) error {
+	user, err := h.resolvePathUser(c)
+	if err != nil {
+		return h.handleUserAccessError(c, err)
+	}
+	saved, err := h.store.ListImportProfiles(c.Request().Context(), user.FamilyID)
+	if err != nil {
+		return err
+	}
+	profiles := append(imports.Builtin(), saved...)
+	return c.JSON(http.StatusOK, map[string]interface{}{"profiles": profiles})
+}
+
+// CreateImportProfile saves a column mapping for the family's next imports.
+func (h *Handlers) CreateImportProfile(c echo.Context) error {
+	user, err := h.resolvePathUser(c)
+	if err != nil {
+		return h.handleUserAccessError(c, err)
+	}
+	if !canManageReferenceData(user) {
+		return c.JSON(http.StatusForbidden, map[string]string{"error": "only owner or adult can manage import profiles"})
+	}
+
+	var profile domain.ImportProfile
+	if err := c.Bind(&profile); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "invalid payload"})
+	}
+	if err := imports.NormalizeProfile(&profile); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+	}
+	if profile.Name == "" {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "name is required"})
+	}
+	now := time.Now().UTC()
+	profile.ID = uuid.NewString()
+	profile.FamilyID = user.FamilyID
+	profile.Builtin = false
+	profile.CreatedAt = &now
+	if err := h.store.CreateImportProfile(c.Request().Context(), &profile); err != nil {
+		return err
+	}
+	return c.JSON(http.StatusCreated, profile)
+}
+
+func (h *Handlers) DeleteImportProfile(c echo.Context) error {
+	user, err := h.resolvePathUser(c)
+	if err != nil {
+		return h.handleUserAccessError(c, err)
+	}
+	if !canManageReferenceData(user) {
+		return c.JSON(http.StatusForbidden, map[string]string{"error": "only owner or adult can manage import profiles"})
+	}
+	deleted, err := h.store.DeleteImportProfile(c.Request().Context(), user.FamilyID, c.Param("profileId"))
+	if err != nil {
+		return err
+	}
+	if !deleted {
+		return c.JSON(http.StatusNotFound, map[string]string{"error": "import profile not found"})
+	}
+	return c.NoContent(http.StatusNoContent)
+}
+
+// CreateImport uploads a statement as multipart field "file". The profile
+// is profile_id, a mapping in "profile" or, when neither is given, the
+// first profile whose columns are found in the file, falling back to the
+// generic profile of the file format. A repeated Idempotency-Key returns
+// the earlier import.
+func (h *Handlers) CreateImport(c echo.Context) error {
+	user, err := h.resolvePathUser(c)
+	if err != nil {
+		return h.handleUserAccessError(c, err)
+	}
+	if !canManageReferenceData(user) {
+		return c.JSON(http.StatusForbidden, map[string]string{"error": "only owner or adult can import statements"})
+	}
+	ctx := c.Request().Context()
+
+	key := strings.TrimSpace(c.Request().Header.Get("Idempotency-Key"))
+	if key != "" {
+		existing, content, err := h.store.FindImportByIdempotencyKey(ctx, user.FamilyID, key)
+		if err != nil {
+			return err
+		}
+		if existing != nil {
+			preview, err := h.importPreview(ctx, existing, content)
+			if err != nil {
+				return err
+			}
+			return c.JSON(http.StatusOK, preview)
+		}
+	}
+
+	header, err := c.FormFile("file")
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "file is required"})
+	}
+	if header.Size > maxImportSize {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "file must be smaller than 10 MB"})
+	}
+	format, ok := importExtensions[strings.ToLower(filepath.Ext(header.Filename))]
+	if !ok {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "unsupported file type"})
+	}
+	file, err := header.Open()
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	content, err := io.ReadAll(io.LimitReader(file, maxImportSize+1))
+	if err != nil {
+		return err
+	}
+	if len(content) > maxImportSize {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "file must be smaller than 10 MB"})
+	}
+
+	var custom *domain.ImportProfile
+	if raw := strings.TrimSpace(c.FormValue("profile")); raw != "" {
+		custom = &domain.ImportProfile{}
+		if err := json.Unmarshal([]byte(raw), custom); err != nil {
+			return c.JSON(http.StatusBadRequest, map[string]string{"error": "profile must be a JSON object"})
+		}
+	}
+	profile, err := h.chooseImportProfile(ctx, user.FamilyID, strings.TrimSpace(c.FormValue("profile_id")), custom)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+	}
+	if profile == nil {
+		candidates, err := h.importProfilesFor(ctx, user.FamilyID, format)
+		if err != nil {
+			return err
+		}
+		detected, ok := imports.Detect(content, candidates)
+		if !ok {
+			detected, _ = imports.FindBuiltin("generic-" + format + "-v1")
+		}
+		profile = &detected
+	}
+	if profile.Format != format {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "profile format does not match the file"})
+	}
+
+	now := time.Now().UTC()
+	imp := &domain.Import{
+		ID:        uuid.NewString(),
+		FamilyID:  user.FamilyID,
+		UserID:    user.ID,
+		FileName:  filepath.Base(header.Filename),
+		Status:    store.ImportStatusPending,
+		Profile:   *profile,
+		CreatedAt: now,
+	}
+	if accountID := strings.TrimSpace(c.FormValue("account_id")); accountID != "" {
+		account, err := h.store.GetAccount(ctx, accountID)
+		if err != nil {
+			return err
+		}
+		if account == nil || account.FamilyID != user.FamilyID {
+			return c.JSON(http.StatusBadRequest, map[string]string{"error": "account not found"})
+		}
+		imp.AccountID = &account.ID
+	}
+	if err := h.store.CreateImport(ctx, imp, content, key); err != nil {
+		return err
+	}
+
+	preview, err := h.importPreview(ctx, imp, content)
+	if err != nil {
+		return err
+	}
+	return c.JSON(http.StatusCreated, preview)
+}
+
+// GetImport returns the preview of an import.
+func (h *Handlers) GetImport(c echo.Context) error {
+	user, err := h.resolvePathUser(c)
+	if err != nil {
+		return h.handleUserAccessError(c, err)
+	}
+	ctx := c.Request().Context()
+	imp, content, err := h.store.GetImport(ctx, c.Param("importId"))
+	if err != nil {
+		return err
+	}
+	if imp == nil || imp.FamilyID != user.FamilyID {
+		return c.JSON(http.StatusNotFound, map[string]string{"error": "import not found"})
+	}
+	preview, err := h.importPreview(ctx, imp, content)
+	if err != nil {
+		return err
+	}
+	return c.JSON(http.StatusOK, preview)
+}
+
+// UpdateImport changes the profile or account of a pending import and
+// returns the new preview.
+func (h *Handlers) UpdateImport(c echo.Context) error {
+	user, err := h.resolvePathUser(c)
+	if err != nil {
+		return h.handleUserAccessError(c, err)
+	}
+	if !canManageReferenceData(user) {
+		return c.JSON(http.StatusForbidden, map[string]string{"error": "only owner or adult can import statements"})
+	}
+	ctx := c.Request().Context()
+
+	imp, content, err := h.store.GetImport(ctx, c.Param("importId"))
+	if err != nil {
+		return err
+	}
+	if imp == nil || imp.FamilyID != user.FamilyID {
+		return c.JSON(http.StatusNotFound, map[string]string{"error": "import not found"})
+	}
+	if imp.Status != store.ImportStatusPending {
+		return c.JSON(http.StatusConflict, map[string]string{"error": store.ErrImportNotPending.Error()})
+	}
+
+	var req ImportUpdateRequest
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "invalid payload"})
+	}
+	profile, err := h.chooseImportProfile(ctx, user.FamilyID, strings.TrimSpace(req.ProfileID), req.Profile)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+	}
+	if profile != nil {
+		if profile.Format != imp.Profile.Format {
+			return c.JSON(http.StatusBadRequest, map[string]string{"error": "profile format does not match the file"})
+		}
+		imp.Profile = *profile
+	}
+	if req.AccountID != nil {
+		imp.AccountID = nil
+		if accountID := strings.TrimSpace(*req.AccountID); accountID != "" {
+			account, err := h.store.GetAccount(ctx, accountID)
+			if err != nil {
+				return err
+			}
+			if account == nil || account.FamilyID != user.FamilyID {
+				return c.JSON(http.StatusBadRequest, map[string]string{"error": "account not found"})
+			}
+			imp.AccountID = &account.ID
+		}
+	}
+	if err := h.store.UpdateImport(ctx, imp); err != nil {
+		if errors.Is(err, store.ErrImportNotPending) {
+			return c.JSON(http.StatusConflict, map[string]string{"error": err.Error()})
+		}
+		return err
+	}
+
+	preview, err := h.importPreview(ctx, imp, content)
+	if err != nil {
+		return err
+	}
+	return c.JSON(http.StatusOK, preview)
+}
+
+// CancelImport drops a pending import and its file.
+func (h *Handlers) CancelImport(c echo.Context) error {
+	user, err := h.resolvePathUser(c)
+	if err != nil {
+		return h.handleUserAccessError(c, err)
+	}
+	if !canManageReferenceData(user) {
+		return c.JSON(http.StatusForbidden, map[string]string{"error": "only owner or adult can import statements"})
+	}
+	ctx := c.Request().Context()
+
+	imp, _, err := h.store.GetImport(ctx, c.Param("importId"))
+	if err != nil {
+		return err
+	}
+	if imp == nil || imp.FamilyID != user.FamilyID {
+		return c.JSON(http.StatusNotFound, map[string]string{"error": "import not found"})
+	}
+	if err := h.store.CancelImport(ctx, imp.ID); err != nil {
+		if errors.Is(err, store.ErrImportNotPending) {
+			return c.JSON(http.StatusConflict, map[string]string{"error": err.Error()})
+		}
+		return err
+	}
+	return c.NoContent(http.StatusNoContent)
+}
+
+// CommitImport creates a transaction for every valid row of the preview in
+// one go, through the same balance bookkeeping as CreateTransaction.
+func (h *Handlers) CommitImport(c echo.Context) error {
+	user, err := h.resolvePathUser(c)
+	if err != nil {
+		return h.handleUserAccessError(c, err)
+	}
+	if !canManageReferenceData(user) {
+		return c.JSON(http.StatusForbidden, map[string]string{"error": "only owner or adult can import statements"})
+	}
+	ctx := c.Request().Context()
+
+	imp, content, err := h.store.GetImport(ctx, c.Param("importId"))
+	if err != nil {
+		return err
+	}
+	if imp == nil || imp.FamilyID != user.FamilyID {
+		return c.JSON(http.StatusNotFound, map[string]string{"error": "import not found"})
+	}
+	if imp.Status != store.ImportStatusPending {
+		return c.JSON(http.StatusConflict, map[string]string{"error": store.ErrImportNotPending.Error()})
+	}
+
+	var req ImportCommitRequest
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "invalid payload"})
+	}
+	if accountID := strings.TrimSpace(req.AccountID); accountID != "" {
+		imp.AccountID = &accountID
+	}
+	if imp.AccountID == nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "account_id is required"})
+	}
+	account, err := h.store.GetAccount(ctx, *imp.AccountID)
+	if err != nil {
+		return err
+	}
+	if account == nil || account.FamilyID != user.FamilyID {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "account not found"})
+	}
+	if account.IsArchived {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "account is archived"})
+	}
+
+	defaults := make(map[string]string, 2)
+	for kind, id := range map[string]string{"income": req.IncomeCategoryID, "expense": req.ExpenseCategoryID} {
+		if id = strings.TrimSpace(id); id == "" {
+			continue
+		}
+		category, err := h.store.GetCategory(ctx, id)
+		if err != nil {
+			return err
+		}
+		if category == nil || category.FamilyID != user.FamilyID || category.Type != kind {
+			return c.JSON(http.StatusBadRequest, map[string]string{"error": kind + "_category_id must be an " + kind + " category of the family"})
+		}
+		if category.IsArchived {
+			return c.JSON(http.StatusBadRequest, map[string]string{"error": "category is archived"})
+		}
+		defaults[kind] = category.ID
+	}
+
+	preview, err := h.importPreview(ctx, imp, content)
+	if err != nil {
+		return err
+	}
+	if len(preview.Errors) > 0 && !req.SkipInvalid {
+		return c.JSON(http.StatusBadRequest, map[string]interface{}{"error": "import has rows with errors", "errors": preview.Errors})
+	}
+	skipDuplicates := req.SkipDuplicates == nil || *req.SkipDuplicates
+
+	now := time.Now().UTC()
+	var txns []*domain.Transaction
+	var hashes []string
+	for _, row := range preview.Rows {
+		if row.Duplicate && skipDuplicates {
+			continue
+		}
+		categoryID := row.CategoryID
+		if categoryID == "" {
+			categoryID = defaults[row.Type]
+		}
+		if categoryID == "" {
+			return c.JSON(http.StatusBadRequest, map[string]string{"error": row.Type + "_category_id is required for rows without a recognised category"})
+		}
+
+		txn := &domain.Transaction{
+			ID:          uuid.NewString(),
+			FamilyID:    user.FamilyID,
+			UserID:      user.ID,
+			AccountID:   account.ID,
+			CategoryID:  categoryID,
+			Type:        row.Type,
+			AmountMinor: row.AmountMinor,
+			Currency:    row.Currency,
+			Comment:     row.Description,
+			OccurredAt:  row.OccurredAt,
+			CreatedAt:   now,
+			UpdatedAt:   now,
+		}
+		if !account.Holds(row.Currency) {
+			// importPreview only keeps such rows when the statement has
+			// the amount charged to the account.
+			amount, rate, err := accountAmount(row.AmountMinor, row.Currency, account.Currency, row.AccountAmountMinor, nil)
+			if err != nil {
+				return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+			}
+			original := row.AmountMinor
+			txn.AmountMinor, txn.ExchangeRate = amount, rate
+			txn.OriginalAmountMinor, txn.OriginalCurrency = &original, row.Currency
+			txn.Currency = account.Currency
+		}
+		txns = append(txns, txn)
+		hashes = append(hashes, row.Hash)
+	}
+	if len(txns) == 0 {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "no rows to import"})
+	}
+
+	if err := h.store.CommitImport(ctx, imp, txns, hashes, preview, now); err != nil {
+		switch {
+		case errors.Is(err, store.ErrImportNotPending):
+			return c.JSON(http.StatusConflict, map[string]string{"error": err.Error()})
+		case errors.Is(err, sql.ErrNoRows):
+			return c.JSON(http.StatusBadRequest, map[string]string{"error": "account not found"})
+		case errors.Is(err, store.ErrAccountArchived):
+			return c.JSON(http.StatusBadRequest, map[string]string{"error": "account is archived"})
+		}
+		return err
+	}
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"import":       imp,
+		"transactions": len(txns),
+		"duplicates":   preview.Duplicates,
+		"skipped":      preview.TotalRows - len(txns),
+	})
+}
+
+// chooseImportProfile returns the profile a member picked by id, or the
+// mapping they entered; nil when they did neither.
+func (h *Handlers) chooseImportProfile(ctx context.Context, familyID, profileID string, custom *domain.ImportProfile) (*domain.ImportProfile, error) {
+	if custom != nil {
+		if err := imports.NormalizeProfile(custom); err != nil {
+			return nil, err
+		}
+		custom.ID, custom.FamilyID, custom.Builtin, custom.CreatedAt = "", "", false, nil
+		if custom.Name == "" {
+			custom.Name = "Custom"
+		}
+		return custom, nil
+	}
+	if profileID == "" {
+		return nil, nil
+	}
+	if profile, ok := imports.FindBuiltin(profileID); ok {
+		return &profile, nil
+	}
+	profile, err := h.store.GetImportProfile(ctx, familyID, profileID)
+	if err != nil {
+		return nil, err
+	}
+	if profile == nil {
+		return nil, errors.New("import profile not found")
+	}
+	return profile, nil
+}
+
+// importProfilesFor returns the profiles detection tries for a file: the
+// family's own first, then the built-in ones.
+func (h *Handlers) importProfilesFor(ctx context.Context, familyID, format string) ([]domain.ImportProfile, error) {
+	saved, err := h.store.ListImportProfiles(ctx, familyID)
+	if err != nil {
+		return nil, err
+	}
+	var candidates []domain.ImportProfile
+	for _, profile := range append(saved, imports.Builtin()...) {
+		if profile.Format == format {
+			candidates = append(candidates, profile)
+		}
+	}
+	return candidates, nil
+}
+
+// importPreview parses an import against its account, or the family
+// currency while no account is chosen, and marks the rows imported before.
+func (h *Handlers) importPreview(ctx context.Context, imp *domain.Import, content []byte) (*domain.ImportPreview, error) {
+	preview := &domain.ImportPreview{
+		Import:   *imp,
+		Rows:     []domain.ImportRow{},
+		Errors:   []domain.ImportIssue{},
+		Warnings: []domain.ImportIssue{},
+	}
+	if imp.Status != store.ImportStatusPending {
+		return preview, nil
+	}
+
+	family, err := h.store.GetFamily(ctx, imp.FamilyID)
+	if err != nil {
+		return nil, err
+	}
+	location, err := h.store.FamilyLocation(ctx, imp.FamilyID)
+	if err != nil {
+		return nil, err
+	}
+	opts := imports.Options{FamilyID: imp.FamilyID, Location: location}
+	if family != nil {
+		opts.Currency = family.CurrencyBase
+	}
+	var account *domain.Account
+	if imp.AccountID != nil {
+		if account, err = h.store.GetAccount(ctx, *imp.AccountID); err != nil {
+			return nil, err
+		}
+		if account != nil {
+			opts.AccountID = account.ID
+			opts.Currency = account.Currency
+		}
+	}
+
+	sheet, err := imports.Read(content, imp.Profile)
+	if err != nil {
+		preview.Errors = append(preview.Errors, domain.ImportIssue{Code: "invalid_file", Message: err.Error()})
+		return preview, nil
+	}
+	result := imports.Parse(sheet, imp.Profile, opts)
+	categories, err := h.store.ListCategoriesByFamily(ctx, imp.FamilyID)
+	if err != nil {
+		return nil, err
+	}
+	imports.MatchCategories(&result, categories)
+	preview.TotalRows = result.TotalRows
+	preview.Errors = append(preview.Errors, result.Errors...)
+	preview.Warnings = append(preview.Warnings, result.Warnings...)
+
+	enabled, err := h.store.FamilyCurrencies(ctx, imp.FamilyID)
+	if err != nil {
+		return nil, err
+	}
+	enabledSet := make(map[string]bool, len(enabled))
+	for _, code := range enabled {
+		enabledSet[code] = true
+	}
+	var hashes []string
+	for _, row := range result.Rows {
+		if !enabledSet[row.Currency] {
+			preview.Errors = append(preview.Errors, domain.ImportIssue{Row: row.Row, Code: "currency_not_enabled", Message: "Валюта не включена для семьи", Details: map[string]string{"value": row.Currency}})
+			continue
+		}
+		if account != nil && !account.Holds(row.Currency) && row.AccountAmountMinor == nil {
+			preview.Errors = append(preview.Errors, domain.ImportIssue{Row: row.Row, Code: "currency_mismatch", Message: "Валюта операции отличается от валюты счёта, а суммы в валюте счёта нет", Details: map[string]string{"value": row.Currency}})
+			continue
+		}
+		preview.Rows = append(preview.Rows, row)
+		hashes = append(hashes, row.Hash)
+	}
+
+	if account != nil {
+		imported, err := h.store.ImportedHashes(ctx, imp.FamilyID, hashes)
+		if err != nil {
+			return nil, err
+		}
+		for i := range preview.Rows {
+			if imported[preview.Rows[i].Hash] {
+				preview.Rows[i].Duplicate = true
+				preview.Duplicates++
+			}
+		}
+	}
+	preview.ValidRows = len(preview.Rows)
+	return preview, nil
+}
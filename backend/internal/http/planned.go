@@ -0,0 +1,418 @@
+package http
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"
+
+	"familybudget/internal/domain"
+	"familybudget/internal/recurrence"
+	"familybudget/internal/store"
+)
+
+// Scopes of a change to a recurring planned operation.
+const (
+	plannedScopeThis   = "this"
+	plannedScopeFuture = "future"
+)
+
+// UpdatePlannedOperationRequest changes a planned operation. Nil fields keep
+// their current values.
+type UpdatePlannedOperationRequest struct {
+	Scope       string  `json:"scope"`
+	AccountID   *string `json:"account_id"`
+	CategoryID  *string `json:"category_id"`
+	Title       *string `json:"title"`
+	AmountMinor *int64  `json:"amount_minor"`
+	Comment     *string `json:"comment"`
+	DueAt       *string `json:"due_at"`
+	Recurrence  *string `json:"recurrence"`
+	AutoPost    *bool   `json:"auto_post"`
+}
+
+type SnoozePlannedOperationRequest struct {
+	Scope string `json:"scope"`
+	DueAt string `json:"due_at"`
+}
+
+// loadEditablePlannedOperation resolves the path user and a pending planned
+// operation that user may change: their own or any one for administrative
+// users. It writes the error response itself and returns nil operation then.
+func (h *Handlers) loadEditablePlannedOperation(c echo.Context) (*domain.User, *domain.PlannedOperation, error) {
+	user, err := h.resolvePathUser(c)
+	if err != nil {
+		return nil, nil, h.handleUserAccessError(c, err)
+	}
+	plan, err := h.store.GetPlannedOperation(c.Request().Context(), c.Param("operationId"))
+	if err != nil {
+		return nil, nil, err
+	}
+	if plan == nil || plan.FamilyID != user.FamilyID {
+		return nil, nil, c.JSON(http.StatusNotFound, map[string]string{"error": "planned operation not found"})
+	}
+	if plan.UserID != user.ID && !canManageReferenceData(user) {
+		return nil, nil, c.JSON(http.StatusForbidden, map[string]string{"error": "only the author or an administrative user can change this planned operation"})
+	}
+	if plan.IsCompleted {
+		return nil, nil, c.JSON(http.StatusBadRequest, map[string]string{"error": "operation already completed"})
+	}
+	return user, plan, nil
+}
+
+// plannedScope validates the scope of a change. One-off operations have a
+// single occurrence, so their scope is always this; recurring series must
+// name one explicitly.
+func plannedScope(plan *domain.PlannedOperation, value string) (string, error) {
+	value = strings.ToLower(strings.TrimSpace(value))
+	if strings.TrimSpace(plan.Recurrence) == "" {
+		return plannedScopeThis, nil
+	}
+	switch value {
+	case plannedScopeThis, plannedScopeFuture:
+		return value, nil
+	case "":
+		return "", errors.New("scope is required for recurring operations: this or future")
+	default:
+		return "", errors.New("scope must be this or future")
+	}
+}
+
+// validatePlannedTargets checks that the account and category of plan belong
+// to the family and can take new operations, and sets the plan currency to
+// the account currency. A non-empty message describes a validation error.
+func (h *Handlers) validatePlannedTargets(ctx context.Context, plan *domain.PlannedOperation) (string, error) {
+	account, err := h.store.GetAccount(ctx, plan.AccountID)
+	if err != nil {
+		return "", err
+	}
+	if account == nil || account.FamilyID != plan.FamilyID {
+		return "account not found", nil
+	}
+	if account.IsArchived {
+		return "account is archived", nil
+	}
+	category, err := h.store.GetCategory(ctx, plan.CategoryID)
+	if err != nil {
+		return "", err
+	}
+	if category == nil || category.FamilyID != plan.FamilyID {
+		return "category not found", nil
+	}
+	if category.IsArchived {
+		return "category is archived", nil
+	}
+	if strings.ToLower(category.Type) != plan.Type {
+		return "category type mismatch", nil
+	}
+	plan.Currency = account.Currency
+	return "", nil
+}
+
+func (h *Handlers) respondPlannedOperation(c echo.Context, status int, id string) error {
+	updated, err := h.store.GetPlannedOperationWithCreator(c.Request().Context(), id)
+	if err != nil {
+		return err
+	}
+	if updated == nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "planned operation not found after update"})
+	}
+	return c.JSON(status, plannedOperationResponse{PlannedOperation: *updated})
+}
+
+func (h *Handlers) handlePlannedOperationError(c echo.Context, err error) error {
+	if errors.Is(err, store.ErrPlannedOperationChanged) {
+		return c.JSON(http.StatusConflict, map[string]string{"error": "planned operation was changed concurrently, reload it and retry"})
+	}
+	if errors.Is(err, sql.ErrNoRows) {
+		return c.JSON(http.StatusNotFound, map[string]string{"error": "planned operation not found"})
+	}
+	return err
+}
+
+// UpdatePlannedOperation edits a planned operation. With scope this the
+// current occurrence of a series is detached into a one-off operation carrying
+// the changes and the series moves on to its next occurrence; with scope
+// future the series itself changes. A new due date or rule restarts the series
+// at the new due date.
+func (h *Handlers) UpdatePlannedOperation(c echo.Context) error {
+	_, plan, err := h.loadEditablePlannedOperation(c)
+	if err != nil || plan == nil {
+		return err
+	}
+	ctx := c.Request().Context()
+
+	var req UpdatePlannedOperationRequest
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "invalid payload"})
+	}
+	scope, err := plannedScope(plan, req.Scope)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+	}
+	recurring := strings.TrimSpace(plan.Recurrence) != ""
+	if recurring && scope == plannedScopeThis && (req.Recurrence != nil || req.AutoPost != nil) {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "recurrence and auto_post apply to the whole series, use scope future"})
+	}
+
+	updated := *plan
+	if req.AccountID != nil {
+		updated.AccountID = strings.TrimSpace(*req.AccountID)
+	}
+	if req.CategoryID != nil {
+		updated.CategoryID = strings.TrimSpace(*req.CategoryID)
+	}
+	if req.Title != nil {
+		updated.Title = strings.TrimSpace(*req.Title)
+		if updated.Title == "" {
+			return c.JSON(http.StatusBadRequest, map[string]string{"error": "title is required"})
+		}
+	}
+	if req.AmountMinor != nil {
+		if *req.AmountMinor <= 0 {
+			return c.JSON(http.StatusBadRequest, map[string]string{"error": "amount_minor must be positive"})
+		}
+		updated.AmountMinor = *req.AmountMinor
+	}
+	if req.Comment != nil {
+		updated.Comment = strings.TrimSpace(*req.Comment)
+	}
+	if req.AutoPost != nil {
+		updated.AutoPost = *req.AutoPost
+	}
+	rescheduled := false
+	if req.DueAt != nil {
+		dueAt, err := time.Parse(time.RFC3339, strings.TrimSpace(*req.DueAt))
+		if err != nil {
+			return c.JSON(http.StatusBadRequest, map[string]string{"error": "due_at must be RFC3339"})
+		}
+		rescheduled = !dueAt.Equal(plan.DueAt)
+		updated.DueAt = dueAt.UTC()
+	}
+	if req.Recurrence != nil {
+		rule, err := recurrence.Normalize(*req.Recurrence)
+		if err != nil {
+			return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+		}
+		rescheduled = rescheduled || rule != plan.Recurrence
+		updated.Recurrence = rule
+	}
+
+	if message, err := h.validatePlannedTargets(ctx, &updated); err != nil {
+		return err
+	} else if message != "" {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": message})
+	}
+
+	now := time.Now().UTC()
+	updated.UpdatedAt = now
+
+	if recurring && scope == plannedScopeThis {
+		loc, err := h.store.FamilyLocation(ctx, plan.FamilyID)
+		if err != nil {
+			return err
+		}
+		single := updated
+		single.ID = uuid.NewString()
+		single.StartsAt = single.DueAt
+		single.SnoozedFrom = nil
+		single.Recurrence = ""
+		single.LastCompletedAt = nil
+		single.CreatedAt = now
+		if err := h.store.DetachPlannedOccurrence(ctx, plan, &single, loc, now); err != nil {
+			return h.handlePlannedOperationError(c, err)
+		}
+		return h.respondPlannedOperation(c, http.StatusOK, single.ID)
+	}
+
+	if rescheduled {
+		updated.StartsAt = updated.DueAt
+		updated.SnoozedFrom = nil
+	}
+	if err := h.store.UpdatePlannedOperation(ctx, &updated); err != nil {
+		return h.handlePlannedOperationError(c, err)
+	}
+	return h.respondPlannedOperation(c, http.StatusOK, updated.ID)
+}
+
+// DeletePlannedOperation removes a planned operation. For a recurring series
+// scope this drops only the current occurrence, like skip, while scope future
+// removes the series.
+func (h *Handlers) DeletePlannedOperation(c echo.Context) error {
+	user, plan, err := h.loadEditablePlannedOperation(c)
+	if err != nil || plan == nil {
+		return err
+	}
+	ctx := c.Request().Context()
+
+	scope, err := plannedScope(plan, c.QueryParam("scope"))
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+	}
+	if strings.TrimSpace(plan.Recurrence) != "" && scope == plannedScopeThis {
+		loc, err := h.store.FamilyLocation(ctx, plan.FamilyID)
+		if err != nil {
+			return err
+		}
+		if err := h.store.SkipPlannedOperation(ctx, plan, user.ID, loc, time.Now().UTC()); err != nil {
+			return h.handlePlannedOperationError(c, err)
+		}
+		return h.respondPlannedOperation(c, http.StatusOK, plan.ID)
+	}
+
+	if err := h.store.DeletePlannedOperation(ctx, plan.FamilyID, plan.ID); err != nil {
+		return h.handlePlannedOperationError(c, err)
+	}
+	return c.NoContent(http.StatusNoContent)
+}
+
+// SkipPlannedOperation moves a planned operation to its next occurrence
+// without posting a transaction. Skipping a one-off operation completes it.
+func (h *Handlers) SkipPlannedOperation(c echo.Context) error {
+	user, plan, err := h.loadEditablePlannedOperation(c)
+	if err != nil || plan == nil {
+		return err
+	}
+	ctx := c.Request().Context()
+
+	loc, err := h.store.FamilyLocation(ctx, plan.FamilyID)
+	if err != nil {
+		return err
+	}
+	if err := h.store.SkipPlannedOperation(ctx, plan, user.ID, loc, time.Now().UTC()); err != nil {
+		return h.handlePlannedOperationError(c, err)
+	}
+	return h.respondPlannedOperation(c, http.StatusOK, plan.ID)
+}
+
+// SnoozePlannedOperation moves the current occurrence to another date. With
+// scope this the rest of the series keeps its schedule, so the occurrence has
+// to stay before the following one; with scope future the whole series is
+// shifted to start at the new date.
+func (h *Handlers) SnoozePlannedOperation(c echo.Context) error {
+	_, plan, err := h.loadEditablePlannedOperation(c)
+	if err != nil || plan == nil {
+		return err
+	}
+	ctx := c.Request().Context()
+
+	var req SnoozePlannedOperationRequest
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "invalid payload"})
+	}
+	scope, err := plannedScope(plan, req.Scope)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+	}
+	dueAt, err := time.Parse(time.RFC3339, strings.TrimSpace(req.DueAt))
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "due_at must be RFC3339"})
+	}
+	dueAt = dueAt.UTC()
+
+	updated := *plan
+	switch {
+	case strings.TrimSpace(plan.Recurrence) == "":
+		updated.StartsAt = dueAt
+	case scope == plannedScopeFuture:
+		updated.StartsAt = dueAt
+		updated.SnoozedFrom = nil
+	default:
+		loc, err := h.store.FamilyLocation(ctx, plan.FamilyID)
+		if err != nil {
+			return err
+		}
+		next, ok, err := store.NextPlannedOccurrence(plan, loc)
+		if err != nil {
+			return err
+		}
+		if ok && !dueAt.Before(next) {
+			return c.JSON(http.StatusBadRequest, map[string]string{"error": "due_at must be before the next occurrence " + next.Format(time.RFC3339) + ", skip this occurrence instead"})
+		}
+		if updated.SnoozedFrom == nil {
+			original := plan.DueAt
+			updated.SnoozedFrom = &original
+		}
+	}
+	updated.DueAt = dueAt
+	updated.UpdatedAt = time.Now().UTC()
+	if err := h.store.UpdatePlannedOperation(ctx, &updated); err != nil {
+		return h.handlePlannedOperationError(c, err)
+	}
+	return h.respondPlannedOperation(c, http.StatusOK, updated.ID)
+}
+
+type plannedHistoryResponse struct {
+	PlannedOperation domain.PlannedOperationWithCreator `json:"planned_operation"`
+	Occurrences      []domain.PlannedOccurrence         `json:"occurrences"`
+	MissedCount      int                                `json:"missed_count"`
+	Overdue          bool                               `json:"overdue"`
+}
+
+// PlannedOperationHistory returns how every past due date of a planned
+// operation was resolved, newest first. Overdue reports that the current
+// occurrence is past due and not paid yet.
+func (h *Handlers) PlannedOperationHistory(c echo.Context) error {
+	user, err := h.resolvePathUser(c)
+	if err != nil {
+		return h.handleUserAccessError(c, err)
+	}
+	ctx := c.Request().Context()
+
+	plan, err := h.store.GetPlannedOperationWithCreator(ctx, c.Param("operationId"))
+	if err != nil {
+		return err
+	}
+	if plan == nil || plan.FamilyID != user.FamilyID {
+		return c.JSON(http.StatusNotFound, map[string]string{"error": "planned operation not found"})
+	}
+
+	occurrences, err := h.store.ListPlannedOccurrences(ctx, user.FamilyID, plan.ID, "")
+	if err != nil {
+		return err
+	}
+	if occurrences == nil {
+		occurrences = []domain.PlannedOccurrence{}
+	}
+	resp := plannedHistoryResponse{
+		PlannedOperation: *plan,
+		Occurrences:      occurrences,
+		Overdue:          !plan.IsCompleted && plan.DueAt.Before(time.Now().UTC()),
+	}
+	for _, occurrence := range occurrences {
+		if occurrence.Status == store.PlannedOccurrenceMissed {
+			resp.MissedCount++
+		}
+	}
+	return c.JSON(http.StatusOK, resp)
+}
+
+// ListPlannedOccurrences returns the recorded occurrences across the family,
+// e.g. ?status=missed to find payments that never happened.
+func (h *Handlers) ListPlannedOccurrences(c echo.Context) error {
+	user, err := h.resolvePathUser(c)
+	if err != nil {
+		return h.handleUserAccessError(c, err)
+	}
+
+	status := strings.ToLower(strings.TrimSpace(c.QueryParam("status")))
+	switch status {
+	case "", store.PlannedOccurrencePaid, store.PlannedOccurrenceSkipped, store.PlannedOccurrenceMissed:
+	default:
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "status must be paid, skipped or missed"})
+	}
+
+	occurrences, err := h.store.ListPlannedOccurrences(c.Request().Context(), user.FamilyID, "", status)
+	if err != nil {
+		return err
+	}
+	if occurrences == nil {
+		occurrences = []domain.PlannedOccurrence{}
+	}
+	return c.JSON(http.StatusOK, map[string]interface{}{"occurrences": occurrences})
+}
@@ -0,0 +1,166 @@
+package http
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"
+
+	"familybudget/internal/domain"
+	"familybudget/internal/store"
+)
+
+// ReminderRuleRequest creates a reminder rule. Without planned_operation_id
+// the rule applies to every planned operation of the family that has no rules
+// of its own. Negative offsets remind after the due date.
+type ReminderRuleRequest struct {
+	PlannedOperationID *string  `json:"planned_operation_id"`
+	OffsetDays         int      `json:"offset_days"`
+	Channels           []string `json:"channels"`
+}
+
+func (h *Handlers) ListReminderRules(c echo.Context) error {
+	user, err := h.resolvePathUser(c)
+	if err != nil {
+		return h.handleUserAccessError(c, err)
+	}
+	rules, err := h.store.ListReminderRules(c.Request().Context(), user.FamilyID)
+	if err != nil {
+		return err
+	}
+	if rules == nil {
+		rules = []domain.ReminderRule{}
+	}
+	return c.JSON(http.StatusOK, rules)
+}
+
+func (h *Handlers) CreateReminderRule(c echo.Context) error {
+	user, err := h.resolvePathUser(c)
+	if err != nil {
+		return h.handleUserAccessError(c, err)
+	}
+	ctx := c.Request().Context()
+
+	var req ReminderRuleRequest
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "invalid payload"})
+	}
+	if req.OffsetDays > store.MaxReminderOffsetDays || req.OffsetDays < -store.MaxReminderOffsetDays {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": fmt.Sprintf("offset_days must be between -%d and %d", store.MaxReminderOffsetDays, store.MaxReminderOffsetDays)})
+	}
+	channels, err := reminderChannels(req.Channels)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+	}
+
+	rule := &domain.ReminderRule{
+		ID:         uuid.NewString(),
+		FamilyID:   user.FamilyID,
+		OffsetDays: req.OffsetDays,
+		Channels:   channels,
+		CreatedBy:  user.ID,
+		CreatedAt:  time.Now().UTC(),
+	}
+	if req.PlannedOperationID != nil && strings.TrimSpace(*req.PlannedOperationID) != "" {
+		plan, err := h.store.GetPlannedOperation(ctx, strings.TrimSpace(*req.PlannedOperationID))
+		if err != nil {
+			return err
+		}
+		if plan == nil || plan.FamilyID != user.FamilyID {
+			return c.JSON(http.StatusNotFound, map[string]string{"error": "planned operation not found"})
+		}
+		if plan.UserID != user.ID && !canManageReferenceData(user) {
+			return c.JSON(http.StatusForbidden, map[string]string{"error": "only the author or an administrative user can add reminders to this planned operation"})
+		}
+		rule.PlannedOperationID = &plan.ID
+	} else if !canManageReferenceData(user) {
+		return c.JSON(http.StatusForbidden, map[string]string{"error": "only owner or adult can manage family reminder rules"})
+	}
+
+	if err := h.store.CreateReminderRule(ctx, rule); err != nil {
+		return err
+	}
+	return c.JSON(http.StatusCreated, rule)
+}
+
+func (h *Handlers) DeleteReminderRule(c echo.Context) error {
+	user, err := h.resolvePathUser(c)
+	if err != nil {
+		return h.handleUserAccessError(c, err)
+	}
+	ctx := c.Request().Context()
+
+	rule, err := h.store.GetReminderRule(ctx, c.Param("ruleId"))
+	if err != nil {
+		return err
+	}
+	if rule == nil || rule.FamilyID != user.FamilyID {
+		return c.JSON(http.StatusNotFound, map[string]string{"error": "reminder rule not found"})
+	}
+	if rule.CreatedBy != user.ID && !canManageReferenceData(user) {
+		return c.JSON(http.StatusForbidden, map[string]string{"error": "only the author or an administrative user can delete this reminder rule"})
+	}
+	if err := h.store.DeleteReminderRule(ctx, user.FamilyID, rule.ID); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return c.JSON(http.StatusNotFound, map[string]string{"error": "reminder rule not found"})
+		}
+		return err
+	}
+	return c.NoContent(http.StatusNoContent)
+}
+
+// reminderChannels normalizes the requested channels, defaulting to the
+// in-app inbox.
+func reminderChannels(values []string) ([]string, error) {
+	if len(values) == 0 {
+		return []string{store.ReminderChannelInbox}, nil
+	}
+	seen := make(map[string]bool, len(values))
+	var channels []string
+	for _, value := range values {
+		channel := strings.ToLower(strings.TrimSpace(value))
+		if channel != store.ReminderChannelEmail && channel != store.ReminderChannelInbox {
+			return nil, errors.New("channels must be email or inbox")
+		}
+		if !seen[channel] {
+			seen[channel] = true
+			channels = append(channels, channel)
+		}
+	}
+	return channels, nil
+}
+
+func (h *Handlers) ListInboxMessages(c echo.Context) error {
+	user, err := h.resolvePathUser(c)
+	if err != nil {
+		return h.handleUserAccessError(c, err)
+	}
+	unreadOnly := strings.EqualFold(strings.TrimSpace(c.QueryParam("unread")), "true")
+	messages, err := h.store.ListInboxMessages(c.Request().Context(), user.ID, unreadOnly)
+	if err != nil {
+		return err
+	}
+	if messages == nil {
+		messages = []domain.InboxMessage{}
+	}
+	return c.JSON(http.StatusOK, messages)
+}
+
+func (h *Handlers) MarkInboxMessageRead(c echo.Context) error {
+	user, err := h.resolvePathUser(c)
+	if err != nil {
+		return h.handleUserAccessError(c, err)
+	}
+	if err := h.store.MarkInboxMessageRead(c.Request().Context(), user.ID, c.Param("messageId"), time.Now().UTC()); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return c.JSON(http.StatusNotFound, map[string]string{"error": "message not found"})
+		}
+		return err
+	}
+	return c.NoContent(http.StatusNoContent)
+}
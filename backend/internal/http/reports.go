@@ -0,0 +1,236 @@
+package http
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/labstack/echo/v4"
+
+	"familybudget/internal/domain"
+	"familybudget/internal/store"
+)
+
+// sundayFirstRegions are the locale regions whose calendars start the week on
+// Sunday; everyone else uses the ISO Monday start.
+var sundayFirstRegions = map[string]bool{
+	"US": true, "CA": true, "MX": true, "BR": true, "JP": true, "KR": true, "TW": true,
+	"HK": true, "IL": true, "PH": true, "IN": true, "ZA": true, "SA": true,
+}
+
+// localeWeekStart returns the first day of the week for a locale such as
+// ru-RU or en_US.
+func localeWeekStart(locale string) time.Weekday {
+	parts := strings.FieldsFunc(locale, func(r rune) bool { return r == '-' || r == '_' })
+	if len(parts) > 1 && sundayFirstRegions[strings.ToUpper(parts[len(parts)-1])] {
+		return time.Sunday
+	}
+	return time.Monday
+}
+
+// parseReportBound parses a report boundary given as a date in the family
+// time zone or as RFC3339. Dates used as the end of a period include the
+// whole day, so the returned end is exclusive.
+func parseReportBound(value string, loc *time.Location, end bool) (*time.Time, error) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return nil, nil
+	}
+	if day, err := time.ParseInLocation("2006-01-02", value, loc); err == nil {
+		if end {
+			day = day.AddDate(0, 0, 1)
+		}
+		return &day, nil
+	}
+	parsed, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return nil, err
+	}
+	if end {
+		parsed = parsed.Add(time.Nanosecond)
+	}
+	return &parsed, nil
+}
+
+// defaultReportSpan is how far back a time series reaches without a
+// start_date.
+func defaultReportSpan(end time.Time, interval string) time.Time {
+	switch interval {
+	case store.ReportIntervalWeek:
+		return end.AddDate(0, 0, -7*12)
+	case store.ReportIntervalMonth:
+		return end.AddDate(0, -12, 0)
+	case store.ReportIntervalQuarter:
+		return end.AddDate(0, -24, 0)
+	case store.ReportIntervalYear:
+		return end.AddDate(-5, 0, 0)
+	default:
+		return end.AddDate(0, 0, -30)
+	}
+}
+
+// GetTimeSeriesReport returns income, expense and net per day, week, month,
+// quarter or year in the family time zone. Weeks start on the first day of
+// the member's locale unless ?week_start= says otherwise; ?split_by= adds a
+// per-category, per-account or per-member breakdown.
+func (h *Handlers) GetTimeSeriesReport(c echo.Context) error {
+	user, err := h.resolvePathUser(c)
+	if err != nil {
+		return h.handleUserAccessError(c, err)
+	}
+	ctx := c.Request().Context()
+
+	interval := strings.ToLower(strings.TrimSpace(c.QueryParam("interval")))
+	if interval == "" {
+		interval = store.ReportIntervalMonth
+	}
+	if !store.ValidReportInterval(interval) {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "interval must be day, week, month, quarter or year"})
+	}
+	split := strings.ToLower(strings.TrimSpace(c.QueryParam("split_by")))
+	if split != "" && !store.ValidReportSplit(split) {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "split_by must be category, account or member"})
+	}
+	amounts, err := reportAmounts(c.QueryParam("amounts"))
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+	}
+
+	weekStart := localeWeekStart(user.Locale)
+	switch strings.ToLower(strings.TrimSpace(c.QueryParam("week_start"))) {
+	case "":
+	case "monday":
+		weekStart = time.Monday
+	case "sunday":
+		weekStart = time.Sunday
+	case "saturday":
+		weekStart = time.Saturday
+	default:
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "week_start must be monday, sunday or saturday"})
+	}
+
+	loc, err := h.store.FamilyLocation(ctx, user.FamilyID)
+	if err != nil {
+		return err
+	}
+	start, err := parseReportBound(c.QueryParam("start_date"), loc, false)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "start_date must be a date (YYYY-MM-DD) or RFC3339"})
+	}
+	end, err := parseReportBound(c.QueryParam("end_date"), loc, true)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "end_date must be a date (YYYY-MM-DD) or RFC3339"})
+	}
+	if end == nil {
+		now := time.Now()
+		end = &now
+	}
+	if start == nil {
+		from := defaultReportSpan(*end, interval)
+		start = &from
+	}
+	if !start.Before(*end) {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "start_date must be before end_date"})
+	}
+
+	converter, err := h.reportConverter(ctx, user, c.QueryParam("convert"), loc)
+	if err != nil {
+		if errors.Is(err, errInvalidConvert) {
+			return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+		}
+		return err
+	}
+
+	report, err := h.store.TimeSeriesReport(ctx, user.FamilyID, store.TimeSeriesOptions{
+		Start:     *start,
+		End:       *end,
+		Interval:  interval,
+		SplitBy:   split,
+		Location:  loc,
+		WeekStart: weekStart,
+		Converter: converter,
+		Amounts:   amounts,
+	})
+	if err != nil {
+		if errors.Is(err, store.ErrReportTooLarge) {
+			return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error() + ", use a larger interval or a shorter period"})
+		}
+		return err
+	}
+	if converter != nil {
+		report.Conversion = converter.Summary()
+	}
+	return c.JSON(http.StatusOK, map[string]interface{}{"report": report})
+}
+
+// reportComparisonModes parses ?compare=: previous, year_ago, both, or a
+// comma-separated list.
+func reportComparisonModes(value string) ([]string, error) {
+	var modes []string
+	seen := make(map[string]bool)
+	for _, part := range strings.Split(value, ",") {
+		part = strings.ToLower(strings.TrimSpace(part))
+		var add []string
+		switch part {
+		case "":
+			continue
+		case store.ReportComparePrevious, store.ReportCompareYearAgo:
+			add = []string{part}
+		case "both":
+			add = []string{store.ReportComparePrevious, store.ReportCompareYearAgo}
+		default:
+			return nil, errors.New("compare must be previous, year_ago or both")
+		}
+		for _, mode := range add {
+			if !seen[mode] {
+				seen[mode] = true
+				modes = append(modes, mode)
+			}
+		}
+	}
+	return modes, nil
+}
+
+// reportConverter returns the converter into the family currency when the
+// report asks for one: ?convert= overrides the member's
+// show_totals_in_family_currency setting. It returns nil when amounts stay in
+// their own currencies.
+func (h *Handlers) reportConverter(ctx context.Context, user *domain.User, raw string, loc *time.Location) (*store.RateConverter, error) {
+	enabled := user.DisplaySettings.ShowTotalsInFamilyCurrency
+	if raw = strings.TrimSpace(raw); raw != "" {
+		parsed, err := strconv.ParseBool(raw)
+		if err != nil {
+			return nil, errInvalidConvert
+		}
+		enabled = parsed
+	}
+	if !enabled {
+		return nil, nil
+	}
+	family, err := h.store.GetFamily(ctx, user.FamilyID)
+	if err != nil {
+		return nil, err
+	}
+	if family == nil {
+		return nil, errors.New("family not found")
+	}
+	return h.store.NewRateConverter(family.CurrencyBase, loc), nil
+}
+
+var errInvalidConvert = errors.New("convert must be true or false")
+
+// reportAmounts parses ?amounts=: account (the default) reports transactions
+// paid in another currency at the amount charged to the account, original
+// at the amount paid.
+func reportAmounts(value string) (string, error) {
+	switch value = strings.ToLower(strings.TrimSpace(value)); value {
+	case "", store.ReportAmountsAccount:
+		return store.ReportAmountsAccount, nil
+	case store.ReportAmountsOriginal:
+		return value, nil
+	}
+	return "", errors.New("amounts must be account or original")
+}
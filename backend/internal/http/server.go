@@ -35,6 +35,8 @@ func (s *Server) Echo() *echo.Echo {
 func RegisterRoutes(e *echo.Echo, handlers *Handlers) {
 	api := e.Group("/api/v1")
 	api.POST("/users", handlers.RegisterUser)
+	api.GET("/calendar/:token", handlers.CalendarFeed)
+	api.GET("/currencies", handlers.ListCurrencies)
 
 	secured := api.Group("")
 	secured.Use(handlers.RequireAuth)
@@ -48,13 +50,85 @@ func RegisterRoutes(e *echo.Echo, handlers *Handlers) {
 	secured.POST("/users/:id/categories/:categoryId/archive", handlers.ToggleCategoryArchive)
 	secured.GET("/users/:id/accounts", handlers.ListAccounts)
 	secured.POST("/users/:id/accounts", handlers.CreateAccount)
+	secured.GET("/users/:id/accounts/:accountId/exchanges", handlers.ListAccountExchanges)
+	secured.POST("/users/:id/accounts/:accountId/exchanges", handlers.CreateAccountExchange)
 	secured.GET("/users/:id/members", handlers.ListMembers)
+	secured.PUT("/users/:id/members/:memberId/role", handlers.UpdateMemberRole)
 	secured.POST("/transactions", handlers.CreateTransaction)
 	secured.GET("/users/:id/transactions", handlers.ListTransactions)
 	secured.GET("/users/:id/reports/overview", handlers.GetReportsOverview)
+	secured.GET("/users/:id/reports/timeseries", handlers.GetTimeSeriesReport)
 	secured.GET("/users/:id/planned-operations", handlers.ListPlannedOperations)
 	secured.POST("/users/:id/planned-operations", handlers.CreatePlannedOperation)
 	secured.POST("/users/:id/planned-operations/:operationId/complete", handlers.CompletePlannedOperation)
+	secured.PUT("/users/:id/planned-operations/:operationId", handlers.UpdatePlannedOperation)
+	secured.DELETE("/users/:id/planned-operations/:operationId", handlers.DeletePlannedOperation)
+	secured.POST("/users/:id/planned-operations/:operationId/skip", handlers.SkipPlannedOperation)
+	secured.POST("/users/:id/planned-operations/:operationId/snooze", handlers.SnoozePlannedOperation)
+	secured.GET("/users/:id/planned-operations/:operationId/history", handlers.PlannedOperationHistory)
+	secured.GET("/users/:id/planned-occurrences", handlers.ListPlannedOccurrences)
+	secured.GET("/users/:id/forecast", handlers.Forecast)
+	secured.GET("/users/:id/subscription-suggestions", handlers.ListSubscriptionSuggestions)
+	secured.POST("/users/:id/subscription-suggestions/:suggestionId/convert", handlers.ConvertSubscriptionSuggestion)
+	secured.POST("/users/:id/subscription-suggestions/:suggestionId/dismiss", handlers.DismissSubscriptionSuggestion)
+	secured.GET("/users/:id/calendar-feed", handlers.GetCalendarFeed)
+	secured.POST("/users/:id/calendar-feed", handlers.IssueCalendarFeed)
+	secured.DELETE("/users/:id/calendar-feed", handlers.RevokeCalendarFeed)
+	secured.GET("/users/:id/reminder-rules", handlers.ListReminderRules)
+	secured.POST("/users/:id/reminder-rules", handlers.CreateReminderRule)
+	secured.DELETE("/users/:id/reminder-rules/:ruleId", handlers.DeleteReminderRule)
+	secured.GET("/users/:id/inbox", handlers.ListInboxMessages)
+	secured.POST("/users/:id/inbox/:messageId/read", handlers.MarkInboxMessageRead)
+	secured.GET("/users/:id/exchange-rates", handlers.ListExchangeRates)
+	secured.POST("/users/:id/exchange-rates", handlers.CreateExchangeRate)
+	secured.GET("/users/:id/exchange-rates/lookup", handlers.LookupExchangeRate)
+	secured.DELETE("/users/:id/exchange-rates/:rateId", handlers.DeleteExchangeRate)
+	secured.GET("/users/:id/currencies", handlers.ListFamilyCurrencies)
+	secured.PUT("/users/:id/currencies/:code", handlers.EnableFamilyCurrency)
+	secured.DELETE("/users/:id/currencies/:code", handlers.DisableFamilyCurrency)
+	secured.GET("/users/:id/import-profiles", handlers.ListImportProfiles)
+	secured.POST("/users/:id/import-profiles", handlers.CreateImportProfile)
+	secured.DELETE("/users/:id/import-profiles/:profileId", handlers.DeleteImportProfile)
+	secured.POST("/users/:id/imports", handlers.CreateImport)
+	secured.GET("/users/:id/imports/:importId", handlers.GetImport)
+	secured.PUT("/users/:id/imports/:importId", handlers.UpdateImport)
+	secured.DELETE("/users/:id/imports/:importId", handlers.CancelImport)
+	secured.POST("/users/:id/imports/:importId/commit", handlers.CommitImport)
+	secured.GET("/users/:id/envelopes", handlers.ListEnvelopes)
+	secured.POST("/users/:id/envelopes", handlers.CreateEnvelope)
+	secured.POST("/users/:id/envelopes/move", handlers.MoveEnvelopeFunds)
+	secured.PUT("/users/:id/envelopes/:envelopeId", handlers.UpdateEnvelope)
+	secured.POST("/users/:id/envelopes/:envelopeId/allocate", handlers.AllocateEnvelope)
+	secured.GET("/users/:id/envelopes/:envelopeId/movements", handlers.ListEnvelopeMovements)
+	secured.GET("/users/:id/goals", handlers.ListGoals)
+	secured.POST("/users/:id/goals", handlers.CreateGoal)
+	secured.GET("/users/:id/goals/:goalId", handlers.GetGoal)
+	secured.PUT("/users/:id/goals/:goalId", handlers.UpdateGoal)
+	secured.POST("/users/:id/goals/:goalId/contributions", handlers.AddGoalContribution)
+	secured.GET("/users/:id/debts", handlers.ListDebts)
+	secured.POST("/users/:id/debts", handlers.CreateDebt)
+	secured.GET("/users/:id/debts/counterparties", handlers.ListDebtCounterparties)
+	secured.GET("/users/:id/debts/:debtId", handlers.GetDebt)
+	secured.PUT("/users/:id/debts/:debtId", handlers.UpdateDebt)
+	secured.POST("/users/:id/debts/:debtId/repayments", handlers.AddDebtRepayment)
+	secured.GET("/users/:id/transactions/:transactionId/split", handlers.GetExpenseSplit)
+	secured.PUT("/users/:id/transactions/:transactionId/split", handlers.SplitExpense)
+	secured.DELETE("/users/:id/transactions/:transactionId/split", handlers.DeleteExpenseSplit)
+	secured.GET("/users/:id/splits/balances", handlers.GetSplitBalances)
+	secured.GET("/users/:id/splits/settlements", handlers.ListSettlements)
+	secured.POST("/users/:id/splits/settlements", handlers.CreateSettlements)
+	secured.GET("/users/:id/allowances", handlers.ListAllowances)
+	secured.POST("/users/:id/allowances", handlers.CreateAllowance)
+	secured.PUT("/users/:id/allowances/:allowanceId", handlers.UpdateAllowance)
+	secured.GET("/users/:id/spending-caps", handlers.ListSpendingCaps)
+	secured.PUT("/users/:id/spending-caps/:categoryId", handlers.PutSpendingCap)
+	secured.DELETE("/users/:id/spending-caps/:categoryId", handlers.DeleteSpendingCap)
+	secured.GET("/users/:id/approvals", handlers.ListApprovals)
+	secured.GET("/users/:id/approvals/policy", handlers.GetApprovalPolicy)
+	secured.PUT("/users/:id/approvals/policy", handlers.UpdateApprovalPolicy)
+	secured.POST("/users/:id/approvals/:approvalId/approve", handlers.ApproveTransaction)
+	secured.POST("/users/:id/approvals/:approvalId/reject", handlers.RejectTransaction)
+	secured.GET("/users/:id/events", handlers.ListEvents)
 	secured.GET("/access/scope", handlers.GetAccessScope)
 }
 
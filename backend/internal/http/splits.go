@@ -0,0 +1,323 @@
+package http
+
+import (
+	"errors"
+	"math"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"
+
+	"familybudget/internal/domain"
+	"familybudget/internal/store"
+)
+
+// SplitParticipantRequest describes one member sharing an expense. Percent is
+// used by the percent method and AmountMinor by the exact method.
+type SplitParticipantRequest struct {
+	UserID      string  `json:"user_id"`
+	Percent     float64 `json:"percent"`
+	AmountMinor int64   `json:"amount_minor"`
+}
+
+type ExpenseSplitRequest struct {
+	Method       string                    `json:"method"`
+	Participants []SplitParticipantRequest `json:"participants"`
+}
+
+type SettlementRequest struct {
+	Transfers []domain.SettlementTransfer `json:"transfers"`
+}
+
+func (h *Handlers) GetExpenseSplit(c echo.Context) error {
+	user, err := h.resolvePathUser(c)
+	if err != nil {
+		return h.handleUserAccessError(c, err)
+	}
+	split, err := h.store.GetExpenseSplitByTransaction(c.Request().Context(), c.Param("transactionId"))
+	if err != nil {
+		return err
+	}
+	if split == nil || split.FamilyID != user.FamilyID {
+		return c.JSON(http.StatusNotFound, map[string]string{"error": "split not found"})
+	}
+	return c.JSON(http.StatusOK, map[string]interface{}{"split": split})
+}
+
+func (h *Handlers) SplitExpense(c echo.Context) error {
+	user, err := h.resolvePathUser(c)
+	if err != nil {
+		return h.handleUserAccessError(c, err)
+	}
+	ctx := c.Request().Context()
+
+	txn, err := h.store.GetTransaction(ctx, c.Param("transactionId"))
+	if err != nil {
+		return err
+	}
+	if txn == nil || txn.FamilyID != user.FamilyID {
+		return c.JSON(http.StatusNotFound, map[string]string{"error": "transaction not found"})
+	}
+	if txn.UserID != user.ID && !canManageReferenceData(user) {
+		return c.JSON(http.StatusForbidden, map[string]string{"error": "only the payer or administrative users can split an expense"})
+	}
+	if txn.Type != "expense" {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "only expenses can be split"})
+	}
+	account, err := h.store.GetAccount(ctx, txn.AccountID)
+	if err != nil {
+		return err
+	}
+	if account == nil {
+		return c.JSON(http.StatusNotFound, map[string]string{"error": "account not found"})
+	}
+	if account.IsShared {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "expenses paid from shared accounts cannot be split"})
+	}
+
+	var req ExpenseSplitRequest
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "invalid payload"})
+	}
+
+	members, err := h.store.ListFamilyMembers(ctx, user.FamilyID)
+	if err != nil {
+		return err
+	}
+	memberIDs := make(map[string]struct{}, len(members))
+	for _, member := range members {
+		memberIDs[member.ID] = struct{}{}
+	}
+
+	shares, err := computeSplitShares(txn.AmountMinor, &req, memberIDs)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+	}
+
+	split := &domain.ExpenseSplit{
+		ID:            uuid.NewString(),
+		FamilyID:      txn.FamilyID,
+		TransactionID: txn.ID,
+		PayerID:       txn.UserID,
+		Method:        req.Method,
+		Currency:      txn.Currency,
+		TotalMinor:    txn.AmountMinor,
+		Shares:        shares,
+		CreatedBy:     user.ID,
+		CreatedAt:     time.Now().UTC(),
+	}
+	if err := h.store.SaveExpenseSplit(ctx, split); err != nil {
+		return err
+	}
+	return c.JSON(http.StatusOK, map[string]interface{}{"split": split})
+}
+
+func (h *Handlers) DeleteExpenseSplit(c echo.Context) error {
+	user, err := h.resolvePathUser(c)
+	if err != nil {
+		return h.handleUserAccessError(c, err)
+	}
+	ctx := c.Request().Context()
+
+	split, err := h.store.GetExpenseSplitByTransaction(ctx, c.Param("transactionId"))
+	if err != nil {
+		return err
+	}
+	if split == nil || split.FamilyID != user.FamilyID {
+		return c.JSON(http.StatusNotFound, map[string]string{"error": "split not found"})
+	}
+	if split.PayerID != user.ID && !canManageReferenceData(user) {
+		return c.JSON(http.StatusForbidden, map[string]string{"error": "only the payer or administrative users can remove a split"})
+	}
+	if err := h.store.DeleteExpenseSplit(ctx, split.FamilyID, split.TransactionID); err != nil {
+		return err
+	}
+	return c.NoContent(http.StatusNoContent)
+}
+
+func (h *Handlers) GetSplitBalances(c echo.Context) error {
+	user, err := h.resolvePathUser(c)
+	if err != nil {
+		return h.handleUserAccessError(c, err)
+	}
+	balances, err := h.store.GetSplitBalances(c.Request().Context(), user.FamilyID)
+	if err != nil {
+		return err
+	}
+	return c.JSON(http.StatusOK, balances)
+}
+
+func (h *Handlers) ListSettlements(c echo.Context) error {
+	user, err := h.resolvePathUser(c)
+	if err != nil {
+		return h.handleUserAccessError(c, err)
+	}
+	settlements, err := h.store.ListSettlements(c.Request().Context(), user.FamilyID)
+	if err != nil {
+		return err
+	}
+	return c.JSON(http.StatusOK, map[string]interface{}{"settlements": settlements})
+}
+
+// CreateSettlements records settle-up transfers. Without explicit transfers
+// the currently suggested set is recorded, which zeroes every balance.
+func (h *Handlers) CreateSettlements(c echo.Context) error {
+	user, err := h.resolvePathUser(c)
+	if err != nil {
+		return h.handleUserAccessError(c, err)
+	}
+	ctx := c.Request().Context()
+
+	var req SettlementRequest
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "invalid payload"})
+	}
+
+	transfers := req.Transfers
+	if len(transfers) == 0 {
+		if !canManageReferenceData(user) {
+			return c.JSON(http.StatusForbidden, map[string]string{"error": "only administrative users can settle all balances"})
+		}
+		balances, err := h.store.GetSplitBalances(ctx, user.FamilyID)
+		if err != nil {
+			return err
+		}
+		transfers = balances.Suggested
+	} else {
+		members, err := h.store.ListFamilyMembers(ctx, user.FamilyID)
+		if err != nil {
+			return err
+		}
+		memberIDs := make(map[string]struct{}, len(members))
+		for _, member := range members {
+			memberIDs[member.ID] = struct{}{}
+		}
+		for i := range transfers {
+			item := &transfers[i]
+			item.Currency = strings.ToUpper(strings.TrimSpace(item.Currency))
+			if _, ok := memberIDs[item.FromUserID]; !ok {
+				return c.JSON(http.StatusBadRequest, map[string]string{"error": "from_user_id must be a family member"})
+			}
+			if _, ok := memberIDs[item.ToUserID]; !ok {
+				return c.JSON(http.StatusBadRequest, map[string]string{"error": "to_user_id must be a family member"})
+			}
+			if item.FromUserID == item.ToUserID {
+				return c.JSON(http.StatusBadRequest, map[string]string{"error": "transfer must be between different members"})
+			}
+			if !isSupportedCurrency(item.Currency) {
+				return c.JSON(http.StatusBadRequest, map[string]string{"error": "unsupported currency"})
+			}
+			if item.AmountMinor <= 0 {
+				return c.JSON(http.StatusBadRequest, map[string]string{"error": "amount_minor must be positive"})
+			}
+			if user.ID != item.FromUserID && user.ID != item.ToUserID && !canManageReferenceData(user) {
+				return c.JSON(http.StatusForbidden, map[string]string{"error": "only participants or administrative users can record a settlement"})
+			}
+		}
+	}
+
+	now := time.Now().UTC()
+	settlements := make([]domain.Settlement, 0, len(transfers))
+	for _, item := range transfers {
+		settlements = append(settlements, domain.Settlement{
+			ID:          uuid.NewString(),
+			FamilyID:    user.FamilyID,
+			FromUserID:  item.FromUserID,
+			ToUserID:    item.ToUserID,
+			Currency:    item.Currency,
+			AmountMinor: item.AmountMinor,
+			CreatedBy:   user.ID,
+			CreatedAt:   now,
+		})
+	}
+	if err := h.store.RecordSettlements(ctx, settlements); err != nil {
+		return err
+	}
+
+	balances, err := h.store.GetSplitBalances(ctx, user.FamilyID)
+	if err != nil {
+		return err
+	}
+	return c.JSON(http.StatusCreated, map[string]interface{}{"settlements": settlements, "balances": balances})
+}
+
+// computeSplitShares turns the requested split into exact minor amounts that
+// always add up to the expense total. Rounding leftovers go to the first
+// participants in request order.
+func computeSplitShares(total int64, req *ExpenseSplitRequest, memberIDs map[string]struct{}) ([]domain.SplitShare, error) {
+	req.Method = strings.ToLower(strings.TrimSpace(req.Method))
+	if req.Method == "" {
+		req.Method = store.SplitMethodEqual
+	}
+	if len(req.Participants) == 0 {
+		return nil, errors.New("participants are required")
+	}
+
+	seen := make(map[string]struct{}, len(req.Participants))
+	for i := range req.Participants {
+		p := &req.Participants[i]
+		p.UserID = strings.TrimSpace(p.UserID)
+		if _, ok := memberIDs[p.UserID]; !ok {
+			return nil, errors.New("participants must be family members")
+		}
+		if _, ok := seen[p.UserID]; ok {
+			return nil, errors.New("participants must be unique")
+		}
+		seen[p.UserID] = struct{}{}
+	}
+
+	shares := make([]domain.SplitShare, len(req.Participants))
+	for i, p := range req.Participants {
+		shares[i].UserID = p.UserID
+	}
+
+	switch req.Method {
+	case store.SplitMethodEqual:
+		count := int64(len(shares))
+		for i := range shares {
+			shares[i].AmountMinor = total / count
+		}
+		distributeRemainder(shares, total)
+	case store.SplitMethodPercent:
+		var sum float64
+		for i, p := range req.Participants {
+			if p.Percent <= 0 {
+				return nil, errors.New("percent must be positive")
+			}
+			sum += p.Percent
+			shares[i].AmountMinor = int64(math.Floor(float64(total) * p.Percent / 100))
+		}
+		if math.Abs(sum-100) > 0.0001 {
+			return nil, errors.New("percentages must add up to 100")
+		}
+		distributeRemainder(shares, total)
+	case store.SplitMethodExact:
+		var sum int64
+		for i, p := range req.Participants {
+			if p.AmountMinor <= 0 {
+				return nil, errors.New("amount_minor must be positive")
+			}
+			sum += p.AmountMinor
+			shares[i].AmountMinor = p.AmountMinor
+		}
+		if sum != total {
+			return nil, errors.New("exact amounts must add up to the expense amount")
+		}
+	default:
+		return nil, errors.New("method must be equal, percent or exact")
+	}
+	return shares, nil
+}
+
+func distributeRemainder(shares []domain.SplitShare, total int64) {
+	var sum int64
+	for _, share := range shares {
+		sum += share.AmountMinor
+	}
+	for i := 0; sum < total; i = (i + 1) % len(shares) {
+		shares[i].AmountMinor++
+		sum++
+	}
+}
@@ -0,0 +1,165 @@
+package http
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"
+
+	"familybudget/internal/domain"
+	"familybudget/internal/store"
+)
+
+// ConvertSubscriptionRequest overrides fields of the planned operation created
+// from a suggestion. By default it uses the detected title, the account of the
+// last charge, the last amount and the next expected date.
+type ConvertSubscriptionRequest struct {
+	Title       *string `json:"title"`
+	AccountID   *string `json:"account_id"`
+	AmountMinor *int64  `json:"amount_minor"`
+	DueAt       *string `json:"due_at"`
+	AutoPost    bool    `json:"auto_post"`
+}
+
+func (h *Handlers) ListSubscriptionSuggestions(c echo.Context) error {
+	user, err := h.resolvePathUser(c)
+	if err != nil {
+		return h.handleUserAccessError(c, err)
+	}
+	ctx := c.Request().Context()
+
+	loc, err := h.store.FamilyLocation(ctx, user.FamilyID)
+	if err != nil {
+		return err
+	}
+	suggestions, err := h.store.DetectSubscriptions(ctx, user.FamilyID, time.Now(), loc)
+	if err != nil {
+		return err
+	}
+	if suggestions == nil {
+		suggestions = []domain.SubscriptionSuggestion{}
+	}
+	return c.JSON(http.StatusOK, map[string]interface{}{"suggestions": suggestions})
+}
+
+// ConvertSubscriptionSuggestion turns a suggestion into a recurring planned
+// operation authored by the member.
+func (h *Handlers) ConvertSubscriptionSuggestion(c echo.Context) error {
+	user, err := h.resolvePathUser(c)
+	if err != nil {
+		return h.handleUserAccessError(c, err)
+	}
+	ctx := c.Request().Context()
+
+	var req ConvertSubscriptionRequest
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "invalid payload"})
+	}
+
+	loc, err := h.store.FamilyLocation(ctx, user.FamilyID)
+	if err != nil {
+		return err
+	}
+	now := time.Now().UTC()
+	suggestion, err := h.store.GetSubscriptionSuggestion(ctx, user.FamilyID, c.Param("suggestionId"), now, loc)
+	if err != nil {
+		return err
+	}
+	if suggestion == nil {
+		return c.JSON(http.StatusNotFound, map[string]string{"error": "subscription suggestion not found"})
+	}
+
+	plan := &domain.PlannedOperation{
+		ID:          uuid.NewString(),
+		FamilyID:    user.FamilyID,
+		UserID:      user.ID,
+		AccountID:   suggestion.AccountID,
+		CategoryID:  suggestion.CategoryID,
+		Type:        suggestion.Type,
+		Title:       suggestion.Title,
+		AmountMinor: suggestion.LastAmountMinor,
+		DueAt:       suggestion.NextExpectedAt,
+		StartsAt:    suggestion.NextExpectedAt,
+		Recurrence:  suggestion.Recurrence,
+		AutoPost:    req.AutoPost,
+		CreatedAt:   now,
+		UpdatedAt:   now,
+	}
+	if req.Title != nil {
+		plan.Title = strings.TrimSpace(*req.Title)
+	}
+	if plan.Title == "" {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "title is required"})
+	}
+	if req.AccountID != nil {
+		plan.AccountID = strings.TrimSpace(*req.AccountID)
+	}
+	if req.AmountMinor != nil {
+		if *req.AmountMinor <= 0 {
+			return c.JSON(http.StatusBadRequest, map[string]string{"error": "amount_minor must be positive"})
+		}
+		plan.AmountMinor = *req.AmountMinor
+	}
+	if req.DueAt != nil {
+		dueAt, err := time.Parse(time.RFC3339, *req.DueAt)
+		if err != nil {
+			return c.JSON(http.StatusBadRequest, map[string]string{"error": "due_at must be RFC3339"})
+		}
+		plan.DueAt, plan.StartsAt = dueAt.UTC(), dueAt.UTC()
+	}
+	if message, err := h.validatePlannedTargets(ctx, plan); err != nil || message != "" {
+		if err != nil {
+			return err
+		}
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": message})
+	}
+	if plan.Currency != suggestion.Currency && req.AmountMinor == nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "amount_minor is required when the account currency differs from the detected charges"})
+	}
+
+	if err := h.store.ConvertSubscriptionSuggestion(ctx, suggestion.ID, plan, now); err != nil {
+		if errors.Is(err, store.ErrSubscriptionDecided) {
+			return c.JSON(http.StatusConflict, map[string]string{"error": err.Error()})
+		}
+		if errors.Is(err, store.ErrAccountArchived) {
+			return c.JSON(http.StatusBadRequest, map[string]string{"error": "account is archived"})
+		}
+		return h.handlePlannedOperationError(c, err)
+	}
+	return h.respondPlannedOperation(c, http.StatusCreated, plan.ID)
+}
+
+// DismissSubscriptionSuggestion hides a suggestion for the whole family.
+func (h *Handlers) DismissSubscriptionSuggestion(c echo.Context) error {
+	user, err := h.resolvePathUser(c)
+	if err != nil {
+		return h.handleUserAccessError(c, err)
+	}
+	if !canManageReferenceData(user) {
+		return c.JSON(http.StatusForbidden, map[string]string{"error": "only owner or adult can dismiss subscription suggestions"})
+	}
+	ctx := c.Request().Context()
+
+	loc, err := h.store.FamilyLocation(ctx, user.FamilyID)
+	if err != nil {
+		return err
+	}
+	now := time.Now().UTC()
+	suggestion, err := h.store.GetSubscriptionSuggestion(ctx, user.FamilyID, c.Param("suggestionId"), now, loc)
+	if err != nil {
+		return err
+	}
+	if suggestion == nil {
+		return c.JSON(http.StatusNotFound, map[string]string{"error": "subscription suggestion not found"})
+	}
+	if err := h.store.DismissSubscriptionSuggestion(ctx, user.FamilyID, suggestion.ID, user.ID, now); err != nil {
+		if errors.Is(err, store.ErrSubscriptionDecided) {
+			return c.JSON(http.StatusConflict, map[string]string{"error": err.Error()})
+		}
+		return err
+	}
+	return c.NoContent(http.StatusNoContent)
+}
@@ -0,0 +1,102 @@
+// Package ical writes iCalendar (RFC 5545) feeds.
+package ical
+
+import (
+	"bytes"
+	"strings"
+	"time"
+)
+
+// maxLineOctets is the longest content line allowed before folding.
+const maxLineOctets = 75
+
+// Event is an all-day calendar event, optionally repeating by RRule.
+type Event struct {
+	UID         string
+	Stamp       time.Time
+	Date        time.Time
+	Summary     string
+	Description string
+	Categories  []string
+	RRule       string
+}
+
+// Calendar is a published calendar.
+type Calendar struct {
+	Name     string
+	Timezone string
+	Events   []Event
+}
+
+// Encode returns the calendar as an iCalendar object with CRLF line endings.
+func (c *Calendar) Encode() []byte {
+	var buf bytes.Buffer
+	line := func(name, value string) {
+		writeFolded(&buf, name+":"+value)
+	}
+	line("BEGIN", "VCALENDAR")
+	line("VERSION", "2.0")
+	line("PRODID", "-//familybudget//planned operations//EN")
+	line("CALSCALE", "GREGORIAN")
+	line("METHOD", "PUBLISH")
+	if c.Name != "" {
+		line("X-WR-CALNAME", EscapeText(c.Name))
+	}
+	if c.Timezone != "" {
+		line("X-WR-TIMEZONE", c.Timezone)
+	}
+	for _, event := range c.Events {
+		line("BEGIN", "VEVENT")
+		line("UID", event.UID)
+		line("DTSTAMP", event.Stamp.UTC().Format("20060102T150405Z"))
+		line("DTSTART;VALUE=DATE", event.Date.Format("20060102"))
+		line("DTEND;VALUE=DATE", event.Date.AddDate(0, 0, 1).Format("20060102"))
+		if event.RRule != "" {
+			line("RRULE", event.RRule)
+		}
+		line("SUMMARY", EscapeText(event.Summary))
+		if event.Description != "" {
+			line("DESCRIPTION", EscapeText(event.Description))
+		}
+		if len(event.Categories) > 0 {
+			escaped := make([]string, len(event.Categories))
+			for i, category := range event.Categories {
+				escaped[i] = EscapeText(category)
+			}
+			line("CATEGORIES", strings.Join(escaped, ","))
+		}
+		line("TRANSP", "TRANSPARENT")
+		line("END", "VEVENT")
+	}
+	line("END", "VCALENDAR")
+	return buf.Bytes()
+}
+
+// EscapeText escapes a TEXT property value.
+func EscapeText(value string) string {
+	replacer := strings.NewReplacer(`\`, `\\`, ";", `\;`, ",", `\,`, "\r\n", `\n`, "\n", `\n`, "\r", `\n`)
+	return replacer.Replace(value)
+}
+
+// writeFolded writes a content line, folding it into 75-octet chunks without
+// splitting UTF-8 sequences.
+func writeFolded(buf *bytes.Buffer, line string) {
+	limit := maxLineOctets
+	for len(line) > limit {
+		cut := limit
+		for cut > 0 && !isRuneStart(line[cut]) {
+			cut--
+		}
+		buf.WriteString(line[:cut])
+		buf.WriteString("\r\n ")
+		line = line[cut:]
+		// Continuation lines start with a space that counts towards the limit.
+		limit = maxLineOctets - 1
+	}
+	buf.WriteString(line)
+	buf.WriteString("\r\n")
+}
+
+func isRuneStart(b byte) bool {
+	return b&0xC0 != 0x80
+}
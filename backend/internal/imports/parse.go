@@ -0,0 +1,287 @@
+package imports
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"familybudget/internal/domain"
+)
+
+// Options are the family and account a statement is imported into.
+type Options struct {
+	FamilyID  string
+	AccountID string
+	// Currency is used for rows without a currency column: the account
+	// currency or, before an account is chosen, the family currency.
+	Currency string
+	// Location is used when the profile has no timezone.
+	Location *time.Location
+}
+
+// Result is a parsed statement. TotalRows counts the non-empty rows below
+// the header.
+type Result struct {
+	TotalRows int
+	Rows      []domain.ImportRow
+	Errors    []domain.ImportIssue
+	Warnings  []domain.ImportIssue
+}
+
+// Parse turns the rows of sheet below the header into import rows. Rows
+// that cannot be read are reported as errors and left out.
+func Parse(sheet *Sheet, profile domain.ImportProfile, opts Options) Result {
+	var result Result
+	headerRow, indexes, missing := sheet.header(profile)
+	if len(missing) > 0 {
+		sort.Strings(missing)
+		result.Errors = append(result.Errors, domain.ImportIssue{
+			Code:    "missing_column",
+			Message: "Колонки профиля не найдены в файле",
+			Details: map[string]string{"columns": strings.Join(missing, ", ")},
+		})
+		return result
+	}
+
+	location := opts.Location
+	if location == nil {
+		location = time.UTC
+	}
+	if profile.Timezone != "" {
+		if loaded, err := time.LoadLocation(profile.Timezone); err == nil {
+			location = loaded
+		}
+	}
+	layouts := make([]string, 0, 2)
+	for _, format := range alternatives(profile.DateFormat) {
+		layouts = append(layouts, goLayout(format))
+	}
+
+	for i := headerRow + 1; i < len(sheet.Rows); i++ {
+		record := sheet.Rows[i]
+		// Headers merged over two rows, or repeated on every page, come
+		// back as rows equal to the header.
+		if blank(record) || sameCells(record, sheet.Rows[headerRow], indexes) {
+			continue
+		}
+		result.TotalRows++
+		line := sheet.Lines[i]
+		cell := func(field string) string {
+			index, ok := indexes[field]
+			if !ok || index >= len(record) {
+				return ""
+			}
+			return strings.TrimSpace(record[index])
+		}
+
+		row := domain.ImportRow{
+			Row:         line,
+			Currency:    strings.ToUpper(opts.Currency),
+			Description: cell(ColumnDescription),
+			Category:    cell(ColumnCategory),
+		}
+
+		value := cell(ColumnOccurredAt)
+		occurredAt, err := parseDate(value, layouts, location)
+		if err != nil && sheet.SerialDates {
+			occurredAt, err = excelDate(value, sheet.Date1904, location)
+		}
+		if err != nil {
+			result.Errors = append(result.Errors, issue(line, "invalid_date", "Дата не распознана", value))
+			continue
+		}
+		row.OccurredAt = occurredAt.UTC()
+
+		if code := strings.ToUpper(cell(ColumnCurrency)); code != "" {
+			if code == "RUR" {
+				code = "RUB"
+			}
+			if _, ok := domain.LookupCurrency(code); !ok {
+				result.Errors = append(result.Errors, issue(line, "invalid_currency", "Валюта не распознана", code))
+				continue
+			}
+			row.Currency = code
+		}
+
+		value = cell(ColumnAmount)
+		amount, err := ParseAmount(value, profile.DecimalSeparator, domain.CurrencyExponent(row.Currency))
+		if err != nil {
+			result.Errors = append(result.Errors, issue(line, "invalid_amount", err.Error(), value))
+			continue
+		}
+		if amount == 0 {
+			result.Errors = append(result.Errors, issue(line, "invalid_amount", "Сумма не может быть нулевой", value))
+			continue
+		}
+		row.Type = "income"
+		row.AmountMinor = amount
+		if amount < 0 {
+			row.Type = "expense"
+			row.AmountMinor = -amount
+		}
+
+		if value = cell(ColumnAccountAmount); value != "" {
+			// The account currency is not known until an account is chosen;
+			// its exponent is that of opts.Currency.
+			accountAmount, err := ParseAmount(value, profile.DecimalSeparator, domain.CurrencyExponent(opts.Currency))
+			if err != nil {
+				result.Errors = append(result.Errors, issue(line, "invalid_amount", err.Error(), value))
+				continue
+			}
+			if accountAmount < 0 {
+				accountAmount = -accountAmount
+			}
+			row.AccountAmountMinor = &accountAmount
+		}
+
+		row.Hash = RowHash(opts.FamilyID, opts.AccountID, row.OccurredAt, amount, row.Description)
+		result.Rows = append(result.Rows, row)
+	}
+	if result.TotalRows == 0 {
+		result.Errors = append(result.Errors, domain.ImportIssue{Code: "no_rows", Message: "В файле нет операций"})
+	}
+	return result
+}
+
+// RowHash identifies a statement row as docs/imports.md prescribes:
+// sha256(family_id + account_id + occurred_at + amount + description).
+func RowHash(familyID, accountID string, occurredAt time.Time, amount int64, description string) string {
+	sum := sha256.Sum256([]byte(familyID + accountID + occurredAt.UTC().Format(time.RFC3339) + strconv.FormatInt(amount, 10) + description))
+	return hex.EncodeToString(sum[:])
+}
+
+// ParseAmount reads a signed amount written with decimalSeparator into
+// minor units of a currency with exponent decimals. Spaces, non-breaking
+// spaces and the other separator group thousands, the latter only in groups
+// of three digits so that a decimal comma read with a "." profile is an
+// error rather than a hundred times the amount; a Unicode minus or
+// parentheses mark a negative amount.
+func ParseAmount(value, decimalSeparator string, exponent int) (int64, error) {
+	thousands := ","
+	if decimalSeparator == "," {
+		thousands = "."
+	}
+	cleaned := strings.NewReplacer(" ", "", "\u00a0", "", "\u202f", "", "'", "", "−", "-").Replace(strings.TrimSpace(value))
+	if cleaned == "" {
+		return 0, errors.New("Сумма не может быть пустой")
+	}
+	negative := false
+	if strings.HasPrefix(cleaned, "(") && strings.HasSuffix(cleaned, ")") {
+		negative = true
+		cleaned = cleaned[1 : len(cleaned)-1]
+	}
+	switch {
+	case strings.HasPrefix(cleaned, "-"):
+		negative = !negative
+		cleaned = cleaned[1:]
+	case strings.HasPrefix(cleaned, "+"):
+		cleaned = cleaned[1:]
+	}
+
+	whole, fraction, _ := strings.Cut(cleaned, decimalSeparator)
+	if strings.Contains(whole, thousands) {
+		groups := strings.Split(whole, thousands)
+		for i, group := range groups {
+			if len(group) != 3 && (i > 0 || group == "" || len(group) > 3) {
+				return 0, errors.New("Разделитель разрядов не на месте, проверьте десятичный разделитель профиля")
+			}
+		}
+		whole = strings.Join(groups, "")
+	}
+	if whole == "" {
+		whole = "0"
+	}
+	if !digits(whole) || !digits(fraction) {
+		return 0, errors.New("Сумма не распознана")
+	}
+	if len(fraction) > exponent {
+		if strings.Trim(fraction[exponent:], "0") != "" {
+			return 0, errors.New("У суммы больше знаков после запятой, чем у валюты")
+		}
+		fraction = fraction[:exponent]
+	}
+	fraction += strings.Repeat("0", exponent-len(fraction))
+
+	units, err := strconv.ParseInt(whole, 10, 64)
+	if err != nil || units > math.MaxInt64/int64(math.Pow10(exponent)) {
+		return 0, errors.New("Сумма слишком велика")
+	}
+	amount := units * int64(math.Pow10(exponent))
+	if fraction != "" {
+		minor, _ := strconv.ParseInt(fraction, 10, 64)
+		amount += minor
+	}
+	if negative {
+		amount = -amount
+	}
+	return amount, nil
+}
+
+func parseDate(value string, layouts []string, location *time.Location) (time.Time, error) {
+	for _, layout := range layouts {
+		if parsed, err := time.ParseInLocation(layout, value, location); err == nil {
+			return parsed, nil
+		}
+	}
+	return time.Time{}, errors.New("unknown date format")
+}
+
+func sameCells(record, header []string, indexes map[string]int) bool {
+	for _, index := range indexes {
+		if index >= len(record) || index >= len(header) || strings.TrimSpace(record[index]) != strings.TrimSpace(header[index]) {
+			return false
+		}
+	}
+	return true
+}
+
+func issue(row int, code, message, value string) domain.ImportIssue {
+	return domain.ImportIssue{Row: row, Code: code, Message: message, Details: map[string]string{"value": value}}
+}
+
+func blank(record []string) bool {
+	for _, cell := range record {
+		if strings.TrimSpace(cell) != "" {
+			return false
+		}
+	}
+	return true
+}
+
+func digits(value string) bool {
+	for _, r := range value {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+// MatchCategories links rows to the family categories of the same type by
+// name and warns about category names the family does not have. Such rows,
+// like rows without a category, get the default category on commit.
+func MatchCategories(result *Result, categories []domain.Category) {
+	byName := make(map[string]string, len(categories))
+	for _, category := range categories {
+		if category.IsArchived {
+			continue
+		}
+		byName[strings.ToLower(category.Type)+"/"+strings.ToLower(strings.TrimSpace(category.Name))] = category.ID
+	}
+	for i := range result.Rows {
+		row := &result.Rows[i]
+		if row.Category == "" {
+			continue
+		}
+		if id, ok := byName[row.Type+"/"+strings.ToLower(row.Category)]; ok {
+			row.CategoryID = id
+			continue
+		}
+		result.Warnings = append(result.Warnings, issue(row.Row, "missing_category", "Категория не распознана", row.Category))
+	}
+}
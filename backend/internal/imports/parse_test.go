@@ -0,0 +1,146 @@
+package imports
+
+import (
+	"testing"
+	"time"
+
+	"familybudget/internal/domain"
+)
+
+func TestParseAmount(t *testing.T) {
+	tests := []struct {
+		value     string
+		separator string
+		exponent  int
+		want      int64
+		wantErr   bool
+	}{
+		{"1500.50", ".", 2, 150050, false},
+		{"-1 500,50", ",", 2, -150050, false},
+		{"1 500,5", ",", 2, 150050, false},
+		{"1 234 567,89", ",", 2, 123456789, false},
+		{"1.234.567,89", ",", 2, 123456789, false},
+		{"1,234,567.89", ".", 2, 123456789, false},
+		{"1'500.25", ".", 2, 150025, false},
+		{"−250,00", ",", 2, -25000, false},
+		{"(250.00)", ".", 2, -25000, false},
+		{"+12", ".", 2, 1200, false},
+		{",5", ",", 2, 50, false},
+		{"1500", ".", 0, 1500, false},
+		{"1.500", ",", 3, 1500000, false},
+		{"12.345", ".", 3, 12345, false},
+		{"12.3400", ".", 2, 1234, false},
+
+		{"12,50", ".", 2, 0, true},
+		{"1,5", ".", 2, 0, true},
+		{"1.50", ",", 2, 0, true},
+		{"1,2345.00", ".", 2, 0, true},
+		{",500.00", ".", 2, 0, true},
+		{"1000,000.00", ".", 2, 0, true},
+		{"12.345", ".", 2, 0, true},
+		{"", ".", 2, 0, true},
+		{"abc", ".", 2, 0, true},
+		{"1.2.3", ".", 2, 0, true},
+		{"99999999999999999999", ".", 2, 0, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.value+"/"+tt.separator, func(t *testing.T) {
+			got, err := ParseAmount(tt.value, tt.separator, tt.exponent)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("got error %v, want error %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Fatalf("got %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestReadCSV(t *testing.T) {
+	// "Дата;Сумма" and a row in Windows-1251.
+	data := []byte("\xc4\xe0\xf2\xe0;\xd1\xf3\xec\xec\xe0\n01.03.2026;-1 500,50\n")
+	sheet, err := readCSV(data, ";")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(sheet.Rows) != 2 || sheet.Rows[0][0] != "Дата" || sheet.Rows[0][1] != "Сумма" {
+		t.Fatalf("got rows %q", sheet.Rows)
+	}
+	if sheet.Lines[1] != 2 {
+		t.Fatalf("got line %d, want 2", sheet.Lines[1])
+	}
+
+	if _, err := readCSV([]byte("\xef\xbb\xbf"), ","); err == nil {
+		t.Fatal("an empty file was read")
+	}
+}
+
+func TestParse(t *testing.T) {
+	data := []byte("Выписка по счёту\n" +
+		"Дата операции;Сумма операции;Валюта операции;Сумма платежа;Категория;Описание\n" +
+		"01.03.2026 10:15:00;-1 500,50;RUR;;Кафе;Кофейня\n" +
+		"02.03.2026;12 000;RUB;;;Зарплата\n" +
+		";;;;;\n" +
+		"03.03.2026;12,5,0;RUB;;;Ошибка\n" +
+		"31.02.2026;100;RUB;;;Нет такой даты\n" +
+		"04.03.2026;0;RUB;;;Ноль\n" +
+		"05.03.2026;10;XXX;;;Валюта\n")
+	profile, ok := FindBuiltin("tinkoff-csv-v1")
+	if !ok {
+		t.Fatal("tinkoff-csv-v1 is missing")
+	}
+	sheet, err := Read(data, profile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	result := Parse(sheet, profile, Options{FamilyID: "family", AccountID: "account", Currency: "RUB"})
+
+	if result.TotalRows != 6 {
+		t.Fatalf("got %d rows, want 6", result.TotalRows)
+	}
+	if len(result.Rows) != 2 {
+		t.Fatalf("got %d parsed rows, want 2: %+v", len(result.Rows), result.Errors)
+	}
+	first := result.Rows[0]
+	moscow := time.FixedZone("MSK", 3*60*60)
+	if first.Type != "expense" || first.AmountMinor != 150050 || first.Currency != "RUB" || !first.OccurredAt.Equal(time.Date(2026, time.March, 1, 10, 15, 0, 0, moscow)) || first.Row != 3 {
+		t.Fatalf("got first row %+v", first)
+	}
+	if first.Category != "Кафе" {
+		t.Fatalf("got category %q", first.Category)
+	}
+	if second := result.Rows[1]; second.Type != "income" || second.AmountMinor != 1200000 {
+		t.Fatalf("got second row %+v", second)
+	}
+	if first.Hash != RowHash("family", "account", first.OccurredAt, -150050, "Кофейня") {
+		t.Fatal("the row hash does not follow RowHash")
+	}
+
+	codes := map[int]string{}
+	for _, problem := range result.Errors {
+		codes[problem.Row] = problem.Code
+	}
+	want := map[int]string{6: "invalid_amount", 7: "invalid_date", 8: "invalid_amount", 9: "invalid_currency"}
+	for row, code := range want {
+		if codes[row] != code {
+			t.Fatalf("row %d: got %q, want %q (%+v)", row, codes[row], code, result.Errors)
+		}
+	}
+}
+
+func TestMatchCategories(t *testing.T) {
+	result := Result{Rows: []domain.ImportRow{
+		{Row: 2, Type: "expense", Category: "Кафе"},
+		{Row: 3, Type: "income", Category: "кафе"},
+		{Row: 4, Type: "expense"},
+	}}
+	MatchCategories(&result, []domain.Category{{ID: "cafe", Name: "Кафе", Type: "expense"}})
+
+	if result.Rows[0].CategoryID != "cafe" || result.Rows[1].CategoryID != "" {
+		t.Fatalf("got rows %+v", result.Rows)
+	}
+	if len(result.Warnings) != 1 || result.Warnings[0].Row != 3 {
+		t.Fatalf("got warnings %+v", result.Warnings)
+	}
+}
@@ -0,0 +1,229 @@
+// Package imports reads bank statements and turns their rows into
+// transactions according to an import profile, as docs/imports.md
+// describes.
+package imports
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"familybudget/internal/domain"
+)
+
+const (
+	FormatCSV  = "csv"
+	FormatXLSX = "xlsx"
+
+	ColumnOccurredAt    = "occurred_at"
+	ColumnAmount        = "amount"
+	ColumnCurrency      = "currency"
+	ColumnDescription   = "description"
+	ColumnCategory      = "category"
+	ColumnAccountAmount = "account_amount"
+)
+
+var knownColumns = map[string]bool{
+	ColumnOccurredAt:    true,
+	ColumnAmount:        true,
+	ColumnCurrency:      true,
+	ColumnDescription:   true,
+	ColumnCategory:      true,
+	ColumnAccountAmount: true,
+}
+
+// Builtin returns the profiles every family can use, the most specific
+// first so that detection prefers them over the generic ones. Column
+// headers, date formats and sheet names may list alternatives separated by
+// "|".
+func Builtin() []domain.ImportProfile {
+	return []domain.ImportProfile{
+		{
+			ID:               "tinkoff-xlsx-v1",
+			Name:             "Tinkoff XLSX",
+			Format:           FormatXLSX,
+			Sheet:            "Operations|Операции",
+			DateFormat:       "dd.MM.yyyy HH:mm:ss|dd.MM.yyyy",
+			DecimalSeparator: ",",
+			Timezone:         "Europe/Moscow",
+			Columns: map[string]string{
+				ColumnOccurredAt:    "Дата операции",
+				ColumnAmount:        "Сумма операции",
+				ColumnCurrency:      "Валюта операции|Валюта",
+				ColumnAccountAmount: "Сумма платежа",
+				ColumnCategory:      "Категория",
+				ColumnDescription:   "Описание",
+			},
+			Builtin: true,
+		},
+		{
+			ID:               "tinkoff-csv-v1",
+			Name:             "Tinkoff CSV",
+			Format:           FormatCSV,
+			Delimiter:        ";",
+			DateFormat:       "dd.MM.yyyy HH:mm:ss|dd.MM.yyyy",
+			DecimalSeparator: ",",
+			Timezone:         "Europe/Moscow",
+			Columns: map[string]string{
+				ColumnOccurredAt:    "Дата операции",
+				ColumnAmount:        "Сумма операции",
+				ColumnCurrency:      "Валюта операции",
+				ColumnAccountAmount: "Сумма платежа",
+				ColumnCategory:      "Категория",
+				ColumnDescription:   "Описание",
+			},
+			Builtin: true,
+		},
+		{
+			ID:               "revolut-csv-v1",
+			Name:             "Revolut",
+			Format:           FormatCSV,
+			Delimiter:        ",",
+			DateFormat:       "yyyy-MM-dd HH:mm:ss|yyyy-MM-dd",
+			DecimalSeparator: ".",
+			Columns: map[string]string{
+				ColumnOccurredAt:  "Completed Date|Date",
+				ColumnAmount:      "Amount",
+				ColumnCurrency:    "Currency",
+				ColumnDescription: "Description",
+			},
+			Builtin: true,
+		},
+		{
+			ID:               "generic-csv-v1",
+			Name:             "Generic CSV",
+			Format:           FormatCSV,
+			Delimiter:        ",",
+			DateFormat:       "yyyy-MM-dd",
+			DecimalSeparator: ".",
+			Columns: map[string]string{
+				ColumnOccurredAt:  "date",
+				ColumnAmount:      "amount",
+				ColumnDescription: "description",
+			},
+			Builtin: true,
+		},
+		{
+			ID:               "generic-xlsx-v1",
+			Name:             "Generic XLSX",
+			Format:           FormatXLSX,
+			DateFormat:       "yyyy-MM-dd|dd.MM.yyyy",
+			DecimalSeparator: ",",
+			Columns: map[string]string{
+				ColumnOccurredAt:  "date|дата",
+				ColumnAmount:      "amount|сумма",
+				ColumnDescription: "description|описание",
+			},
+			Builtin: true,
+		},
+	}
+}
+
+// FindBuiltin returns the built-in profile with id.
+func FindBuiltin(id string) (domain.ImportProfile, bool) {
+	for _, profile := range Builtin() {
+		if profile.ID == id {
+			return profile, true
+		}
+	}
+	return domain.ImportProfile{}, false
+}
+
+// NormalizeProfile trims a profile entered by a member, fills the defaults
+// and checks that it can be used.
+func NormalizeProfile(profile *domain.ImportProfile) error {
+	profile.Name = strings.TrimSpace(profile.Name)
+	profile.Format = strings.ToLower(strings.TrimSpace(profile.Format))
+	if profile.Format == "" {
+		profile.Format = FormatCSV
+	}
+	if profile.Format != FormatCSV && profile.Format != FormatXLSX {
+		return fmt.Errorf("unsupported format %s", profile.Format)
+	}
+	profile.Sheet = strings.TrimSpace(profile.Sheet)
+	if profile.Format == FormatXLSX {
+		profile.Delimiter = ""
+	}
+	if profile.Format == FormatCSV {
+		profile.Sheet = ""
+		if profile.Delimiter == "" {
+			profile.Delimiter = ","
+		}
+		if profile.Delimiter == `\t` {
+			profile.Delimiter = "\t"
+		}
+		if len([]rune(profile.Delimiter)) != 1 {
+			return errors.New("delimiter must be a single character")
+		}
+	}
+	if profile.SkipRows < 0 {
+		return errors.New("skip_rows must not be negative")
+	}
+	profile.DateFormat = strings.TrimSpace(profile.DateFormat)
+	if profile.DateFormat == "" {
+		profile.DateFormat = "yyyy-MM-dd"
+	}
+	if profile.DecimalSeparator == "" {
+		profile.DecimalSeparator = "."
+	}
+	if profile.DecimalSeparator != "." && profile.DecimalSeparator != "," {
+		return errors.New(`decimal_separator must be "." or ","`)
+	}
+	if profile.Timezone = strings.TrimSpace(profile.Timezone); profile.Timezone != "" {
+		if _, err := time.LoadLocation(profile.Timezone); err != nil {
+			return errors.New("unknown timezone")
+		}
+	}
+	columns := make(map[string]string, len(profile.Columns))
+	for field, header := range profile.Columns {
+		field = strings.ToLower(strings.TrimSpace(field))
+		if !knownColumns[field] {
+			return fmt.Errorf("unknown column %s", field)
+		}
+		if header = strings.TrimSpace(header); header != "" {
+			columns[field] = header
+		}
+	}
+	if columns[ColumnOccurredAt] == "" || columns[ColumnAmount] == "" {
+		return errors.New("columns must map occurred_at and amount")
+	}
+	profile.Columns = columns
+	return nil
+}
+
+// goLayout turns a dd.MM.yyyy style date format into a Go time layout.
+func goLayout(format string) string {
+	tokens := []struct{ from, to string }{
+		{"yyyy", "2006"}, {"yy", "06"}, {"MM", "01"}, {"dd", "02"},
+		{"HH", "15"}, {"mm", "04"}, {"ss", "05"},
+	}
+	var layout strings.Builder
+	for i := 0; i < len(format); {
+		matched := false
+		for _, token := range tokens {
+			if strings.HasPrefix(format[i:], token.from) {
+				layout.WriteString(token.to)
+				i += len(token.from)
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			layout.WriteByte(format[i])
+			i++
+		}
+	}
+	return layout.String()
+}
+
+// alternatives splits a "|"-separated list of headers or date formats.
+func alternatives(value string) []string {
+	var list []string
+	for _, item := range strings.Split(value, "|") {
+		if item = strings.TrimSpace(item); item != "" {
+			list = append(list, item)
+		}
+	}
+	return list
+}
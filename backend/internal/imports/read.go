@@ -0,0 +1,161 @@
+package imports
+
+import (
+	"bytes"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"unicode/utf8"
+
+	"familybudget/internal/domain"
+)
+
+// Sheet is a statement read into cells. Lines holds the line of the file
+// each row starts at, so that problems point at what the member sees.
+type Sheet struct {
+	Rows  [][]string
+	Lines []int
+	// SerialDates is set for workbooks, whose dates are day numbers since
+	// 1900, or 1904 when Date1904 is set.
+	SerialDates bool
+	Date1904    bool
+}
+
+// Read reads a statement file in the profile's format.
+func Read(data []byte, profile domain.ImportProfile) (*Sheet, error) {
+	switch profile.Format {
+	case FormatCSV, "":
+		return readCSV(data, profile.Delimiter)
+	case FormatXLSX:
+		return readXLSX(data, profile.Sheet, profile.DecimalSeparator)
+	}
+	return nil, fmt.Errorf("unsupported format %s", profile.Format)
+}
+
+func readCSV(data []byte, delimiter string) (*Sheet, error) {
+	data = bytes.TrimPrefix(data, []byte("\xef\xbb\xbf"))
+	// Russian banks still export Windows-1251 now and then.
+	if !utf8.Valid(data) {
+		data = decodeWindows1251(data)
+	}
+	reader := csv.NewReader(bytes.NewReader(data))
+	if delimiter != "" {
+		reader.Comma, _ = utf8.DecodeRuneInString(delimiter)
+	}
+	reader.FieldsPerRecord = -1
+	reader.LazyQuotes = true
+
+	sheet := &Sheet{}
+	for {
+		record, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("malformed CSV: %w", err)
+		}
+		line, _ := reader.FieldPos(0)
+		sheet.Rows = append(sheet.Rows, record)
+		sheet.Lines = append(sheet.Lines, line)
+	}
+	if len(sheet.Rows) == 0 {
+		return nil, errors.New("the file is empty")
+	}
+	return sheet, nil
+}
+
+// headerSearchRows is how far below skip_rows the header row is looked
+// for, since statements often start with a title and account details.
+const headerSearchRows = 20
+
+// header finds the header row: the first row from skip_rows on that holds
+// every mapped column. It returns the row index and the positions of the
+// profile columns in it, or the mapped headers missing from the row at
+// skip_rows.
+func (s *Sheet) header(profile domain.ImportProfile) (int, map[string]int, []string) {
+	var missing []string
+	for i := profile.SkipRows; i < len(s.Rows) && i <= profile.SkipRows+headerSearchRows; i++ {
+		indexes, notFound := headerColumns(s.Rows[i], profile.Columns)
+		if len(notFound) == 0 {
+			return i, indexes, nil
+		}
+		if i == profile.SkipRows {
+			missing = notFound
+		}
+	}
+	if missing == nil {
+		for _, header := range profile.Columns {
+			missing = append(missing, header)
+		}
+	}
+	return 0, nil, missing
+}
+
+func headerColumns(row []string, columns map[string]string) (map[string]int, []string) {
+	positions := make(map[string]int, len(row))
+	for i, cell := range row {
+		key := strings.ToLower(strings.Join(strings.Fields(cell), " "))
+		if _, ok := positions[key]; !ok {
+			positions[key] = i
+		}
+	}
+
+	indexes := make(map[string]int, len(columns))
+	var missing []string
+	for field, header := range columns {
+		found := false
+		for _, candidate := range alternatives(header) {
+			if i, ok := positions[strings.ToLower(candidate)]; ok {
+				indexes[field] = i
+				found = true
+				break
+			}
+		}
+		if !found {
+			missing = append(missing, header)
+		}
+	}
+	return indexes, missing
+}
+
+// Detect returns the first of profiles whose columns are all found in the
+// file.
+func Detect(data []byte, profiles []domain.ImportProfile) (domain.ImportProfile, bool) {
+	for _, profile := range profiles {
+		sheet, err := Read(data, profile)
+		if err != nil {
+			continue
+		}
+		if _, _, missing := sheet.header(profile); len(missing) == 0 {
+			return profile, true
+		}
+	}
+	return domain.ImportProfile{}, false
+}
+
+// windows1251 maps the upper half of Windows-1251 from 0x80 to 0xBF; 0xC0
+// to 0xFF are А to я in order.
+var windows1251 = [64]rune{
+	'Ђ', 'Ѓ', '‚', 'ѓ', '„', '…', '†', '‡', '€', '‰', 'Љ', '‹', 'Њ', 'Ќ', 'Ћ', 'Џ',
+	'ђ', '‘', '’', '“', '”', '•', '–', '—', utf8.RuneError, '™', 'љ', '›', 'њ', 'ќ', 'ћ', 'џ',
+	'\u00a0', 'Ў', 'ў', 'Ј', '¤', 'Ґ', '¦', '§', 'Ё', '©', 'Є', '«', '¬', '\u00ad', '®', 'Ї',
+	'°', '±', 'І', 'і', 'ґ', 'µ', '¶', '·', 'ё', '№', 'є', '»', 'ј', 'Ѕ', 'ѕ', 'ї',
+}
+
+func decodeWindows1251(data []byte) []byte {
+	var decoded bytes.Buffer
+	decoded.Grow(len(data) * 2)
+	for _, b := range data {
+		switch {
+		case b < 0x80:
+			decoded.WriteByte(b)
+		case b < 0xC0:
+			decoded.WriteRune(windows1251[b-0x80])
+		default:
+			decoded.WriteRune(rune(b) - 0xC0 + 'А')
+		}
+	}
+	return decoded.Bytes()
+}
@@ -0,0 +1,328 @@
+package imports
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// maxXLSXPart caps how much of a single workbook part is decompressed.
+const maxXLSXPart = 64 << 20
+
+// maxXLSXRows and maxXLSXColumns bound the cells of a sheet, so that a
+// small file with a value far down or to the right cannot make the reader
+// build a huge grid.
+const (
+	maxXLSXRows    = 100000
+	maxXLSXColumns = 256
+)
+
+type xlsxWorkbook struct {
+	Properties struct {
+		Date1904 bool `xml:"date1904,attr"`
+	} `xml:"workbookPr"`
+	Sheets []struct {
+		Name  string `xml:"name,attr"`
+		RelID string `xml:"http://schemas.openxmlformats.org/officeDocument/2006/relationships id,attr"`
+	} `xml:"sheets>sheet"`
+}
+
+type xlsxRelationships struct {
+	Relationships []struct {
+		ID     string `xml:"Id,attr"`
+		Target string `xml:"Target,attr"`
+	} `xml:"Relationship"`
+}
+
+type xlsxSharedStrings struct {
+	Items []xlsxText `xml:"si"`
+}
+
+// xlsxText is a string that is either plain or split into formatted runs.
+type xlsxText struct {
+	T    string `xml:"t"`
+	Runs []struct {
+		T string `xml:"t"`
+	} `xml:"r"`
+}
+
+func (t xlsxText) String() string {
+	text := t.T
+	for _, run := range t.Runs {
+		text += run.T
+	}
+	return text
+}
+
+type xlsxWorksheet struct {
+	Rows []struct {
+		R     int        `xml:"r,attr"`
+		Cells []xlsxCell `xml:"c"`
+	} `xml:"sheetData>row"`
+	MergeCells []struct {
+		Ref string `xml:"ref,attr"`
+	} `xml:"mergeCells>mergeCell"`
+}
+
+type xlsxCell struct {
+	Ref    string   `xml:"r,attr"`
+	Type   string   `xml:"t,attr"`
+	Value  string   `xml:"v"`
+	Inline xlsxText `xml:"is"`
+}
+
+// readXLSX reads the sheet named sheetName (alternatives separated by "|",
+// the first sheet when empty) of an Office Open XML workbook. Numbers are
+// written with decimalSeparator so that they parse like the text cells of
+// the same statement, and merged cells repeat their value in every cell
+// they cover on rows that have values. Rows without values stay nil.
+func readXLSX(data []byte, sheetName, decimalSeparator string) (*Sheet, error) {
+	archive, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return nil, errors.New("the file is not an XLSX workbook")
+	}
+	parts := make(map[string]*zip.File, len(archive.File))
+	for _, file := range archive.File {
+		parts[strings.TrimPrefix(file.Name, "/")] = file
+	}
+
+	var workbook xlsxWorkbook
+	if err := readXLSXPart(parts, "xl/workbook.xml", &workbook); err != nil {
+		return nil, err
+	}
+	if len(workbook.Sheets) == 0 {
+		return nil, errors.New("the workbook has no sheets")
+	}
+	sheet := workbook.Sheets[0]
+	if names := alternatives(sheetName); len(names) > 0 {
+		found := false
+		for _, name := range names {
+			for _, candidate := range workbook.Sheets {
+				if strings.EqualFold(strings.TrimSpace(candidate.Name), name) {
+					sheet, found = candidate, true
+					break
+				}
+			}
+			if found {
+				break
+			}
+		}
+		if !found {
+			available := make([]string, 0, len(workbook.Sheets))
+			for _, candidate := range workbook.Sheets {
+				available = append(available, candidate.Name)
+			}
+			return nil, fmt.Errorf("sheet %s not found, the workbook has %s", strings.Join(names, " or "), strings.Join(available, ", "))
+		}
+	}
+
+	var relationships xlsxRelationships
+	if err := readXLSXPart(parts, "xl/_rels/workbook.xml.rels", &relationships); err != nil {
+		return nil, err
+	}
+	target := ""
+	for _, relationship := range relationships.Relationships {
+		if relationship.ID == sheet.RelID {
+			target = relationship.Target
+		}
+	}
+	if target == "" {
+		return nil, fmt.Errorf("sheet %s is missing from the workbook", sheet.Name)
+	}
+	if strings.HasPrefix(target, "/") {
+		target = strings.TrimPrefix(target, "/")
+	} else {
+		target = path.Join("xl", target)
+	}
+
+	var shared xlsxSharedStrings
+	if _, ok := parts["xl/sharedStrings.xml"]; ok {
+		if err := readXLSXPart(parts, "xl/sharedStrings.xml", &shared); err != nil {
+			return nil, err
+		}
+	}
+	var worksheet xlsxWorksheet
+	if err := readXLSXPart(parts, target, &worksheet); err != nil {
+		return nil, err
+	}
+
+	cells := make(map[int]map[int]string)
+	maxRow, maxCol := 0, 0
+	rowNumber := 0
+	for _, row := range worksheet.Rows {
+		rowNumber++
+		if row.R > 0 {
+			rowNumber = row.R
+		}
+		col := 0
+		for _, cell := range row.Cells {
+			col++
+			if cell.Ref != "" {
+				if _, refCol, ok := cellRef(cell.Ref); ok {
+					col = refCol
+				}
+			}
+			value := cellValue(cell, shared.Items, decimalSeparator)
+			if strings.TrimSpace(value) == "" {
+				continue
+			}
+			if rowNumber > maxXLSXRows {
+				return nil, fmt.Errorf("the sheet has more than %d rows", maxXLSXRows)
+			}
+			if col > maxXLSXColumns {
+				return nil, fmt.Errorf("the sheet has more than %d columns", maxXLSXColumns)
+			}
+			if cells[rowNumber] == nil {
+				cells[rowNumber] = make(map[int]string)
+			}
+			cells[rowNumber][col] = value
+			maxRow = max(maxRow, rowNumber)
+			maxCol = max(maxCol, col)
+		}
+	}
+	if maxRow == 0 {
+		return nil, errors.New("the sheet is empty")
+	}
+
+	rowNumbers := make([]int, 0, len(cells))
+	for r := range cells {
+		rowNumbers = append(rowNumbers, r)
+	}
+	sort.Ints(rowNumbers)
+	for _, merge := range worksheet.MergeCells {
+		from, to, ok := strings.Cut(merge.Ref, ":")
+		if !ok {
+			continue
+		}
+		fromRow, fromCol, ok1 := cellRef(from)
+		toRow, toCol, ok2 := cellRef(to)
+		if !ok1 || !ok2 {
+			continue
+		}
+		value, ok := cells[fromRow][fromCol]
+		if !ok {
+			continue
+		}
+		for i := sort.SearchInts(rowNumbers, fromRow); i < len(rowNumbers) && rowNumbers[i] <= toRow; i++ {
+			for c := fromCol; c <= min(toCol, maxCol); c++ {
+				cells[rowNumbers[i]][c] = value
+			}
+		}
+	}
+
+	result := &Sheet{
+		Rows:        make([][]string, maxRow),
+		Lines:       make([]int, maxRow),
+		SerialDates: true,
+		Date1904:    workbook.Properties.Date1904,
+	}
+	for r := 1; r <= maxRow; r++ {
+		result.Lines[r-1] = r
+	}
+	for r, row := range cells {
+		width := 0
+		for c := range row {
+			width = max(width, c)
+		}
+		record := make([]string, width)
+		for c, value := range row {
+			record[c-1] = value
+		}
+		result.Rows[r-1] = record
+	}
+	return result, nil
+}
+
+func readXLSXPart(parts map[string]*zip.File, name string, into interface{}) error {
+	file, ok := parts[name]
+	if !ok {
+		return fmt.Errorf("the workbook has no %s", name)
+	}
+	reader, err := file.Open()
+	if err != nil {
+		return fmt.Errorf("malformed workbook: %w", err)
+	}
+	defer reader.Close()
+	if err := xml.NewDecoder(io.LimitReader(reader, maxXLSXPart)).Decode(into); err != nil {
+		return fmt.Errorf("malformed workbook: %s: %w", name, err)
+	}
+	return nil
+}
+
+func cellValue(cell xlsxCell, shared []xlsxText, decimalSeparator string) string {
+	switch cell.Type {
+	case "s":
+		index, err := strconv.Atoi(strings.TrimSpace(cell.Value))
+		if err != nil || index < 0 || index >= len(shared) {
+			return ""
+		}
+		return shared[index].String()
+	case "inlineStr":
+		return cell.Inline.String()
+	case "b":
+		if strings.TrimSpace(cell.Value) == "1" {
+			return "TRUE"
+		}
+		return "FALSE"
+	case "str", "e", "d":
+		return cell.Value
+	}
+	number, err := strconv.ParseFloat(strings.TrimSpace(cell.Value), 64)
+	if err != nil {
+		return cell.Value
+	}
+	// Excel keeps 15 significant digits; rounding to them drops the binary
+	// noise of computed values such as 1500.1000000000001.
+	number, _ = strconv.ParseFloat(strconv.FormatFloat(number, 'g', 15, 64), 64)
+	value := strconv.FormatFloat(number, 'f', -1, 64)
+	if decimalSeparator == "," {
+		value = strings.Replace(value, ".", ",", 1)
+	}
+	return value
+}
+
+// cellRef splits a reference such as "B12" into its row and column, both
+// counted from 1.
+func cellRef(ref string) (int, int, bool) {
+	ref = strings.ToUpper(strings.ReplaceAll(ref, "$", ""))
+	col, i := 0, 0
+	for ; i < len(ref) && ref[i] >= 'A' && ref[i] <= 'Z'; i++ {
+		col = col*26 + int(ref[i]-'A'+1)
+	}
+	row, err := strconv.Atoi(ref[i:])
+	if i == 0 || err != nil || row < 1 || col > 16384 {
+		return 0, 0, false
+	}
+	return row, col, true
+}
+
+// excelDate reads a date cell of a workbook: a serial day number with the
+// time of day as its fraction, or an ISO 8601 value. Serials count from
+// 1899-12-30, which is right from March 1900 on.
+func excelDate(value string, date1904 bool, location *time.Location) (time.Time, error) {
+	value = strings.TrimSpace(value)
+	for _, layout := range []string{"2006-01-02T15:04:05Z", "2006-01-02T15:04:05", "2006-01-02"} {
+		if parsed, err := time.ParseInLocation(layout, value, location); err == nil {
+			return parsed, nil
+		}
+	}
+	serial, err := strconv.ParseFloat(strings.Replace(value, ",", ".", 1), 64)
+	if err != nil || serial < 1 || serial > 2958465 {
+		return time.Time{}, errors.New("unknown date format")
+	}
+	days := int(math.Floor(serial))
+	seconds := int(math.Round((serial - float64(days)) * 86400))
+	if date1904 {
+		return time.Date(1904, 1, 1+days, 0, 0, seconds, 0, location), nil
+	}
+	return time.Date(1899, 12, 30+days, 0, 0, seconds, 0, location), nil
+}
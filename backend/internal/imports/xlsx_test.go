@@ -0,0 +1,195 @@
+package imports
+
+import (
+	"archive/zip"
+	"bytes"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+)
+
+// buildXLSX writes a workbook whose sheets are named by names and hold the
+// sheetData and mergeCells XML in sheets.
+func buildXLSX(t *testing.T, date1904 bool, names []string, sheets []string, shared []string) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	archive := zip.NewWriter(&buf)
+	write := func(name, content string) {
+		w, err := archive.Create(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := w.Write([]byte(content)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var list, rels strings.Builder
+	for i, name := range names {
+		fmt.Fprintf(&list, `<sheet name="%s" sheetId="%d" r:id="rId%d"/>`, name, i+1, i+1)
+		fmt.Fprintf(&rels, `<Relationship Id="rId%d" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/worksheet" Target="worksheets/sheet%d.xml"/>`, i+1, i+1)
+		write(fmt.Sprintf("xl/worksheets/sheet%d.xml", i+1), `<worksheet xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main">`+sheets[i]+`</worksheet>`)
+	}
+	write("xl/workbook.xml", fmt.Sprintf(`<workbook xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main" xmlns:r="http://schemas.openxmlformats.org/officeDocument/2006/relationships"><workbookPr date1904="%t"/><sheets>%s</sheets></workbook>`, date1904, list.String()))
+	write("xl/_rels/workbook.xml.rels", `<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">`+rels.String()+`</Relationships>`)
+	if shared != nil {
+		write("xl/sharedStrings.xml", `<sst xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main">`+strings.Join(shared, "")+`</sst>`)
+	}
+	if err := archive.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestReadXLSX(t *testing.T) {
+	data := buildXLSX(t, false, []string{"Summary", "Операции"}, []string{
+		`<sheetData><row r="1"><c r="A1" t="inlineStr"><is><t>Итого</t></is></c></row></sheetData>`,
+		`<sheetData>
+			<row r="1"><c r="A1" t="s"><v>0</v></c><c r="B1" t="s"><v>1</v></c><c r="C1" t="inlineStr"><is><t>Описание</t></is></c></row>
+			<row r="2"><c r="A2"><v>46082.4375</v></c><c r="B2"><v>-1500.1000000000001</v></c><c r="C2" t="s"><v>2</v></c></row>
+			<row r="3"><c r="B3" t="str"><v>-1` + "\u00a0" + `200,50</v></c><c r="C3" t="b"><v>1</v></c></row>
+			<row r="5"><c r="C5" t="inlineStr"><is><t>После пропуска</t></is></c></row>
+		</sheetData>
+		<mergeCells count="2"><mergeCell ref="A2:A4"/><mergeCell ref="C5:D9"/></mergeCells>`,
+	}, []string{
+		`<si><t>Дата</t></si>`,
+		`<si><t>Сумма</t></si>`,
+		`<si><r><t>Кофе</t></r><r><t>йня</t></r></si>`,
+	})
+
+	sheet, err := readXLSX(data, "Operations|операции", ",")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := [][]string{
+		{"Дата", "Сумма", "Описание"},
+		{"46082,4375", "-1500,1", "Кофейня"},
+		{"46082,4375", "-1\u00a0200,50", "TRUE"},
+		nil,
+		{"", "", "После пропуска"},
+	}
+	if len(sheet.Rows) != len(want) {
+		t.Fatalf("got %d rows %q", len(sheet.Rows), sheet.Rows)
+	}
+	for i := range want {
+		if fmt.Sprintf("%q", sheet.Rows[i]) != fmt.Sprintf("%q", want[i]) {
+			t.Fatalf("row %d: got %q, want %q", i+1, sheet.Rows[i], want[i])
+		}
+		if sheet.Lines[i] != i+1 {
+			t.Fatalf("row %d: got line %d", i+1, sheet.Lines[i])
+		}
+	}
+	if !sheet.SerialDates || sheet.Date1904 {
+		t.Fatalf("got serial dates %v, 1904 dates %v", sheet.SerialDates, sheet.Date1904)
+	}
+
+	first, err := readXLSX(data, "", ".")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(first.Rows) != 1 || first.Rows[0][0] != "Итого" {
+		t.Fatalf("got first sheet %q", first.Rows)
+	}
+}
+
+func TestReadXLSXRejects(t *testing.T) {
+	tests := []struct {
+		name  string
+		data  []byte
+		sheet string
+		want  string
+	}{
+		{"not a workbook", []byte("date,amount\n"), "", "not an XLSX workbook"},
+		{"unknown sheet", buildXLSX(t, false, []string{"Sheet1"}, []string{`<sheetData/>`}, nil), "Operations|Операции", "sheet Operations or Операции not found"},
+		{"empty sheet", buildXLSX(t, false, []string{"Sheet1"}, []string{`<sheetData><row r="1"><c r="A1" t="inlineStr"><is><t> </t></is></c></row></sheetData>`}, nil), "", "the sheet is empty"},
+		{"too many rows", buildXLSX(t, false, []string{"Sheet1"}, []string{`<sheetData><row r="100001"><c r="A100001"><v>1</v></c></row></sheetData>`}, nil), "", "more than 100000 rows"},
+		{"too many columns", buildXLSX(t, false, []string{"Sheet1"}, []string{`<sheetData><row r="1"><c r="IW1"><v>1</v></c></row></sheetData>`}, nil), "", "more than 256 columns"},
+		{"malformed part", buildXLSX(t, false, []string{"Sheet1"}, []string{`<sheetData><row>`}, nil), "", "malformed workbook"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := readXLSX(tt.data, tt.sheet, ",")
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Fatalf("got error %v, want %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestReadXLSXLastCells(t *testing.T) {
+	data := buildXLSX(t, false, []string{"Sheet1"}, []string{`<sheetData>
+		<row r="100000"><c r="IV100000"><v>1</v></c></row>
+	</sheetData><mergeCells><mergeCell ref="IV100000:XFD1048576"/></mergeCells>`}, nil)
+
+	sheet, err := readXLSX(data, "", ".")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(sheet.Rows) != maxXLSXRows || len(sheet.Rows[maxXLSXRows-1]) != maxXLSXColumns || sheet.Rows[0] != nil {
+		t.Fatalf("got %d rows, last of %d cells", len(sheet.Rows), len(sheet.Rows[len(sheet.Rows)-1]))
+	}
+}
+
+func TestExcelDate(t *testing.T) {
+	moscow := time.FixedZone("MSK", 3*60*60)
+	tests := []struct {
+		value    string
+		date1904 bool
+		want     time.Time
+		wantErr  bool
+	}{
+		{"46082", false, time.Date(2026, time.March, 1, 0, 0, 0, 0, moscow), false},
+		{"46082.4375", false, time.Date(2026, time.March, 1, 10, 30, 0, 0, moscow), false},
+		{"46082,75", false, time.Date(2026, time.March, 1, 18, 0, 0, 0, moscow), false},
+		{"44620.5", true, time.Date(2026, time.March, 1, 12, 0, 0, 0, moscow), false},
+		{"61", false, time.Date(1900, time.March, 1, 0, 0, 0, 0, moscow), false},
+		{"2026-03-01", false, time.Date(2026, time.March, 1, 0, 0, 0, 0, moscow), false},
+		{"2026-03-01T10:15:00", false, time.Date(2026, time.March, 1, 10, 15, 0, 0, moscow), false},
+		{"0", false, time.Time{}, true},
+		{"3000000", false, time.Time{}, true},
+		{"01.03.2026", false, time.Time{}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.value, func(t *testing.T) {
+			got, err := excelDate(tt.value, tt.date1904, moscow)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("got error %v, want error %v", err, tt.wantErr)
+			}
+			if !got.Equal(tt.want) {
+				t.Fatalf("got %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseXLSX(t *testing.T) {
+	data := buildXLSX(t, false, []string{"Operations"}, []string{`<sheetData>
+		<row r="1"><c r="A1" t="inlineStr"><is><t>Выписка</t></is></c></row>
+		<row r="2"><c r="A2" t="inlineStr"><is><t>Дата операции</t></is></c><c r="B2" t="inlineStr"><is><t>Сумма операции</t></is></c><c r="C2" t="inlineStr"><is><t>Валюта операции</t></is></c><c r="D2" t="inlineStr"><is><t>Сумма платежа</t></is></c><c r="E2" t="inlineStr"><is><t>Категория</t></is></c><c r="F2" t="inlineStr"><is><t>Описание</t></is></c></row>
+		<row r="3"><c r="A3"><v>46082.4375</v></c><c r="B3"><v>-1500.5</v></c><c r="C3" t="inlineStr"><is><t>RUB</t></is></c><c r="F3" t="inlineStr"><is><t>Кофейня</t></is></c></row>
+		<row r="4"><c r="B4" t="inlineStr"><is><t>-1 200,00</t></is></c><c r="C4" t="inlineStr"><is><t>RUB</t></is></c><c r="F4" t="inlineStr"><is><t>Такси</t></is></c></row>
+	</sheetData><mergeCells><mergeCell ref="A3:A4"/></mergeCells>`}, nil)
+	profile, ok := FindBuiltin("tinkoff-xlsx-v1")
+	if !ok {
+		t.Fatal("tinkoff-xlsx-v1 is missing")
+	}
+	sheet, err := Read(data, profile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	result := Parse(sheet, profile, Options{FamilyID: "family", AccountID: "account", Currency: "RUB"})
+
+	if len(result.Rows) != 2 || len(result.Errors) != 0 {
+		t.Fatalf("got rows %+v, errors %+v", result.Rows, result.Errors)
+	}
+	at := time.Date(2026, time.March, 1, 10, 30, 0, 0, time.FixedZone("MSK", 3*60*60))
+	for i, amount := range []int64{150050, 120000} {
+		row := result.Rows[i]
+		if !row.OccurredAt.Equal(at) || row.AmountMinor != amount || row.Type != "expense" || row.Row != i+3 {
+			t.Fatalf("got row %+v", row)
+		}
+	}
+}
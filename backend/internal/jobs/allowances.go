@@ -0,0 +1,25 @@
+package jobs
+
+import (
+	"context"
+	"time"
+
+	"familybudget/internal/store"
+)
+
+type AllowancePosting struct {
+	store *store.Store
+}
+
+func NewAllowancePosting(st *store.Store) *AllowancePosting {
+	return &AllowancePosting{store: st}
+}
+
+func (j *AllowancePosting) Name() string {
+	return "allowance_posting"
+}
+
+func (j *AllowancePosting) Run(ctx context.Context, now time.Time) error {
+	_, err := j.store.PostDueAllowances(ctx, now)
+	return err
+}
@@ -0,0 +1,28 @@
+package jobs
+
+import (
+	"context"
+	"time"
+
+	"familybudget/internal/store"
+)
+
+// debtDueSoonWindow matches the debt.due_soon trigger in docs/events.md.
+const debtDueSoonWindow = 3 * 24 * time.Hour
+
+type DebtDueSoon struct {
+	store *store.Store
+}
+
+func NewDebtDueSoon(st *store.Store) *DebtDueSoon {
+	return &DebtDueSoon{store: st}
+}
+
+func (j *DebtDueSoon) Name() string {
+	return "debt_due_soon"
+}
+
+func (j *DebtDueSoon) Run(ctx context.Context, now time.Time) error {
+	_, err := j.store.NotifyDebtsDueSoon(ctx, now, debtDueSoonWindow)
+	return err
+}
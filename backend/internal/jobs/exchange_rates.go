@@ -0,0 +1,69 @@
+package jobs
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"familybudget/internal/rates"
+	"familybudget/internal/store"
+)
+
+// exchangeRateRetryDelay follows docs/currency.md: a failed fetch is retried
+// after five minutes.
+const exchangeRateRetryDelay = 5 * time.Minute
+
+// ExchangeRateFetch stores the rates of each provider once per interval,
+// daily by default. A provider that fails is retried sooner without
+// delaying the others.
+type ExchangeRateFetch struct {
+	store     *store.Store
+	providers []rates.Provider
+	interval  time.Duration
+	next      map[string]time.Time
+}
+
+func NewExchangeRateFetch(st *store.Store, interval time.Duration, providers ...rates.Provider) *ExchangeRateFetch {
+	if interval <= 0 {
+		interval = 24 * time.Hour
+	}
+	return &ExchangeRateFetch{
+		store:     st,
+		providers: providers,
+		interval:  interval,
+		next:      make(map[string]time.Time),
+	}
+}
+
+func (j *ExchangeRateFetch) Name() string {
+	return "exchange_rate_fetch"
+}
+
+func (j *ExchangeRateFetch) Run(ctx context.Context, now time.Time) error {
+	var errs []error
+	for _, provider := range j.providers {
+		if now.Before(j.next[provider.Name()]) {
+			continue
+		}
+		fetched, err := provider.Fetch(ctx)
+		if err == nil {
+			for i := range fetched {
+				fetched[i].Source = provider.Name()
+				fetched[i].CreatedAt = now
+			}
+			var saved int
+			if saved, err = j.store.SaveExchangeRates(ctx, fetched); err == nil {
+				log.Printf("exchange rates: stored %d of %d rates from %s", saved, len(fetched), provider.Name())
+			}
+		}
+		if err != nil {
+			j.next[provider.Name()] = now.Add(exchangeRateRetryDelay)
+			errs = append(errs, fmt.Errorf("%s: %w", provider.Name(), err))
+			continue
+		}
+		j.next[provider.Name()] = now.Add(j.interval)
+	}
+	return errors.Join(errs...)
+}
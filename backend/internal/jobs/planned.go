@@ -0,0 +1,27 @@
+package jobs
+
+import (
+	"context"
+	"time"
+
+	"familybudget/internal/store"
+)
+
+// PlannedOperationPosting turns due planned operations with auto_post enabled
+// into transactions.
+type PlannedOperationPosting struct {
+	store *store.Store
+}
+
+func NewPlannedOperationPosting(st *store.Store) *PlannedOperationPosting {
+	return &PlannedOperationPosting{store: st}
+}
+
+func (j *PlannedOperationPosting) Name() string {
+	return "planned_operation_posting"
+}
+
+func (j *PlannedOperationPosting) Run(ctx context.Context, now time.Time) error {
+	_, err := j.store.PostDuePlannedOperations(ctx, now)
+	return err
+}
@@ -0,0 +1,27 @@
+package jobs
+
+import (
+	"context"
+	"time"
+
+	"familybudget/internal/store"
+)
+
+// PlannedOccurrenceMissed records unpaid occurrences of recurring planned
+// operations once the next occurrence is due, leaving the series overdue.
+type PlannedOccurrenceMissed struct {
+	store *store.Store
+}
+
+func NewPlannedOccurrenceMissed(st *store.Store) *PlannedOccurrenceMissed {
+	return &PlannedOccurrenceMissed{store: st}
+}
+
+func (j *PlannedOccurrenceMissed) Name() string {
+	return "planned_occurrence_missed"
+}
+
+func (j *PlannedOccurrenceMissed) Run(ctx context.Context, now time.Time) error {
+	_, err := j.store.MarkMissedPlannedOccurrences(ctx, now)
+	return err
+}
@@ -0,0 +1,94 @@
+package jobs
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"familybudget/internal/domain"
+	"familybudget/internal/notify"
+	"familybudget/internal/store"
+)
+
+// ReminderDispatch delivers reminders about upcoming and overdue planned
+// operations. Channels without a configured notifier are skipped, so email
+// reminders wait until SMTP is set up.
+type ReminderDispatch struct {
+	store     *store.Store
+	notifiers map[string]notify.Notifier
+}
+
+func NewReminderDispatch(st *store.Store, notifiers map[string]notify.Notifier) *ReminderDispatch {
+	return &ReminderDispatch{store: st, notifiers: notifiers}
+}
+
+func (j *ReminderDispatch) Name() string {
+	return "reminder_dispatch"
+}
+
+func (j *ReminderDispatch) Run(ctx context.Context, now time.Time) error {
+	reminders, err := j.store.DueReminders(ctx, now)
+	if err != nil {
+		return err
+	}
+	locations := make(map[string]*time.Location)
+	var errs []error
+	for _, reminder := range reminders {
+		notifier, ok := j.notifiers[reminder.Channel]
+		if !ok {
+			continue
+		}
+		claimed, err := j.store.ClaimReminderDelivery(ctx, reminder, now)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		if !claimed {
+			continue
+		}
+
+		loc, ok := locations[reminder.Operation.FamilyID]
+		if !ok {
+			if loc, err = j.store.FamilyLocation(ctx, reminder.Operation.FamilyID); err != nil {
+				loc = time.UTC
+			}
+			locations[reminder.Operation.FamilyID] = loc
+		}
+		notification := reminderNotification(reminder, loc, now)
+		if err := notifier.Notify(ctx, notification); err != nil {
+			errs = append(errs, fmt.Errorf("%s reminder for %s: %w", reminder.Channel, reminder.Recipient.ID, err))
+			if err := j.store.ReleaseReminderDelivery(ctx, reminder); err != nil {
+				errs = append(errs, err)
+			}
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func reminderNotification(reminder store.DueReminder, loc *time.Location, now time.Time) notify.Notification {
+	op := reminder.Operation
+	amount := domain.FormatAmount(op.AmountMinor, op.Currency)
+	due := op.DueAt.In(loc).Format("2006-01-02")
+
+	subject := fmt.Sprintf("Reminder: %s %s due %s", op.Title, amount, due)
+	body := fmt.Sprintf("%s (%s) is due on %s.", op.Title, amount, due)
+	if reminder.Overdue {
+		subject = fmt.Sprintf("Overdue: %s %s was due %s", op.Title, amount, due)
+		body = fmt.Sprintf("%s (%s) was due on %s and has not been paid yet.", op.Title, amount, due)
+	}
+	if op.Comment != "" {
+		body += "\n\n" + op.Comment
+	}
+	return notify.Notification{
+		FamilyID:           op.FamilyID,
+		UserID:             reminder.Recipient.ID,
+		Email:              reminder.Recipient.Email,
+		Name:               reminder.Recipient.Name,
+		Kind:               "reminder",
+		Subject:            subject,
+		Body:               body,
+		PlannedOperationID: op.ID,
+		CreatedAt:          now,
+	}
+}
@@ -0,0 +1,57 @@
+package jobs
+
+import (
+	"context"
+	"log"
+	"time"
+)
+
+// Job is a periodic background task. Run receives the current time so that
+// jobs stay deterministic with respect to a single tick.
+type Job interface {
+	Name() string
+	Run(ctx context.Context, now time.Time) error
+}
+
+type Runner struct {
+	interval time.Duration
+	jobs     []Job
+}
+
+func NewRunner(interval time.Duration, jobs ...Job) *Runner {
+	if interval <= 0 {
+		interval = time.Minute
+	}
+	return &Runner{interval: interval, jobs: jobs}
+}
+
+// Start runs every job once immediately and then on each tick until ctx is
+// cancelled. It does not block.
+func (r *Runner) Start(ctx context.Context) {
+	go func() {
+		ticker := time.NewTicker(r.interval)
+		defer ticker.Stop()
+
+		r.tick(ctx)
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				r.tick(ctx)
+			}
+		}
+	}()
+}
+
+func (r *Runner) tick(ctx context.Context) {
+	now := time.Now().UTC()
+	for _, job := range r.jobs {
+		if ctx.Err() != nil {
+			return
+		}
+		if err := job.Run(ctx, now); err != nil {
+			log.Printf("job %s failed: %v", job.Name(), err)
+		}
+	}
+}
@@ -0,0 +1,37 @@
+package jobs
+
+import (
+	"context"
+	"time"
+
+	"familybudget/internal/store"
+)
+
+// transactionBaseAmountsBatch bounds the rate lookups of one tick; later
+// ticks continue after the last transaction visited.
+const transactionBaseAmountsBatch = 500
+
+// TransactionBaseAmounts fills the family-currency amount of transactions
+// stored before a rate for their date was available, following the
+// exchange_rate_pending queue in docs/currency.md.
+type TransactionBaseAmounts struct {
+	store  *store.Store
+	cursor string
+}
+
+func NewTransactionBaseAmounts(st *store.Store) *TransactionBaseAmounts {
+	return &TransactionBaseAmounts{store: st}
+}
+
+func (j *TransactionBaseAmounts) Name() string {
+	return "transaction_base_amounts"
+}
+
+func (j *TransactionBaseAmounts) Run(ctx context.Context, now time.Time) error {
+	_, next, err := j.store.FillTransactionBaseAmounts(ctx, j.cursor, transactionBaseAmountsBatch)
+	if err != nil {
+		return err
+	}
+	j.cursor = next
+	return nil
+}
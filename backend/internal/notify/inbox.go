@@ -0,0 +1,33 @@
+package notify
+
+import (
+	"context"
+
+	"familybudget/internal/domain"
+	"familybudget/internal/store"
+)
+
+// Inbox stores notifications as in-app inbox messages.
+type Inbox struct {
+	store *store.Store
+}
+
+func NewInbox(st *store.Store) *Inbox {
+	return &Inbox{store: st}
+}
+
+func (i *Inbox) Notify(ctx context.Context, n Notification) error {
+	message := &domain.InboxMessage{
+		FamilyID:  n.FamilyID,
+		UserID:    n.UserID,
+		Kind:      n.Kind,
+		Subject:   n.Subject,
+		Body:      n.Body,
+		CreatedAt: n.CreatedAt,
+	}
+	if n.PlannedOperationID != "" {
+		id := n.PlannedOperationID
+		message.PlannedOperationID = &id
+	}
+	return i.store.CreateInboxMessage(ctx, message)
+}
@@ -0,0 +1,33 @@
+// Package notify delivers notifications to family members over pluggable
+// channels.
+package notify
+
+import (
+	"context"
+	"time"
+)
+
+// Notification is a message for one member.
+type Notification struct {
+	FamilyID           string
+	UserID             string
+	Email              string
+	Name               string
+	Kind               string
+	Subject            string
+	Body               string
+	PlannedOperationID string
+	CreatedAt          time.Time
+}
+
+// Notifier delivers notifications over one channel.
+type Notifier interface {
+	Notify(ctx context.Context, n Notification) error
+}
+
+// NotifierFunc adapts a function to Notifier.
+type NotifierFunc func(ctx context.Context, n Notification) error
+
+func (f NotifierFunc) Notify(ctx context.Context, n Notification) error {
+	return f(ctx, n)
+}
@@ -0,0 +1,106 @@
+package notify
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"mime"
+	"net"
+	"net/smtp"
+	"strings"
+	"time"
+)
+
+// SMTPConfig configures email delivery. Username and Password are optional,
+// which allows delivery through a local relay or a fake server in tests.
+type SMTPConfig struct {
+	Addr     string
+	From     string
+	Username string
+	Password string
+}
+
+// SMTP sends notifications as plain-text email.
+type SMTP struct {
+	config SMTPConfig
+	dial   func(ctx context.Context, addr string) (net.Conn, error)
+}
+
+func NewSMTP(config SMTPConfig) *SMTP {
+	dialer := &net.Dialer{Timeout: 10 * time.Second}
+	return &SMTP{config: config, dial: func(ctx context.Context, addr string) (net.Conn, error) {
+		return dialer.DialContext(ctx, "tcp", addr)
+	}}
+}
+
+func (s *SMTP) Notify(ctx context.Context, n Notification) error {
+	if strings.TrimSpace(n.Email) == "" {
+		return errors.New("notify: recipient has no email")
+	}
+	conn, err := s.dial(ctx, s.config.Addr)
+	if err != nil {
+		return err
+	}
+	if deadline, ok := ctx.Deadline(); ok {
+		_ = conn.SetDeadline(deadline)
+	}
+	host, _, err := net.SplitHostPort(s.config.Addr)
+	if err != nil {
+		conn.Close()
+		return err
+	}
+	client, err := smtp.NewClient(conn, host)
+	if err != nil {
+		conn.Close()
+		return err
+	}
+	defer client.Close()
+
+	if ok, _ := client.Extension("STARTTLS"); ok {
+		if err := client.StartTLS(nil); err != nil {
+			return err
+		}
+	}
+	if s.config.Username != "" {
+		if err := client.Auth(smtp.PlainAuth("", s.config.Username, s.config.Password, host)); err != nil {
+			return err
+		}
+	}
+	if err := client.Mail(s.config.From); err != nil {
+		return err
+	}
+	if err := client.Rcpt(n.Email); err != nil {
+		return err
+	}
+	w, err := client.Data()
+	if err != nil {
+		return err
+	}
+	if _, err := w.Write(buildMessage(s.config.From, n)); err != nil {
+		w.Close()
+		return err
+	}
+	if err := w.Close(); err != nil {
+		return err
+	}
+	return client.Quit()
+}
+
+func buildMessage(from string, n Notification) []byte {
+	var b strings.Builder
+	fmt.Fprintf(&b, "From: %s\r\n", from)
+	fmt.Fprintf(&b, "To: %s\r\n", n.Email)
+	fmt.Fprintf(&b, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", n.Subject))
+	fmt.Fprintf(&b, "Date: %s\r\n", time.Now().UTC().Format(time.RFC1123Z))
+	b.WriteString("MIME-Version: 1.0\r\n")
+	b.WriteString("Content-Type: text/plain; charset=utf-8\r\n")
+	b.WriteString("Content-Transfer-Encoding: 8bit\r\n")
+	b.WriteString("\r\n")
+	body := strings.ReplaceAll(n.Body, "\r\n", "\n")
+	for _, line := range strings.Split(body, "\n") {
+		// A leading dot is escaped by the DATA writer.
+		b.WriteString(line)
+		b.WriteString("\r\n")
+	}
+	return []byte(b.String())
+}
@@ -0,0 +1,152 @@
+package notify
+
+import (
+	"bufio"
+	"context"
+	"encoding/base64"
+	"net"
+	"strings"
+	"testing"
+	"time"
+)
+
+// smtpSession is what the fake server received.
+type smtpSession struct {
+	auth string
+	from string
+	to   []string
+	data string
+}
+
+// serveSMTP speaks enough SMTP on conn for net/smtp to deliver one message.
+// rejectRcpt makes the server refuse every recipient.
+func serveSMTP(conn net.Conn, rejectRcpt bool) <-chan smtpSession {
+	done := make(chan smtpSession, 1)
+	go func() {
+		defer conn.Close()
+		var session smtpSession
+		defer func() { done <- session }()
+
+		r := bufio.NewReader(conn)
+		reply := func(line string) { conn.Write([]byte(line + "\r\n")) }
+		reply("220 fake ESMTP")
+		for {
+			line, err := r.ReadString('\n')
+			if err != nil {
+				return
+			}
+			line = strings.TrimRight(line, "\r\n")
+			verb := strings.ToUpper(strings.SplitN(line, " ", 2)[0])
+			switch verb {
+			case "EHLO", "HELO":
+				reply("250-fake")
+				reply("250 AUTH PLAIN")
+			case "AUTH":
+				session.auth = strings.TrimPrefix(line, "AUTH PLAIN ")
+				reply("235 accepted")
+			case "MAIL":
+				session.from = line
+				reply("250 ok")
+			case "RCPT":
+				if rejectRcpt {
+					reply("550 no such user")
+					continue
+				}
+				session.to = append(session.to, line)
+				reply("250 ok")
+			case "DATA":
+				reply("354 go ahead")
+				var data strings.Builder
+				for {
+					line, err := r.ReadString('\n')
+					if err != nil {
+						return
+					}
+					if line == ".\r\n" {
+						break
+					}
+					data.WriteString(line)
+				}
+				session.data = data.String()
+				reply("250 queued")
+			case "RSET", "NOOP":
+				reply("250 ok")
+			case "QUIT":
+				reply("221 bye")
+				return
+			default:
+				reply("502 not implemented")
+			}
+		}
+	}()
+	return done
+}
+
+func fakeSMTP(config SMTPConfig, rejectRcpt bool) (*SMTP, <-chan smtpSession) {
+	client, server := net.Pipe()
+	sessions := serveSMTP(server, rejectRcpt)
+	notifier := NewSMTP(config)
+	notifier.dial = func(ctx context.Context, addr string) (net.Conn, error) {
+		return client, nil
+	}
+	return notifier, sessions
+}
+
+func TestSMTPNotify(t *testing.T) {
+	notifier, sessions := fakeSMTP(SMTPConfig{Addr: "localhost:25", From: "budget@example.com", Username: "budget", Password: "secret"}, false)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	err := notifier.Notify(ctx, Notification{
+		Email:   "anna@example.com",
+		Subject: "Напоминание: аренда",
+		Body:    "Rent is due tomorrow.\n.hidden line",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	session := <-sessions
+
+	auth, err := base64.StdEncoding.DecodeString(session.auth)
+	if err != nil || string(auth) != "\x00budget\x00secret" {
+		t.Fatalf("got auth %q", auth)
+	}
+	if session.from != "MAIL FROM:<budget@example.com>" {
+		t.Fatalf("got %q", session.from)
+	}
+	if len(session.to) != 1 || session.to[0] != "RCPT TO:<anna@example.com>" {
+		t.Fatalf("got recipients %q", session.to)
+	}
+	for _, want := range []string{
+		"From: budget@example.com\r\n",
+		"To: anna@example.com\r\n",
+		"Subject: =?utf-8?q?",
+		"Content-Type: text/plain; charset=utf-8\r\n",
+		"\r\n\r\nRent is due tomorrow.\r\n..hidden line\r\n",
+	} {
+		if !strings.Contains(session.data, want) {
+			t.Fatalf("message lacks %q:\n%s", want, session.data)
+		}
+	}
+}
+
+func TestSMTPNotifyErrors(t *testing.T) {
+	ctx := context.Background()
+
+	notifier, sessions := fakeSMTP(SMTPConfig{Addr: "localhost:25", From: "budget@example.com"}, true)
+	if err := notifier.Notify(ctx, Notification{Email: "nobody@example.com", Subject: "Reminder"}); err == nil {
+		t.Fatal("a rejected recipient was accepted")
+	}
+	if session := <-sessions; session.auth != "" || session.data != "" {
+		t.Fatalf("got session %+v", session)
+	}
+
+	notifier = NewSMTP(SMTPConfig{Addr: "localhost:25"})
+	notifier.dial = func(ctx context.Context, addr string) (net.Conn, error) {
+		t.Fatal("dialled without a recipient")
+		return nil, nil
+	}
+	if err := notifier.Notify(ctx, Notification{Email: " "}); err == nil {
+		t.Fatal("a notification without email was sent")
+	}
+}
@@ -0,0 +1,113 @@
+package rates
+
+import (
+	"context"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"familybudget/internal/domain"
+)
+
+const (
+	// ECBDailyURL publishes the reference rates of the last working day.
+	ECBDailyURL = "https://www.ecb.europa.eu/stats/eurofxref/eurofxref-daily.xml"
+	// ECBHistoryURL publishes the reference rates of the last 90 days.
+	ECBHistoryURL = "https://www.ecb.europa.eu/stats/eurofxref/eurofxref-hist-90d.xml"
+
+	ECBSource = "ecb"
+)
+
+// ECB reads the euro foreign exchange reference rates of the European Central
+// Bank from an http(s) URL or a local file in the same XML format.
+type ECB struct {
+	source string
+	client *http.Client
+}
+
+// NewECB returns a provider reading source, which is an http(s) URL or a
+// file path. A nil client uses a client with a 30 second timeout.
+func NewECB(source string, client *http.Client) *ECB {
+	if client == nil {
+		client = &http.Client{Timeout: 30 * time.Second}
+	}
+	return &ECB{source: source, client: client}
+}
+
+func (p *ECB) Name() string {
+	return ECBSource
+}
+
+func (p *ECB) Fetch(ctx context.Context) ([]domain.ExchangeRate, error) {
+	if !strings.HasPrefix(p.source, "http://") && !strings.HasPrefix(p.source, "https://") {
+		file, err := os.Open(strings.TrimPrefix(p.source, "file://"))
+		if err != nil {
+			return nil, err
+		}
+		defer file.Close()
+		return ParseECB(file)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, p.source, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("ecb: unexpected status %s", resp.Status)
+	}
+	return ParseECB(resp.Body)
+}
+
+// ecbEnvelope matches the gesmes envelope of the ECB feeds: a Cube per day
+// holding a Cube per currency.
+type ecbEnvelope struct {
+	Days []struct {
+		Time  string `xml:"time,attr"`
+		Rates []struct {
+			Currency string `xml:"currency,attr"`
+			Rate     string `xml:"rate,attr"`
+		} `xml:"Cube"`
+	} `xml:"Cube>Cube"`
+}
+
+// ParseECB reads rates from an ECB reference rate document. Every rate is the
+// price of one euro in the quoted currency.
+func ParseECB(r io.Reader) ([]domain.ExchangeRate, error) {
+	var envelope ecbEnvelope
+	if err := xml.NewDecoder(r).Decode(&envelope); err != nil {
+		return nil, fmt.Errorf("ecb: %w", err)
+	}
+	var result []domain.ExchangeRate
+	for _, day := range envelope.Days {
+		if _, err := time.Parse("2006-01-02", day.Time); err != nil {
+			return nil, fmt.Errorf("ecb: invalid date %q", day.Time)
+		}
+		for _, entry := range day.Rates {
+			rate, err := strconv.ParseFloat(strings.TrimSpace(entry.Rate), 64)
+			if err != nil || rate <= 0 {
+				return nil, fmt.Errorf("ecb: invalid rate %q for %s on %s", entry.Rate, entry.Currency, day.Time)
+			}
+			result = append(result, domain.ExchangeRate{
+				Base:   "EUR",
+				Quote:  strings.ToUpper(strings.TrimSpace(entry.Currency)),
+				Rate:   rate,
+				AsOf:   day.Time,
+				Source: ECBSource,
+			})
+		}
+	}
+	if len(result) == 0 {
+		return nil, fmt.Errorf("ecb: no rates in document")
+	}
+	return result, nil
+}
@@ -0,0 +1,78 @@
+package rates
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestParseECB(t *testing.T) {
+	file, err := os.Open("testdata/eurofxref-hist.xml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+
+	rates, err := ParseECB(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rates) != 6 {
+		t.Fatalf("got %d rates, want 6", len(rates))
+	}
+	first := rates[0]
+	if first.Base != "EUR" || first.Quote != "USD" || first.Rate != 1.0412 || first.AsOf != "2026-03-03" || first.Source != ECBSource {
+		t.Fatalf("got first rate %+v", first)
+	}
+	if last := rates[5]; last.Quote != "HUF" || last.Rate != 403.1 || last.AsOf != "2026-03-02" {
+		t.Fatalf("got last rate %+v", last)
+	}
+}
+
+func TestParseECBRejectsInvalidDocuments(t *testing.T) {
+	documents := map[string]string{
+		"not xml":      "rates",
+		"no rates":     `<Envelope><Cube></Cube></Envelope>`,
+		"invalid date": `<Envelope><Cube><Cube time="03.03.2026"><Cube currency="USD" rate="1.04"/></Cube></Cube></Envelope>`,
+		"invalid rate": `<Envelope><Cube><Cube time="2026-03-03"><Cube currency="USD" rate="n/a"/></Cube></Cube></Envelope>`,
+		"zero rate":    `<Envelope><Cube><Cube time="2026-03-03"><Cube currency="USD" rate="0"/></Cube></Cube></Envelope>`,
+	}
+	for name, document := range documents {
+		t.Run(name, func(t *testing.T) {
+			if _, err := ParseECB(strings.NewReader(document)); err == nil {
+				t.Fatal("the document was accepted")
+			}
+		})
+	}
+}
+
+func TestECBFetch(t *testing.T) {
+	fixture, err := os.ReadFile("testdata/eurofxref-hist.xml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/eurofxref.xml" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Write(fixture)
+	}))
+	defer server.Close()
+
+	for _, source := range []string{"testdata/eurofxref-hist.xml", server.URL + "/eurofxref.xml"} {
+		rates, err := NewECB(source, server.Client()).Fetch(context.Background())
+		if err != nil {
+			t.Fatalf("%s: %v", source, err)
+		}
+		if len(rates) != 6 {
+			t.Fatalf("%s: got %d rates, want 6", source, len(rates))
+		}
+	}
+	if _, err := NewECB(server.URL+"/missing.xml", server.Client()).Fetch(context.Background()); err == nil {
+		t.Fatal("a 404 response was accepted")
+	}
+}
@@ -0,0 +1,15 @@
+// Package rates fetches exchange rates from external providers.
+package rates
+
+import (
+	"context"
+
+	"familybudget/internal/domain"
+)
+
+// Provider fetches the latest published exchange rates. Rates carry the
+// provider name as their source.
+type Provider interface {
+	Name() string
+	Fetch(ctx context.Context) ([]domain.ExchangeRate, error)
+}
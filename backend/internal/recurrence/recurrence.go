@@ -0,0 +1,608 @@
+// Package recurrence computes repeat dates for planned operations, allowances
+// and other scheduled entries.
+//
+// A rule is either one of the simple values weekly, monthly and yearly or an
+// RFC 5545 RRULE such as "FREQ=MONTHLY;BYDAY=MO,TU,WE,TH,FR;BYSETPOS=-1". The
+// simple monthly and yearly values keep the day of the series start and fall
+// back to the last day of shorter months, so a series started on Jan 31 is due
+// on Feb 28 and then again on Mar 31.
+package recurrence
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var ErrSeriesEnded = errors.New("recurrence has no further occurrences")
+
+const (
+	FreqDaily   = "DAILY"
+	FreqWeekly  = "WEEKLY"
+	FreqMonthly = "MONTHLY"
+	FreqYearly  = "YEARLY"
+)
+
+// searchHorizon bounds the search for the next occurrence of rules that can
+// never match again, e.g. "FREQ=YEARLY;BYMONTH=2;BYMONTHDAY=30".
+const searchHorizon = 30
+
+type weekdayNum struct {
+	n       int
+	weekday time.Weekday
+}
+
+// Rule is a parsed recurrence rule.
+type Rule struct {
+	Freq       string
+	Interval   int
+	Count      int
+	until      string
+	byDay      []weekdayNum
+	byMonthDay []int
+	byMonth    []int
+	bySetPos   []int
+	weekStart  time.Weekday
+	simple     string
+}
+
+var weekdayCodes = map[string]time.Weekday{
+	"SU": time.Sunday,
+	"MO": time.Monday,
+	"TU": time.Tuesday,
+	"WE": time.Wednesday,
+	"TH": time.Thursday,
+	"FR": time.Friday,
+	"SA": time.Saturday,
+}
+
+var weekdayNames = [...]string{"SU", "MO", "TU", "WE", "TH", "FR", "SA"}
+
+// Normalize validates a recurrence rule and returns its canonical form. An
+// empty result means the entry does not repeat.
+func Normalize(value string) (string, error) {
+	trimmed := strings.TrimSpace(value)
+	if trimmed == "" || strings.EqualFold(trimmed, "none") {
+		return "", nil
+	}
+	rule, err := Parse(trimmed)
+	if err != nil {
+		return "", err
+	}
+	return rule.String(), nil
+}
+
+// Parse parses a simple value or an RRULE, with or without the "RRULE:"
+// prefix.
+func Parse(value string) (*Rule, error) {
+	trimmed := strings.TrimSpace(value)
+	switch strings.ToLower(trimmed) {
+	case "weekly":
+		return &Rule{Freq: FreqWeekly, Interval: 1, weekStart: time.Monday, simple: "weekly"}, nil
+	case "monthly":
+		return &Rule{Freq: FreqMonthly, Interval: 1, weekStart: time.Monday, simple: "monthly"}, nil
+	case "yearly":
+		return &Rule{Freq: FreqYearly, Interval: 1, weekStart: time.Monday, simple: "yearly"}, nil
+	}
+
+	upper := strings.ToUpper(trimmed)
+	upper = strings.TrimPrefix(upper, "RRULE:")
+	if upper == "" {
+		return nil, fmt.Errorf("recurrence must be weekly, monthly, yearly, none or an RRULE")
+	}
+
+	rule := &Rule{Interval: 1, weekStart: time.Monday}
+	seen := map[string]bool{}
+	for _, part := range strings.Split(upper, ";") {
+		if part == "" {
+			continue
+		}
+		key, val, ok := strings.Cut(part, "=")
+		if !ok || val == "" {
+			return nil, fmt.Errorf("invalid RRULE part %q", part)
+		}
+		if seen[key] {
+			return nil, fmt.Errorf("duplicate RRULE part %s", key)
+		}
+		seen[key] = true
+
+		var err error
+		switch key {
+		case "FREQ":
+			switch val {
+			case FreqDaily, FreqWeekly, FreqMonthly, FreqYearly:
+				rule.Freq = val
+			default:
+				return nil, fmt.Errorf("FREQ must be DAILY, WEEKLY, MONTHLY or YEARLY")
+			}
+		case "INTERVAL":
+			rule.Interval, err = strconv.Atoi(val)
+			if err != nil || rule.Interval < 1 {
+				return nil, fmt.Errorf("INTERVAL must be a positive number")
+			}
+		case "COUNT":
+			rule.Count, err = strconv.Atoi(val)
+			if err != nil || rule.Count < 1 {
+				return nil, fmt.Errorf("COUNT must be a positive number")
+			}
+		case "UNTIL":
+			if _, err := parseUntil(val, time.UTC); err != nil {
+				return nil, err
+			}
+			rule.until = val
+		case "BYDAY":
+			for _, item := range strings.Split(val, ",") {
+				day, err := parseWeekdayNum(item)
+				if err != nil {
+					return nil, err
+				}
+				rule.byDay = append(rule.byDay, day)
+			}
+		case "BYMONTHDAY":
+			rule.byMonthDay, err = parseIntList(val, 1, 31, true)
+			if err != nil {
+				return nil, fmt.Errorf("BYMONTHDAY: %w", err)
+			}
+		case "BYMONTH":
+			rule.byMonth, err = parseIntList(val, 1, 12, false)
+			if err != nil {
+				return nil, fmt.Errorf("BYMONTH: %w", err)
+			}
+		case "BYSETPOS":
+			rule.bySetPos, err = parseIntList(val, 1, 366, true)
+			if err != nil {
+				return nil, fmt.Errorf("BYSETPOS: %w", err)
+			}
+		case "WKST":
+			weekday, ok := weekdayCodes[val]
+			if !ok {
+				return nil, fmt.Errorf("WKST must be a weekday code")
+			}
+			rule.weekStart = weekday
+		default:
+			return nil, fmt.Errorf("unsupported RRULE part %s", key)
+		}
+	}
+
+	if rule.Freq == "" {
+		return nil, fmt.Errorf("RRULE requires FREQ")
+	}
+	if rule.Count > 0 && rule.until != "" {
+		return nil, fmt.Errorf("RRULE must not contain both COUNT and UNTIL")
+	}
+	if rule.Freq == FreqWeekly && len(rule.byMonthDay) > 0 {
+		return nil, fmt.Errorf("BYMONTHDAY is not allowed with FREQ=WEEKLY")
+	}
+	if len(rule.bySetPos) > 0 && len(rule.byDay) == 0 && len(rule.byMonthDay) == 0 && len(rule.byMonth) == 0 {
+		return nil, fmt.Errorf("BYSETPOS requires another BYxxx part")
+	}
+	for _, day := range rule.byDay {
+		if day.n != 0 && rule.Freq != FreqMonthly && rule.Freq != FreqYearly {
+			return nil, fmt.Errorf("numbered BYDAY values require FREQ=MONTHLY or FREQ=YEARLY")
+		}
+	}
+	return rule, nil
+}
+
+// String returns the canonical form of the rule.
+func (r *Rule) String() string {
+	if r.simple != "" {
+		return r.simple
+	}
+	parts := []string{"FREQ=" + r.Freq}
+	if r.Interval > 1 {
+		parts = append(parts, "INTERVAL="+strconv.Itoa(r.Interval))
+	}
+	if len(r.byMonth) > 0 {
+		parts = append(parts, "BYMONTH="+joinInts(r.byMonth))
+	}
+	if len(r.byMonthDay) > 0 {
+		parts = append(parts, "BYMONTHDAY="+joinInts(r.byMonthDay))
+	}
+	if len(r.byDay) > 0 {
+		days := make([]string, 0, len(r.byDay))
+		for _, day := range r.byDay {
+			code := weekdayNames[day.weekday]
+			if day.n != 0 {
+				code = strconv.Itoa(day.n) + code
+			}
+			days = append(days, code)
+		}
+		parts = append(parts, "BYDAY="+strings.Join(days, ","))
+	}
+	if len(r.bySetPos) > 0 {
+		parts = append(parts, "BYSETPOS="+joinInts(r.bySetPos))
+	}
+	if r.Count > 0 {
+		parts = append(parts, "COUNT="+strconv.Itoa(r.Count))
+	}
+	if r.until != "" {
+		parts = append(parts, "UNTIL="+r.until)
+	}
+	if r.weekStart != time.Monday {
+		parts = append(parts, "WKST="+weekdayNames[r.weekStart])
+	}
+	return strings.Join(parts, ";")
+}
+
+// RRule returns the rule as an RRULE value for a calendar event that
+// continues the series started at seriesStart after consumed occurrences.
+// Simple values are spelled out so that calendars reproduce the fallback to
+// the last day of shorter months, and COUNT is reduced by consumed.
+func (r *Rule) RRule(seriesStart time.Time, consumed int, loc *time.Location) string {
+	if loc == nil {
+		loc = time.UTC
+	}
+	start := seriesStart.In(loc)
+	switch r.simple {
+	case "weekly":
+		return "FREQ=WEEKLY"
+	case "monthly":
+		return "FREQ=MONTHLY;" + clampedMonthDays(start.Day())
+	case "yearly":
+		if start.Month() == time.February {
+			return "FREQ=YEARLY;BYMONTH=2;" + clampedMonthDays(start.Day())
+		}
+		return "FREQ=YEARLY;BYMONTH=" + strconv.Itoa(int(start.Month())) + ";BYMONTHDAY=" + strconv.Itoa(start.Day())
+	}
+	continued := *r
+	if continued.Count > 0 {
+		continued.Count -= consumed
+		if continued.Count < 1 {
+			continued.Count = 1
+		}
+	}
+	return continued.String()
+}
+
+// clampedMonthDays selects day, or the last day of months shorter than day.
+func clampedMonthDays(day int) string {
+	if day <= 28 {
+		return "BYMONTHDAY=" + strconv.Itoa(day)
+	}
+	days := make([]int, 0, day-27)
+	for d := 28; d <= day; d++ {
+		days = append(days, d)
+	}
+	return "BYMONTHDAY=" + joinInts(days) + ";BYSETPOS=-1"
+}
+
+// Next returns the first occurrence strictly after `after` of the series that
+// starts at start. Dates are evaluated in loc so that wall-clock time and day
+// boundaries follow the family's time zone. ErrSeriesEnded is returned when
+// COUNT or UNTIL exhausted the series.
+func Next(rule string, start, after time.Time, loc *time.Location) (time.Time, error) {
+	parsed, err := Parse(rule)
+	if err != nil {
+		return time.Time{}, err
+	}
+	return parsed.Next(start, after, loc)
+}
+
+// Between returns the occurrences of the series in [from, to).
+func Between(rule string, start, from, to time.Time, loc *time.Location) ([]time.Time, error) {
+	parsed, err := Parse(rule)
+	if err != nil {
+		return nil, err
+	}
+	if loc == nil {
+		loc = time.UTC
+	}
+	var result []time.Time
+	err = parsed.walk(start.In(loc), to.In(loc), func(occurrence time.Time) bool {
+		if !occurrence.Before(to) {
+			return false
+		}
+		if !occurrence.Before(from) {
+			result = append(result, occurrence.UTC())
+		}
+		return true
+	})
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+func (r *Rule) Next(start, after time.Time, loc *time.Location) (time.Time, error) {
+	if loc == nil {
+		loc = time.UTC
+	}
+	start = start.In(loc)
+	after = after.In(loc)
+	if start.After(after) {
+		return start.UTC(), nil
+	}
+	horizon := after.AddDate(searchHorizon, 0, 0)
+	if start.After(horizon) {
+		horizon = start.AddDate(searchHorizon, 0, 0)
+	}
+
+	var next time.Time
+	err := r.walk(start, horizon, func(occurrence time.Time) bool {
+		if occurrence.After(after) {
+			next = occurrence
+			return false
+		}
+		return true
+	})
+	if err != nil {
+		return time.Time{}, err
+	}
+	if next.IsZero() {
+		return time.Time{}, ErrSeriesEnded
+	}
+	return next.UTC(), nil
+}
+
+// walk passes the occurrences of the series that starts at start to visit in
+// order, until visit returns false, COUNT or UNTIL end the series or the
+// occurrences pass limit. Callers walk the series once instead of searching
+// it from the start for every occurrence.
+func (r *Rule) walk(start, limit time.Time, visit func(time.Time) bool) error {
+	// The series start is always its first occurrence, as DTSTART in RFC 5545.
+	if !visit(start) {
+		return nil
+	}
+
+	if r.simple == "monthly" || r.simple == "yearly" {
+		step := 1
+		if r.simple == "yearly" {
+			step = 12
+		}
+		for k := 1; ; k++ {
+			candidate := addMonthsClamped(start, k*step)
+			if candidate.After(limit) || !visit(candidate) {
+				return nil
+			}
+		}
+	}
+
+	var until *time.Time
+	if r.until != "" {
+		parsed, err := parseUntil(r.until, start.Location())
+		if err != nil {
+			return err
+		}
+		until = &parsed
+	}
+
+	count := 1
+	for period := 0; ; period++ {
+		from, to := r.periodScope(start, period)
+		if from.After(limit) {
+			return nil
+		}
+		for _, candidate := range r.candidates(start, from, to) {
+			if !candidate.After(start) {
+				continue
+			}
+			count++
+			if r.Count > 0 && count > r.Count {
+				return nil
+			}
+			if until != nil && candidate.After(*until) {
+				return nil
+			}
+			if !visit(candidate) {
+				return nil
+			}
+		}
+	}
+}
+
+// periodScope returns the days [from, to) covered by the n-th period of the
+// series, as midnights in the start's location.
+func (r *Rule) periodScope(start time.Time, period int) (time.Time, time.Time) {
+	day := time.Date(start.Year(), start.Month(), start.Day(), 0, 0, 0, 0, start.Location())
+	step := period * r.Interval
+	switch r.Freq {
+	case FreqDaily:
+		from := day.AddDate(0, 0, step)
+		return from, from.AddDate(0, 0, 1)
+	case FreqWeekly:
+		offset := (int(day.Weekday()) - int(r.weekStart) + 7) % 7
+		from := day.AddDate(0, 0, -offset+7*step)
+		return from, from.AddDate(0, 0, 7)
+	case FreqMonthly:
+		from := time.Date(day.Year(), day.Month(), 1, 0, 0, 0, 0, day.Location()).AddDate(0, step, 0)
+		return from, from.AddDate(0, 1, 0)
+	default:
+		from := time.Date(day.Year()+step, time.January, 1, 0, 0, 0, 0, day.Location())
+		return from, from.AddDate(1, 0, 0)
+	}
+}
+
+func (r *Rule) candidates(start, from, to time.Time) []time.Time {
+	var result []time.Time
+	for day := from; day.Before(to); day = day.AddDate(0, 0, 1) {
+		if r.matches(start, day) {
+			result = append(result, time.Date(day.Year(), day.Month(), day.Day(), start.Hour(), start.Minute(), start.Second(), start.Nanosecond(), start.Location()))
+		}
+	}
+	if len(r.bySetPos) == 0 || len(result) == 0 {
+		return result
+	}
+
+	picked := map[int]bool{}
+	for _, pos := range r.bySetPos {
+		index := pos - 1
+		if pos < 0 {
+			index = len(result) + pos
+		}
+		if index >= 0 && index < len(result) {
+			picked[index] = true
+		}
+	}
+	indexes := make([]int, 0, len(picked))
+	for index := range picked {
+		indexes = append(indexes, index)
+	}
+	sort.Ints(indexes)
+	selected := make([]time.Time, 0, len(indexes))
+	for _, index := range indexes {
+		selected = append(selected, result[index])
+	}
+	return selected
+}
+
+func (r *Rule) matches(start, day time.Time) bool {
+	if len(r.byMonth) > 0 {
+		if !containsInt(r.byMonth, int(day.Month())) {
+			return false
+		}
+	} else if r.Freq == FreqYearly && len(r.byMonthDay) == 0 && len(r.byDay) == 0 && day.Month() != start.Month() {
+		return false
+	}
+
+	monthLength := daysIn(day.Year(), day.Month())
+	if len(r.byMonthDay) > 0 {
+		matched := false
+		for _, value := range r.byMonthDay {
+			if value == day.Day() || (value < 0 && monthLength+1+value == day.Day()) {
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			return false
+		}
+	} else if len(r.byDay) == 0 && (r.Freq == FreqMonthly || r.Freq == FreqYearly) && day.Day() != start.Day() {
+		return false
+	}
+
+	if len(r.byDay) > 0 {
+		inMonth := r.Freq == FreqMonthly || len(r.byMonth) > 0
+		matched := false
+		for _, item := range r.byDay {
+			if item.weekday != day.Weekday() {
+				continue
+			}
+			if item.n == 0 {
+				matched = true
+				break
+			}
+			position, length := day.Day(), monthLength
+			if !inMonth {
+				position, length = day.YearDay(), daysInYear(day.Year())
+			}
+			if item.n > 0 && (position-1)/7+1 == item.n {
+				matched = true
+				break
+			}
+			if item.n < 0 && (length-position)/7+1 == -item.n {
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			return false
+		}
+	} else if r.Freq == FreqWeekly && day.Weekday() != start.Weekday() {
+		return false
+	}
+	return true
+}
+
+// addMonthsClamped adds months keeping the day of month, falling back to the
+// last day of shorter months.
+func addMonthsClamped(start time.Time, months int) time.Time {
+	first := time.Date(start.Year(), start.Month(), 1, start.Hour(), start.Minute(), start.Second(), start.Nanosecond(), start.Location()).AddDate(0, months, 0)
+	day := start.Day()
+	if length := daysIn(first.Year(), first.Month()); day > length {
+		day = length
+	}
+	return first.AddDate(0, 0, day-1)
+}
+
+func parseUntil(value string, loc *time.Location) (time.Time, error) {
+	if t, err := time.Parse("20060102T150405Z", value); err == nil {
+		return t, nil
+	}
+	if t, err := time.ParseInLocation("20060102T150405", value, loc); err == nil {
+		return t, nil
+	}
+	if t, err := time.ParseInLocation("20060102", value, loc); err == nil {
+		return t.AddDate(0, 0, 1).Add(-time.Second), nil
+	}
+	return time.Time{}, fmt.Errorf("UNTIL must be a date (YYYYMMDD) or date-time (YYYYMMDDTHHMMSSZ)")
+}
+
+func parseWeekdayNum(value string) (weekdayNum, error) {
+	value = strings.TrimSpace(value)
+	if len(value) < 2 {
+		return weekdayNum{}, fmt.Errorf("invalid BYDAY value %q", value)
+	}
+	code := value[len(value)-2:]
+	weekday, ok := weekdayCodes[code]
+	if !ok {
+		return weekdayNum{}, fmt.Errorf("invalid BYDAY value %q", value)
+	}
+	result := weekdayNum{weekday: weekday}
+	if prefix := value[:len(value)-2]; prefix != "" {
+		n, err := strconv.Atoi(prefix)
+		if err != nil || n == 0 || n > 53 || n < -53 {
+			return weekdayNum{}, fmt.Errorf("invalid BYDAY value %q", value)
+		}
+		result.n = n
+	}
+	return result, nil
+}
+
+func parseIntList(value string, min, max int, allowNegative bool) ([]int, error) {
+	var result []int
+	for _, item := range strings.Split(value, ",") {
+		n, err := strconv.Atoi(strings.TrimSpace(item))
+		if err != nil {
+			return nil, fmt.Errorf("invalid number %q", item)
+		}
+		abs := n
+		if abs < 0 {
+			if !allowNegative {
+				return nil, fmt.Errorf("negative values are not allowed")
+			}
+			abs = -abs
+		}
+		if abs < min || abs > max {
+			return nil, fmt.Errorf("value %d is out of range", n)
+		}
+		result = append(result, n)
+	}
+	return result, nil
+}
+
+func joinInts(values []int) string {
+	items := make([]string, 0, len(values))
+	for _, value := range values {
+		items = append(items, strconv.Itoa(value))
+	}
+	return strings.Join(items, ",")
+}
+
+func containsInt(values []int, target int) bool {
+	for _, value := range values {
+		if value == target {
+			return true
+		}
+	}
+	return false
+}
+
+func daysIn(year int, month time.Month) int {
+	return time.Date(year, month+1, 0, 0, 0, 0, 0, time.UTC).Day()
+}
+
+func daysInYear(year int) int {
+	return time.Date(year, time.December, 31, 0, 0, 0, 0, time.UTC).YearDay()
+}
+
+// LoadLocation resolves an IANA time zone name, treating an empty name as UTC.
+func LoadLocation(name string) (*time.Location, error) {
+	if strings.TrimSpace(name) == "" {
+		return time.UTC, nil
+	}
+	return time.LoadLocation(strings.TrimSpace(name))
+}
@@ -0,0 +1,236 @@
+package recurrence
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestBetween(t *testing.T) {
+	berlin, err := time.LoadLocation("Europe/Berlin")
+	if err != nil {
+		t.Fatal(err)
+	}
+	utc := func(value string) time.Time {
+		parsed, err := time.Parse(time.RFC3339, value)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return parsed
+	}
+
+	tests := []struct {
+		name  string
+		rule  string
+		start time.Time
+		from  time.Time
+		to    time.Time
+		loc   *time.Location
+		want  []string
+	}{
+		{
+			name:  "last weekday of the month",
+			rule:  "FREQ=MONTHLY;BYDAY=MO,TU,WE,TH,FR;BYSETPOS=-1",
+			start: utc("2026-01-30T10:00:00Z"),
+			from:  utc("2026-01-01T00:00:00Z"),
+			to:    utc("2026-06-01T00:00:00Z"),
+			want:  []string{"2026-01-30T10:00:00Z", "2026-02-27T10:00:00Z", "2026-03-31T10:00:00Z", "2026-04-30T10:00:00Z", "2026-05-29T10:00:00Z"},
+		},
+		{
+			name:  "second and last friday",
+			rule:  "FREQ=MONTHLY;BYDAY=FR;BYSETPOS=2,-1",
+			start: utc("2026-03-13T09:00:00Z"),
+			from:  utc("2026-03-01T00:00:00Z"),
+			to:    utc("2026-05-01T00:00:00Z"),
+			want:  []string{"2026-03-13T09:00:00Z", "2026-03-27T09:00:00Z", "2026-04-10T09:00:00Z", "2026-04-24T09:00:00Z"},
+		},
+		{
+			name:  "last day of the month",
+			rule:  "FREQ=MONTHLY;BYMONTHDAY=-1",
+			start: utc("2026-01-31T12:00:00Z"),
+			from:  utc("2026-01-01T00:00:00Z"),
+			to:    utc("2026-05-01T00:00:00Z"),
+			want:  []string{"2026-01-31T12:00:00Z", "2026-02-28T12:00:00Z", "2026-03-31T12:00:00Z", "2026-04-30T12:00:00Z"},
+		},
+		{
+			name:  "count",
+			rule:  "FREQ=DAILY;COUNT=3",
+			start: utc("2026-03-01T09:00:00Z"),
+			from:  utc("2026-01-01T00:00:00Z"),
+			to:    utc("2027-01-01T00:00:00Z"),
+			want:  []string{"2026-03-01T09:00:00Z", "2026-03-02T09:00:00Z", "2026-03-03T09:00:00Z"},
+		},
+		{
+			name:  "count spent before the range",
+			rule:  "FREQ=WEEKLY;COUNT=2",
+			start: utc("2026-03-02T09:00:00Z"),
+			from:  utc("2026-03-10T00:00:00Z"),
+			to:    utc("2026-06-01T00:00:00Z"),
+			want:  nil,
+		},
+		{
+			name:  "until",
+			rule:  "FREQ=DAILY;INTERVAL=2;UNTIL=20260307T000000Z",
+			start: utc("2026-03-01T09:00:00Z"),
+			from:  utc("2026-03-01T00:00:00Z"),
+			to:    utc("2026-04-01T00:00:00Z"),
+			want:  []string{"2026-03-01T09:00:00Z", "2026-03-03T09:00:00Z", "2026-03-05T09:00:00Z"},
+		},
+		{
+			name:  "until as a date includes that day",
+			rule:  "FREQ=WEEKLY;BYDAY=MO,TH;UNTIL=20260312",
+			start: utc("2026-03-02T18:00:00Z"),
+			from:  utc("2026-03-01T00:00:00Z"),
+			to:    utc("2026-04-01T00:00:00Z"),
+			want:  []string{"2026-03-02T18:00:00Z", "2026-03-05T18:00:00Z", "2026-03-09T18:00:00Z", "2026-03-12T18:00:00Z"},
+		},
+		{
+			name:  "monthly falls back to the last day of shorter months",
+			rule:  "monthly",
+			start: utc("2026-01-31T08:00:00Z"),
+			from:  utc("2026-01-01T00:00:00Z"),
+			to:    utc("2026-05-01T00:00:00Z"),
+			want:  []string{"2026-01-31T08:00:00Z", "2026-02-28T08:00:00Z", "2026-03-31T08:00:00Z", "2026-04-30T08:00:00Z"},
+		},
+		{
+			name:  "yearly from a leap day",
+			rule:  "yearly",
+			start: utc("2024-02-29T08:00:00Z"),
+			from:  utc("2024-01-01T00:00:00Z"),
+			to:    utc("2029-01-01T00:00:00Z"),
+			want:  []string{"2024-02-29T08:00:00Z", "2025-02-28T08:00:00Z", "2026-02-28T08:00:00Z", "2027-02-28T08:00:00Z", "2028-02-29T08:00:00Z"},
+		},
+		{
+			name:  "weekly keeps the weekday of the start",
+			rule:  "weekly",
+			start: utc("2026-01-07T07:30:00Z"),
+			from:  utc("2026-01-10T00:00:00Z"),
+			to:    utc("2026-01-29T00:00:00Z"),
+			want:  []string{"2026-01-14T07:30:00Z", "2026-01-21T07:30:00Z", "2026-01-28T07:30:00Z"},
+		},
+		{
+			name:  "wall-clock time kept across the spring DST change",
+			rule:  "FREQ=DAILY",
+			start: time.Date(2026, time.March, 28, 9, 0, 0, 0, berlin),
+			from:  utc("2026-03-28T00:00:00Z"),
+			to:    utc("2026-03-31T00:00:00Z"),
+			loc:   berlin,
+			want:  []string{"2026-03-28T08:00:00Z", "2026-03-29T07:00:00Z", "2026-03-30T07:00:00Z"},
+		},
+		{
+			name:  "wall-clock time kept across the autumn DST change",
+			rule:  "FREQ=WEEKLY",
+			start: time.Date(2026, time.October, 18, 9, 0, 0, 0, berlin),
+			from:  utc("2026-10-01T00:00:00Z"),
+			to:    utc("2026-11-01T00:00:00Z"),
+			loc:   berlin,
+			want:  []string{"2026-10-18T07:00:00Z", "2026-10-25T08:00:00Z"},
+		},
+		{
+			name:  "day boundaries follow the family time zone",
+			rule:  "FREQ=MONTHLY;BYMONTHDAY=1",
+			start: time.Date(2026, time.January, 1, 0, 30, 0, 0, berlin),
+			from:  utc("2026-01-01T00:00:00Z"),
+			to:    utc("2026-03-15T00:00:00Z"),
+			loc:   berlin,
+			want:  []string{"2026-01-31T23:30:00Z", "2026-02-28T23:30:00Z"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Between(tt.rule, tt.start, tt.from, tt.to, tt.loc)
+			if err != nil {
+				t.Fatalf("Between: %v", err)
+			}
+			if len(got) != len(tt.want) {
+				t.Fatalf("got %v, want %v", got, tt.want)
+			}
+			for i := range got {
+				if got[i].Format(time.RFC3339) != tt.want[i] {
+					t.Fatalf("occurrence %d: got %s, want %s", i, got[i].Format(time.RFC3339), tt.want[i])
+				}
+			}
+		})
+	}
+}
+
+func TestNext(t *testing.T) {
+	start := time.Date(2026, time.March, 1, 9, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name    string
+		rule    string
+		after   time.Time
+		want    time.Time
+		wantErr error
+	}{
+		{"start is the first occurrence", "FREQ=DAILY;COUNT=2", start.Add(-time.Hour), start, nil},
+		{"strictly after", "FREQ=DAILY;COUNT=2", start, start.AddDate(0, 0, 1), nil},
+		{"count exhausted", "FREQ=DAILY;COUNT=2", start.AddDate(0, 0, 1), time.Time{}, ErrSeriesEnded},
+		{"until exhausted", "FREQ=WEEKLY;UNTIL=20260310", start.AddDate(0, 0, 7), time.Time{}, ErrSeriesEnded},
+		{"impossible date", "FREQ=YEARLY;BYMONTH=2;BYMONTHDAY=30", start, time.Time{}, ErrSeriesEnded},
+		{"legacy monthly", "monthly", start, start.AddDate(0, 1, 0), nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Next(tt.rule, start, tt.after, time.UTC)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("got error %v, want %v", err, tt.wantErr)
+			}
+			if !got.Equal(tt.want) {
+				t.Fatalf("got %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestBetweenWalksLongSeriesOnce(t *testing.T) {
+	start := time.Date(2000, time.January, 1, 9, 0, 0, 0, time.UTC)
+	from := time.Date(2026, time.January, 1, 0, 0, 0, 0, time.UTC)
+
+	began := time.Now()
+	got, err := Between("FREQ=DAILY", start, from, from.AddDate(1, 0, 0), time.UTC)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 365 {
+		t.Fatalf("got %d occurrences, want 365", len(got))
+	}
+	if elapsed := time.Since(began); elapsed > 200*time.Millisecond {
+		t.Fatalf("expanding a year took %s", elapsed)
+	}
+}
+
+func TestNormalize(t *testing.T) {
+	tests := []struct {
+		value   string
+		want    string
+		wantErr bool
+	}{
+		{"", "", false},
+		{"none", "", false},
+		{"Monthly", "monthly", false},
+		{"rrule:freq=monthly;bysetpos=-1;byday=mo,tu,we,th,fr", "FREQ=MONTHLY;BYDAY=MO,TU,WE,TH,FR;BYSETPOS=-1", false},
+		{"FREQ=DAILY;INTERVAL=1;WKST=MO", "FREQ=DAILY", false},
+		{"FREQ=DAILY;COUNT=2;UNTIL=20260101", "", true},
+		{"FREQ=WEEKLY;BYMONTHDAY=1", "", true},
+		{"FREQ=WEEKLY;BYDAY=1MO", "", true},
+		{"FREQ=MONTHLY;BYSETPOS=1", "", true},
+		{"FREQ=HOURLY", "", true},
+		{"fortnightly", "", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.value, func(t *testing.T) {
+			got, err := Normalize(tt.value)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("got error %v, want error %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Fatalf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
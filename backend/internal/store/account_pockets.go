@@ -0,0 +1,178 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+
+	"familybudget/internal/domain"
+)
+
+var ErrAccountNotMultiCurrency = errors.New("account is not multi-currency")
+
+// moveAccountBalance adds delta to the balance of an account: to its
+// primary balance when pocket is empty, otherwise to the pocket of that
+// currency, which is opened on first use.
+func moveAccountBalance(ctx context.Context, q queryer, familyID, accountID, pocket string, delta int64, now time.Time) error {
+	primary := delta
+	if pocket != "" {
+		primary = 0
+	}
+	res, err := q.ExecContext(ctx, `UPDATE accounts SET balance_minor = balance_minor + ?, updated_at = ? WHERE id = ? AND family_id = ?`, primary, now, accountID, familyID)
+	if err != nil {
+		return err
+	}
+	if affected, err := res.RowsAffected(); err != nil {
+		return err
+	} else if affected == 0 {
+		return sql.ErrNoRows
+	}
+	if pocket == "" {
+		return nil
+	}
+	_, err = q.ExecContext(ctx, `INSERT INTO account_pockets (account_id, currency, balance_minor, updated_at) VALUES (?, ?, ?, ?)
+ON CONFLICT (account_id, currency) DO UPDATE SET balance_minor = account_pockets.balance_minor + excluded.balance_minor, updated_at = excluded.updated_at`,
+		accountID, pocket, delta, now)
+	return err
+}
+
+// loadAccountPockets fills the pockets of the multi-currency accounts among
+// accounts: the primary balance first, then the other currencies by code.
+func (s *Store) loadAccountPockets(ctx context.Context, accounts []domain.Account) error {
+	index := make(map[string]int)
+	var ids []interface{}
+	for i := range accounts {
+		if !accounts[i].MultiCurrency {
+			continue
+		}
+		accounts[i].Pockets = []domain.AccountPocket{{Currency: accounts[i].Currency, BalanceMinor: accounts[i].BalanceMinor}}
+		index[accounts[i].ID] = i
+		ids = append(ids, accounts[i].ID)
+	}
+	if len(ids) == 0 {
+		return nil
+	}
+	rows, err := s.db.QueryContext(ctx, `SELECT account_id, currency, balance_minor FROM account_pockets WHERE account_id IN (?`+strings.Repeat(", ?", len(ids)-1)+`) ORDER BY account_id, currency`, ids...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var accountID string
+		var pocket domain.AccountPocket
+		if err := rows.Scan(&accountID, &pocket.Currency, &pocket.BalanceMinor); err != nil {
+			return err
+		}
+		account := &accounts[index[accountID]]
+		account.Pockets = append(account.Pockets, pocket)
+	}
+	return rows.Err()
+}
+
+// CreateAccountExchange moves money between two pockets of a multi-currency
+// account and records the exchange.
+func (s *Store) CreateAccountExchange(ctx context.Context, exchange *domain.AccountExchange) error {
+	return s.withTx(ctx, func(dbTx *sql.Tx) error {
+		row := dbTx.QueryRowContext(ctx, `SELECT family_id, currency, multi_currency, is_archived FROM accounts WHERE id = ?`, exchange.AccountID)
+		var accountFamily, accountCurrency string
+		var multiCurrency, isArchived bool
+		if err := row.Scan(&accountFamily, &accountCurrency, &multiCurrency, &isArchived); err != nil {
+			return err
+		}
+		if accountFamily != exchange.FamilyID {
+			return sql.ErrNoRows
+		}
+		if isArchived {
+			return ErrAccountArchived
+		}
+		if !multiCurrency {
+			return ErrAccountNotMultiCurrency
+		}
+
+		pocket := func(currency string) string {
+			if strings.EqualFold(currency, accountCurrency) {
+				return ""
+			}
+			return strings.ToUpper(currency)
+		}
+		if err := moveAccountBalance(ctx, dbTx, exchange.FamilyID, exchange.AccountID, pocket(exchange.FromCurrency), -exchange.FromAmountMinor, exchange.CreatedAt); err != nil {
+			return err
+		}
+		if err := moveAccountBalance(ctx, dbTx, exchange.FamilyID, exchange.AccountID, pocket(exchange.ToCurrency), exchange.ToAmountMinor, exchange.CreatedAt); err != nil {
+			return err
+		}
+
+		if exchange.ID == "" {
+			exchange.ID = uuid.NewString()
+		}
+		if _, err := dbTx.ExecContext(ctx, `INSERT INTO account_exchanges (id, family_id, account_id, user_id, from_currency, from_amount_minor, to_currency, to_amount_minor, rate, comment, occurred_at, created_at)
+VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+			exchange.ID, exchange.FamilyID, exchange.AccountID, exchange.UserID, exchange.FromCurrency, exchange.FromAmountMinor, exchange.ToCurrency, exchange.ToAmountMinor, exchange.Rate, nullableString(exchange.Comment), exchange.OccurredAt, exchange.CreatedAt); err != nil {
+			return err
+		}
+		return appendEvent(ctx, dbTx, exchange.FamilyID, domain.EventAccountExchanged, map[string]interface{}{
+			"exchange_id":       exchange.ID,
+			"account_id":        exchange.AccountID,
+			"user_id":           exchange.UserID,
+			"from_currency":     exchange.FromCurrency,
+			"from_amount_minor": exchange.FromAmountMinor,
+			"to_currency":       exchange.ToCurrency,
+			"to_amount_minor":   exchange.ToAmountMinor,
+		}, exchange.CreatedAt)
+	})
+}
+
+// ListAccountExchanges returns the exchanges of an account, newest first.
+func (s *Store) ListAccountExchanges(ctx context.Context, accountID string) ([]domain.AccountExchange, error) {
+	rows, err := s.db.QueryContext(ctx, `SELECT id, family_id, account_id, user_id, from_currency, from_amount_minor, to_currency, to_amount_minor, rate, comment, occurred_at, created_at
+FROM account_exchanges WHERE account_id = ? ORDER BY occurred_at DESC, created_at DESC`, accountID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var exchanges []domain.AccountExchange
+	for rows.Next() {
+		var exchange domain.AccountExchange
+		var comment sql.NullString
+		if err := rows.Scan(&exchange.ID, &exchange.FamilyID, &exchange.AccountID, &exchange.UserID, &exchange.FromCurrency, &exchange.FromAmountMinor, &exchange.ToCurrency, &exchange.ToAmountMinor, &exchange.Rate, &comment, &exchange.OccurredAt, &exchange.CreatedAt); err != nil {
+			return nil, err
+		}
+		exchange.Comment = comment.String
+		exchanges = append(exchanges, exchange)
+	}
+	return exchanges, rows.Err()
+}
+
+// convertAccountBalance sets the family-currency amount of an account
+// balance and of each of its pockets at today's rates.
+func convertAccountBalance(ctx context.Context, converter *RateConverter, report *domain.AccountBalanceReport) error {
+	now := time.Now()
+	if len(report.Pockets) == 0 {
+		amount, ok, err := converter.Convert(ctx, report.Currency, report.BalanceMinor, now)
+		if err != nil {
+			return err
+		}
+		sum := baseSum{amount: amount, unconverted: !ok}
+		report.BaseAmount = sum.value()
+		return nil
+	}
+	var total baseSum
+	for i := range report.Pockets {
+		pocket := &report.Pockets[i]
+		amount, ok, err := converter.Convert(ctx, pocket.Currency, pocket.BalanceMinor, now)
+		if err != nil {
+			return err
+		}
+		sum := baseSum{amount: amount, unconverted: !ok}
+		pocket.BaseAmount = sum.value()
+		total.amount += amount
+		total.unconverted = total.unconverted || !ok
+	}
+	report.BaseAmount = total.value()
+	return nil
+}
@@ -0,0 +1,280 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+
+	"familybudget/internal/domain"
+	"familybudget/internal/recurrence"
+)
+
+const (
+	CapPeriodWeekly  = "weekly"
+	CapPeriodMonthly = "monthly"
+)
+
+// maxAllowanceCatchUp bounds how many missed occurrences a single allowance
+// posts in one run, so a long outage does not flood the account.
+const maxAllowanceCatchUp = 60
+
+const allowanceSelect = `SELECT id, family_id, user_id, account_id, category_id, amount_minor, currency, recurrence, starts_at, next_run_at, is_active, last_posted_at, created_by, created_at, updated_at FROM allowances`
+
+func scanAllowance(row rowScanner) (*domain.Allowance, error) {
+	var allowance domain.Allowance
+	var startsAt, lastPostedAt sql.NullTime
+	if err := row.Scan(&allowance.ID, &allowance.FamilyID, &allowance.UserID, &allowance.AccountID, &allowance.CategoryID, &allowance.AmountMinor, &allowance.Currency, &allowance.Recurrence, &startsAt, &allowance.NextRunAt, &allowance.IsActive, &lastPostedAt, &allowance.CreatedBy, &allowance.CreatedAt, &allowance.UpdatedAt); err != nil {
+		return nil, err
+	}
+	allowance.StartsAt = allowance.NextRunAt
+	if startsAt.Valid {
+		allowance.StartsAt = startsAt.Time
+	}
+	if lastPostedAt.Valid {
+		t := lastPostedAt.Time
+		allowance.LastPostedAt = &t
+	}
+	return &allowance, nil
+}
+
+func (s *Store) CreateAllowance(ctx context.Context, allowance *domain.Allowance) error {
+	_, err := s.db.ExecContext(ctx, `INSERT INTO allowances (id, family_id, user_id, account_id, category_id, amount_minor, currency, recurrence, starts_at, next_run_at, is_active, last_posted_at, created_by, created_at, updated_at) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		allowance.ID, allowance.FamilyID, allowance.UserID, allowance.AccountID, allowance.CategoryID, allowance.AmountMinor, allowance.Currency, allowance.Recurrence, allowance.StartsAt, allowance.NextRunAt, allowance.IsActive, nullableTime(allowance.LastPostedAt), allowance.CreatedBy, allowance.CreatedAt, allowance.UpdatedAt)
+	return err
+}
+
+func (s *Store) UpdateAllowance(ctx context.Context, allowance *domain.Allowance) error {
+	res, err := s.db.ExecContext(ctx, `UPDATE allowances SET account_id = ?, category_id = ?, amount_minor = ?, currency = ?, recurrence = ?, starts_at = ?, next_run_at = ?, is_active = ?, updated_at = ? WHERE id = ? AND family_id = ?`,
+		allowance.AccountID, allowance.CategoryID, allowance.AmountMinor, allowance.Currency, allowance.Recurrence, allowance.StartsAt, allowance.NextRunAt, allowance.IsActive, allowance.UpdatedAt, allowance.ID, allowance.FamilyID)
+	if err != nil {
+		return err
+	}
+	rows, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rows == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+func (s *Store) GetAllowance(ctx context.Context, id string) (*domain.Allowance, error) {
+	allowance, err := scanAllowance(s.db.QueryRowContext(ctx, allowanceSelect+` WHERE id = ?`, id))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return allowance, nil
+}
+
+func (s *Store) ListAllowancesByFamily(ctx context.Context, familyID string) ([]domain.Allowance, error) {
+	rows, err := s.db.QueryContext(ctx, allowanceSelect+` WHERE family_id = ? ORDER BY is_active DESC, next_run_at`, familyID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var allowances []domain.Allowance
+	for rows.Next() {
+		allowance, err := scanAllowance(rows)
+		if err != nil {
+			return nil, err
+		}
+		allowances = append(allowances, *allowance)
+	}
+	return allowances, rows.Err()
+}
+
+// PostDueAllowances posts an income for every active allowance occurrence that
+// is due by now, including occurrences missed while the server was down. Each
+// occurrence is claimed by moving next_run_at forward in the same database
+// transaction as the posting, so concurrent runners never post it twice.
+// Occurrences follow the series from its start in the family time zone; an
+// allowance whose series COUNT or UNTIL ended is deactivated.
+func (s *Store) PostDueAllowances(ctx context.Context, now time.Time) (int, error) {
+	rows, err := s.db.QueryContext(ctx, allowanceSelect+` WHERE is_active = ? AND next_run_at <= ?`, true, now.UTC())
+	if err != nil {
+		return 0, err
+	}
+	var due []domain.Allowance
+	for rows.Next() {
+		allowance, err := scanAllowance(rows)
+		if err != nil {
+			rows.Close()
+			return 0, err
+		}
+		due = append(due, *allowance)
+	}
+	if err := rows.Close(); err != nil {
+		return 0, err
+	}
+
+	posted := 0
+	for _, allowance := range due {
+		loc, err := s.FamilyLocation(ctx, allowance.FamilyID)
+		if err != nil {
+			return posted, err
+		}
+		for i := 0; i < maxAllowanceCatchUp && !allowance.NextRunAt.After(now); i++ {
+			occurrence := allowance.NextRunAt
+			next, err := recurrence.Next(allowance.Recurrence, allowance.StartsAt, occurrence, loc)
+			ended := errors.Is(err, recurrence.ErrSeriesEnded)
+			if err != nil && !ended {
+				return posted, err
+			}
+			if ended {
+				next = occurrence
+			}
+
+			claimed := false
+			err = s.withTx(ctx, func(dbTx *sql.Tx) error {
+				res, err := dbTx.ExecContext(ctx, `UPDATE allowances SET next_run_at = ?, is_active = ?, last_posted_at = ?, updated_at = ? WHERE id = ? AND next_run_at = ? AND is_active = ?`,
+					next, !ended, now.UTC(), now.UTC(), allowance.ID, occurrence, true)
+				if err != nil {
+					return err
+				}
+				if affected, err := res.RowsAffected(); err != nil || affected == 0 {
+					return err
+				}
+				claimed = true
+
+				txn := &domain.Transaction{
+					ID:          uuid.NewString(),
+					FamilyID:    allowance.FamilyID,
+					UserID:      allowance.UserID,
+					AccountID:   allowance.AccountID,
+					CategoryID:  allowance.CategoryID,
+					Type:        "income",
+					AmountMinor: allowance.AmountMinor,
+					Currency:    allowance.Currency,
+					Comment:     "Карманные деньги",
+					OccurredAt:  occurrence,
+					CreatedAt:   now.UTC(),
+					UpdatedAt:   now.UTC(),
+				}
+				if err := s.createTransactionTx(ctx, dbTx, txn); err != nil {
+					return err
+				}
+				return appendEvent(ctx, dbTx, allowance.FamilyID, domain.EventAllowancePosted, map[string]interface{}{
+					"allowance_id":   allowance.ID,
+					"user_id":        allowance.UserID,
+					"transaction_id": txn.ID,
+					"amount":         allowance.AmountMinor,
+					"currency":       allowance.Currency,
+					"occurred_at":    occurrence.UTC().Format(time.RFC3339),
+				}, now)
+			})
+			if err != nil {
+				return posted, err
+			}
+			if !claimed {
+				break
+			}
+			posted++
+			if ended {
+				break
+			}
+			allowance.NextRunAt = next
+		}
+	}
+	return posted, nil
+}
+
+// CapPeriodStart returns the beginning of the cap period that contains now,
+// in the location of now. Weeks start on Monday.
+func CapPeriodStart(period string, now time.Time) time.Time {
+	day := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+	if period == CapPeriodWeekly {
+		offset := (int(day.Weekday()) + 6) % 7
+		return day.AddDate(0, 0, -offset)
+	}
+	return day.AddDate(0, 0, 1-day.Day())
+}
+
+func (s *Store) UpsertSpendingCap(ctx context.Context, spendingCap *domain.SpendingCap) error {
+	return s.withTx(ctx, func(dbTx *sql.Tx) error {
+		if _, err := dbTx.ExecContext(ctx, `DELETE FROM spending_caps WHERE user_id = ? AND category_id = ?`, spendingCap.UserID, spendingCap.CategoryID); err != nil {
+			return err
+		}
+		_, err := dbTx.ExecContext(ctx, `INSERT INTO spending_caps (family_id, user_id, category_id, period, amount_minor, currency, updated_at) VALUES (?, ?, ?, ?, ?, ?, ?)`,
+			spendingCap.FamilyID, spendingCap.UserID, spendingCap.CategoryID, spendingCap.Period, spendingCap.AmountMinor, spendingCap.Currency, spendingCap.UpdatedAt)
+		return err
+	})
+}
+
+func (s *Store) DeleteSpendingCap(ctx context.Context, userID, categoryID string) error {
+	res, err := s.db.ExecContext(ctx, `DELETE FROM spending_caps WHERE user_id = ? AND category_id = ?`, userID, categoryID)
+	if err != nil {
+		return err
+	}
+	rows, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rows == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+// GetSpendingCap returns the cap of a member for a category together with the
+// amount already spent in the current period, or nil when there is no cap.
+func (s *Store) GetSpendingCap(ctx context.Context, userID, categoryID string, now time.Time) (*domain.SpendingCap, error) {
+	var spendingCap domain.SpendingCap
+	err := s.db.QueryRowContext(ctx, `SELECT family_id, user_id, category_id, period, amount_minor, currency, updated_at FROM spending_caps WHERE user_id = ? AND category_id = ?`, userID, categoryID).
+		Scan(&spendingCap.FamilyID, &spendingCap.UserID, &spendingCap.CategoryID, &spendingCap.Period, &spendingCap.AmountMinor, &spendingCap.Currency, &spendingCap.UpdatedAt)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	if err := s.fillCapSpent(ctx, &spendingCap, now); err != nil {
+		return nil, err
+	}
+	return &spendingCap, nil
+}
+
+func (s *Store) ListSpendingCaps(ctx context.Context, userID string, now time.Time) ([]domain.SpendingCap, error) {
+	rows, err := s.db.QueryContext(ctx, `SELECT family_id, user_id, category_id, period, amount_minor, currency, updated_at FROM spending_caps WHERE user_id = ? ORDER BY category_id`, userID)
+	if err != nil {
+		return nil, err
+	}
+	var caps []domain.SpendingCap
+	for rows.Next() {
+		var item domain.SpendingCap
+		if err := rows.Scan(&item.FamilyID, &item.UserID, &item.CategoryID, &item.Period, &item.AmountMinor, &item.Currency, &item.UpdatedAt); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		caps = append(caps, item)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+
+	for i := range caps {
+		if err := s.fillCapSpent(ctx, &caps[i], now); err != nil {
+			return nil, err
+		}
+	}
+	return caps, nil
+}
+
+// fillCapSpent sums the member's expenses of the current period. Weeks and
+// months begin at midnight in the family time zone, as allowances do.
+func (s *Store) fillCapSpent(ctx context.Context, spendingCap *domain.SpendingCap, now time.Time) error {
+	loc, err := s.FamilyLocation(ctx, spendingCap.FamilyID)
+	if err != nil {
+		return err
+	}
+	since := CapPeriodStart(spendingCap.Period, now.In(loc)).UTC()
+	return s.db.QueryRowContext(ctx, `SELECT COALESCE(SUM(amount_minor), 0) FROM transactions WHERE user_id = ? AND category_id = ? AND currency = ? AND LOWER(type) = 'expense' AND occurred_at >= ?`,
+		spendingCap.UserID, spendingCap.CategoryID, spendingCap.Currency, since).Scan(&spendingCap.SpentMinor)
+}
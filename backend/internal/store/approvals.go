@@ -0,0 +1,157 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"familybudget/internal/domain"
+)
+
+const (
+	ApprovalStatusPending  = "pending_approval"
+	ApprovalStatusApproved = "approved"
+	ApprovalStatusRejected = "rejected"
+
+	ApprovalReasonCategoryCap      = "category_cap_exceeded"
+	ApprovalReasonRestrictedMember = "restricted_member"
+)
+
+var ErrApprovalResolved = errors.New("approval is already resolved")
+
+const approvalSelect = `SELECT id, family_id, status, reason, transaction_id, user_id, account_id, category_id, envelope_id, type, amount_minor, currency, comment, occurred_at, reviewer_id, review_note, created_at, reviewed_at,
+    original_amount_minor, original_currency, exchange_rate FROM transaction_approvals`
+
+func scanApproval(row rowScanner) (*domain.TransactionApproval, error) {
+	var approval domain.TransactionApproval
+	var envelopeID, comment, reviewerID, reviewNote sql.NullString
+	var reviewedAt sql.NullTime
+	var amounts transactionAmounts
+	txn := &approval.Transaction
+	if err := row.Scan(&approval.ID, &approval.FamilyID, &approval.Status, &approval.Reason, &txn.ID, &txn.UserID, &txn.AccountID, &txn.CategoryID, &envelopeID, &txn.Type, &txn.AmountMinor, &txn.Currency, &comment, &txn.OccurredAt, &reviewerID, &reviewNote, &approval.CreatedAt, &reviewedAt,
+		&amounts.originalAmount, &amounts.originalCurrency, &amounts.rate); err != nil {
+		return nil, err
+	}
+	amounts.apply(txn)
+	txn.FamilyID = approval.FamilyID
+	txn.CreatedAt = approval.CreatedAt
+	txn.UpdatedAt = approval.CreatedAt
+	if envelopeID.Valid {
+		txn.EnvelopeID = &envelopeID.String
+	}
+	if comment.Valid {
+		txn.Comment = comment.String
+	}
+	if reviewerID.Valid {
+		approval.ReviewerID = &reviewerID.String
+	}
+	if reviewNote.Valid {
+		approval.ReviewNote = reviewNote.String
+	}
+	if reviewedAt.Valid {
+		t := reviewedAt.Time
+		approval.ReviewedAt = &t
+	}
+	return &approval, nil
+}
+
+// CreateTransactionApproval queues a transaction for review. The account
+// balance is not touched until the transaction is approved.
+func (s *Store) CreateTransactionApproval(ctx context.Context, approval *domain.TransactionApproval) error {
+	return s.withTx(ctx, func(dbTx *sql.Tx) error {
+		txn := approval.Transaction
+		if _, err := dbTx.ExecContext(ctx, `INSERT INTO transaction_approvals (id, family_id, status, reason, transaction_id, user_id, account_id, category_id, envelope_id, type, amount_minor, currency, comment, occurred_at, created_at,
+            original_amount_minor, original_currency, exchange_rate) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+			approval.ID, approval.FamilyID, approval.Status, approval.Reason, txn.ID, txn.UserID, txn.AccountID, txn.CategoryID, txn.EnvelopeID, txn.Type, txn.AmountMinor, txn.Currency, nullableString(txn.Comment), txn.OccurredAt, approval.CreatedAt,
+			txn.OriginalAmountMinor, nullableString(txn.OriginalCurrency), txn.ExchangeRate); err != nil {
+			return err
+		}
+		return appendEvent(ctx, dbTx, approval.FamilyID, domain.EventApprovalRequested, map[string]interface{}{
+			"approval_id": approval.ID,
+			"user_id":     txn.UserID,
+			"category_id": txn.CategoryID,
+			"amount":      txn.AmountMinor,
+			"currency":    txn.Currency,
+			"reason":      approval.Reason,
+		}, approval.CreatedAt)
+	})
+}
+
+func (s *Store) GetTransactionApproval(ctx context.Context, id string) (*domain.TransactionApproval, error) {
+	approval, err := scanApproval(s.db.QueryRowContext(ctx, approvalSelect+` WHERE id = ?`, id))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return approval, nil
+}
+
+// ListTransactionApprovals returns the approvals of a family, newest first.
+// An empty status returns every approval.
+func (s *Store) ListTransactionApprovals(ctx context.Context, familyID, status string) ([]domain.TransactionApproval, error) {
+	query := approvalSelect + ` WHERE family_id = ?`
+	args := []interface{}{familyID}
+	if status != "" {
+		query += ` AND status = ?`
+		args = append(args, status)
+	}
+	query += ` ORDER BY created_at DESC`
+
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var approvals []domain.TransactionApproval
+	for rows.Next() {
+		approval, err := scanApproval(rows)
+		if err != nil {
+			return nil, err
+		}
+		approvals = append(approvals, *approval)
+	}
+	return approvals, rows.Err()
+}
+
+// ApproveTransaction resolves a pending approval and posts txn, which may carry
+// the reviewer's edits, through the regular balance path in one database
+// transaction.
+func (s *Store) ApproveTransaction(ctx context.Context, approvalID, reviewerID, note string, txn *domain.Transaction, now time.Time) error {
+	return s.withTx(ctx, func(dbTx *sql.Tx) error {
+		res, err := dbTx.ExecContext(ctx, `UPDATE transaction_approvals SET status = ?, reviewer_id = ?, review_note = ?, reviewed_at = ?,
+            account_id = ?, category_id = ?, envelope_id = ?, amount_minor = ?, currency = ?, comment = ?, occurred_at = ?, exchange_rate = ?
+            WHERE id = ? AND status = ?`,
+			ApprovalStatusApproved, reviewerID, nullableString(note), now, txn.AccountID, txn.CategoryID, txn.EnvelopeID, txn.AmountMinor, txn.Currency, nullableString(txn.Comment), txn.OccurredAt, txn.ExchangeRate, approvalID, ApprovalStatusPending)
+		if err != nil {
+			return err
+		}
+		affected, err := res.RowsAffected()
+		if err != nil {
+			return err
+		}
+		if affected == 0 {
+			return ErrApprovalResolved
+		}
+		return s.createTransactionTx(ctx, dbTx, txn)
+	})
+}
+
+func (s *Store) RejectTransaction(ctx context.Context, approvalID, reviewerID, reason string, now time.Time) error {
+	res, err := s.db.ExecContext(ctx, `UPDATE transaction_approvals SET status = ?, reviewer_id = ?, review_note = ?, reviewed_at = ? WHERE id = ? AND status = ?`,
+		ApprovalStatusRejected, reviewerID, reason, now, approvalID, ApprovalStatusPending)
+	if err != nil {
+		return err
+	}
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return ErrApprovalResolved
+	}
+	return nil
+}
@@ -0,0 +1,70 @@
+package store
+
+import (
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"time"
+
+	"familybudget/internal/domain"
+)
+
+func calendarTokenHash(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// IssueCalendarFeed stores token as the only valid feed token of the member,
+// revoking the previous one.
+func (s *Store) IssueCalendarFeed(ctx context.Context, userID, familyID, token string, now time.Time) (*domain.CalendarFeed, error) {
+	err := s.withTx(ctx, func(dbTx *sql.Tx) error {
+		if _, err := dbTx.ExecContext(ctx, `DELETE FROM calendar_feeds WHERE user_id = ?`, userID); err != nil {
+			return err
+		}
+		_, err := dbTx.ExecContext(ctx, `INSERT INTO calendar_feeds (user_id, family_id, token_hash, created_at) VALUES (?, ?, ?, ?)`,
+			userID, familyID, calendarTokenHash(token), now.UTC())
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &domain.CalendarFeed{UserID: userID, FamilyID: familyID, Token: token, CreatedAt: now.UTC()}, nil
+}
+
+func (s *Store) RevokeCalendarFeed(ctx context.Context, userID string) error {
+	res, err := s.db.ExecContext(ctx, `DELETE FROM calendar_feeds WHERE user_id = ?`, userID)
+	if err != nil {
+		return err
+	}
+	rows, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rows == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+func (s *Store) GetCalendarFeed(ctx context.Context, userID string) (*domain.CalendarFeed, error) {
+	return s.getCalendarFeed(ctx, `SELECT user_id, family_id, created_at FROM calendar_feeds WHERE user_id = ?`, userID)
+}
+
+// GetCalendarFeedByToken returns the feed a token grants access to, or nil for
+// unknown and revoked tokens.
+func (s *Store) GetCalendarFeedByToken(ctx context.Context, token string) (*domain.CalendarFeed, error) {
+	return s.getCalendarFeed(ctx, `SELECT user_id, family_id, created_at FROM calendar_feeds WHERE token_hash = ?`, calendarTokenHash(token))
+}
+
+func (s *Store) getCalendarFeed(ctx context.Context, query string, arg string) (*domain.CalendarFeed, error) {
+	var feed domain.CalendarFeed
+	if err := s.db.QueryRowContext(ctx, query, arg).Scan(&feed.UserID, &feed.FamilyID, &feed.CreatedAt); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &feed, nil
+}
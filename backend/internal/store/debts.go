@@ -0,0 +1,287 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"sort"
+	"time"
+
+	"familybudget/internal/domain"
+)
+
+const (
+	DebtDirectionWeOwe   = "we_owe"
+	DebtDirectionTheyOwe = "they_owe"
+)
+
+var (
+	ErrDebtClosed           = errors.New("debt is closed")
+	ErrRepaymentExceedsDebt = errors.New("repayment exceeds outstanding amount")
+	ErrTransactionLinked    = errors.New("transaction is already linked to a debt")
+)
+
+const debtSelect = `SELECT d.id, d.family_id, d.counterparty, d.direction, d.amount_minor, d.currency, d.due_date, d.notes, d.closed_at, d.created_at, d.updated_at,
+        COALESCE((SELECT SUM(r.amount_minor) FROM debt_repayments r WHERE r.debt_id = d.id), 0)
+FROM debts d`
+
+func scanDebt(row rowScanner, now time.Time) (*domain.Debt, error) {
+	var debt domain.Debt
+	var dueDate sql.NullTime
+	var notes sql.NullString
+	var closedAt sql.NullTime
+	if err := row.Scan(&debt.ID, &debt.FamilyID, &debt.Counterparty, &debt.Direction, &debt.AmountMinor, &debt.Currency, &dueDate, &notes, &closedAt, &debt.CreatedAt, &debt.UpdatedAt, &debt.RepaidMinor); err != nil {
+		return nil, err
+	}
+	if dueDate.Valid {
+		t := dueDate.Time
+		debt.DueDate = &t
+	}
+	if notes.Valid {
+		debt.Notes = notes.String
+	}
+	if closedAt.Valid {
+		t := closedAt.Time
+		debt.ClosedAt = &t
+	}
+	debt.OutstandingMinor = debt.AmountMinor - debt.RepaidMinor
+	if debt.OutstandingMinor < 0 {
+		debt.OutstandingMinor = 0
+	}
+	debt.IsOverdue = debt.DueDate != nil && debt.DueDate.Before(now) && debt.OutstandingMinor > 0
+	return &debt, nil
+}
+
+func (s *Store) CreateDebt(ctx context.Context, debt *domain.Debt) error {
+	_, err := s.db.ExecContext(ctx, `INSERT INTO debts (id, family_id, counterparty, direction, amount_minor, currency, due_date, notes, closed_at, created_at, updated_at) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		debt.ID, debt.FamilyID, debt.Counterparty, debt.Direction, debt.AmountMinor, debt.Currency, nullableTime(debt.DueDate), nullableString(debt.Notes), nullableTime(debt.ClosedAt), debt.CreatedAt, debt.UpdatedAt)
+	return err
+}
+
+// UpdateDebt changes the editable fields of a debt. Moving the due date resets
+// the due-soon notification so the reminder fires again for the new date.
+func (s *Store) UpdateDebt(ctx context.Context, debt *domain.Debt, dueDateChanged bool) error {
+	query := `UPDATE debts SET counterparty = ?, amount_minor = ?, due_date = ?, notes = ?, closed_at = ?, updated_at = ?`
+	if dueDateChanged {
+		query += `, due_soon_notified_at = NULL`
+	}
+	query += ` WHERE id = ? AND family_id = ?`
+	res, err := s.db.ExecContext(ctx, query, debt.Counterparty, debt.AmountMinor, nullableTime(debt.DueDate), nullableString(debt.Notes), nullableTime(debt.ClosedAt), debt.UpdatedAt, debt.ID, debt.FamilyID)
+	if err != nil {
+		return err
+	}
+	rows, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rows == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+func (s *Store) GetDebt(ctx context.Context, id string) (*domain.Debt, error) {
+	debt, err := scanDebt(s.db.QueryRowContext(ctx, debtSelect+` WHERE d.id = ?`, id), time.Now().UTC())
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return debt, nil
+}
+
+type DebtListFilters struct {
+	Counterparty string
+	OpenOnly     bool
+	OverdueOnly  bool
+}
+
+func (s *Store) ListDebtsByFamily(ctx context.Context, familyID string, filters DebtListFilters) ([]domain.Debt, error) {
+	query := debtSelect + ` WHERE d.family_id = ?`
+	args := []interface{}{familyID}
+	if filters.Counterparty != "" {
+		query += " AND LOWER(d.counterparty) = LOWER(?)"
+		args = append(args, filters.Counterparty)
+	}
+	if filters.OpenOnly || filters.OverdueOnly {
+		query += " AND d.closed_at IS NULL"
+	}
+	query += " ORDER BY d.closed_at IS NOT NULL, d.due_date IS NULL, d.due_date, d.created_at"
+
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	now := time.Now().UTC()
+	var debts []domain.Debt
+	for rows.Next() {
+		debt, err := scanDebt(rows, now)
+		if err != nil {
+			return nil, err
+		}
+		if filters.OverdueOnly && !debt.IsOverdue {
+			continue
+		}
+		debts = append(debts, *debt)
+	}
+	return debts, rows.Err()
+}
+
+// ListCounterpartyBalances sums open debts per counterparty and currency.
+// A positive net amount means the counterparty owes the family.
+func (s *Store) ListCounterpartyBalances(ctx context.Context, familyID string) ([]domain.CounterpartyBalance, error) {
+	debts, err := s.ListDebtsByFamily(ctx, familyID, DebtListFilters{OpenOnly: true})
+	if err != nil {
+		return nil, err
+	}
+
+	type key struct{ counterparty, currency string }
+	balances := map[key]*domain.CounterpartyBalance{}
+	var order []key
+	for _, debt := range debts {
+		k := key{counterparty: debt.Counterparty, currency: debt.Currency}
+		balance, ok := balances[k]
+		if !ok {
+			balance = &domain.CounterpartyBalance{Counterparty: debt.Counterparty, Currency: debt.Currency}
+			balances[k] = balance
+			order = append(order, k)
+		}
+		if debt.Direction == DebtDirectionTheyOwe {
+			balance.TheyOweMinor += debt.OutstandingMinor
+		} else {
+			balance.WeOweMinor += debt.OutstandingMinor
+		}
+		balance.NetMinor = balance.TheyOweMinor - balance.WeOweMinor
+		if debt.IsOverdue {
+			balance.OverdueCount++
+		}
+	}
+
+	result := make([]domain.CounterpartyBalance, 0, len(order))
+	for _, k := range order {
+		result = append(result, *balances[k])
+	}
+	sort.SliceStable(result, func(i, j int) bool {
+		if result[i].Counterparty != result[j].Counterparty {
+			return result[i].Counterparty < result[j].Counterparty
+		}
+		return result[i].Currency < result[j].Currency
+	})
+	return result, nil
+}
+
+// AddDebtRepayment links a repayment to a transaction. When txn is not nil it
+// is posted to its account first, otherwise transactionID must reference an
+// already stored transaction.
+func (s *Store) AddDebtRepayment(ctx context.Context, repayment *domain.DebtRepayment, txn *domain.Transaction) error {
+	return s.withTx(ctx, func(dbTx *sql.Tx) error {
+		debt, err := scanDebt(dbTx.QueryRowContext(ctx, debtSelect+` WHERE d.id = ?`, repayment.DebtID), repayment.CreatedAt)
+		if err != nil {
+			return err
+		}
+		if debt.FamilyID != repayment.FamilyID {
+			return sql.ErrNoRows
+		}
+		if debt.ClosedAt != nil {
+			return ErrDebtClosed
+		}
+		if repayment.AmountMinor > debt.OutstandingMinor {
+			return ErrRepaymentExceedsDebt
+		}
+
+		if txn != nil {
+			if err := s.createTransactionTx(ctx, dbTx, txn); err != nil {
+				return err
+			}
+			repayment.TransactionID = txn.ID
+		} else {
+			var linked int
+			if err := dbTx.QueryRowContext(ctx, `SELECT COUNT(1) FROM debt_repayments WHERE transaction_id = ?`, repayment.TransactionID).Scan(&linked); err != nil {
+				return err
+			}
+			if linked > 0 {
+				return ErrTransactionLinked
+			}
+		}
+
+		if _, err := dbTx.ExecContext(ctx, `INSERT INTO debt_repayments (id, debt_id, family_id, user_id, transaction_id, amount_minor, created_at) VALUES (?, ?, ?, ?, ?, ?, ?)`,
+			repayment.ID, repayment.DebtID, repayment.FamilyID, repayment.UserID, repayment.TransactionID, repayment.AmountMinor, repayment.CreatedAt); err != nil {
+			return err
+		}
+
+		if repayment.AmountMinor == debt.OutstandingMinor {
+			if _, err := dbTx.ExecContext(ctx, `UPDATE debts SET closed_at = ?, updated_at = ? WHERE id = ?`, repayment.CreatedAt, repayment.CreatedAt, debt.ID); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func (s *Store) ListDebtRepayments(ctx context.Context, debtID string) ([]domain.DebtRepayment, error) {
+	rows, err := s.db.QueryContext(ctx, `SELECT id, debt_id, family_id, user_id, transaction_id, amount_minor, created_at FROM debt_repayments WHERE debt_id = ? ORDER BY created_at DESC`, debtID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var repayments []domain.DebtRepayment
+	for rows.Next() {
+		var item domain.DebtRepayment
+		if err := rows.Scan(&item.ID, &item.DebtID, &item.FamilyID, &item.UserID, &item.TransactionID, &item.AmountMinor, &item.CreatedAt); err != nil {
+			return nil, err
+		}
+		repayments = append(repayments, item)
+	}
+	return repayments, rows.Err()
+}
+
+// NotifyDebtsDueSoon emits debt.due_soon for open debts whose due date falls
+// within the given window and that were not notified yet.
+func (s *Store) NotifyDebtsDueSoon(ctx context.Context, now time.Time, window time.Duration) (int, error) {
+	rows, err := s.db.QueryContext(ctx, debtSelect+` WHERE d.closed_at IS NULL AND d.due_soon_notified_at IS NULL AND d.due_date IS NOT NULL AND d.due_date <= ?`, now.Add(window).UTC())
+	if err != nil {
+		return 0, err
+	}
+	var due []domain.Debt
+	for rows.Next() {
+		debt, err := scanDebt(rows, now)
+		if err != nil {
+			rows.Close()
+			return 0, err
+		}
+		if debt.OutstandingMinor > 0 {
+			due = append(due, *debt)
+		}
+	}
+	if err := rows.Close(); err != nil {
+		return 0, err
+	}
+
+	for _, debt := range due {
+		err := s.withTx(ctx, func(dbTx *sql.Tx) error {
+			res, err := dbTx.ExecContext(ctx, `UPDATE debts SET due_soon_notified_at = ? WHERE id = ? AND due_soon_notified_at IS NULL`, now.UTC(), debt.ID)
+			if err != nil {
+				return err
+			}
+			if affected, err := res.RowsAffected(); err != nil || affected == 0 {
+				return err
+			}
+			return appendEvent(ctx, dbTx, debt.FamilyID, domain.EventDebtDueSoon, map[string]interface{}{
+				"debt_id":      debt.ID,
+				"due_date":     debt.DueDate.UTC().Format("2006-01-02"),
+				"amount":       debt.OutstandingMinor,
+				"currency":     debt.Currency,
+				"counterparty": debt.Counterparty,
+				"direction":    debt.Direction,
+			}, now)
+		})
+		if err != nil {
+			return 0, err
+		}
+	}
+	return len(due), nil
+}
@@ -0,0 +1,437 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+
+	"familybudget/internal/domain"
+)
+
+var (
+	ErrEnvelopeNotFound  = errors.New("envelope not found")
+	ErrEnvelopeArchived  = errors.New("envelope is archived")
+	ErrInsufficientFunds = errors.New("insufficient funds")
+	ErrCurrencyMismatch  = errors.New("currency mismatch")
+)
+
+const (
+	EnvelopeMovementIncome   = "income"
+	EnvelopeMovementAllocate = "allocate"
+	EnvelopeMovementMove     = "move"
+	EnvelopeMovementSpend    = "spend"
+	EnvelopeMovementAutoFill = "auto_fill"
+)
+
+const envelopeColumns = `id, family_id, name, currency, target_amount_minor, current_amount_minor, auto_fill_rule, is_archived, created_at, updated_at`
+
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+func scanEnvelope(row rowScanner) (*domain.Envelope, error) {
+	var envelope domain.Envelope
+	var rule sql.NullString
+	var isArchived bool
+	if err := row.Scan(&envelope.ID, &envelope.FamilyID, &envelope.Name, &envelope.Currency, &envelope.TargetAmountMinor, &envelope.CurrentAmountMinor, &rule, &isArchived, &envelope.CreatedAt, &envelope.UpdatedAt); err != nil {
+		return nil, err
+	}
+	envelope.IsArchived = isArchived
+	if rule.Valid && strings.TrimSpace(rule.String) != "" {
+		var parsed domain.EnvelopeAutoFillRule
+		if err := json.Unmarshal([]byte(rule.String), &parsed); err == nil {
+			envelope.AutoFillRule = &parsed
+		}
+	}
+	return &envelope, nil
+}
+
+func marshalAutoFillRule(rule *domain.EnvelopeAutoFillRule) (interface{}, error) {
+	if rule == nil {
+		return nil, nil
+	}
+	payload, err := json.Marshal(rule)
+	if err != nil {
+		return nil, err
+	}
+	return string(payload), nil
+}
+
+func (s *Store) CreateEnvelope(ctx context.Context, envelope *domain.Envelope) error {
+	rule, err := marshalAutoFillRule(envelope.AutoFillRule)
+	if err != nil {
+		return err
+	}
+	_, err = s.db.ExecContext(ctx, `INSERT INTO envelopes (`+envelopeColumns+`) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		envelope.ID, envelope.FamilyID, envelope.Name, envelope.Currency, envelope.TargetAmountMinor, envelope.CurrentAmountMinor, rule, envelope.IsArchived, envelope.CreatedAt, envelope.UpdatedAt)
+	return err
+}
+
+func (s *Store) GetEnvelope(ctx context.Context, id string) (*domain.Envelope, error) {
+	envelope, err := scanEnvelope(s.db.QueryRowContext(ctx, `SELECT `+envelopeColumns+` FROM envelopes WHERE id = ?`, id))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return envelope, nil
+}
+
+func (s *Store) ListEnvelopesByFamily(ctx context.Context, familyID string) ([]domain.Envelope, error) {
+	rows, err := s.db.QueryContext(ctx, `SELECT `+envelopeColumns+` FROM envelopes WHERE family_id = ? ORDER BY is_archived, name`, familyID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var envelopes []domain.Envelope
+	for rows.Next() {
+		envelope, err := scanEnvelope(rows)
+		if err != nil {
+			return nil, err
+		}
+		envelopes = append(envelopes, *envelope)
+	}
+	return envelopes, rows.Err()
+}
+
+func (s *Store) UpdateEnvelope(ctx context.Context, envelope *domain.Envelope) error {
+	rule, err := marshalAutoFillRule(envelope.AutoFillRule)
+	if err != nil {
+		return err
+	}
+	res, err := s.db.ExecContext(ctx, `UPDATE envelopes SET name = ?, target_amount_minor = ?, auto_fill_rule = ?, is_archived = ?, updated_at = ? WHERE id = ? AND family_id = ?`,
+		envelope.Name, envelope.TargetAmountMinor, rule, envelope.IsArchived, envelope.UpdatedAt, envelope.ID, envelope.FamilyID)
+	if err != nil {
+		return err
+	}
+	rows, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rows == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+func (s *Store) ListUnassignedByFamily(ctx context.Context, familyID string) ([]domain.CurrencyAmount, error) {
+	rows, err := s.db.QueryContext(ctx, `SELECT currency, unassigned_minor FROM envelope_pools WHERE family_id = ? ORDER BY currency`, familyID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var pools []domain.CurrencyAmount
+	for rows.Next() {
+		var pool domain.CurrencyAmount
+		if err := rows.Scan(&pool.Currency, &pool.AmountMinor); err != nil {
+			return nil, err
+		}
+		pools = append(pools, pool)
+	}
+	return pools, rows.Err()
+}
+
+func (s *Store) ListEnvelopeMovements(ctx context.Context, envelopeID string) ([]domain.EnvelopeMovement, error) {
+	rows, err := s.db.QueryContext(ctx, `SELECT id, family_id, user_id, kind, from_envelope_id, to_envelope_id, transaction_id, amount_minor, currency, created_at
+FROM envelope_movements
+WHERE from_envelope_id = ? OR to_envelope_id = ?
+ORDER BY created_at DESC`, envelopeID, envelopeID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var movements []domain.EnvelopeMovement
+	for rows.Next() {
+		var movement domain.EnvelopeMovement
+		var fromID, toID, txnID sql.NullString
+		if err := rows.Scan(&movement.ID, &movement.FamilyID, &movement.UserID, &movement.Kind, &fromID, &toID, &txnID, &movement.AmountMinor, &movement.Currency, &movement.CreatedAt); err != nil {
+			return nil, err
+		}
+		if fromID.Valid {
+			movement.FromEnvelopeID = &fromID.String
+		}
+		if toID.Valid {
+			movement.ToEnvelopeID = &toID.String
+		}
+		if txnID.Valid {
+			movement.TransactionID = &txnID.String
+		}
+		movements = append(movements, movement)
+	}
+	return movements, rows.Err()
+}
+
+// AllocateToEnvelope moves money from the family's unassigned pool into an
+// envelope. A negative amount returns money from the envelope to the pool.
+func (s *Store) AllocateToEnvelope(ctx context.Context, familyID, userID, envelopeID string, amountMinor int64) error {
+	return s.withTx(ctx, func(dbTx *sql.Tx) error {
+		envelope, err := lockEnvelopeTx(ctx, dbTx, familyID, envelopeID)
+		if err != nil {
+			return err
+		}
+		if amountMinor > 0 {
+			available, err := unassignedTx(ctx, dbTx, familyID, envelope.Currency)
+			if err != nil {
+				return err
+			}
+			if available < amountMinor {
+				return ErrInsufficientFunds
+			}
+		} else if envelope.CurrentAmountMinor < -amountMinor {
+			return ErrInsufficientFunds
+		}
+
+		now := time.Now().UTC()
+		if err := adjustUnassignedTx(ctx, dbTx, familyID, envelope.Currency, -amountMinor, now); err != nil {
+			return err
+		}
+		if err := adjustEnvelopeTx(ctx, dbTx, envelope.ID, amountMinor, now); err != nil {
+			return err
+		}
+
+		movement := domain.EnvelopeMovement{
+			FamilyID:    familyID,
+			UserID:      userID,
+			Kind:        EnvelopeMovementAllocate,
+			AmountMinor: amountMinor,
+			Currency:    envelope.Currency,
+			CreatedAt:   now,
+		}
+		if amountMinor > 0 {
+			movement.ToEnvelopeID = &envelope.ID
+		} else {
+			movement.FromEnvelopeID = &envelope.ID
+			movement.AmountMinor = -amountMinor
+		}
+		return insertEnvelopeMovementTx(ctx, dbTx, &movement)
+	})
+}
+
+func (s *Store) MoveBetweenEnvelopes(ctx context.Context, familyID, userID, fromID, toID string, amountMinor int64) error {
+	return s.withTx(ctx, func(dbTx *sql.Tx) error {
+		from, err := lockEnvelopeTx(ctx, dbTx, familyID, fromID)
+		if err != nil {
+			return err
+		}
+		to, err := lockEnvelopeTx(ctx, dbTx, familyID, toID)
+		if err != nil {
+			return err
+		}
+		if from.Currency != to.Currency {
+			return ErrCurrencyMismatch
+		}
+		if from.CurrentAmountMinor < amountMinor {
+			return ErrInsufficientFunds
+		}
+
+		now := time.Now().UTC()
+		if err := adjustEnvelopeTx(ctx, dbTx, from.ID, -amountMinor, now); err != nil {
+			return err
+		}
+		if err := adjustEnvelopeTx(ctx, dbTx, to.ID, amountMinor, now); err != nil {
+			return err
+		}
+		return insertEnvelopeMovementTx(ctx, dbTx, &domain.EnvelopeMovement{
+			FamilyID:       familyID,
+			UserID:         userID,
+			Kind:           EnvelopeMovementMove,
+			FromEnvelopeID: &from.ID,
+			ToEnvelopeID:   &to.ID,
+			AmountMinor:    amountMinor,
+			Currency:       from.Currency,
+			CreatedAt:      now,
+		})
+	})
+}
+
+// applyEnvelopeEffectsTx keeps envelopes in sync with a freshly stored
+// transaction: expenses tied to an envelope are spent from it, and every
+// income lands in the unassigned pool before the auto-fill rules run.
+func (s *Store) applyEnvelopeEffectsTx(ctx context.Context, dbTx *sql.Tx, txn *domain.Transaction) error {
+	switch strings.ToLower(txn.Type) {
+	case "expense":
+		if txn.EnvelopeID == nil {
+			return nil
+		}
+		envelope, err := lockEnvelopeTx(ctx, dbTx, txn.FamilyID, *txn.EnvelopeID)
+		if err != nil {
+			return err
+		}
+		if envelope.Currency != txn.Currency {
+			return ErrCurrencyMismatch
+		}
+		if err := adjustEnvelopeTx(ctx, dbTx, envelope.ID, -txn.AmountMinor, txn.UpdatedAt); err != nil {
+			return err
+		}
+		return insertEnvelopeMovementTx(ctx, dbTx, &domain.EnvelopeMovement{
+			FamilyID:       txn.FamilyID,
+			UserID:         txn.UserID,
+			Kind:           EnvelopeMovementSpend,
+			FromEnvelopeID: &envelope.ID,
+			TransactionID:  &txn.ID,
+			AmountMinor:    txn.AmountMinor,
+			Currency:       txn.Currency,
+			CreatedAt:      txn.UpdatedAt,
+		})
+	case "income":
+		if err := adjustUnassignedTx(ctx, dbTx, txn.FamilyID, txn.Currency, txn.AmountMinor, txn.UpdatedAt); err != nil {
+			return err
+		}
+		if err := insertEnvelopeMovementTx(ctx, dbTx, &domain.EnvelopeMovement{
+			FamilyID:      txn.FamilyID,
+			UserID:        txn.UserID,
+			Kind:          EnvelopeMovementIncome,
+			TransactionID: &txn.ID,
+			AmountMinor:   txn.AmountMinor,
+			Currency:      txn.Currency,
+			CreatedAt:     txn.UpdatedAt,
+		}); err != nil {
+			return err
+		}
+		return runAutoFillTx(ctx, dbTx, txn)
+	}
+	return nil
+}
+
+func runAutoFillTx(ctx context.Context, dbTx *sql.Tx, income *domain.Transaction) error {
+	rows, err := dbTx.QueryContext(ctx, `SELECT `+envelopeColumns+` FROM envelopes WHERE family_id = ? AND currency = ? AND is_archived = 0 AND auto_fill_rule IS NOT NULL`, income.FamilyID, income.Currency)
+	if err != nil {
+		return err
+	}
+	var envelopes []domain.Envelope
+	for rows.Next() {
+		envelope, err := scanEnvelope(rows)
+		if err != nil {
+			rows.Close()
+			return err
+		}
+		if envelope.AutoFillRule != nil {
+			envelopes = append(envelopes, *envelope)
+		}
+	}
+	if err := rows.Close(); err != nil {
+		return err
+	}
+	if len(envelopes) == 0 {
+		return nil
+	}
+	sort.SliceStable(envelopes, func(i, j int) bool {
+		if envelopes[i].AutoFillRule.Priority != envelopes[j].AutoFillRule.Priority {
+			return envelopes[i].AutoFillRule.Priority < envelopes[j].AutoFillRule.Priority
+		}
+		return envelopes[i].CreatedAt.Before(envelopes[j].CreatedAt)
+	})
+
+	available, err := unassignedTx(ctx, dbTx, income.FamilyID, income.Currency)
+	if err != nil {
+		return err
+	}
+	for _, envelope := range envelopes {
+		if available <= 0 {
+			break
+		}
+		amount := autoFillAmount(envelope, income.AmountMinor)
+		if amount > available {
+			amount = available
+		}
+		if amount <= 0 {
+			continue
+		}
+		if err := adjustUnassignedTx(ctx, dbTx, income.FamilyID, income.Currency, -amount, income.UpdatedAt); err != nil {
+			return err
+		}
+		if err := adjustEnvelopeTx(ctx, dbTx, envelope.ID, amount, income.UpdatedAt); err != nil {
+			return err
+		}
+		envelopeID := envelope.ID
+		if err := insertEnvelopeMovementTx(ctx, dbTx, &domain.EnvelopeMovement{
+			FamilyID:      income.FamilyID,
+			UserID:        income.UserID,
+			Kind:          EnvelopeMovementAutoFill,
+			ToEnvelopeID:  &envelopeID,
+			TransactionID: &income.ID,
+			AmountMinor:   amount,
+			Currency:      income.Currency,
+			CreatedAt:     income.UpdatedAt,
+		}); err != nil {
+			return err
+		}
+		available -= amount
+	}
+	return nil
+}
+
+func autoFillAmount(envelope domain.Envelope, incomeMinor int64) int64 {
+	rule := envelope.AutoFillRule
+	var amount int64
+	switch rule.Mode {
+	case "fixed":
+		amount = rule.AmountMinor
+	case "percent":
+		amount = incomeMinor * int64(rule.Percent) / 100
+	}
+	if rule.StopAtTarget && envelope.TargetAmountMinor > 0 {
+		if missing := envelope.TargetAmountMinor - envelope.CurrentAmountMinor; amount > missing {
+			amount = missing
+		}
+	}
+	return amount
+}
+
+func lockEnvelopeTx(ctx context.Context, dbTx *sql.Tx, familyID, envelopeID string) (*domain.Envelope, error) {
+	envelope, err := scanEnvelope(dbTx.QueryRowContext(ctx, `SELECT `+envelopeColumns+` FROM envelopes WHERE id = ?`, envelopeID))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrEnvelopeNotFound
+		}
+		return nil, err
+	}
+	if envelope.FamilyID != familyID {
+		return nil, ErrEnvelopeNotFound
+	}
+	if envelope.IsArchived {
+		return nil, ErrEnvelopeArchived
+	}
+	return envelope, nil
+}
+
+func unassignedTx(ctx context.Context, dbTx *sql.Tx, familyID, currency string) (int64, error) {
+	var amount int64
+	err := dbTx.QueryRowContext(ctx, `SELECT unassigned_minor FROM envelope_pools WHERE family_id = ? AND currency = ?`, familyID, currency).Scan(&amount)
+	if errors.Is(err, sql.ErrNoRows) {
+		return 0, nil
+	}
+	return amount, err
+}
+
+func adjustUnassignedTx(ctx context.Context, dbTx *sql.Tx, familyID, currency string, delta int64, at time.Time) error {
+	_, err := dbTx.ExecContext(ctx, `INSERT INTO envelope_pools (family_id, currency, unassigned_minor, updated_at) VALUES (?, ?, ?, ?)
+ON CONFLICT (family_id, currency) DO UPDATE SET unassigned_minor = envelope_pools.unassigned_minor + excluded.unassigned_minor, updated_at = excluded.updated_at`,
+		familyID, currency, delta, at)
+	return err
+}
+
+func adjustEnvelopeTx(ctx context.Context, dbTx *sql.Tx, envelopeID string, delta int64, at time.Time) error {
+	_, err := dbTx.ExecContext(ctx, `UPDATE envelopes SET current_amount_minor = current_amount_minor + ?, updated_at = ? WHERE id = ?`, delta, at, envelopeID)
+	return err
+}
+
+func insertEnvelopeMovementTx(ctx context.Context, dbTx *sql.Tx, movement *domain.EnvelopeMovement) error {
+	if movement.ID == "" {
+		movement.ID = uuid.NewString()
+	}
+	_, err := dbTx.ExecContext(ctx, `INSERT INTO envelope_movements (id, family_id, user_id, kind, from_envelope_id, to_envelope_id, transaction_id, amount_minor, currency, created_at)
+VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		movement.ID, movement.FamilyID, movement.UserID, movement.Kind, movement.FromEnvelopeID, movement.ToEnvelopeID, movement.TransactionID, movement.AmountMinor, movement.Currency, movement.CreatedAt)
+	return err
+}
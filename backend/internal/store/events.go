@@ -0,0 +1,53 @@
+package store
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"github.com/google/uuid"
+
+	"familybudget/internal/domain"
+)
+
+const eventVersion = "v1"
+
+// appendEvent records a domain event in the outbox. Passing the open database
+// transaction keeps the event consistent with the change that caused it.
+func appendEvent(ctx context.Context, db queryer, familyID, code string, payload interface{}, at time.Time) error {
+	raw, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+	_, err = db.ExecContext(ctx, `INSERT INTO events (id, family_id, event, version, payload, created_at) VALUES (?, ?, ?, ?, ?, ?)`,
+		uuid.NewString(), familyID, code, eventVersion, string(raw), at.UTC())
+	return err
+}
+
+func (s *Store) ListEvents(ctx context.Context, familyID string, since *time.Time) ([]domain.Event, error) {
+	query := `SELECT id, family_id, event, version, payload, created_at FROM events WHERE family_id = ?`
+	args := []interface{}{familyID}
+	if since != nil {
+		query += " AND created_at > ?"
+		args = append(args, since.UTC())
+	}
+	query += " ORDER BY created_at ASC"
+
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var events []domain.Event
+	for rows.Next() {
+		var event domain.Event
+		var payload string
+		if err := rows.Scan(&event.ID, &event.FamilyID, &event.Event, &event.Version, &payload, &event.Timestamp); err != nil {
+			return nil, err
+		}
+		event.Payload = json.RawMessage(payload)
+		events = append(events, event)
+	}
+	return events, rows.Err()
+}
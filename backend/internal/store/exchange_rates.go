@@ -0,0 +1,481 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+
+	"familybudget/internal/domain"
+)
+
+const (
+	rateDateLayout = "2006-01-02"
+
+	// ExchangeRateSourceManual marks rates entered by hand. They override
+	// provider rates of the same pair and date.
+	ExchangeRateSourceManual = "manual"
+
+	// StaleRateDays is how old the latest rate of a currency may get before
+	// administrators are warned. Providers skip weekends and holidays, so a
+	// rate from Friday is still current on Tuesday.
+	StaleRateDays = 4
+)
+
+var ErrExchangeRateNotManual = errors.New("only manual exchange rates can be deleted")
+
+// SaveExchangeRate stores a rate, replacing the rate of the same pair and
+// date unless that one was entered manually and rate was not. Rates are
+// rounded by domain.RoundRate. It reports whether the rate was stored. A
+// manual rate corrects the family-currency amounts stored with transactions
+// since its date: they are cleared for FillTransactionBaseAmounts.
+func (s *Store) SaveExchangeRate(ctx context.Context, rate *domain.ExchangeRate) (bool, error) {
+	saved := false
+	err := s.withTx(ctx, func(dbTx *sql.Tx) error {
+		var err error
+		if saved, err = saveExchangeRate(ctx, dbTx, rate); err != nil || !saved {
+			return err
+		}
+		if rate.Source != ExchangeRateSourceManual {
+			return nil
+		}
+		return clearConvertedBaseAmounts(ctx, dbTx, rate.AsOf)
+	})
+	if err != nil {
+		return false, err
+	}
+	return saved, nil
+}
+
+// SaveExchangeRates stores a batch of rates from a provider in one
+// transaction and returns how many were stored.
+func (s *Store) SaveExchangeRates(ctx context.Context, rates []domain.ExchangeRate) (int, error) {
+	saved := 0
+	err := s.withTx(ctx, func(dbTx *sql.Tx) error {
+		for i := range rates {
+			ok, err := saveExchangeRate(ctx, dbTx, &rates[i])
+			if err != nil {
+				return err
+			}
+			if ok {
+				saved++
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	return saved, nil
+}
+
+func saveExchangeRate(ctx context.Context, q queryer, rate *domain.ExchangeRate) (bool, error) {
+	if rate.ID == "" {
+		rate.ID = uuid.NewString()
+	}
+	if rate.CreatedAt.IsZero() {
+		rate.CreatedAt = time.Now().UTC()
+	}
+	rate.Base = strings.ToUpper(rate.Base)
+	rate.Quote = strings.ToUpper(rate.Quote)
+	rate.Rate = domain.RoundRate(rate.Rate)
+	res, err := q.ExecContext(ctx, `INSERT INTO exchange_rates (id, base, quote, rate, as_of, source, created_at) VALUES (?, ?, ?, ?, ?, ?, ?)
+ON CONFLICT (base, quote, as_of) DO UPDATE SET rate = excluded.rate, source = excluded.source, created_at = excluded.created_at
+WHERE exchange_rates.source <> ? OR excluded.source = ?`,
+		rate.ID, rate.Base, rate.Quote, rate.Rate, rate.AsOf, rate.Source, rate.CreatedAt.UTC(), ExchangeRateSourceManual, ExchangeRateSourceManual)
+	if err != nil {
+		return false, err
+	}
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return affected > 0, nil
+}
+
+const exchangeRateSelect = `SELECT id, base, quote, rate, as_of, source, created_at FROM exchange_rates`
+
+func scanExchangeRate(row rowScanner) (*domain.ExchangeRate, error) {
+	var rate domain.ExchangeRate
+	if err := row.Scan(&rate.ID, &rate.Base, &rate.Quote, &rate.Rate, &rate.AsOf, &rate.Source, &rate.CreatedAt); err != nil {
+		return nil, err
+	}
+	return &rate, nil
+}
+
+// ExchangeRateFilter narrows ListExchangeRates. Empty fields match all rates;
+// From and To are inclusive dates.
+type ExchangeRateFilter struct {
+	Base   string
+	Quote  string
+	Source string
+	From   string
+	To     string
+	Limit  int
+}
+
+// ListExchangeRates returns stored rates, newest first.
+func (s *Store) ListExchangeRates(ctx context.Context, filter ExchangeRateFilter) ([]domain.ExchangeRate, error) {
+	query := exchangeRateSelect + ` WHERE 1 = 1`
+	var args []interface{}
+	if filter.Base != "" {
+		query += " AND base = ?"
+		args = append(args, strings.ToUpper(filter.Base))
+	}
+	if filter.Quote != "" {
+		query += " AND quote = ?"
+		args = append(args, strings.ToUpper(filter.Quote))
+	}
+	if filter.Source != "" {
+		query += " AND source = ?"
+		args = append(args, filter.Source)
+	}
+	if filter.From != "" {
+		query += " AND as_of >= ?"
+		args = append(args, filter.From)
+	}
+	if filter.To != "" {
+		query += " AND as_of <= ?"
+		args = append(args, filter.To)
+	}
+	query += " ORDER BY as_of DESC, base, quote"
+	if filter.Limit > 0 {
+		query += " LIMIT ?"
+		args = append(args, filter.Limit)
+	}
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var result []domain.ExchangeRate
+	for rows.Next() {
+		rate, err := scanExchangeRate(rows)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, *rate)
+	}
+	return result, rows.Err()
+}
+
+// DeleteExchangeRate removes a manual rate so that provider rates apply
+// again. It returns false when there is no such rate.
+func (s *Store) DeleteExchangeRate(ctx context.Context, id string) (bool, error) {
+	rate, err := scanExchangeRate(s.db.QueryRowContext(ctx, exchangeRateSelect+` WHERE id = ?`, id))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return false, nil
+		}
+		return false, err
+	}
+	if rate.Source != ExchangeRateSourceManual {
+		return false, ErrExchangeRateNotManual
+	}
+	err = s.withTx(ctx, func(dbTx *sql.Tx) error {
+		if _, err := dbTx.ExecContext(ctx, `DELETE FROM exchange_rates WHERE id = ?`, id); err != nil {
+			return err
+		}
+		return clearConvertedBaseAmounts(ctx, dbTx, rate.AsOf)
+	})
+	if err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// clearConvertedBaseAmounts clears the family-currency amounts converted at
+// a rate since the day before asOf, to cover family time zones. A rate also
+// serves later dates and cross rates of other pairs, so the currencies are
+// not narrowed down.
+func clearConvertedBaseAmounts(ctx context.Context, q queryer, asOf string) error {
+	day, err := time.Parse(rateDateLayout, asOf)
+	if err != nil {
+		return err
+	}
+	_, err = q.ExecContext(ctx, `UPDATE transactions SET amount_base_minor = NULL
+WHERE amount_base_minor IS NOT NULL AND occurred_at >= ?
+AND EXISTS (SELECT 1 FROM families f WHERE f.id = transactions.family_id
+    AND UPPER(transactions.currency) <> UPPER(f.currency_base)
+    AND (transactions.original_currency IS NULL OR UPPER(transactions.original_currency) <> UPPER(f.currency_base)))`, day.AddDate(0, 0, -1))
+	return err
+}
+
+// ExchangeRateOn returns the rate of base in quote on date or, when there is
+// none, on the nearest earlier date. It returns nil when no such rate exists.
+func (s *Store) ExchangeRateOn(ctx context.Context, base, quote, date string) (*domain.ExchangeRate, error) {
+	return exchangeRateOn(ctx, s.db, base, quote, date)
+}
+
+func exchangeRateOn(ctx context.Context, q queryer, base, quote, date string) (*domain.ExchangeRate, error) {
+	rate, err := scanExchangeRate(q.QueryRowContext(ctx, exchangeRateSelect+`
+WHERE base = ? AND quote = ? AND as_of <= ?
+ORDER BY as_of DESC LIMIT 1`, strings.ToUpper(base), strings.ToUpper(quote), date))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return rate, nil
+}
+
+// LookupExchangeRate returns the rate converting from into to on date: the
+// stored rate of the pair or the inverse of the opposite pair, whichever is
+// more recent, or else a cross rate through a currency both are quoted
+// against, such as the euro for ECB rates. Every rate falls back to the
+// nearest earlier date. It returns nil when no rate is known.
+func (s *Store) LookupExchangeRate(ctx context.Context, from, to, date string) (*domain.RateUsage, error) {
+	return lookupExchangeRate(ctx, s.db, from, to, date)
+}
+
+func lookupExchangeRate(ctx context.Context, q queryer, from, to, date string) (*domain.RateUsage, error) {
+	from, to = strings.ToUpper(from), strings.ToUpper(to)
+	if from == to {
+		return &domain.RateUsage{Base: from, Quote: to, Rate: 1, AsOf: date}, nil
+	}
+	direct, err := pairRate(ctx, q, from, to, date)
+	if err != nil || direct != nil {
+		return direct, err
+	}
+
+	rows, err := q.QueryContext(ctx, `SELECT DISTINCT base FROM exchange_rates WHERE base <> ? AND base <> ? AND as_of <= ? ORDER BY base`, from, to, date)
+	if err != nil {
+		return nil, err
+	}
+	var pivots []string
+	for rows.Next() {
+		var pivot string
+		if err := rows.Scan(&pivot); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		pivots = append(pivots, pivot)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	for _, pivot := range pivots {
+		fromLeg, err := pairRate(ctx, q, pivot, from, date)
+		if err != nil {
+			return nil, err
+		}
+		if fromLeg == nil {
+			continue
+		}
+		toLeg, err := pairRate(ctx, q, pivot, to, date)
+		if err != nil {
+			return nil, err
+		}
+		if toLeg == nil {
+			continue
+		}
+		usage := &domain.RateUsage{
+			Base:   from,
+			Quote:  to,
+			Rate:   toLeg.Rate / fromLeg.Rate,
+			AsOf:   fromLeg.AsOf,
+			Source: fromLeg.Source,
+			Via:    pivot,
+		}
+		if toLeg.AsOf < usage.AsOf {
+			usage.AsOf = toLeg.AsOf
+		}
+		if toLeg.Source != fromLeg.Source {
+			usage.Source += "+" + toLeg.Source
+		}
+		return usage, nil
+	}
+	return nil, nil
+}
+
+// pairRate returns the stored rate of from in to, or the inverse of the
+// stored rate of to in from when that one is newer.
+func pairRate(ctx context.Context, q queryer, from, to, date string) (*domain.RateUsage, error) {
+	direct, err := exchangeRateOn(ctx, q, from, to, date)
+	if err != nil {
+		return nil, err
+	}
+	inverse, err := exchangeRateOn(ctx, q, to, from, date)
+	if err != nil {
+		return nil, err
+	}
+	if inverse != nil && inverse.Rate > 0 && (direct == nil || direct.Rate <= 0 || inverse.AsOf > direct.AsOf) {
+		return &domain.RateUsage{Base: from, Quote: to, Rate: 1 / inverse.Rate, AsOf: inverse.AsOf, Source: inverse.Source, Inverted: true}, nil
+	}
+	if direct != nil && direct.Rate > 0 {
+		return &domain.RateUsage{Base: from, Quote: to, Rate: direct.Rate, AsOf: direct.AsOf, Source: direct.Source}, nil
+	}
+	return nil, nil
+}
+
+// StaleExchangeRates warns about the currencies of the family's accounts and
+// transactions that have no rate into the family currency, or only one older
+// than StaleRateDays on today's date in the family time zone.
+func (s *Store) StaleExchangeRates(ctx context.Context, familyID string, now time.Time, loc *time.Location) ([]domain.StaleRateWarning, error) {
+	family, err := s.GetFamily(ctx, familyID)
+	if err != nil || family == nil {
+		return nil, err
+	}
+	rows, err := s.db.QueryContext(ctx, `SELECT currency FROM accounts WHERE family_id = ? AND is_archived = 0
+UNION SELECT currency FROM transactions WHERE family_id = ?
+ORDER BY currency`, familyID, familyID)
+	if err != nil {
+		return nil, err
+	}
+	var currencies []string
+	for rows.Next() {
+		var currency string
+		if err := rows.Scan(&currency); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		currencies = append(currencies, strings.ToUpper(currency))
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+
+	if loc == nil {
+		loc = time.UTC
+	}
+	local := now.In(loc)
+	today := time.Date(local.Year(), local.Month(), local.Day(), 0, 0, 0, 0, time.UTC)
+	base := strings.ToUpper(family.CurrencyBase)
+	var warnings []domain.StaleRateWarning
+	seen := make(map[string]bool)
+	for _, currency := range currencies {
+		if currency == base || seen[currency] {
+			continue
+		}
+		seen[currency] = true
+		rate, err := s.LookupExchangeRate(ctx, currency, base, today.Format(rateDateLayout))
+		if err != nil {
+			return nil, err
+		}
+		if rate == nil {
+			warnings = append(warnings, domain.StaleRateWarning{
+				Currency: currency,
+				Quote:    base,
+				Message:  "no exchange rate from " + currency + " to " + base,
+			})
+			continue
+		}
+		asOf, err := time.Parse(rateDateLayout, rate.AsOf)
+		if err != nil {
+			return nil, err
+		}
+		age := int(today.Sub(asOf).Hours() / 24)
+		if age <= StaleRateDays {
+			continue
+		}
+		latest := rate.AsOf
+		warnings = append(warnings, domain.StaleRateWarning{
+			Currency:   currency,
+			Quote:      base,
+			LatestAsOf: &latest,
+			AgeDays:    age,
+			Message:    "latest exchange rate from " + currency + " to " + base + " is from " + rate.AsOf,
+		})
+	}
+	return warnings, nil
+}
+
+// RateConverter converts report amounts into the family currency at the rate
+// of each transaction's date in the family time zone, and remembers which
+// rates it used and which amounts it could not convert.
+type RateConverter struct {
+	store    *Store
+	currency string
+	loc      *time.Location
+
+	rates   map[string]*domain.RateUsage
+	usage   map[string]*domain.RateUsage
+	order   []string
+	missing map[string]*domain.MissingRate
+}
+
+func (s *Store) NewRateConverter(currency string, loc *time.Location) *RateConverter {
+	if loc == nil {
+		loc = time.UTC
+	}
+	return &RateConverter{
+		store:    s,
+		currency: strings.ToUpper(currency),
+		loc:      loc,
+		rates:    make(map[string]*domain.RateUsage),
+		usage:    make(map[string]*domain.RateUsage),
+		missing:  make(map[string]*domain.MissingRate),
+	}
+}
+
+// Currency is the currency amounts are converted into.
+func (c *RateConverter) Currency() string {
+	return c.currency
+}
+
+// Convert returns amount in the family currency. ok is false when no rate
+// was found; the amount is then recorded as missing.
+func (c *RateConverter) Convert(ctx context.Context, currency string, amount int64, at time.Time) (int64, bool, error) {
+	currency = strings.ToUpper(currency)
+	if currency == c.currency {
+		return amount, true, nil
+	}
+	date := at.In(c.loc).Format(rateDateLayout)
+	cacheKey := currency + "/" + date
+	rate, ok := c.rates[cacheKey]
+	if !ok {
+		var err error
+		if rate, err = c.store.LookupExchangeRate(ctx, currency, c.currency, date); err != nil {
+			return 0, false, err
+		}
+		c.rates[cacheKey] = rate
+	}
+	if rate == nil {
+		missing, ok := c.missing[currency]
+		if !ok {
+			missing = &domain.MissingRate{Currency: currency, From: date, To: date}
+			c.missing[currency] = missing
+		}
+		if date < missing.From {
+			missing.From = date
+		}
+		if date > missing.To {
+			missing.To = date
+		}
+		missing.Transactions++
+		missing.AmountMinor += amount
+		return 0, false, nil
+	}
+
+	usageKey := currency + "/" + rate.AsOf + "/" + rate.Source + "/" + rate.Via
+	usage, ok := c.usage[usageKey]
+	if !ok {
+		copied := *rate
+		usage = &copied
+		c.usage[usageKey] = usage
+		c.order = append(c.order, usageKey)
+	}
+	usage.Transactions++
+	return domain.ConvertAmount(amount, currency, c.currency, rate.Rate), true, nil
+}
+
+// Summary describes the rates used so far and the amounts left unconverted.
+func (c *RateConverter) Summary() *domain.ReportConversion {
+	summary := &domain.ReportConversion{
+		Currency: c.currency,
+		Complete: len(c.missing) == 0,
+		Rates:    make([]domain.RateUsage, 0, len(c.order)),
+	}
+	for _, key := range c.order {
+		summary.Rates = append(summary.Rates, *c.usage[key])
+	}
+	for _, currency := range sortedMapKeys(c.missing) {
+		summary.Missing = append(summary.Missing, *c.missing[currency])
+	}
+	return summary
+}
@@ -0,0 +1,69 @@
+package store
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"familybudget/internal/domain"
+)
+
+// FamilyCurrencies returns the currencies a family can use: the default
+// currencies, the family currency and those the family enabled, in that
+// order.
+func (s *Store) FamilyCurrencies(ctx context.Context, familyID string) ([]string, error) {
+	var base string
+	if err := s.db.QueryRowContext(ctx, `SELECT currency_base FROM families WHERE id = ?`, familyID).Scan(&base); err != nil {
+		return nil, err
+	}
+	rows, err := s.db.QueryContext(ctx, `SELECT code FROM family_currencies WHERE family_id = ? ORDER BY code`, familyID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	codes := append([]string(nil), domain.DefaultCurrencies...)
+	seen := make(map[string]bool, len(codes))
+	for _, code := range codes {
+		seen[code] = true
+	}
+	add := func(code string) {
+		code = strings.ToUpper(code)
+		if !seen[code] {
+			seen[code] = true
+			codes = append(codes, code)
+		}
+	}
+	add(base)
+	for rows.Next() {
+		var code string
+		if err := rows.Scan(&code); err != nil {
+			return nil, err
+		}
+		add(code)
+	}
+	return codes, rows.Err()
+}
+
+// EnableFamilyCurrency lets the family use code. Enabling a currency twice
+// is a no-op.
+func (s *Store) EnableFamilyCurrency(ctx context.Context, familyID, code string, now time.Time) error {
+	_, err := s.db.ExecContext(ctx, `INSERT INTO family_currencies (family_id, code, created_at) VALUES (?, ?, ?) ON CONFLICT (family_id, code) DO NOTHING`,
+		familyID, strings.ToUpper(code), now)
+	return err
+}
+
+// DisableFamilyCurrency stops offering code for new accounts and records.
+// Existing accounts, transactions and balances in it are kept. It reports
+// whether the currency had been enabled.
+func (s *Store) DisableFamilyCurrency(ctx context.Context, familyID, code string) (bool, error) {
+	res, err := s.db.ExecContext(ctx, `DELETE FROM family_currencies WHERE family_id = ? AND code = ?`, familyID, strings.ToUpper(code))
+	if err != nil {
+		return false, err
+	}
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return affected > 0, nil
+}
@@ -0,0 +1,303 @@
+package store
+
+import (
+	"context"
+	"sort"
+	"strings"
+	"time"
+
+	"familybudget/internal/domain"
+	"familybudget/internal/recurrence"
+)
+
+const forecastDateLayout = "2006-01-02"
+
+// ForecastOptions configure a cash-flow forecast. Days run from the day
+// containing Now to Until inclusive, in Location. A positive BaselineDays adds
+// the average daily spending per account and category over that many past
+// days, leaving out transactions that paid planned operations.
+type ForecastOptions struct {
+	Now          time.Time
+	Until        time.Time
+	Location     *time.Location
+	BaselineDays int
+}
+
+// Forecast projects the daily balance of every active account of the family
+// from its current balance and the pending planned operations, expanding
+// recurring ones. Overdue occurrences are expected on the first day.
+// Multi-currency accounts are projected once per pocket.
+func (s *Store) Forecast(ctx context.Context, familyID string, opts ForecastOptions) (*domain.Forecast, error) {
+	loc := opts.Location
+	if loc == nil {
+		loc = time.UTC
+	}
+	now := opts.Now.In(loc)
+	first := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, loc)
+	until := opts.Until.In(loc)
+	last := time.Date(until.Year(), until.Month(), until.Day(), 0, 0, 0, 0, loc)
+	end := last.AddDate(0, 0, 1)
+
+	var dates []string
+	index := make(map[string]int)
+	for day := first; day.Before(end); day = day.AddDate(0, 0, 1) {
+		index[day.Format(forecastDateLayout)] = len(dates)
+		dates = append(dates, day.Format(forecastDateLayout))
+	}
+	dayOf := func(t time.Time) int {
+		if t.Before(first) {
+			return 0
+		}
+		return index[t.In(loc).Format(forecastDateLayout)]
+	}
+
+	accounts, err := s.ListAccountsByFamily(ctx, familyID)
+	if err != nil {
+		return nil, err
+	}
+	// Every account is projected per pocket: multi-currency accounts get a
+	// series per currency, the others a single one that takes all their
+	// operations.
+	series := newForecastSeries(len(dates))
+	for _, account := range accounts {
+		if account.IsArchived {
+			continue
+		}
+		series.addAccount(account)
+	}
+	inflow, outflow := series.inflow, series.outflow
+
+	ops, err := s.ListPlannedOperationsByFamily(ctx, familyID, PlannedOperationStatusPending)
+	if err != nil {
+		return nil, err
+	}
+	for _, op := range ops {
+		key, ok := series.key(op.AccountID, op.Currency)
+		if !ok || !op.DueAt.Before(end) {
+			continue
+		}
+		occurrences := []time.Time{op.DueAt}
+		if rule := strings.TrimSpace(op.Recurrence); rule != "" {
+			from := op.DueAt
+			if op.SnoozedFrom != nil && op.SnoozedFrom.After(from) {
+				from = *op.SnoozedFrom
+			}
+			rest, err := recurrence.Between(rule, op.StartsAt, from.Add(time.Nanosecond), end, loc)
+			if err != nil {
+				return nil, err
+			}
+			occurrences = append(occurrences, rest...)
+		}
+		for _, occurrence := range occurrences {
+			i := dayOf(occurrence)
+			if strings.ToLower(op.Type) == "income" {
+				inflow[key][i] += op.AmountMinor
+			} else {
+				outflow[key][i] += op.AmountMinor
+			}
+		}
+	}
+
+	forecast := &domain.Forecast{
+		From:     first.Format(forecastDateLayout),
+		Until:    last.Format(forecastDateLayout),
+		Timezone: loc.String(),
+	}
+	if opts.BaselineDays > 0 {
+		forecast.BaselineDays = opts.BaselineDays
+		baseline, err := s.addSpendingBaseline(ctx, familyID, first, opts.BaselineDays, series)
+		if err != nil {
+			return nil, err
+		}
+		forecast.Baseline = baseline
+	}
+
+	totals := make(map[string]*domain.CurrencyForecast)
+	for _, pocket := range series.ordered() {
+		item := domain.AccountForecast{
+			AccountID:           pocket.account.ID,
+			Name:                pocket.account.Name,
+			Currency:            pocket.currency,
+			OpeningBalanceMinor: pocket.balance,
+			MinBalanceMinor:     pocket.balance,
+			Days:                make([]domain.ForecastDay, len(dates)),
+		}
+		total, ok := totals[pocket.currency]
+		if !ok {
+			total = &domain.CurrencyForecast{Currency: pocket.currency, Days: make([]domain.ForecastDay, len(dates))}
+			for i := range total.Days {
+				total.Days[i].Date = dates[i]
+			}
+			totals[pocket.currency] = total
+		}
+		total.OpeningBalanceMinor += pocket.balance
+
+		balance := pocket.balance
+		for i, date := range dates {
+			in, out := inflow[pocket.key][i], outflow[pocket.key][i]
+			balance += in - out
+			item.Days[i] = domain.ForecastDay{Date: date, InflowMinor: in, OutflowMinor: out, BalanceMinor: balance, Negative: balance < 0}
+			if balance < item.MinBalanceMinor {
+				item.MinBalanceMinor = balance
+			}
+			if balance < 0 && item.FirstNegativeDate == nil {
+				negative := date
+				item.FirstNegativeDate = &negative
+			}
+			total.Days[i].InflowMinor += in
+			total.Days[i].OutflowMinor += out
+			total.Days[i].BalanceMinor += balance
+		}
+		forecast.Accounts = append(forecast.Accounts, item)
+	}
+
+	currencies := make([]string, 0, len(totals))
+	for currency := range totals {
+		currencies = append(currencies, currency)
+	}
+	sort.Strings(currencies)
+	for _, currency := range currencies {
+		total := totals[currency]
+		total.MinBalanceMinor = total.OpeningBalanceMinor
+		for i := range total.Days {
+			day := &total.Days[i]
+			day.Negative = day.BalanceMinor < 0
+			if day.BalanceMinor < total.MinBalanceMinor {
+				total.MinBalanceMinor = day.BalanceMinor
+			}
+			if day.Negative && total.FirstNegativeDate == nil {
+				negative := day.Date
+				total.FirstNegativeDate = &negative
+			}
+		}
+		forecast.Totals = append(forecast.Totals, *total)
+	}
+	if forecast.Accounts == nil {
+		forecast.Accounts = []domain.AccountForecast{}
+	}
+	if forecast.Totals == nil {
+		forecast.Totals = []domain.CurrencyForecast{}
+	}
+	return forecast, nil
+}
+
+// addSpendingBaseline spreads the average daily spending per category of the
+// past baselineDays over the forecast days of each account. Amounts are taken
+// from the running total so rounding does not drift over long horizons.
+func (s *Store) addSpendingBaseline(ctx context.Context, familyID string, first time.Time, baselineDays int, series *forecastSeries) ([]domain.ForecastBaseline, error) {
+	since := first.AddDate(0, 0, -baselineDays)
+	rows, err := s.db.QueryContext(ctx, `SELECT account_id, category_id, currency, SUM(amount_minor) AS total
+FROM transactions
+WHERE family_id = ? AND LOWER(type) = 'expense' AND planned_operation_id IS NULL AND occurred_at >= ? AND occurred_at < ?
+GROUP BY account_id, category_id, currency
+ORDER BY total DESC`, familyID, since.UTC(), first.UTC())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var baseline []domain.ForecastBaseline
+	for rows.Next() {
+		var item domain.ForecastBaseline
+		if err := rows.Scan(&item.AccountID, &item.CategoryID, &item.Currency, &item.SpentMinor); err != nil {
+			return nil, err
+		}
+		key, ok := series.key(item.AccountID, item.Currency)
+		if !ok {
+			continue
+		}
+		days := series.outflow[key]
+		item.DailyAverageMinor = item.SpentMinor / int64(baselineDays)
+		baseline = append(baseline, item)
+
+		var previous int64
+		for i := range days {
+			cumulative := item.SpentMinor * int64(i+1) / int64(baselineDays)
+			days[i] += cumulative - previous
+			previous = cumulative
+		}
+	}
+	return baseline, rows.Err()
+}
+
+// forecastPocket is one projected balance: an account, or one currency
+// pocket of a multi-currency account.
+type forecastPocket struct {
+	key      string
+	account  domain.Account
+	currency string
+	balance  int64
+}
+
+// forecastSeries holds the daily inflow and outflow of each pocket keyed by
+// account and currency.
+type forecastSeries struct {
+	days     int
+	pockets  []*forecastPocket
+	byKey    map[string]*forecastPocket
+	accounts map[string]domain.Account
+	order    map[string]int
+	inflow   map[string][]int64
+	outflow  map[string][]int64
+}
+
+func newForecastSeries(days int) *forecastSeries {
+	return &forecastSeries{
+		days:     days,
+		byKey:    make(map[string]*forecastPocket),
+		accounts: make(map[string]domain.Account),
+		order:    make(map[string]int),
+		inflow:   make(map[string][]int64),
+		outflow:  make(map[string][]int64),
+	}
+}
+
+func (f *forecastSeries) addAccount(account domain.Account) {
+	f.accounts[account.ID] = account
+	f.order[account.ID] = len(f.order)
+	if !account.MultiCurrency {
+		f.addPocket(account, account.Currency, account.BalanceMinor)
+		return
+	}
+	for _, pocket := range account.Pockets {
+		f.addPocket(account, pocket.Currency, pocket.BalanceMinor)
+	}
+}
+
+func (f *forecastSeries) addPocket(account domain.Account, currency string, balance int64) string {
+	currency = strings.ToUpper(currency)
+	key := account.ID + "/" + currency
+	if _, ok := f.byKey[key]; !ok {
+		pocket := &forecastPocket{key: key, account: account, currency: currency, balance: balance}
+		f.pockets = append(f.pockets, pocket)
+		f.byKey[key] = pocket
+		f.inflow[key] = make([]int64, f.days)
+		f.outflow[key] = make([]int64, f.days)
+	}
+	return key
+}
+
+// key returns the series that amounts in currency on accountID move. A
+// multi-currency account opens an empty pocket for a currency it does not
+// hold yet; other accounts take every amount into their only series. ok is
+// false for archived and unknown accounts.
+func (f *forecastSeries) key(accountID, currency string) (string, bool) {
+	account, ok := f.accounts[accountID]
+	if !ok {
+		return "", false
+	}
+	if !account.MultiCurrency {
+		currency = account.Currency
+	}
+	return f.addPocket(account, currency, 0), true
+}
+
+// ordered returns the pockets in account order, pockets opened by planned
+// operations right after those of their account.
+func (f *forecastSeries) ordered() []*forecastPocket {
+	pockets := append([]*forecastPocket(nil), f.pockets...)
+	sort.SliceStable(pockets, func(i, j int) bool {
+		return f.order[pockets[i].account.ID] < f.order[pockets[j].account.ID]
+	})
+	return pockets
+}
@@ -0,0 +1,327 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"math"
+	"time"
+
+	"familybudget/internal/domain"
+)
+
+const (
+	GoalFundingAccounts      = "accounts"
+	GoalFundingContributions = "contributions"
+)
+
+// goalPaceWindow is the look-back period used to estimate how fast a goal is
+// being funded.
+const goalPaceWindow = 90 * 24 * time.Hour
+
+const goalColumns = `id, family_id, name, currency, target_amount_minor, due_date, priority, funding_mode, completed_at, is_archived, created_at, updated_at`
+
+func scanGoal(row rowScanner) (*domain.Goal, error) {
+	var goal domain.Goal
+	var dueDate sql.NullTime
+	var completedAt sql.NullTime
+	var isArchived bool
+	if err := row.Scan(&goal.ID, &goal.FamilyID, &goal.Name, &goal.Currency, &goal.TargetAmountMinor, &dueDate, &goal.Priority, &goal.FundingMode, &completedAt, &isArchived, &goal.CreatedAt, &goal.UpdatedAt); err != nil {
+		return nil, err
+	}
+	if dueDate.Valid {
+		t := dueDate.Time
+		goal.DueDate = &t
+	}
+	if completedAt.Valid {
+		t := completedAt.Time
+		goal.CompletedAt = &t
+	}
+	goal.IsArchived = isArchived
+	goal.AccountIDs = []string{}
+	return &goal, nil
+}
+
+func (s *Store) CreateGoal(ctx context.Context, goal *domain.Goal) error {
+	return s.withTx(ctx, func(dbTx *sql.Tx) error {
+		if _, err := dbTx.ExecContext(ctx, `INSERT INTO goals (`+goalColumns+`) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+			goal.ID, goal.FamilyID, goal.Name, goal.Currency, goal.TargetAmountMinor, nullableTime(goal.DueDate), goal.Priority, goal.FundingMode, nullableTime(goal.CompletedAt), goal.IsArchived, goal.CreatedAt, goal.UpdatedAt); err != nil {
+			return err
+		}
+		if err := replaceGoalAccountsTx(ctx, dbTx, goal.ID, goal.AccountIDs); err != nil {
+			return err
+		}
+		return checkGoalCompletionTx(ctx, dbTx, goal.ID, goal.UpdatedAt)
+	})
+}
+
+func (s *Store) UpdateGoal(ctx context.Context, goal *domain.Goal) error {
+	return s.withTx(ctx, func(dbTx *sql.Tx) error {
+		res, err := dbTx.ExecContext(ctx, `UPDATE goals SET name = ?, target_amount_minor = ?, due_date = ?, priority = ?, funding_mode = ?, completed_at = ?, is_archived = ?, updated_at = ? WHERE id = ? AND family_id = ?`,
+			goal.Name, goal.TargetAmountMinor, nullableTime(goal.DueDate), goal.Priority, goal.FundingMode, nullableTime(goal.CompletedAt), goal.IsArchived, goal.UpdatedAt, goal.ID, goal.FamilyID)
+		if err != nil {
+			return err
+		}
+		if rows, err := res.RowsAffected(); err != nil {
+			return err
+		} else if rows == 0 {
+			return sql.ErrNoRows
+		}
+		if err := replaceGoalAccountsTx(ctx, dbTx, goal.ID, goal.AccountIDs); err != nil {
+			return err
+		}
+		return checkGoalCompletionTx(ctx, dbTx, goal.ID, goal.UpdatedAt)
+	})
+}
+
+func (s *Store) GetGoal(ctx context.Context, id string) (*domain.Goal, error) {
+	goal, err := scanGoal(s.db.QueryRowContext(ctx, `SELECT `+goalColumns+` FROM goals WHERE id = ?`, id))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	if err := s.loadGoalDetails(ctx, goal, time.Now().UTC()); err != nil {
+		return nil, err
+	}
+	return goal, nil
+}
+
+func (s *Store) ListGoalsByFamily(ctx context.Context, familyID string) ([]domain.Goal, error) {
+	rows, err := s.db.QueryContext(ctx, `SELECT `+goalColumns+` FROM goals WHERE family_id = ? ORDER BY is_archived, priority, due_date`, familyID)
+	if err != nil {
+		return nil, err
+	}
+	var goals []domain.Goal
+	for rows.Next() {
+		goal, err := scanGoal(rows)
+		if err != nil {
+			rows.Close()
+			return nil, err
+		}
+		goals = append(goals, *goal)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+
+	now := time.Now().UTC()
+	for i := range goals {
+		if err := s.loadGoalDetails(ctx, &goals[i], now); err != nil {
+			return nil, err
+		}
+	}
+	return goals, nil
+}
+
+func (s *Store) AddGoalContribution(ctx context.Context, contribution *domain.GoalContribution) error {
+	return s.withTx(ctx, func(dbTx *sql.Tx) error {
+		if _, err := dbTx.ExecContext(ctx, `INSERT INTO goal_contributions (id, goal_id, family_id, user_id, amount_minor, comment, contributed_at, created_at) VALUES (?, ?, ?, ?, ?, ?, ?, ?)`,
+			contribution.ID, contribution.GoalID, contribution.FamilyID, contribution.UserID, contribution.AmountMinor, nullableString(contribution.Comment), contribution.ContributedAt, contribution.CreatedAt); err != nil {
+			return err
+		}
+		return checkGoalCompletionTx(ctx, dbTx, contribution.GoalID, contribution.CreatedAt)
+	})
+}
+
+func (s *Store) ListGoalContributions(ctx context.Context, goalID string) ([]domain.GoalContribution, error) {
+	rows, err := s.db.QueryContext(ctx, `SELECT id, goal_id, family_id, user_id, amount_minor, comment, contributed_at, created_at FROM goal_contributions WHERE goal_id = ? ORDER BY contributed_at DESC`, goalID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var contributions []domain.GoalContribution
+	for rows.Next() {
+		var item domain.GoalContribution
+		var comment sql.NullString
+		if err := rows.Scan(&item.ID, &item.GoalID, &item.FamilyID, &item.UserID, &item.AmountMinor, &comment, &item.ContributedAt, &item.CreatedAt); err != nil {
+			return nil, err
+		}
+		if comment.Valid {
+			item.Comment = comment.String
+		}
+		contributions = append(contributions, item)
+	}
+	return contributions, rows.Err()
+}
+
+func (s *Store) loadGoalDetails(ctx context.Context, goal *domain.Goal, now time.Time) error {
+	accountIDs, err := goalAccountIDs(ctx, s.db, goal.ID)
+	if err != nil {
+		return err
+	}
+	goal.AccountIDs = accountIDs
+
+	saved, err := goalSavedMinor(ctx, s.db, goal)
+	if err != nil {
+		return err
+	}
+	windowSum, err := goalWindowSum(ctx, s.db, goal, now.Add(-goalPaceWindow))
+	if err != nil {
+		return err
+	}
+	goal.Progress = computeGoalProgress(goal, saved, windowSum, now)
+	return nil
+}
+
+func computeGoalProgress(goal *domain.Goal, saved, windowSum int64, now time.Time) domain.GoalProgress {
+	progress := domain.GoalProgress{SavedMinor: saved}
+	progress.RemainingMinor = goal.TargetAmountMinor - saved
+	if progress.RemainingMinor < 0 {
+		progress.RemainingMinor = 0
+	}
+	if goal.TargetAmountMinor > 0 {
+		percent := saved * 100 / goal.TargetAmountMinor
+		if percent < 0 {
+			percent = 0
+		}
+		if percent > 100 {
+			percent = 100
+		}
+		progress.Percent = int(percent)
+	}
+
+	windowDays := goalPaceWindow.Hours() / 24
+	progress.MonthlyPaceMinor = int64(math.Round(float64(windowSum) * 30 / windowDays))
+
+	if progress.RemainingMinor == 0 {
+		completed := now
+		if goal.CompletedAt != nil {
+			completed = *goal.CompletedAt
+		}
+		progress.ProjectedCompletion = &completed
+		return progress
+	}
+
+	if goal.DueDate != nil {
+		months := math.Ceil(goal.DueDate.Sub(now).Hours() / 24 / 30.4375)
+		if months < 1 {
+			months = 1
+		}
+		required := int64(math.Ceil(float64(progress.RemainingMinor) / months))
+		progress.RequiredMonthlyMinor = &required
+	}
+
+	if windowSum > 0 {
+		days := math.Ceil(float64(progress.RemainingMinor) * windowDays / float64(windowSum))
+		projected := now.AddDate(0, 0, int(days))
+		progress.ProjectedCompletion = &projected
+	}
+	return progress
+}
+
+func goalAccountIDs(ctx context.Context, db queryer, goalID string) ([]string, error) {
+	rows, err := db.QueryContext(ctx, `SELECT account_id FROM goal_accounts WHERE goal_id = ? ORDER BY account_id`, goalID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	ids := []string{}
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, rows.Err()
+}
+
+func goalSavedMinor(ctx context.Context, db queryer, goal *domain.Goal) (int64, error) {
+	var saved int64
+	var err error
+	if goal.FundingMode == GoalFundingAccounts {
+		err = db.QueryRowContext(ctx, `SELECT COALESCE(SUM(a.balance_minor), 0) FROM goal_accounts ga JOIN accounts a ON a.id = ga.account_id WHERE ga.goal_id = ?`, goal.ID).Scan(&saved)
+	} else {
+		err = db.QueryRowContext(ctx, `SELECT COALESCE(SUM(amount_minor), 0) FROM goal_contributions WHERE goal_id = ?`, goal.ID).Scan(&saved)
+	}
+	return saved, err
+}
+
+// goalWindowSum returns how much was put towards the goal since the given
+// moment: net account flows for account-funded goals, contributions otherwise.
+func goalWindowSum(ctx context.Context, db queryer, goal *domain.Goal, since time.Time) (int64, error) {
+	var sum int64
+	var err error
+	if goal.FundingMode == GoalFundingAccounts {
+		err = db.QueryRowContext(ctx, `SELECT COALESCE(SUM(CASE WHEN LOWER(t.type) = 'income' THEN t.amount_minor ELSE -t.amount_minor END), 0)
+FROM transactions t
+JOIN goal_accounts ga ON ga.account_id = t.account_id
+WHERE ga.goal_id = ? AND t.occurred_at >= ?`, goal.ID, since.UTC()).Scan(&sum)
+	} else {
+		err = db.QueryRowContext(ctx, `SELECT COALESCE(SUM(amount_minor), 0) FROM goal_contributions WHERE goal_id = ? AND contributed_at >= ?`, goal.ID, since.UTC()).Scan(&sum)
+	}
+	return sum, err
+}
+
+func replaceGoalAccountsTx(ctx context.Context, dbTx *sql.Tx, goalID string, accountIDs []string) error {
+	if _, err := dbTx.ExecContext(ctx, `DELETE FROM goal_accounts WHERE goal_id = ?`, goalID); err != nil {
+		return err
+	}
+	for _, accountID := range accountIDs {
+		if _, err := dbTx.ExecContext(ctx, `INSERT INTO goal_accounts (goal_id, account_id) VALUES (?, ?)`, goalID, accountID); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// checkGoalCompletionTx marks a goal as completed the first time its saved
+// amount reaches the target and emits goal.completed.
+func checkGoalCompletionTx(ctx context.Context, dbTx *sql.Tx, goalID string, at time.Time) error {
+	goal, err := scanGoal(dbTx.QueryRowContext(ctx, `SELECT `+goalColumns+` FROM goals WHERE id = ?`, goalID))
+	if err != nil {
+		return err
+	}
+	if goal.CompletedAt != nil || goal.IsArchived || goal.TargetAmountMinor <= 0 {
+		return nil
+	}
+	saved, err := goalSavedMinor(ctx, dbTx, goal)
+	if err != nil {
+		return err
+	}
+	if saved < goal.TargetAmountMinor {
+		return nil
+	}
+
+	completedAt := at.UTC()
+	if _, err := dbTx.ExecContext(ctx, `UPDATE goals SET completed_at = ?, updated_at = ? WHERE id = ?`, completedAt, completedAt, goal.ID); err != nil {
+		return err
+	}
+	return appendEvent(ctx, dbTx, goal.FamilyID, domain.EventGoalCompleted, map[string]interface{}{
+		"goal_id":      goal.ID,
+		"name":         goal.Name,
+		"completed_at": completedAt,
+	}, completedAt)
+}
+
+// checkAccountGoalsTx re-evaluates account-funded goals after the balance of
+// one of their accounts changed.
+func checkAccountGoalsTx(ctx context.Context, dbTx *sql.Tx, accountID string, at time.Time) error {
+	rows, err := dbTx.QueryContext(ctx, `SELECT g.id FROM goals g JOIN goal_accounts ga ON ga.goal_id = g.id
+WHERE ga.account_id = ? AND g.funding_mode = ? AND g.completed_at IS NULL AND g.is_archived = 0`, accountID, GoalFundingAccounts)
+	if err != nil {
+		return err
+	}
+	var goalIDs []string
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			rows.Close()
+			return err
+		}
+		goalIDs = append(goalIDs, id)
+	}
+	if err := rows.Close(); err != nil {
+		return err
+	}
+	for _, id := range goalIDs {
+		if err := checkGoalCompletionTx(ctx, dbTx, id, at); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,239 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"strings"
+	"time"
+
+	"familybudget/internal/domain"
+)
+
+const (
+	ImportStatusPending   = "pending_validation"
+	ImportStatusCommitted = "committed"
+	ImportStatusCancelled = "cancelled"
+)
+
+var ErrImportNotPending = errors.New("import is already committed or cancelled")
+
+// ListImportProfiles returns the profiles a family saved, by name.
+func (s *Store) ListImportProfiles(ctx context.Context, familyID string) ([]domain.ImportProfile, error) {
+	rows, err := s.db.QueryContext(ctx, `SELECT id, family_id, name, config, created_at FROM import_profiles WHERE family_id = ? ORDER BY name, created_at`, familyID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var profiles []domain.ImportProfile
+	for rows.Next() {
+		profile, err := scanImportProfile(rows)
+		if err != nil {
+			return nil, err
+		}
+		profiles = append(profiles, *profile)
+	}
+	return profiles, rows.Err()
+}
+
+// GetImportProfile returns a saved profile of the family, or nil.
+func (s *Store) GetImportProfile(ctx context.Context, familyID, id string) (*domain.ImportProfile, error) {
+	row := s.db.QueryRowContext(ctx, `SELECT id, family_id, name, config, created_at FROM import_profiles WHERE id = ? AND family_id = ?`, id, familyID)
+	profile, err := scanImportProfile(row)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	return profile, err
+}
+
+func (s *Store) CreateImportProfile(ctx context.Context, profile *domain.ImportProfile) error {
+	config, err := json.Marshal(profile)
+	if err != nil {
+		return err
+	}
+	_, err = s.db.ExecContext(ctx, `INSERT INTO import_profiles (id, family_id, name, config, created_at) VALUES (?, ?, ?, ?, ?)`,
+		profile.ID, profile.FamilyID, profile.Name, string(config), profile.CreatedAt)
+	return err
+}
+
+// DeleteImportProfile removes a saved profile. Imports that used it keep
+// their own copy.
+func (s *Store) DeleteImportProfile(ctx context.Context, familyID, id string) (bool, error) {
+	res, err := s.db.ExecContext(ctx, `DELETE FROM import_profiles WHERE id = ? AND family_id = ?`, id, familyID)
+	if err != nil {
+		return false, err
+	}
+	affected, err := res.RowsAffected()
+	return affected > 0, err
+}
+
+func scanImportProfile(row interface{ Scan(...interface{}) error }) (*domain.ImportProfile, error) {
+	var id, familyID, name, config string
+	var createdAt time.Time
+	if err := row.Scan(&id, &familyID, &name, &config, &createdAt); err != nil {
+		return nil, err
+	}
+	var profile domain.ImportProfile
+	if err := json.Unmarshal([]byte(config), &profile); err != nil {
+		return nil, err
+	}
+	profile.ID = id
+	profile.FamilyID = familyID
+	profile.Name = name
+	profile.Builtin = false
+	profile.CreatedAt = &createdAt
+	return &profile, nil
+}
+
+// CreateImport stores an uploaded statement until it is committed or
+// cancelled.
+func (s *Store) CreateImport(ctx context.Context, imp *domain.Import, content []byte, idempotencyKey string) error {
+	profile, err := json.Marshal(imp.Profile)
+	if err != nil {
+		return err
+	}
+	_, err = s.db.ExecContext(ctx, `INSERT INTO imports (id, family_id, user_id, account_id, file_name, content, profile, status, idempotency_key, transactions, created_at)
+VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		imp.ID, imp.FamilyID, imp.UserID, imp.AccountID, imp.FileName, content, string(profile), imp.Status, nullableString(idempotencyKey), imp.Transactions, imp.CreatedAt)
+	return err
+}
+
+const importColumns = `id, family_id, user_id, account_id, file_name, content, profile, status, transactions, created_at, committed_at`
+
+// GetImport returns an import with the uploaded file, or nil.
+func (s *Store) GetImport(ctx context.Context, id string) (*domain.Import, []byte, error) {
+	return scanImport(s.db.QueryRowContext(ctx, `SELECT `+importColumns+` FROM imports WHERE id = ?`, id))
+}
+
+// FindImportByIdempotencyKey returns the import a family uploaded with key,
+// or nil.
+func (s *Store) FindImportByIdempotencyKey(ctx context.Context, familyID, key string) (*domain.Import, []byte, error) {
+	return scanImport(s.db.QueryRowContext(ctx, `SELECT `+importColumns+` FROM imports WHERE family_id = ? AND idempotency_key = ?`, familyID, key))
+}
+
+func scanImport(row *sql.Row) (*domain.Import, []byte, error) {
+	var imp domain.Import
+	var accountID sql.NullString
+	var content []byte
+	var profile string
+	var committedAt sql.NullTime
+	if err := row.Scan(&imp.ID, &imp.FamilyID, &imp.UserID, &accountID, &imp.FileName, &content, &profile, &imp.Status, &imp.Transactions, &imp.CreatedAt, &committedAt); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil, nil
+		}
+		return nil, nil, err
+	}
+	if accountID.Valid {
+		imp.AccountID = &accountID.String
+	}
+	if committedAt.Valid {
+		imp.CommittedAt = &committedAt.Time
+	}
+	if err := json.Unmarshal([]byte(profile), &imp.Profile); err != nil {
+		return nil, nil, err
+	}
+	return &imp, content, nil
+}
+
+// UpdateImport stores the profile and account chosen for a pending import.
+func (s *Store) UpdateImport(ctx context.Context, imp *domain.Import) error {
+	profile, err := json.Marshal(imp.Profile)
+	if err != nil {
+		return err
+	}
+	res, err := s.db.ExecContext(ctx, `UPDATE imports SET profile = ?, account_id = ? WHERE id = ? AND status = ?`, string(profile), imp.AccountID, imp.ID, ImportStatusPending)
+	if err != nil {
+		return err
+	}
+	if affected, err := res.RowsAffected(); err != nil {
+		return err
+	} else if affected == 0 {
+		return ErrImportNotPending
+	}
+	return nil
+}
+
+// CancelImport cancels a pending import; the uploaded file is dropped.
+func (s *Store) CancelImport(ctx context.Context, id string) error {
+	res, err := s.db.ExecContext(ctx, `UPDATE imports SET status = ?, content = ? WHERE id = ? AND status = ?`, ImportStatusCancelled, []byte{}, id, ImportStatusPending)
+	if err != nil {
+		return err
+	}
+	if affected, err := res.RowsAffected(); err != nil {
+		return err
+	} else if affected == 0 {
+		return ErrImportNotPending
+	}
+	return nil
+}
+
+// ImportedHashes returns which of hashes belong to rows the family already
+// imported.
+func (s *Store) ImportedHashes(ctx context.Context, familyID string, hashes []string) (map[string]bool, error) {
+	found := make(map[string]bool)
+	if len(hashes) == 0 {
+		return found, nil
+	}
+	args := make([]interface{}, 0, len(hashes)+1)
+	args = append(args, familyID)
+	for _, hash := range hashes {
+		args = append(args, hash)
+	}
+	rows, err := s.db.QueryContext(ctx, `SELECT DISTINCT hash FROM imported_transactions WHERE family_id = ? AND hash IN (?`+strings.Repeat(", ?", len(hashes)-1)+`)`, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var hash string
+		if err := rows.Scan(&hash); err != nil {
+			return nil, err
+		}
+		found[hash] = true
+	}
+	return found, rows.Err()
+}
+
+// CommitImport creates the transactions of an import through the usual
+// balance bookkeeping, all or none, and marks the import committed. hashes
+// holds the row hash of each transaction; preview supplies the row counts
+// of the import.completed event.
+func (s *Store) CommitImport(ctx context.Context, imp *domain.Import, txns []*domain.Transaction, hashes []string, preview *domain.ImportPreview, now time.Time) error {
+	return s.withTx(ctx, func(dbTx *sql.Tx) error {
+		res, err := dbTx.ExecContext(ctx, `UPDATE imports SET status = ?, account_id = ?, transactions = ?, committed_at = ? WHERE id = ? AND status = ?`,
+			ImportStatusCommitted, imp.AccountID, len(txns), now, imp.ID, ImportStatusPending)
+		if err != nil {
+			return err
+		}
+		if affected, err := res.RowsAffected(); err != nil {
+			return err
+		} else if affected == 0 {
+			return ErrImportNotPending
+		}
+
+		for i, txn := range txns {
+			if err := s.createTransactionTx(ctx, dbTx, txn); err != nil {
+				return err
+			}
+			if _, err := dbTx.ExecContext(ctx, `INSERT INTO imported_transactions (transaction_id, import_id, family_id, hash) VALUES (?, ?, ?, ?)`,
+				txn.ID, imp.ID, imp.FamilyID, hashes[i]); err != nil {
+				return err
+			}
+		}
+
+		imp.Status = ImportStatusCommitted
+		imp.Transactions = len(txns)
+		imp.CommittedAt = &now
+		return appendEvent(ctx, dbTx, imp.FamilyID, domain.EventImportCompleted, map[string]interface{}{
+			"import_id":    imp.ID,
+			"account_id":   imp.AccountID,
+			"user_id":      imp.UserID,
+			"total":        preview.TotalRows,
+			"transactions": len(txns),
+			"duplicates":   preview.Duplicates,
+			"warnings":     len(preview.Warnings),
+		}, now)
+	})
+}
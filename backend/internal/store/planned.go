@@ -0,0 +1,493 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+
+	"familybudget/internal/domain"
+	"familybudget/internal/recurrence"
+)
+
+// maxPlannedCatchUp bounds how many missed occurrences of one planned
+// operation are auto-posted in a single run.
+const maxPlannedCatchUp = 60
+
+const (
+	PlannedOccurrencePaid    = "paid"
+	PlannedOccurrenceSkipped = "skipped"
+	PlannedOccurrenceMissed  = "missed"
+)
+
+var ErrPlannedOperationChanged = errors.New("planned operation was changed concurrently")
+
+// advancePlannedOperation moves op to the first occurrence after its current
+// due date, its original date when the occurrence was snoozed, and occurredAt.
+// One-off operations and series exhausted by COUNT or UNTIL are completed.
+func advancePlannedOperation(op *domain.PlannedOperation, occurredAt time.Time, loc *time.Location) error {
+	rule := strings.TrimSpace(op.Recurrence)
+	after := op.DueAt
+	if op.SnoozedFrom != nil && op.SnoozedFrom.After(after) {
+		after = *op.SnoozedFrom
+	}
+	op.SnoozedFrom = nil
+	if rule == "" {
+		op.IsCompleted = true
+		return nil
+	}
+	if occurredAt.After(after) {
+		after = occurredAt
+	}
+	next, err := recurrence.Next(rule, op.StartsAt, after, loc)
+	if errors.Is(err, recurrence.ErrSeriesEnded) {
+		op.IsCompleted = true
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	op.DueAt = next.UTC()
+	op.IsCompleted = false
+	return nil
+}
+
+// CompletePlannedOperation posts txn for the current occurrence of op and
+// advances op in one database transaction. The occurrence is claimed by its
+// due date, so a concurrent completion of the same occurrence, manual or
+// automatic, returns ErrPlannedOperationChanged instead of posting twice.
+func (s *Store) CompletePlannedOperation(ctx context.Context, op *domain.PlannedOperation, txn *domain.Transaction, loc *time.Location, now time.Time) error {
+	return s.withTx(ctx, func(dbTx *sql.Tx) error {
+		return s.completePlannedOperationTx(ctx, dbTx, op, txn, loc, now, false)
+	})
+}
+
+func (s *Store) completePlannedOperationTx(ctx context.Context, dbTx *sql.Tx, op *domain.PlannedOperation, txn *domain.Transaction, loc *time.Location, now time.Time, auto bool) error {
+	occurrence := op.DueAt
+	next := *op
+	if err := advancePlannedOperation(&next, txn.OccurredAt, loc); err != nil {
+		return err
+	}
+	next.LastCompletedAt = &now
+	next.UpdatedAt = now
+
+	if err := claimPlannedOccurrence(ctx, dbTx, &next, occurrence); err != nil {
+		return err
+	}
+
+	txn.PlannedOperationID = &op.ID
+	if err := s.createTransactionTx(ctx, dbTx, txn); err != nil {
+		return err
+	}
+	if err := recordPlannedOccurrenceTx(ctx, dbTx, op, occurrence, PlannedOccurrencePaid, &txn.ID, &txn.UserID, now); err != nil {
+		return err
+	}
+	// Paying late moves the series past the occurrences due until the
+	// payment, so they were never paid.
+	if _, err := recordMissedOccurrencesTx(ctx, dbTx, op, txn.OccurredAt.Add(time.Nanosecond), loc, now); err != nil {
+		return err
+	}
+	if err := appendEvent(ctx, dbTx, op.FamilyID, domain.EventPlannedOperationPosted, map[string]interface{}{
+		"planned_operation_id": op.ID,
+		"transaction_id":       txn.ID,
+		"amount":               txn.AmountMinor,
+		"currency":             txn.Currency,
+		"due_at":               occurrence.UTC().Format(time.RFC3339),
+		"user_id":              txn.UserID,
+		"auto_post":            auto,
+	}, now); err != nil {
+		return err
+	}
+	*op = next
+	return nil
+}
+
+// claimPlannedOccurrence moves the operation from the occurrence due at
+// occurrence to the state of next. It fails with ErrPlannedOperationChanged
+// when the occurrence was already completed, skipped or rescheduled.
+func claimPlannedOccurrence(ctx context.Context, dbTx *sql.Tx, next *domain.PlannedOperation, occurrence time.Time) error {
+	res, err := dbTx.ExecContext(ctx, `UPDATE planned_operations SET due_at = ?, snoozed_from = ?, is_completed = ?, last_completed_at = ?, updated_at = ? WHERE id = ? AND family_id = ? AND due_at = ? AND is_completed = ?`,
+		next.DueAt, nullableTime(next.SnoozedFrom), next.IsCompleted, nullableTime(next.LastCompletedAt), next.UpdatedAt, next.ID, next.FamilyID, occurrence, false)
+	if err != nil {
+		return err
+	}
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return ErrPlannedOperationChanged
+	}
+	return nil
+}
+
+// NextPlannedOccurrence returns the occurrence that follows the current one
+// without changing op. ok is false when the current occurrence is the last.
+func NextPlannedOccurrence(op *domain.PlannedOperation, loc *time.Location) (time.Time, bool, error) {
+	next := *op
+	if err := advancePlannedOperation(&next, op.DueAt, loc); err != nil {
+		return time.Time{}, false, err
+	}
+	if next.IsCompleted {
+		return time.Time{}, false, nil
+	}
+	return next.DueAt, true, nil
+}
+
+// SkipPlannedOperation advances op past its current occurrence without posting
+// a transaction and records the occurrence as skipped by userID. Skipping the
+// last occurrence completes the operation.
+func (s *Store) SkipPlannedOperation(ctx context.Context, op *domain.PlannedOperation, userID string, loc *time.Location, now time.Time) error {
+	return s.withTx(ctx, func(dbTx *sql.Tx) error {
+		skipped := *op
+		if err := s.skipPlannedOperationTx(ctx, dbTx, op, loc, now); err != nil {
+			return err
+		}
+		return recordPlannedOccurrenceTx(ctx, dbTx, &skipped, skipped.DueAt, PlannedOccurrenceSkipped, nil, &userID, now)
+	})
+}
+
+func (s *Store) skipPlannedOperationTx(ctx context.Context, dbTx *sql.Tx, op *domain.PlannedOperation, loc *time.Location, now time.Time) error {
+	occurrence := op.DueAt
+	next := *op
+	if err := advancePlannedOperation(&next, occurrence, loc); err != nil {
+		return err
+	}
+	next.UpdatedAt = now
+	if err := claimPlannedOccurrence(ctx, dbTx, &next, occurrence); err != nil {
+		return err
+	}
+	*op = next
+	return nil
+}
+
+// DetachPlannedOccurrence moves the current occurrence of the series op into
+// the one-off operation single, e.g. to change its amount, and advances the
+// series past it.
+func (s *Store) DetachPlannedOccurrence(ctx context.Context, op, single *domain.PlannedOperation, loc *time.Location, now time.Time) error {
+	return s.withTx(ctx, func(dbTx *sql.Tx) error {
+		if err := s.skipPlannedOperationTx(ctx, dbTx, op, loc, now); err != nil {
+			return err
+		}
+		_, err := dbTx.ExecContext(ctx, `INSERT INTO planned_operations (id, family_id, user_id, account_id, category_id, type, title, amount_minor, currency, comment, due_at, starts_at, snoozed_from, recurrence, auto_post, is_completed, last_completed_at, created_at, updated_at)
+VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+			single.ID, single.FamilyID, single.UserID, single.AccountID, single.CategoryID, single.Type, single.Title, single.AmountMinor, single.Currency, nullableString(single.Comment), single.DueAt, single.StartsAt, nil, nil, single.AutoPost, false, nil, single.CreatedAt, single.UpdatedAt)
+		return err
+	})
+}
+
+// UpdatePlannedOperation saves the editable fields of op.
+func (s *Store) UpdatePlannedOperation(ctx context.Context, op *domain.PlannedOperation) error {
+	res, err := s.db.ExecContext(ctx, `UPDATE planned_operations SET account_id = ?, category_id = ?, title = ?, amount_minor = ?, currency = ?, comment = ?, due_at = ?, starts_at = ?, snoozed_from = ?, recurrence = ?, auto_post = ?, updated_at = ? WHERE id = ? AND family_id = ? AND is_completed = ?`,
+		op.AccountID, op.CategoryID, op.Title, op.AmountMinor, op.Currency, nullableString(op.Comment), op.DueAt, op.StartsAt, nullableTime(op.SnoozedFrom), nullableString(op.Recurrence), op.AutoPost, op.UpdatedAt, op.ID, op.FamilyID, false)
+	if err != nil {
+		return err
+	}
+	rows, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rows == 0 {
+		return ErrPlannedOperationChanged
+	}
+	return nil
+}
+
+// DeletePlannedOperation removes the operation with its occurrence history.
+// Transactions posted for it stay and keep their planned_operation_id.
+func (s *Store) DeletePlannedOperation(ctx context.Context, familyID, id string) error {
+	return s.withTx(ctx, func(dbTx *sql.Tx) error {
+		if _, err := dbTx.ExecContext(ctx, `DELETE FROM planned_occurrences WHERE planned_operation_id = ? AND family_id = ?`, id, familyID); err != nil {
+			return err
+		}
+		if _, err := dbTx.ExecContext(ctx, `DELETE FROM reminder_rules WHERE planned_operation_id = ? AND family_id = ?`, id, familyID); err != nil {
+			return err
+		}
+		if _, err := dbTx.ExecContext(ctx, `DELETE FROM reminder_deliveries WHERE planned_operation_id = ?`, id); err != nil {
+			return err
+		}
+		// A deleted subscription may be suggested again.
+		if _, err := dbTx.ExecContext(ctx, `DELETE FROM subscription_decisions WHERE planned_operation_id = ? AND family_id = ?`, id, familyID); err != nil {
+			return err
+		}
+		res, err := dbTx.ExecContext(ctx, `DELETE FROM planned_operations WHERE id = ? AND family_id = ?`, id, familyID)
+		if err != nil {
+			return err
+		}
+		rows, err := res.RowsAffected()
+		if err != nil {
+			return err
+		}
+		if rows == 0 {
+			return sql.ErrNoRows
+		}
+		return nil
+	})
+}
+
+func recordPlannedOccurrenceTx(ctx context.Context, dbTx *sql.Tx, op *domain.PlannedOperation, dueAt time.Time, status string, transactionID, userID *string, now time.Time) error {
+	// An occurrence recorded as missed while the series was overdue can still
+	// be paid or skipped later, which settles it.
+	if _, err := dbTx.ExecContext(ctx, `DELETE FROM planned_occurrences WHERE planned_operation_id = ? AND due_at = ? AND status = ?`, op.ID, dueAt.UTC(), PlannedOccurrenceMissed); err != nil {
+		return err
+	}
+	return insertPlannedOccurrenceTx(ctx, dbTx, op, dueAt, status, transactionID, userID, now)
+}
+
+func insertPlannedOccurrenceTx(ctx context.Context, dbTx *sql.Tx, op *domain.PlannedOperation, dueAt time.Time, status string, transactionID, userID *string, now time.Time) error {
+	_, err := dbTx.ExecContext(ctx, `INSERT INTO planned_occurrences (id, family_id, planned_operation_id, due_at, status, transaction_id, amount_minor, currency, user_id, recorded_at) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		uuid.NewString(), op.FamilyID, op.ID, dueAt.UTC(), status, transactionID, op.AmountMinor, op.Currency, userID, now.UTC())
+	return err
+}
+
+// recordMissedOccurrencesTx records as missed the occurrences of op that fall
+// after its current one and before to, unless they are already recorded. It
+// returns how many it recorded.
+func recordMissedOccurrencesTx(ctx context.Context, dbTx *sql.Tx, op *domain.PlannedOperation, to time.Time, loc *time.Location, now time.Time) (int, error) {
+	rule := strings.TrimSpace(op.Recurrence)
+	if rule == "" {
+		return 0, nil
+	}
+	from := op.DueAt
+	if op.SnoozedFrom != nil && op.SnoozedFrom.After(from) {
+		from = *op.SnoozedFrom
+	}
+	if !to.After(from) {
+		return 0, nil
+	}
+	missed, err := recurrence.Between(rule, op.StartsAt, from.Add(time.Nanosecond), to, loc)
+	if err != nil {
+		return 0, err
+	}
+	recorded := 0
+	for _, dueAt := range missed {
+		var existing int
+		if err := dbTx.QueryRowContext(ctx, `SELECT COUNT(*) FROM planned_occurrences WHERE planned_operation_id = ? AND due_at = ?`, op.ID, dueAt.UTC()).Scan(&existing); err != nil {
+			return recorded, err
+		}
+		if existing > 0 {
+			continue
+		}
+		if err := insertPlannedOccurrenceTx(ctx, dbTx, op, dueAt, PlannedOccurrenceMissed, nil, nil, now); err != nil {
+			return recorded, err
+		}
+		recorded++
+	}
+	return recorded, nil
+}
+
+const plannedOccurrenceSelect = `SELECT id, family_id, planned_operation_id, due_at, status, transaction_id, amount_minor, currency, user_id, recorded_at FROM planned_occurrences`
+
+func scanPlannedOccurrence(row rowScanner) (*domain.PlannedOccurrence, error) {
+	var occurrence domain.PlannedOccurrence
+	var transactionID, userID sql.NullString
+	if err := row.Scan(&occurrence.ID, &occurrence.FamilyID, &occurrence.PlannedOperationID, &occurrence.DueAt, &occurrence.Status, &transactionID, &occurrence.AmountMinor, &occurrence.Currency, &userID, &occurrence.RecordedAt); err != nil {
+		return nil, err
+	}
+	if transactionID.Valid {
+		occurrence.TransactionID = &transactionID.String
+	}
+	if userID.Valid {
+		occurrence.UserID = &userID.String
+	}
+	return &occurrence, nil
+}
+
+// ListPlannedOccurrences returns the recorded occurrences of a family, newest
+// due date first. Empty operationID or status do not filter.
+func (s *Store) ListPlannedOccurrences(ctx context.Context, familyID, operationID, status string) ([]domain.PlannedOccurrence, error) {
+	query := plannedOccurrenceSelect + ` WHERE family_id = ?`
+	args := []interface{}{familyID}
+	if operationID != "" {
+		query += ` AND planned_operation_id = ?`
+		args = append(args, operationID)
+	}
+	if status != "" {
+		query += ` AND status = ?`
+		args = append(args, status)
+	}
+	query += ` ORDER BY due_at DESC`
+
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var occurrences []domain.PlannedOccurrence
+	for rows.Next() {
+		occurrence, err := scanPlannedOccurrence(rows)
+		if err != nil {
+			return nil, err
+		}
+		occurrences = append(occurrences, *occurrence)
+	}
+	return occurrences, rows.Err()
+}
+
+// PlannedOperationTransaction builds the transaction that pays the current
+// occurrence of op on behalf of userID.
+func PlannedOperationTransaction(op *domain.PlannedOperation, userID string, occurredAt, now time.Time) *domain.Transaction {
+	comment := op.Comment
+	if strings.TrimSpace(comment) == "" {
+		comment = op.Title
+	}
+	return &domain.Transaction{
+		ID:          uuid.NewString(),
+		FamilyID:    op.FamilyID,
+		UserID:      userID,
+		AccountID:   op.AccountID,
+		CategoryID:  op.CategoryID,
+		Type:        op.Type,
+		AmountMinor: op.AmountMinor,
+		Currency:    op.Currency,
+		Comment:     comment,
+		OccurredAt:  occurredAt,
+		CreatedAt:   now,
+		UpdatedAt:   now,
+	}
+}
+
+// PostDuePlannedOperations posts every due occurrence of planned operations
+// with auto_post enabled, catching up on occurrences missed while the server
+// was down. Operations whose account or category can no longer take the
+// posting are left pending for a person to resolve.
+func (s *Store) PostDuePlannedOperations(ctx context.Context, now time.Time) (int, error) {
+	rows, err := s.db.QueryContext(ctx, plannedOperationSelect+` WHERE p.auto_post = ? AND p.is_completed = ? AND p.due_at <= ? ORDER BY p.due_at`, true, false, now.UTC())
+	if err != nil {
+		return 0, err
+	}
+	var due []domain.PlannedOperation
+	for rows.Next() {
+		op, err := scanPlannedOperation(rows)
+		if err != nil {
+			rows.Close()
+			return 0, err
+		}
+		due = append(due, op.PlannedOperation)
+	}
+	if err := rows.Close(); err != nil {
+		return 0, err
+	}
+
+	locations := make(map[string]*time.Location)
+	posted := 0
+	for _, op := range due {
+		loc, ok := locations[op.FamilyID]
+		if !ok {
+			if loc, err = s.FamilyLocation(ctx, op.FamilyID); err != nil {
+				return posted, err
+			}
+			locations[op.FamilyID] = loc
+		}
+		postable, err := s.plannedOperationPostable(ctx, &op)
+		if err != nil {
+			return posted, err
+		}
+		if !postable {
+			continue
+		}
+
+		for i := 0; i < maxPlannedCatchUp && !op.IsCompleted && !op.DueAt.After(now); i++ {
+			txn := PlannedOperationTransaction(&op, op.UserID, op.DueAt, now.UTC())
+			err := s.withTx(ctx, func(dbTx *sql.Tx) error {
+				return s.completePlannedOperationTx(ctx, dbTx, &op, txn, loc, now.UTC(), true)
+			})
+			if errors.Is(err, ErrPlannedOperationChanged) {
+				break
+			}
+			if err != nil {
+				return posted, err
+			}
+			posted++
+		}
+	}
+	return posted, nil
+}
+
+func (s *Store) plannedOperationPostable(ctx context.Context, op *domain.PlannedOperation) (bool, error) {
+	account, err := s.GetAccount(ctx, op.AccountID)
+	if err != nil {
+		return false, err
+	}
+	if account == nil || account.FamilyID != op.FamilyID || account.IsArchived || !account.Holds(op.Currency) {
+		return false, nil
+	}
+	category, err := s.GetCategory(ctx, op.CategoryID)
+	if err != nil {
+		return false, err
+	}
+	return category != nil && category.FamilyID == op.FamilyID && !category.IsArchived, nil
+}
+
+// MarkMissedPlannedOccurrences records as missed the occurrences of manual
+// recurring operations that were followed by another due occurrence while
+// the series stayed unpaid. The series keeps its due date, so the unpaid
+// bill stays overdue until it is paid, skipped or rescheduled. Auto-posted
+// series are left to PostDuePlannedOperations.
+func (s *Store) MarkMissedPlannedOccurrences(ctx context.Context, now time.Time) (int, error) {
+	rows, err := s.db.QueryContext(ctx, plannedOperationSelect+` WHERE p.auto_post = ? AND p.is_completed = ? AND p.recurrence IS NOT NULL AND p.recurrence <> '' AND p.due_at <= ?`, false, false, now.UTC())
+	if err != nil {
+		return 0, err
+	}
+	var due []domain.PlannedOperation
+	for rows.Next() {
+		op, err := scanPlannedOperation(rows)
+		if err != nil {
+			rows.Close()
+			return 0, err
+		}
+		due = append(due, op.PlannedOperation)
+	}
+	if err := rows.Close(); err != nil {
+		return 0, err
+	}
+
+	locations := make(map[string]*time.Location)
+	missed := 0
+	for i := range due {
+		op := &due[i]
+		loc, ok := locations[op.FamilyID]
+		if !ok {
+			if loc, err = s.FamilyLocation(ctx, op.FamilyID); err != nil {
+				return missed, err
+			}
+			locations[op.FamilyID] = loc
+		}
+		// Every occurrence before the latest due one has been followed by a
+		// due occurrence.
+		latest, err := latestDueOccurrence(op, now, loc)
+		if err != nil {
+			return missed, err
+		}
+		if latest.IsZero() {
+			continue
+		}
+		err = s.withTx(ctx, func(dbTx *sql.Tx) error {
+			recorded, err := recordMissedOccurrencesTx(ctx, dbTx, op, latest, loc, now)
+			missed += recorded
+			return err
+		})
+		if err != nil {
+			return missed, err
+		}
+	}
+	return missed, nil
+}
+
+// latestDueOccurrence returns the last occurrence of op after its current one
+// that is due by now, or the zero time when the next one is not due yet.
+func latestDueOccurrence(op *domain.PlannedOperation, now time.Time, loc *time.Location) (time.Time, error) {
+	from := op.DueAt
+	if op.SnoozedFrom != nil && op.SnoozedFrom.After(from) {
+		from = *op.SnoozedFrom
+	}
+	due, err := recurrence.Between(op.Recurrence, op.StartsAt, from.Add(time.Nanosecond), now.Add(time.Nanosecond), loc)
+	if err != nil || len(due) == 0 {
+		return time.Time{}, err
+	}
+	return due[len(due)-1], nil
+}
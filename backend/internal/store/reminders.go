@@ -0,0 +1,306 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+
+	"familybudget/internal/domain"
+)
+
+const (
+	ReminderChannelEmail = "email"
+	ReminderChannelInbox = "inbox"
+)
+
+// MaxReminderOffsetDays bounds how far before or after the due date a rule
+// may remind.
+const MaxReminderOffsetDays = 60
+
+const reminderRuleSelect = `SELECT id, family_id, planned_operation_id, offset_days, channels, created_by, created_at FROM reminder_rules`
+
+func scanReminderRule(row rowScanner) (*domain.ReminderRule, error) {
+	var rule domain.ReminderRule
+	var operationID sql.NullString
+	var channels string
+	if err := row.Scan(&rule.ID, &rule.FamilyID, &operationID, &rule.OffsetDays, &channels, &rule.CreatedBy, &rule.CreatedAt); err != nil {
+		return nil, err
+	}
+	if operationID.Valid {
+		rule.PlannedOperationID = &operationID.String
+	}
+	rule.Channels = strings.Split(channels, ",")
+	return &rule, nil
+}
+
+func (s *Store) CreateReminderRule(ctx context.Context, rule *domain.ReminderRule) error {
+	_, err := s.db.ExecContext(ctx, `INSERT INTO reminder_rules (id, family_id, planned_operation_id, offset_days, channels, created_by, created_at) VALUES (?, ?, ?, ?, ?, ?, ?)`,
+		rule.ID, rule.FamilyID, rule.PlannedOperationID, rule.OffsetDays, strings.Join(rule.Channels, ","), rule.CreatedBy, rule.CreatedAt)
+	return err
+}
+
+func (s *Store) GetReminderRule(ctx context.Context, id string) (*domain.ReminderRule, error) {
+	rule, err := scanReminderRule(s.db.QueryRowContext(ctx, reminderRuleSelect+` WHERE id = ?`, id))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return rule, nil
+}
+
+func (s *Store) DeleteReminderRule(ctx context.Context, familyID, id string) error {
+	res, err := s.db.ExecContext(ctx, `DELETE FROM reminder_rules WHERE id = ? AND family_id = ?`, id, familyID)
+	if err != nil {
+		return err
+	}
+	rows, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rows == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+// ListReminderRules returns the rules of a family, family-wide rules first,
+// each group ordered from the earliest reminder to the latest.
+func (s *Store) ListReminderRules(ctx context.Context, familyID string) ([]domain.ReminderRule, error) {
+	return s.queryReminderRules(ctx, reminderRuleSelect+` WHERE family_id = ? ORDER BY planned_operation_id IS NOT NULL, planned_operation_id, offset_days DESC`, familyID)
+}
+
+func (s *Store) queryReminderRules(ctx context.Context, query string, args ...interface{}) ([]domain.ReminderRule, error) {
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var rules []domain.ReminderRule
+	for rows.Next() {
+		rule, err := scanReminderRule(rows)
+		if err != nil {
+			return nil, err
+		}
+		rules = append(rules, *rule)
+	}
+	return rules, rows.Err()
+}
+
+// DueReminder is one reminder that should be delivered to one member over one
+// channel.
+type DueReminder struct {
+	Rule      domain.ReminderRule
+	Operation domain.PlannedOperation
+	Recipient domain.FamilyMember
+	Channel   string
+	Overdue   bool
+}
+
+// DueReminders returns the reminders to deliver at now. For every pending
+// planned operation only the most urgent rule whose time has come is used,
+// so a server that was down does not send a burst of stale reminders.
+// Operation rules replace the family rules for that operation. Recipients are
+// the author of the operation and, for shared accounts, the owner and adults.
+// Reminders that were already delivered are left out.
+func (s *Store) DueReminders(ctx context.Context, now time.Time) ([]DueReminder, error) {
+	rules, err := s.queryReminderRules(ctx, reminderRuleSelect)
+	if err != nil || len(rules) == 0 {
+		return nil, err
+	}
+	familyRules := make(map[string][]domain.ReminderRule)
+	operationRules := make(map[string][]domain.ReminderRule)
+	for _, rule := range rules {
+		if rule.PlannedOperationID != nil {
+			operationRules[*rule.PlannedOperationID] = append(operationRules[*rule.PlannedOperationID], rule)
+		} else {
+			familyRules[rule.FamilyID] = append(familyRules[rule.FamilyID], rule)
+		}
+	}
+
+	rows, err := s.db.QueryContext(ctx, plannedOperationSelect+` WHERE p.is_completed = ? AND p.due_at <= ? ORDER BY p.due_at`, false, now.AddDate(0, 0, MaxReminderOffsetDays+1).UTC())
+	if err != nil {
+		return nil, err
+	}
+	var ops []domain.PlannedOperation
+	for rows.Next() {
+		op, err := scanPlannedOperation(rows)
+		if err != nil {
+			rows.Close()
+			return nil, err
+		}
+		ops = append(ops, op.PlannedOperation)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+
+	locations := make(map[string]*time.Location)
+	members := make(map[string][]domain.FamilyMember)
+	var due []DueReminder
+	for _, op := range ops {
+		applicable := operationRules[op.ID]
+		if len(applicable) == 0 {
+			applicable = familyRules[op.FamilyID]
+		}
+		if len(applicable) == 0 {
+			continue
+		}
+		loc, ok := locations[op.FamilyID]
+		if !ok {
+			if loc, err = s.FamilyLocation(ctx, op.FamilyID); err != nil {
+				return nil, err
+			}
+			locations[op.FamilyID] = loc
+		}
+
+		var chosen *domain.ReminderRule
+		var chosenAt time.Time
+		for i := range applicable {
+			fireAt := op.DueAt.In(loc).AddDate(0, 0, -applicable[i].OffsetDays)
+			if fireAt.After(now) {
+				continue
+			}
+			if chosen == nil || fireAt.After(chosenAt) {
+				chosen, chosenAt = &applicable[i], fireAt
+			}
+		}
+		if chosen == nil {
+			continue
+		}
+
+		familyMembers, ok := members[op.FamilyID]
+		if !ok {
+			if familyMembers, err = s.ListFamilyMembers(ctx, op.FamilyID); err != nil {
+				return nil, err
+			}
+			members[op.FamilyID] = familyMembers
+		}
+		account, err := s.GetAccount(ctx, op.AccountID)
+		if err != nil {
+			return nil, err
+		}
+		shared := account != nil && account.IsShared
+
+		for _, member := range familyMembers {
+			role := strings.ToLower(member.Role)
+			if member.ID != op.UserID && !(shared && (role == "owner" || role == "adult")) {
+				continue
+			}
+			for _, channel := range chosen.Channels {
+				delivered, err := s.reminderDelivered(ctx, op.ID, op.DueAt, chosen.OffsetDays, member.ID, channel)
+				if err != nil {
+					return nil, err
+				}
+				if delivered {
+					continue
+				}
+				due = append(due, DueReminder{
+					Rule:      *chosen,
+					Operation: op,
+					Recipient: member,
+					Channel:   channel,
+					Overdue:   op.DueAt.Before(now),
+				})
+			}
+		}
+	}
+	return due, nil
+}
+
+func (s *Store) reminderDelivered(ctx context.Context, operationID string, dueAt time.Time, offsetDays int, userID, channel string) (bool, error) {
+	var count int
+	err := s.db.QueryRowContext(ctx, `SELECT COUNT(*) FROM reminder_deliveries WHERE planned_operation_id = ? AND due_at = ? AND offset_days = ? AND user_id = ? AND channel = ?`,
+		operationID, dueAt.UTC(), offsetDays, userID, channel).Scan(&count)
+	return count > 0, err
+}
+
+// ClaimReminderDelivery records that a reminder is being delivered. It returns
+// false when another worker already claimed it, which keeps members from
+// receiving duplicates.
+func (s *Store) ClaimReminderDelivery(ctx context.Context, reminder DueReminder, now time.Time) (bool, error) {
+	_, err := s.db.ExecContext(ctx, `INSERT INTO reminder_deliveries (planned_operation_id, due_at, offset_days, user_id, channel, sent_at) VALUES (?, ?, ?, ?, ?, ?)`,
+		reminder.Operation.ID, reminder.Operation.DueAt.UTC(), reminder.Rule.OffsetDays, reminder.Recipient.ID, reminder.Channel, now.UTC())
+	if err != nil {
+		delivered, checkErr := s.reminderDelivered(ctx, reminder.Operation.ID, reminder.Operation.DueAt, reminder.Rule.OffsetDays, reminder.Recipient.ID, reminder.Channel)
+		if checkErr == nil && delivered {
+			return false, nil
+		}
+		return false, err
+	}
+	return true, nil
+}
+
+// ReleaseReminderDelivery drops a claim after a failed delivery so that the
+// next run retries it.
+func (s *Store) ReleaseReminderDelivery(ctx context.Context, reminder DueReminder) error {
+	_, err := s.db.ExecContext(ctx, `DELETE FROM reminder_deliveries WHERE planned_operation_id = ? AND due_at = ? AND offset_days = ? AND user_id = ? AND channel = ?`,
+		reminder.Operation.ID, reminder.Operation.DueAt.UTC(), reminder.Rule.OffsetDays, reminder.Recipient.ID, reminder.Channel)
+	return err
+}
+
+const inboxSelect = `SELECT id, family_id, user_id, kind, subject, body, planned_operation_id, created_at, read_at FROM inbox_messages`
+
+func (s *Store) CreateInboxMessage(ctx context.Context, message *domain.InboxMessage) error {
+	if message.ID == "" {
+		message.ID = uuid.NewString()
+	}
+	_, err := s.db.ExecContext(ctx, `INSERT INTO inbox_messages (id, family_id, user_id, kind, subject, body, planned_operation_id, created_at) VALUES (?, ?, ?, ?, ?, ?, ?, ?)`,
+		message.ID, message.FamilyID, message.UserID, message.Kind, message.Subject, message.Body, message.PlannedOperationID, message.CreatedAt.UTC())
+	return err
+}
+
+// ListInboxMessages returns the inbox of a member, newest first.
+func (s *Store) ListInboxMessages(ctx context.Context, userID string, unreadOnly bool) ([]domain.InboxMessage, error) {
+	query := inboxSelect + ` WHERE user_id = ?`
+	if unreadOnly {
+		query += ` AND read_at IS NULL`
+	}
+	query += ` ORDER BY created_at DESC`
+
+	rows, err := s.db.QueryContext(ctx, query, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var messages []domain.InboxMessage
+	for rows.Next() {
+		var message domain.InboxMessage
+		var operationID sql.NullString
+		var readAt sql.NullTime
+		if err := rows.Scan(&message.ID, &message.FamilyID, &message.UserID, &message.Kind, &message.Subject, &message.Body, &operationID, &message.CreatedAt, &readAt); err != nil {
+			return nil, err
+		}
+		if operationID.Valid {
+			message.PlannedOperationID = &operationID.String
+		}
+		if readAt.Valid {
+			t := readAt.Time
+			message.ReadAt = &t
+		}
+		messages = append(messages, message)
+	}
+	return messages, rows.Err()
+}
+
+func (s *Store) MarkInboxMessageRead(ctx context.Context, userID, id string, now time.Time) error {
+	res, err := s.db.ExecContext(ctx, `UPDATE inbox_messages SET read_at = COALESCE(read_at, ?) WHERE id = ? AND user_id = ?`, now.UTC(), id, userID)
+	if err != nil {
+		return err
+	}
+	rows, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rows == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
@@ -0,0 +1,180 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"familybudget/internal/domain"
+)
+
+// deliveriesDriver keeps reminder_deliveries in memory and understands only
+// the statements of the claim path, enforcing the table's primary key.
+type deliveriesDriver struct {
+	mu   sync.Mutex
+	rows map[string]bool
+	fail error
+}
+
+func (d *deliveriesDriver) Open(string) (driver.Conn, error) {
+	return deliveriesConn{d}, nil
+}
+
+func (d *deliveriesDriver) Connect(context.Context) (driver.Conn, error) {
+	return deliveriesConn{d}, nil
+}
+
+func (d *deliveriesDriver) Driver() driver.Driver {
+	return d
+}
+
+type deliveriesConn struct{ d *deliveriesDriver }
+
+func (c deliveriesConn) Prepare(query string) (driver.Stmt, error) {
+	return deliveriesStmt{d: c.d, query: strings.TrimSpace(query)}, nil
+}
+
+func (c deliveriesConn) Close() error { return nil }
+func (c deliveriesConn) Begin() (driver.Tx, error) {
+	return nil, errors.New("transactions are not supported")
+}
+
+type deliveriesStmt struct {
+	d     *deliveriesDriver
+	query string
+}
+
+func (s deliveriesStmt) Close() error  { return nil }
+func (s deliveriesStmt) NumInput() int { return -1 }
+
+func deliveryKey(args []driver.Value) string {
+	parts := make([]string, len(args))
+	for i, arg := range args {
+		if at, ok := arg.(time.Time); ok {
+			arg = at.UTC().Format(time.RFC3339Nano)
+		}
+		parts[i] = fmt.Sprint(arg)
+	}
+	return strings.Join(parts, "|")
+}
+
+func (s deliveriesStmt) Exec(args []driver.Value) (driver.Result, error) {
+	s.d.mu.Lock()
+	defer s.d.mu.Unlock()
+	if s.d.fail != nil {
+		return nil, s.d.fail
+	}
+	switch {
+	case strings.HasPrefix(s.query, "INSERT INTO reminder_deliveries"):
+		key := deliveryKey(args[:5])
+		if s.d.rows[key] {
+			return nil, errors.New("UNIQUE constraint failed: reminder_deliveries")
+		}
+		s.d.rows[key] = true
+	case strings.HasPrefix(s.query, "DELETE FROM reminder_deliveries"):
+		delete(s.d.rows, deliveryKey(args))
+	default:
+		return nil, fmt.Errorf("unexpected statement %q", s.query)
+	}
+	return driver.RowsAffected(1), nil
+}
+
+func (s deliveriesStmt) Query(args []driver.Value) (driver.Rows, error) {
+	s.d.mu.Lock()
+	defer s.d.mu.Unlock()
+	if s.d.fail != nil {
+		return nil, s.d.fail
+	}
+	if !strings.HasPrefix(s.query, "SELECT COUNT(*) FROM reminder_deliveries") {
+		return nil, fmt.Errorf("unexpected query %q", s.query)
+	}
+	count := int64(0)
+	if s.d.rows[deliveryKey(args)] {
+		count = 1
+	}
+	return &countRows{count: count}, nil
+}
+
+type countRows struct {
+	count int64
+	done  bool
+}
+
+func (r *countRows) Columns() []string { return []string{"count"} }
+func (r *countRows) Close() error      { return nil }
+
+func (r *countRows) Next(dest []driver.Value) error {
+	if r.done {
+		return io.EOF
+	}
+	r.done = true
+	dest[0] = r.count
+	return nil
+}
+
+func TestReminderDeliveryClaims(t *testing.T) {
+	fake := &deliveriesDriver{rows: make(map[string]bool)}
+	db := sql.OpenDB(fake)
+	defer db.Close()
+	st := New(db)
+	ctx := context.Background()
+	now := time.Date(2026, time.March, 1, 9, 0, 0, 0, time.UTC)
+
+	reminder := DueReminder{
+		Rule:      domain.ReminderRule{OffsetDays: 1},
+		Operation: domain.PlannedOperation{ID: "rent", DueAt: now.AddDate(0, 0, 1)},
+		Recipient: domain.FamilyMember{ID: "parent"},
+		Channel:   "email",
+	}
+	claim := func(reminder DueReminder) bool {
+		t.Helper()
+		claimed, err := st.ClaimReminderDelivery(ctx, reminder, now)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return claimed
+	}
+
+	if !claim(reminder) {
+		t.Fatal("the first claim failed")
+	}
+	if claim(reminder) {
+		t.Fatal("the reminder was claimed twice")
+	}
+	delivered, err := st.reminderDelivered(ctx, "rent", reminder.Operation.DueAt, 1, "parent", "email")
+	if err != nil || !delivered {
+		t.Fatalf("got delivered %v, error %v", delivered, err)
+	}
+
+	inbox := reminder
+	inbox.Channel = "inbox"
+	if !claim(inbox) {
+		t.Fatal("another channel shares the claim")
+	}
+	earlier := reminder
+	earlier.Rule.OffsetDays = 3
+	if !claim(earlier) {
+		t.Fatal("another offset shares the claim")
+	}
+
+	if err := st.ReleaseReminderDelivery(ctx, reminder); err != nil {
+		t.Fatal(err)
+	}
+	if !claim(reminder) {
+		t.Fatal("a released reminder was not claimed again")
+	}
+
+	child := reminder
+	child.Recipient.ID = "child"
+	fake.fail = errors.New("database is locked")
+	if _, err := st.ClaimReminderDelivery(ctx, child, now); err == nil {
+		t.Fatal("a failed insert was reported as a duplicate")
+	}
+}
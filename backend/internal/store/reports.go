@@ -0,0 +1,915 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"time"
+
+	"familybudget/internal/domain"
+)
+
+// Bucket sizes of time-series reports.
+const (
+	ReportIntervalDay     = "day"
+	ReportIntervalWeek    = "week"
+	ReportIntervalMonth   = "month"
+	ReportIntervalQuarter = "quarter"
+	ReportIntervalYear    = "year"
+)
+
+// Dimensions a report can be split by.
+const (
+	ReportSplitCategory = "category"
+	ReportSplitAccount  = "account"
+	ReportSplitMember   = "member"
+)
+
+// Amounts a report can use for transactions paid in another currency than
+// their account's: the amount charged to the account or the amount paid.
+const (
+	ReportAmountsAccount  = "account"
+	ReportAmountsOriginal = "original"
+)
+
+// reportAmountColumns returns the SQL expressions of the amount and currency
+// of transaction t in the given amounts mode.
+func reportAmountColumns(amounts string) (string, string) {
+	if amounts == ReportAmountsOriginal {
+		return "COALESCE(t.original_amount_minor, t.amount_minor)", "COALESCE(t.original_currency, t.currency)"
+	}
+	return "t.amount_minor", "t.currency"
+}
+
+// MaxReportBuckets bounds the size of a time-series report.
+const MaxReportBuckets = 1000
+
+var ErrReportTooLarge = fmt.Errorf("report has more than %d buckets", MaxReportBuckets)
+
+// ValidReportInterval reports whether interval is a known bucket size.
+func ValidReportInterval(interval string) bool {
+	switch interval {
+	case ReportIntervalDay, ReportIntervalWeek, ReportIntervalMonth, ReportIntervalQuarter, ReportIntervalYear:
+		return true
+	}
+	return false
+}
+
+// ValidReportSplit reports whether split is a known report dimension.
+func ValidReportSplit(split string) bool {
+	switch split {
+	case ReportSplitCategory, ReportSplitAccount, ReportSplitMember:
+		return true
+	}
+	return false
+}
+
+// ReportBucketStart returns the start of the bucket containing t, in the
+// location of t.
+func ReportBucketStart(t time.Time, interval string, weekStart time.Weekday) time.Time {
+	loc := t.Location()
+	switch interval {
+	case ReportIntervalWeek:
+		day := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, loc)
+		back := (int(day.Weekday()) - int(weekStart) + 7) % 7
+		return day.AddDate(0, 0, -back)
+	case ReportIntervalMonth:
+		return time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, loc)
+	case ReportIntervalQuarter:
+		return time.Date(t.Year(), (t.Month()-1)/3*3+1, 1, 0, 0, 0, 0, loc)
+	case ReportIntervalYear:
+		return time.Date(t.Year(), time.January, 1, 0, 0, 0, 0, loc)
+	default:
+		return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, loc)
+	}
+}
+
+// nextReportBucket returns the start of the bucket after the one starting at
+// start.
+func nextReportBucket(start time.Time, interval string) time.Time {
+	switch interval {
+	case ReportIntervalWeek:
+		return start.AddDate(0, 0, 7)
+	case ReportIntervalMonth:
+		return start.AddDate(0, 1, 0)
+	case ReportIntervalQuarter:
+		return start.AddDate(0, 3, 0)
+	case ReportIntervalYear:
+		return start.AddDate(1, 0, 0)
+	default:
+		return start.AddDate(0, 0, 1)
+	}
+}
+
+func reportBucketLabel(start time.Time, interval string, weekStart time.Weekday) string {
+	switch interval {
+	case ReportIntervalWeek:
+		if weekStart == time.Monday {
+			year, week := start.ISOWeek()
+			return fmt.Sprintf("%04d-W%02d", year, week)
+		}
+		return start.Format("2006-01-02")
+	case ReportIntervalMonth:
+		return start.Format("2006-01")
+	case ReportIntervalQuarter:
+		return fmt.Sprintf("%04d-Q%d", start.Year(), (int(start.Month())-1)/3+1)
+	case ReportIntervalYear:
+		return start.Format("2006")
+	default:
+		return start.Format("2006-01-02")
+	}
+}
+
+// reportTransaction is the part of a transaction that reports aggregate.
+type reportTransaction struct {
+	Type        string
+	AmountMinor int64
+	Currency    string
+	OccurredAt  time.Time
+	CategoryID  string
+	AccountID   string
+	UserID      string
+	// BaseMinor is the stored amount in the family currency, if known.
+	BaseMinor sql.NullInt64
+}
+
+// convertTransaction returns txn's amount in the family currency: the amount
+// stored with the transaction or, while none is stored yet, the amount
+// converted at the rate of its date.
+func (c *RateConverter) convertTransaction(ctx context.Context, txn reportTransaction) (int64, bool, error) {
+	if txn.BaseMinor.Valid {
+		return txn.BaseMinor.Int64, true, nil
+	}
+	return c.Convert(ctx, txn.Currency, txn.AmountMinor, txn.OccurredAt)
+}
+
+// reportTransactions returns the transactions of a family that occurred in
+// [start, end).
+func (s *Store) reportTransactions(ctx context.Context, familyID string, start, end time.Time, amounts string) ([]reportTransaction, error) {
+	amount, currency := reportAmountColumns(amounts)
+	rows, err := s.db.QueryContext(ctx, `SELECT t.type, `+amount+`, `+currency+`, t.occurred_at, t.category_id, t.account_id, t.user_id, t.amount_base_minor
+FROM transactions t
+WHERE t.family_id = ? AND t.occurred_at >= ? AND t.occurred_at < ?
+ORDER BY t.occurred_at`, familyID, start.UTC(), end.UTC())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var txns []reportTransaction
+	for rows.Next() {
+		var txn reportTransaction
+		if err := rows.Scan(&txn.Type, &txn.AmountMinor, &txn.Currency, &txn.OccurredAt, &txn.CategoryID, &txn.AccountID, &txn.UserID, &txn.BaseMinor); err != nil {
+			return nil, err
+		}
+		txn.Type = strings.ToLower(txn.Type)
+		txns = append(txns, txn)
+	}
+	return txns, rows.Err()
+}
+
+// reportDimension returns the key of txn in a split and a function naming
+// the keys of that split.
+func (s *Store) reportDimension(ctx context.Context, familyID, split string) (func(reportTransaction) string, map[string]string, error) {
+	names := make(map[string]string)
+	switch split {
+	case ReportSplitCategory:
+		categories, err := s.ListCategoriesByFamily(ctx, familyID)
+		if err != nil {
+			return nil, nil, err
+		}
+		for _, category := range categories {
+			names[category.ID] = category.Name
+		}
+		return func(txn reportTransaction) string { return txn.CategoryID }, names, nil
+	case ReportSplitAccount:
+		accounts, err := s.ListAccountsByFamily(ctx, familyID)
+		if err != nil {
+			return nil, nil, err
+		}
+		for _, account := range accounts {
+			names[account.ID] = account.Name
+		}
+		return func(txn reportTransaction) string { return txn.AccountID }, names, nil
+	case ReportSplitMember:
+		members, err := s.ListFamilyMembers(ctx, familyID)
+		if err != nil {
+			return nil, nil, err
+		}
+		for _, member := range members {
+			names[member.ID] = member.Name
+		}
+		return func(txn reportTransaction) string { return txn.UserID }, names, nil
+	}
+	return nil, nil, errors.New("unknown report split " + split)
+}
+
+// TimeSeriesOptions configure a time-series report. The period [Start, End)
+// is widened to whole buckets in Location.
+type TimeSeriesOptions struct {
+	Start     time.Time
+	End       time.Time
+	Interval  string
+	SplitBy   string
+	Location  *time.Location
+	WeekStart time.Weekday
+	// Converter adds family-currency amounts to every bucket and group.
+	Converter *RateConverter
+	// Amounts is ReportAmountsAccount or ReportAmountsOriginal, as in
+	// ReportOptions.
+	Amounts string
+}
+
+// TimeSeriesReport returns income, expense and net per bucket and currency,
+// optionally split by category, account or member.
+func (s *Store) TimeSeriesReport(ctx context.Context, familyID string, opts TimeSeriesOptions) (*domain.TimeSeriesReport, error) {
+	loc := opts.Location
+	if loc == nil {
+		loc = time.UTC
+	}
+	first := ReportBucketStart(opts.Start.In(loc), opts.Interval, opts.WeekStart)
+	var starts []time.Time
+	for start := first; start.Before(opts.End); start = nextReportBucket(start, opts.Interval) {
+		if len(starts) == MaxReportBuckets {
+			return nil, ErrReportTooLarge
+		}
+		starts = append(starts, start)
+	}
+	if len(starts) == 0 {
+		starts = append(starts, first)
+	}
+	end := nextReportBucket(starts[len(starts)-1], opts.Interval)
+
+	txns, err := s.reportTransactions(ctx, familyID, first, end, opts.Amounts)
+	if err != nil {
+		return nil, err
+	}
+	var keyOf func(reportTransaction) string
+	var names map[string]string
+	if opts.SplitBy != "" {
+		if keyOf, names, err = s.reportDimension(ctx, familyID, opts.SplitBy); err != nil {
+			return nil, err
+		}
+	}
+
+	// Sums per bucket, group ("" for the bucket total) and currency; amounts
+	// converted into the family currency are kept under baseKey.
+	const baseKey = "\x00base"
+	type cell struct {
+		income, expense int64
+		unconverted     bool
+	}
+	sums := make([]map[string]map[string]*cell, len(starts))
+	for i := range sums {
+		sums[i] = make(map[string]map[string]*cell)
+	}
+	currencies := make(map[string]bool)
+	groups := make(map[string]bool)
+	index := 0
+	add := func(bucket int, group, currency, txnType string, amount int64, converted bool) {
+		byCurrency, ok := sums[bucket][group]
+		if !ok {
+			byCurrency = make(map[string]*cell)
+			sums[bucket][group] = byCurrency
+		}
+		c, ok := byCurrency[currency]
+		if !ok {
+			c = &cell{}
+			byCurrency[currency] = c
+		}
+		if !converted {
+			c.unconverted = true
+		} else if txnType == "income" {
+			c.income += amount
+		} else {
+			c.expense += amount
+		}
+	}
+	for _, txn := range txns {
+		at := txn.OccurredAt.In(loc)
+		for index+1 < len(starts) && !at.Before(starts[index+1]) {
+			index++
+		}
+		var key string
+		if keyOf != nil {
+			key = keyOf(txn)
+			groups[key] = true
+		}
+		currencies[txn.Currency] = true
+		add(index, "", txn.Currency, txn.Type, txn.AmountMinor, true)
+		if keyOf != nil {
+			add(index, key, txn.Currency, txn.Type, txn.AmountMinor, true)
+		}
+		if opts.Converter != nil {
+			base, ok, err := opts.Converter.convertTransaction(ctx, txn)
+			if err != nil {
+				return nil, err
+			}
+			add(index, "", baseKey, txn.Type, base, ok)
+			if keyOf != nil {
+				add(index, key, baseKey, txn.Type, base, ok)
+			}
+		}
+	}
+
+	currencyList := sortedMapKeys(currencies)
+	groupList := sortedMapKeys(groups)
+	sort.SliceStable(groupList, func(i, j int) bool { return names[groupList[i]] < names[groupList[j]] })
+	amounts := func(bucket int, group string) []domain.SeriesAmount {
+		result := make([]domain.SeriesAmount, 0, len(currencyList))
+		for _, currency := range currencyList {
+			amount := domain.SeriesAmount{Currency: currency}
+			if c, ok := sums[bucket][group][currency]; ok {
+				amount.IncomeMinor, amount.ExpenseMinor = c.income, c.expense
+				amount.NetMinor = c.income - c.expense
+			}
+			result = append(result, amount)
+		}
+		return result
+	}
+	baseAmount := func(bucket int, group string) *domain.SeriesAmount {
+		if opts.Converter == nil {
+			return nil
+		}
+		amount := &domain.SeriesAmount{Currency: opts.Converter.Currency()}
+		if c, ok := sums[bucket][group][baseKey]; ok {
+			amount.IncomeMinor, amount.ExpenseMinor = c.income, c.expense
+			amount.NetMinor = c.income - c.expense
+			amount.Unconverted = c.unconverted
+		}
+		return amount
+	}
+
+	weekStart := strings.ToLower(opts.WeekStart.String())
+	lastDay := end.Add(-time.Nanosecond)
+	report := &domain.TimeSeriesReport{
+		Interval:  opts.Interval,
+		SplitBy:   opts.SplitBy,
+		Timezone:  loc.String(),
+		WeekStart: weekStart,
+		Period:    domain.ReportPeriod{Start: &first, End: &lastDay},
+		Buckets:   make([]domain.SeriesBucket, 0, len(starts)),
+	}
+	for i, start := range starts {
+		bucket := domain.SeriesBucket{
+			Label:  reportBucketLabel(start, opts.Interval, opts.WeekStart),
+			Start:  start,
+			End:    nextReportBucket(start, opts.Interval),
+			Totals: amounts(i, ""),
+			Base:   baseAmount(i, ""),
+		}
+		if keyOf != nil {
+			bucket.Groups = make([]domain.SeriesGroup, 0, len(groupList))
+			for _, key := range groupList {
+				bucket.Groups = append(bucket.Groups, domain.SeriesGroup{Key: key, Name: names[key], Totals: amounts(i, key), Base: baseAmount(i, key)})
+			}
+		}
+		report.Buckets = append(report.Buckets, bucket)
+	}
+	return report, nil
+}
+
+// Periods a reports overview can be compared with.
+const (
+	ReportComparePrevious = "previous"
+	ReportCompareYearAgo  = "year_ago"
+)
+
+// Presence of a category in compared periods.
+const (
+	PresenceBoth         = "both"
+	PresenceCurrentOnly  = "current_only"
+	PresencePreviousOnly = "previous_only"
+)
+
+// ComparisonPeriod returns the period that [start, end] is compared with:
+// the same period a year earlier, or the period of the same length right
+// before it. A period of whole calendar months in loc is compared with as
+// many whole months, so March follows February rather than 31 days.
+func ComparisonPeriod(mode string, start, end time.Time, loc *time.Location) (time.Time, time.Time) {
+	start = start.In(loc)
+	// end is inclusive; after is the first second past it.
+	after := end.Truncate(time.Second).Add(time.Second).In(loc)
+	slack := after.Sub(end)
+
+	var from, to time.Time
+	switch {
+	case mode == ReportCompareYearAgo:
+		from, to = start.AddDate(-1, 0, 0), after.AddDate(-1, 0, 0)
+	case isMonthStart(start) && isMonthStart(after):
+		months := (after.Year()-start.Year())*12 + int(after.Month()) - int(start.Month())
+		from, to = start.AddDate(0, -months, 0), start
+	default:
+		from, to = start.Add(-after.Sub(start)), start
+	}
+	return from, to.Add(-slack)
+}
+
+func isMonthStart(t time.Time) bool {
+	return t.Day() == 1 && t.Hour() == 0 && t.Minute() == 0 && t.Second() == 0 && t.Nanosecond() == 0
+}
+
+// CompareReports adds the overview of the period selected by mode to
+// overview, built with opts, with deltas of the current period against it.
+func (s *Store) CompareReports(ctx context.Context, familyID string, overview *domain.ReportsOverview, opts ReportOptions, mode string, loc *time.Location) error {
+	if opts.Start == nil || opts.End == nil {
+		return errors.New("comparison needs a bounded period")
+	}
+	start, end := ComparisonPeriod(mode, *opts.Start, *opts.End, loc)
+	start, end = start.UTC(), end.UTC()
+	opts.Start, opts.End = &start, &end
+	expenses, err := s.movementReport(ctx, familyID, "expense", opts)
+	if err != nil {
+		return err
+	}
+	incomes, err := s.movementReport(ctx, familyID, "income", opts)
+	if err != nil {
+		return err
+	}
+	overview.Comparisons = append(overview.Comparisons, domain.ReportComparison{
+		Mode:          mode,
+		Period:        domain.ReportPeriod{Start: &start, End: &end},
+		Expenses:      expenses,
+		Incomes:       incomes,
+		ExpenseDeltas: compareMovement(overview.Expenses, expenses),
+		IncomeDeltas:  compareMovement(overview.Incomes, incomes),
+	})
+	return nil
+}
+
+func amountDelta(currency string, current, previous int64) domain.AmountDelta {
+	delta := domain.AmountDelta{
+		Currency:      currency,
+		CurrentMinor:  current,
+		PreviousMinor: previous,
+		DeltaMinor:    current - previous,
+	}
+	if previous != 0 {
+		percent := math.Round(float64(delta.DeltaMinor)*1000/float64(previous)) / 10
+		delta.DeltaPercent = &percent
+	}
+	return delta
+}
+
+func compareMovement(current, previous domain.MovementReport) domain.MovementDelta {
+	result := domain.MovementDelta{
+		Totals:         []domain.AmountDelta{},
+		ByCategory:     []domain.CategoryDelta{},
+		OnlyInCurrent:  []domain.CategoryReportItem{},
+		OnlyInPrevious: []domain.CategoryReportItem{},
+	}
+
+	totals := make(map[string][2]int64)
+	for _, total := range current.Totals {
+		sums := totals[total.Currency]
+		sums[0] += total.AmountMinor
+		totals[total.Currency] = sums
+	}
+	for _, total := range previous.Totals {
+		sums := totals[total.Currency]
+		sums[1] += total.AmountMinor
+		totals[total.Currency] = sums
+	}
+	for _, currency := range sortedMapKeys(totals) {
+		result.Totals = append(result.Totals, amountDelta(currency, totals[currency][0], totals[currency][1]))
+	}
+	if current.BaseTotal != nil && previous.BaseTotal != nil {
+		delta := amountDelta(current.BaseTotal.Currency, current.BaseTotal.AmountMinor, previous.BaseTotal.AmountMinor)
+		result.BaseTotal = &delta
+	}
+
+	type categoryKey struct{ id, currency string }
+	items := make(map[categoryKey]*domain.CategoryDelta)
+	var order []categoryKey
+	inCurrent := make(map[string]bool)
+	inPrevious := make(map[string]bool)
+	entry := func(item domain.CategoryReportItem) *domain.CategoryDelta {
+		key := categoryKey{item.CategoryID, item.Currency}
+		delta, ok := items[key]
+		if !ok {
+			delta = &domain.CategoryDelta{CategoryID: item.CategoryID, CategoryName: item.CategoryName}
+			delta.Currency = item.Currency
+			items[key] = delta
+			order = append(order, key)
+		}
+		return delta
+	}
+	for _, item := range current.ByCategory {
+		entry(item).CurrentMinor += item.AmountMinor
+		inCurrent[item.CategoryID] = true
+	}
+	for _, item := range previous.ByCategory {
+		entry(item).PreviousMinor += item.AmountMinor
+		inPrevious[item.CategoryID] = true
+	}
+	for _, key := range order {
+		delta := items[key]
+		delta.AmountDelta = amountDelta(delta.Currency, delta.CurrentMinor, delta.PreviousMinor)
+		switch {
+		case !inPrevious[delta.CategoryID]:
+			delta.Presence = PresenceCurrentOnly
+		case !inCurrent[delta.CategoryID]:
+			delta.Presence = PresencePreviousOnly
+		default:
+			delta.Presence = PresenceBoth
+		}
+		result.ByCategory = append(result.ByCategory, *delta)
+	}
+	sort.SliceStable(result.ByCategory, func(i, j int) bool {
+		a, b := result.ByCategory[i], result.ByCategory[j]
+		if a.CurrentMinor != b.CurrentMinor {
+			return a.CurrentMinor > b.CurrentMinor
+		}
+		return a.PreviousMinor > b.PreviousMinor
+	})
+
+	for _, item := range current.ByCategory {
+		if !inPrevious[item.CategoryID] {
+			result.OnlyInCurrent = append(result.OnlyInCurrent, item)
+		}
+	}
+	for _, item := range previous.ByCategory {
+		if !inCurrent[item.CategoryID] {
+			result.OnlyInPrevious = append(result.OnlyInPrevious, item)
+		}
+	}
+	return result
+}
+
+func sortedMapKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// maxCategoryDepth guards the ancestry query against parent cycles.
+const maxCategoryDepth = 32
+
+// reportCategoryTree rolls the activity of txnType up the category
+// hierarchy. A recursive query pairs every category with its ancestors, so
+// each ancestor sums the transactions of its whole subtree. Only categories
+// with activity in their subtree are returned, archived ones included.
+func (s *Store) reportCategoryTree(ctx context.Context, familyID, txnType string, opts ReportOptions) ([]domain.CategoryTreeNode, error) {
+	amount, currency := reportAmountColumns(opts.Amounts)
+	query := `WITH RECURSIVE ancestry(category_id, ancestor_id, depth) AS (
+    SELECT id, id, 0 FROM categories WHERE family_id = ?
+    UNION ALL
+    SELECT a.category_id, c.parent_id, a.depth + 1
+    FROM ancestry a
+    JOIN categories c ON c.id = a.ancestor_id
+    WHERE c.parent_id IS NOT NULL AND a.depth < ?
+)
+SELECT a.ancestor_id, ` + currency + `,
+    SUM(CASE WHEN a.depth = 0 THEN ` + amount + ` ELSE 0 END) AS own,
+    SUM(` + amount + `) AS total
+FROM transactions t
+JOIN ancestry a ON a.category_id = t.category_id
+WHERE t.family_id = ? AND LOWER(t.type) = ?`
+	args := []interface{}{familyID, maxCategoryDepth, familyID, strings.ToLower(txnType)}
+	if opts.Start != nil {
+		query += " AND t.occurred_at >= ?"
+		args = append(args, opts.Start.UTC())
+	}
+	if opts.End != nil {
+		query += " AND t.occurred_at <= ?"
+		args = append(args, opts.End.UTC())
+	}
+	query += " GROUP BY a.ancestor_id, " + currency
+
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	amounts := make(map[string][]domain.CategoryNodeAmount)
+	for rows.Next() {
+		var categoryID string
+		var amount domain.CategoryNodeAmount
+		if err := rows.Scan(&categoryID, &amount.Currency, &amount.OwnMinor, &amount.TotalMinor); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		amounts[categoryID] = append(amounts[categoryID], amount)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if len(amounts) == 0 {
+		return []domain.CategoryTreeNode{}, nil
+	}
+
+	categories, err := s.ListCategoriesByFamily(ctx, familyID)
+	if err != nil {
+		return nil, err
+	}
+	byID := make(map[string]domain.Category, len(categories))
+	children := make(map[string][]string)
+	var roots []string
+	for _, category := range categories {
+		if _, ok := amounts[category.ID]; !ok {
+			continue
+		}
+		byID[category.ID] = category
+	}
+	for _, category := range categories {
+		if _, ok := byID[category.ID]; !ok {
+			continue
+		}
+		if category.ParentID != nil {
+			if _, ok := byID[*category.ParentID]; ok && *category.ParentID != category.ID {
+				children[*category.ParentID] = append(children[*category.ParentID], category.ID)
+				continue
+			}
+		}
+		roots = append(roots, category.ID)
+	}
+
+	var build func(ids []string, depth int, seen map[string]bool) []domain.CategoryTreeNode
+	build = func(ids []string, depth int, seen map[string]bool) []domain.CategoryTreeNode {
+		nodes := make([]domain.CategoryTreeNode, 0, len(ids))
+		for _, id := range ids {
+			if seen[id] {
+				continue
+			}
+			seen[id] = true
+			category := byID[id]
+			nodeAmounts := amounts[id]
+			sort.Slice(nodeAmounts, func(i, j int) bool { return nodeAmounts[i].Currency < nodeAmounts[j].Currency })
+			node := domain.CategoryTreeNode{
+				CategoryID:    category.ID,
+				CategoryName:  category.Name,
+				CategoryColor: category.Color,
+				ParentID:      category.ParentID,
+				IsArchived:    category.IsArchived,
+				Depth:         depth,
+				Amounts:       nodeAmounts,
+			}
+			if len(children[id]) > 0 {
+				if opts.TreeDepth > 0 && depth >= opts.TreeDepth {
+					node.Collapsed = true
+				} else {
+					node.Children = build(children[id], depth+1, seen)
+				}
+			}
+			nodes = append(nodes, node)
+		}
+		sort.SliceStable(nodes, func(i, j int) bool {
+			a, b := nodeTotal(nodes[i]), nodeTotal(nodes[j])
+			if a != b {
+				return a > b
+			}
+			return nodes[i].CategoryName < nodes[j].CategoryName
+		})
+		return nodes
+	}
+	seen := make(map[string]bool)
+	tree := build(roots, 1, seen)
+	// Categories caught in a parent cycle have no root; list them at the top.
+	for _, category := range categories {
+		if _, ok := byID[category.ID]; ok && !seen[category.ID] {
+			tree = append(tree, build([]string{category.ID}, 1, seen)...)
+		}
+	}
+	return tree, nil
+}
+
+// nodeTotal orders tree nodes; amounts in different currencies are simply
+// added up, which is good enough for sorting.
+func nodeTotal(node domain.CategoryTreeNode) int64 {
+	var total int64
+	for _, amount := range node.Amounts {
+		total += amount.TotalMinor
+	}
+	return total
+}
+
+// reportColumns maps report dimensions to transaction columns.
+var reportColumns = map[string]string{
+	ReportSplitCategory: "t.category_id",
+	ReportSplitAccount:  "t.account_id",
+	ReportSplitMember:   "t.user_id",
+}
+
+// reportCrossTab sums txnType by the two dimensions requested in opts.
+func (s *Store) reportCrossTab(ctx context.Context, familyID, txnType string, opts ReportOptions) (*domain.CrossTabReport, error) {
+	amount, currency := reportAmountColumns(opts.Amounts)
+	rowColumn, ok := reportColumns[opts.CrossTabRows]
+	if !ok {
+		return nil, errors.New("unknown report dimension " + opts.CrossTabRows)
+	}
+	colColumn, ok := reportColumns[opts.CrossTabColumns]
+	if !ok {
+		return nil, errors.New("unknown report dimension " + opts.CrossTabColumns)
+	}
+	query := `SELECT ` + rowColumn + `, ` + colColumn + `, ` + currency + `, SUM(` + amount + `) AS total
+FROM transactions t
+WHERE t.family_id = ? AND LOWER(t.type) = ?`
+	args := []interface{}{familyID, strings.ToLower(txnType)}
+	if opts.Start != nil {
+		query += " AND t.occurred_at >= ?"
+		args = append(args, opts.Start.UTC())
+	}
+	if opts.End != nil {
+		query += " AND t.occurred_at <= ?"
+		args = append(args, opts.End.UTC())
+	}
+	query += " GROUP BY " + rowColumn + ", " + colColumn + ", " + currency + " ORDER BY total DESC"
+
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	report := &domain.CrossTabReport{
+		Rows:    opts.CrossTabRows,
+		Columns: opts.CrossTabColumns,
+		RowKeys: []domain.CrossTabHeader{},
+		ColKeys: []domain.CrossTabHeader{},
+		Cells:   []domain.CrossTabCell{},
+	}
+	rowSeen := make(map[string]bool)
+	colSeen := make(map[string]bool)
+	for rows.Next() {
+		var cell domain.CrossTabCell
+		if err := rows.Scan(&cell.Row, &cell.Column, &cell.Currency, &cell.AmountMinor); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		report.Cells = append(report.Cells, cell)
+		rowSeen[cell.Row] = true
+		colSeen[cell.Column] = true
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+
+	_, rowNames, err := s.reportDimension(ctx, familyID, opts.CrossTabRows)
+	if err != nil {
+		return nil, err
+	}
+	colNames := rowNames
+	if opts.CrossTabColumns != opts.CrossTabRows {
+		if _, colNames, err = s.reportDimension(ctx, familyID, opts.CrossTabColumns); err != nil {
+			return nil, err
+		}
+	}
+	headers := func(seen map[string]bool, names map[string]string) []domain.CrossTabHeader {
+		result := make([]domain.CrossTabHeader, 0, len(seen))
+		for _, key := range sortedMapKeys(seen) {
+			result = append(result, domain.CrossTabHeader{Key: key, Name: names[key]})
+		}
+		sort.SliceStable(result, func(i, j int) bool { return result[i].Name < result[j].Name })
+		return result
+	}
+	report.RowKeys = headers(rowSeen, rowNames)
+	report.ColKeys = headers(colSeen, colNames)
+	return report, nil
+}
+
+// reportKey returns the key of txn in a report dimension.
+func reportKey(dimension string, txn reportTransaction) string {
+	switch dimension {
+	case ReportSplitAccount:
+		return txn.AccountID
+	case ReportSplitMember:
+		return txn.UserID
+	default:
+		return txn.CategoryID
+	}
+}
+
+// baseSum accumulates converted amounts of one report item.
+type baseSum struct {
+	amount      int64
+	unconverted bool
+}
+
+func (b *baseSum) value() domain.BaseAmount {
+	if b == nil {
+		zero := int64(0)
+		return domain.BaseAmount{BaseAmountMinor: &zero}
+	}
+	amount := b.amount
+	return domain.BaseAmount{BaseAmountMinor: &amount, Unconverted: b.unconverted}
+}
+
+// convertMovementReport fills the family-currency amounts of report by
+// converting every transaction of txnType in the period at the rate of its
+// own date, then summing per item.
+func (s *Store) convertMovementReport(ctx context.Context, familyID, txnType string, opts ReportOptions, report *domain.MovementReport) error {
+	amount, currency := reportAmountColumns(opts.Amounts)
+	query := `SELECT t.type, ` + amount + `, ` + currency + `, t.occurred_at, t.category_id, t.account_id, t.user_id, t.amount_base_minor
+FROM transactions t
+WHERE t.family_id = ? AND LOWER(t.type) = ?`
+	args := []interface{}{familyID, strings.ToLower(txnType)}
+	if opts.Start != nil {
+		query += " AND t.occurred_at >= ?"
+		args = append(args, opts.Start.UTC())
+	}
+	if opts.End != nil {
+		query += " AND t.occurred_at <= ?"
+		args = append(args, opts.End.UTC())
+	}
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return err
+	}
+	var txns []reportTransaction
+	for rows.Next() {
+		var txn reportTransaction
+		if err := rows.Scan(&txn.Type, &txn.AmountMinor, &txn.Currency, &txn.OccurredAt, &txn.CategoryID, &txn.AccountID, &txn.UserID, &txn.BaseMinor); err != nil {
+			rows.Close()
+			return err
+		}
+		txns = append(txns, txn)
+	}
+	if err := rows.Close(); err != nil {
+		return err
+	}
+
+	categories, err := s.ListCategoriesByFamily(ctx, familyID)
+	if err != nil {
+		return err
+	}
+	parents := make(map[string]string, len(categories))
+	for _, category := range categories {
+		if category.ParentID != nil {
+			parents[category.ID] = *category.ParentID
+		}
+	}
+
+	sums := make(map[string]*baseSum)
+	add := func(key string, amount int64, ok bool) {
+		sum, exists := sums[key]
+		if !exists {
+			sum = &baseSum{}
+			sums[key] = sum
+		}
+		if ok {
+			sum.amount += amount
+		} else {
+			sum.unconverted = true
+		}
+	}
+	for _, txn := range txns {
+		base, ok, err := opts.Converter.convertTransaction(ctx, txn)
+		if err != nil {
+			return err
+		}
+		add("all", base, ok)
+		add("total|"+txn.Currency, base, ok)
+		add("category|"+txn.CategoryID+"|"+txn.Currency, base, ok)
+		add("member|"+txn.UserID+"|"+txn.Currency, base, ok)
+		add("account|"+txn.AccountID+"|"+txn.Currency, base, ok)
+		if opts.CrossTabRows != "" {
+			add("cross|"+reportKey(opts.CrossTabRows, txn)+"|"+reportKey(opts.CrossTabColumns, txn)+"|"+txn.Currency, base, ok)
+		}
+		ancestor := txn.CategoryID
+		for depth := 0; ancestor != "" && depth <= maxCategoryDepth; depth++ {
+			add("tree|"+ancestor+"|"+txn.Currency, base, ok)
+			ancestor = parents[ancestor]
+		}
+	}
+
+	for i := range report.Totals {
+		report.Totals[i].BaseAmount = sums["total|"+report.Totals[i].Currency].value()
+	}
+	for i := range report.ByCategory {
+		item := &report.ByCategory[i]
+		item.BaseAmount = sums["category|"+item.CategoryID+"|"+item.Currency].value()
+	}
+	for i := range report.ByMember {
+		item := &report.ByMember[i]
+		item.BaseAmount = sums["member|"+item.UserID+"|"+item.Currency].value()
+	}
+	for i := range report.ByAccount {
+		item := &report.ByAccount[i]
+		item.BaseAmount = sums["account|"+item.AccountID+"|"+item.Currency].value()
+	}
+	if report.CrossTab != nil {
+		for i := range report.CrossTab.Cells {
+			cell := &report.CrossTab.Cells[i]
+			cell.BaseAmount = sums["cross|"+cell.Row+"|"+cell.Column+"|"+cell.Currency].value()
+		}
+	}
+	var convertTree func(nodes []domain.CategoryTreeNode)
+	convertTree = func(nodes []domain.CategoryTreeNode) {
+		for i := range nodes {
+			for j := range nodes[i].Amounts {
+				amount := &nodes[i].Amounts[j]
+				amount.BaseAmount = sums["tree|"+nodes[i].CategoryID+"|"+amount.Currency].value()
+			}
+			convertTree(nodes[i].Children)
+		}
+	}
+	convertTree(report.CategoryTree)
+
+	report.BaseTotal = &domain.CurrencyAmount{Currency: opts.Converter.Currency(), BaseAmount: sums["all"].value()}
+	report.BaseTotal.AmountMinor = *report.BaseTotal.BaseAmountMinor
+	return nil
+}
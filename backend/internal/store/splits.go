@@ -0,0 +1,264 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"sort"
+
+	"github.com/google/uuid"
+
+	"familybudget/internal/domain"
+)
+
+const (
+	SplitMethodEqual   = "equal"
+	SplitMethodPercent = "percent"
+	SplitMethodExact   = "exact"
+)
+
+// SaveExpenseSplit stores the split of a transaction, replacing any previous
+// split of the same transaction.
+func (s *Store) SaveExpenseSplit(ctx context.Context, split *domain.ExpenseSplit) error {
+	return s.withTx(ctx, func(dbTx *sql.Tx) error {
+		if err := deleteExpenseSplitTx(ctx, dbTx, split.FamilyID, split.TransactionID); err != nil {
+			return err
+		}
+		if _, err := dbTx.ExecContext(ctx, `INSERT INTO expense_splits (id, family_id, transaction_id, payer_id, method, currency, total_minor, created_by, created_at) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+			split.ID, split.FamilyID, split.TransactionID, split.PayerID, split.Method, split.Currency, split.TotalMinor, split.CreatedBy, split.CreatedAt); err != nil {
+			return err
+		}
+		for _, share := range split.Shares {
+			if _, err := dbTx.ExecContext(ctx, `INSERT INTO expense_split_shares (split_id, user_id, amount_minor) VALUES (?, ?, ?)`, split.ID, share.UserID, share.AmountMinor); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func (s *Store) DeleteExpenseSplit(ctx context.Context, familyID, transactionID string) error {
+	return s.withTx(ctx, func(dbTx *sql.Tx) error {
+		return deleteExpenseSplitTx(ctx, dbTx, familyID, transactionID)
+	})
+}
+
+func deleteExpenseSplitTx(ctx context.Context, dbTx *sql.Tx, familyID, transactionID string) error {
+	if _, err := dbTx.ExecContext(ctx, `DELETE FROM expense_split_shares WHERE split_id IN (SELECT id FROM expense_splits WHERE family_id = ? AND transaction_id = ?)`, familyID, transactionID); err != nil {
+		return err
+	}
+	_, err := dbTx.ExecContext(ctx, `DELETE FROM expense_splits WHERE family_id = ? AND transaction_id = ?`, familyID, transactionID)
+	return err
+}
+
+func (s *Store) GetExpenseSplitByTransaction(ctx context.Context, transactionID string) (*domain.ExpenseSplit, error) {
+	var split domain.ExpenseSplit
+	err := s.db.QueryRowContext(ctx, `SELECT id, family_id, transaction_id, payer_id, method, currency, total_minor, created_by, created_at FROM expense_splits WHERE transaction_id = ?`, transactionID).
+		Scan(&split.ID, &split.FamilyID, &split.TransactionID, &split.PayerID, &split.Method, &split.Currency, &split.TotalMinor, &split.CreatedBy, &split.CreatedAt)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	rows, err := s.db.QueryContext(ctx, `SELECT user_id, amount_minor FROM expense_split_shares WHERE split_id = ? ORDER BY amount_minor DESC, user_id`, split.ID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var share domain.SplitShare
+		if err := rows.Scan(&share.UserID, &share.AmountMinor); err != nil {
+			return nil, err
+		}
+		split.Shares = append(split.Shares, share)
+	}
+	return &split, rows.Err()
+}
+
+func (s *Store) RecordSettlements(ctx context.Context, settlements []domain.Settlement) error {
+	return s.withTx(ctx, func(dbTx *sql.Tx) error {
+		for i := range settlements {
+			item := &settlements[i]
+			if item.ID == "" {
+				item.ID = uuid.NewString()
+			}
+			if _, err := dbTx.ExecContext(ctx, `INSERT INTO settlements (id, family_id, from_user_id, to_user_id, currency, amount_minor, created_by, created_at) VALUES (?, ?, ?, ?, ?, ?, ?, ?)`,
+				item.ID, item.FamilyID, item.FromUserID, item.ToUserID, item.Currency, item.AmountMinor, item.CreatedBy, item.CreatedAt); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func (s *Store) ListSettlements(ctx context.Context, familyID string) ([]domain.Settlement, error) {
+	rows, err := s.db.QueryContext(ctx, `SELECT id, family_id, from_user_id, to_user_id, currency, amount_minor, created_by, created_at FROM settlements WHERE family_id = ? ORDER BY created_at DESC`, familyID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var settlements []domain.Settlement
+	for rows.Next() {
+		var item domain.Settlement
+		if err := rows.Scan(&item.ID, &item.FamilyID, &item.FromUserID, &item.ToUserID, &item.Currency, &item.AmountMinor, &item.CreatedBy, &item.CreatedAt); err != nil {
+			return nil, err
+		}
+		settlements = append(settlements, item)
+	}
+	return settlements, rows.Err()
+}
+
+type debtEdge struct {
+	from, to, currency string
+}
+
+// GetSplitBalances computes who owes whom from all shared expenses and the
+// settlements recorded so far, together with a short list of transfers that
+// brings every balance back to zero.
+func (s *Store) GetSplitBalances(ctx context.Context, familyID string) (domain.SplitBalances, error) {
+	owes := map[debtEdge]int64{}
+
+	rows, err := s.db.QueryContext(ctx, `SELECT sh.user_id, s.payer_id, s.currency, sh.amount_minor
+FROM expense_splits s
+JOIN expense_split_shares sh ON sh.split_id = s.id
+WHERE s.family_id = ? AND sh.user_id <> s.payer_id`, familyID)
+	if err != nil {
+		return domain.SplitBalances{}, err
+	}
+	for rows.Next() {
+		var edge debtEdge
+		var amount int64
+		if err := rows.Scan(&edge.from, &edge.to, &edge.currency, &amount); err != nil {
+			rows.Close()
+			return domain.SplitBalances{}, err
+		}
+		owes[edge] += amount
+	}
+	if err := rows.Close(); err != nil {
+		return domain.SplitBalances{}, err
+	}
+
+	settlements, err := s.ListSettlements(ctx, familyID)
+	if err != nil {
+		return domain.SplitBalances{}, err
+	}
+	for _, item := range settlements {
+		owes[debtEdge{from: item.FromUserID, to: item.ToUserID, currency: item.Currency}] -= item.AmountMinor
+	}
+
+	return buildSplitBalances(owes), nil
+}
+
+func buildSplitBalances(owes map[debtEdge]int64) domain.SplitBalances {
+	seen := map[debtEdge]bool{}
+	net := map[string]map[string]int64{}
+	result := domain.SplitBalances{Members: []domain.MemberBalance{}, Pairs: []domain.SettlementTransfer{}, Suggested: []domain.SettlementTransfer{}}
+
+	for edge := range owes {
+		a, b := edge.from, edge.to
+		if a > b {
+			a, b = b, a
+		}
+		key := debtEdge{from: a, to: b, currency: edge.currency}
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+
+		amount := owes[debtEdge{from: a, to: b, currency: edge.currency}] - owes[debtEdge{from: b, to: a, currency: edge.currency}]
+		if amount == 0 {
+			continue
+		}
+		if amount < 0 {
+			a, b, amount = b, a, -amount
+		}
+		result.Pairs = append(result.Pairs, domain.SettlementTransfer{FromUserID: a, ToUserID: b, Currency: edge.currency, AmountMinor: amount})
+		if net[edge.currency] == nil {
+			net[edge.currency] = map[string]int64{}
+		}
+		net[edge.currency][a] -= amount
+		net[edge.currency][b] += amount
+	}
+	sort.Slice(result.Pairs, func(i, j int) bool {
+		return transferLess(result.Pairs[i], result.Pairs[j])
+	})
+
+	currencies := make([]string, 0, len(net))
+	for currency := range net {
+		currencies = append(currencies, currency)
+	}
+	sort.Strings(currencies)
+
+	for _, currency := range currencies {
+		var debtors, creditors []domain.MemberBalance
+		for userID, amount := range net[currency] {
+			if amount == 0 {
+				continue
+			}
+			balance := domain.MemberBalance{UserID: userID, Currency: currency, NetMinor: amount}
+			result.Members = append(result.Members, balance)
+			if amount < 0 {
+				debtors = append(debtors, balance)
+			} else {
+				creditors = append(creditors, balance)
+			}
+		}
+		result.Suggested = append(result.Suggested, minimalTransfers(currency, debtors, creditors)...)
+	}
+	sort.Slice(result.Members, func(i, j int) bool {
+		if result.Members[i].Currency != result.Members[j].Currency {
+			return result.Members[i].Currency < result.Members[j].Currency
+		}
+		return result.Members[i].UserID < result.Members[j].UserID
+	})
+	return result
+}
+
+// minimalTransfers greedily matches the largest debtor with the largest
+// creditor, which needs at most n-1 transfers for n members.
+func minimalTransfers(currency string, debtors, creditors []domain.MemberBalance) []domain.SettlementTransfer {
+	sort.Slice(debtors, func(i, j int) bool {
+		if debtors[i].NetMinor != debtors[j].NetMinor {
+			return debtors[i].NetMinor < debtors[j].NetMinor
+		}
+		return debtors[i].UserID < debtors[j].UserID
+	})
+	sort.Slice(creditors, func(i, j int) bool {
+		if creditors[i].NetMinor != creditors[j].NetMinor {
+			return creditors[i].NetMinor > creditors[j].NetMinor
+		}
+		return creditors[i].UserID < creditors[j].UserID
+	})
+
+	var transfers []domain.SettlementTransfer
+	i, j := 0, 0
+	for i < len(debtors) && j < len(creditors) {
+		amount := -debtors[i].NetMinor
+		if creditors[j].NetMinor < amount {
+			amount = creditors[j].NetMinor
+		}
+		transfers = append(transfers, domain.SettlementTransfer{FromUserID: debtors[i].UserID, ToUserID: creditors[j].UserID, Currency: currency, AmountMinor: amount})
+		debtors[i].NetMinor += amount
+		creditors[j].NetMinor -= amount
+		if debtors[i].NetMinor == 0 {
+			i++
+		}
+		if creditors[j].NetMinor == 0 {
+			j++
+		}
+	}
+	return transfers
+}
+
+func transferLess(a, b domain.SettlementTransfer) bool {
+	if a.Currency != b.Currency {
+		return a.Currency < b.Currency
+	}
+	if a.FromUserID != b.FromUserID {
+		return a.FromUserID < b.FromUserID
+	}
+	return a.ToUserID < b.ToUserID
+}
@@ -77,11 +77,337 @@ func migrate(db *sql.DB) error {
             occurred_at TIMESTAMP NOT NULL,
             created_at TIMESTAMP NOT NULL,
             updated_at TIMESTAMP NOT NULL
+        );`,
+		`CREATE TABLE IF NOT EXISTS planned_operations (
+            id TEXT PRIMARY KEY,
+            family_id TEXT NOT NULL REFERENCES families(id),
+            user_id TEXT NOT NULL REFERENCES users(id),
+            account_id TEXT NOT NULL REFERENCES accounts(id),
+            category_id TEXT NOT NULL REFERENCES categories(id),
+            type TEXT NOT NULL,
+            title TEXT NOT NULL,
+            amount_minor INTEGER NOT NULL,
+            currency TEXT NOT NULL,
+            comment TEXT,
+            due_at TIMESTAMP NOT NULL,
+            starts_at TIMESTAMP NULL,
+            snoozed_from TIMESTAMP NULL,
+            recurrence TEXT,
+            auto_post INTEGER NOT NULL DEFAULT 0,
+            is_completed INTEGER NOT NULL DEFAULT 0,
+            last_completed_at TIMESTAMP NULL,
+            created_at TIMESTAMP NOT NULL,
+            updated_at TIMESTAMP NOT NULL
+        );`,
+		`CREATE TABLE IF NOT EXISTS planned_occurrences (
+            id TEXT PRIMARY KEY,
+            family_id TEXT NOT NULL REFERENCES families(id),
+            planned_operation_id TEXT NOT NULL REFERENCES planned_operations(id) ON DELETE CASCADE,
+            due_at TIMESTAMP NOT NULL,
+            status TEXT NOT NULL,
+            transaction_id TEXT NULL,
+            amount_minor INTEGER NOT NULL,
+            currency TEXT NOT NULL,
+            user_id TEXT NULL REFERENCES users(id),
+            recorded_at TIMESTAMP NOT NULL
+        );`,
+		`CREATE TABLE IF NOT EXISTS calendar_feeds (
+            user_id TEXT PRIMARY KEY REFERENCES users(id),
+            family_id TEXT NOT NULL REFERENCES families(id),
+            token_hash TEXT NOT NULL UNIQUE,
+            created_at TIMESTAMP NOT NULL
+        );`,
+		`CREATE TABLE IF NOT EXISTS reminder_rules (
+            id TEXT PRIMARY KEY,
+            family_id TEXT NOT NULL REFERENCES families(id),
+            planned_operation_id TEXT NULL REFERENCES planned_operations(id) ON DELETE CASCADE,
+            offset_days INTEGER NOT NULL,
+            channels TEXT NOT NULL,
+            created_by TEXT NOT NULL REFERENCES users(id),
+            created_at TIMESTAMP NOT NULL
+        );`,
+		`CREATE TABLE IF NOT EXISTS reminder_deliveries (
+            planned_operation_id TEXT NOT NULL,
+            due_at TIMESTAMP NOT NULL,
+            offset_days INTEGER NOT NULL,
+            user_id TEXT NOT NULL REFERENCES users(id),
+            channel TEXT NOT NULL,
+            sent_at TIMESTAMP NOT NULL,
+            PRIMARY KEY (planned_operation_id, due_at, offset_days, user_id, channel)
+        );`,
+		`CREATE TABLE IF NOT EXISTS inbox_messages (
+            id TEXT PRIMARY KEY,
+            family_id TEXT NOT NULL REFERENCES families(id),
+            user_id TEXT NOT NULL REFERENCES users(id),
+            kind TEXT NOT NULL,
+            subject TEXT NOT NULL,
+            body TEXT NOT NULL,
+            planned_operation_id TEXT NULL,
+            created_at TIMESTAMP NOT NULL,
+            read_at TIMESTAMP NULL
+        );`,
+		`CREATE TABLE IF NOT EXISTS subscription_decisions (
+            family_id TEXT NOT NULL REFERENCES families(id),
+            suggestion_id TEXT NOT NULL,
+            decision TEXT NOT NULL,
+            planned_operation_id TEXT NULL,
+            decided_by TEXT NOT NULL REFERENCES users(id),
+            decided_at TIMESTAMP NOT NULL,
+            PRIMARY KEY (family_id, suggestion_id)
+        );`,
+		`CREATE TABLE IF NOT EXISTS exchange_rates (
+            id TEXT PRIMARY KEY,
+            base TEXT NOT NULL,
+            quote TEXT NOT NULL,
+            rate REAL NOT NULL,
+            as_of TEXT NOT NULL,
+            source TEXT NOT NULL,
+            created_at TIMESTAMP NOT NULL,
+            UNIQUE (base, quote, as_of)
+        );`,
+		`CREATE TABLE IF NOT EXISTS family_currencies (
+            family_id TEXT NOT NULL REFERENCES families(id),
+            code TEXT NOT NULL,
+            created_at TIMESTAMP NOT NULL,
+            PRIMARY KEY (family_id, code)
+        );`,
+		`CREATE TABLE IF NOT EXISTS account_pockets (
+            account_id TEXT NOT NULL REFERENCES accounts(id),
+            currency TEXT NOT NULL,
+            balance_minor INTEGER NOT NULL DEFAULT 0,
+            updated_at TIMESTAMP NOT NULL,
+            PRIMARY KEY (account_id, currency)
+        );`,
+		`CREATE TABLE IF NOT EXISTS account_exchanges (
+            id TEXT PRIMARY KEY,
+            family_id TEXT NOT NULL REFERENCES families(id),
+            account_id TEXT NOT NULL REFERENCES accounts(id),
+            user_id TEXT NOT NULL REFERENCES users(id),
+            from_currency TEXT NOT NULL,
+            from_amount_minor INTEGER NOT NULL,
+            to_currency TEXT NOT NULL,
+            to_amount_minor INTEGER NOT NULL,
+            rate REAL NOT NULL,
+            comment TEXT,
+            occurred_at TIMESTAMP NOT NULL,
+            created_at TIMESTAMP NOT NULL
+        );`,
+		`CREATE TABLE IF NOT EXISTS import_profiles (
+            id TEXT PRIMARY KEY,
+            family_id TEXT NOT NULL REFERENCES families(id),
+            name TEXT NOT NULL,
+            config TEXT NOT NULL,
+            created_at TIMESTAMP NOT NULL
+        );`,
+		`CREATE TABLE IF NOT EXISTS imports (
+            id TEXT PRIMARY KEY,
+            family_id TEXT NOT NULL REFERENCES families(id),
+            user_id TEXT NOT NULL REFERENCES users(id),
+            account_id TEXT REFERENCES accounts(id),
+            file_name TEXT NOT NULL,
+            content BLOB NOT NULL,
+            profile TEXT NOT NULL,
+            status TEXT NOT NULL,
+            idempotency_key TEXT,
+            transactions INTEGER NOT NULL DEFAULT 0,
+            created_at TIMESTAMP NOT NULL,
+            committed_at TIMESTAMP
+        );`,
+		`CREATE TABLE IF NOT EXISTS imported_transactions (
+            transaction_id TEXT PRIMARY KEY REFERENCES transactions(id),
+            import_id TEXT NOT NULL REFERENCES imports(id),
+            family_id TEXT NOT NULL REFERENCES families(id),
+            hash TEXT NOT NULL
+        );`,
+		`CREATE TABLE IF NOT EXISTS envelopes (
+            id TEXT PRIMARY KEY,
+            family_id TEXT NOT NULL REFERENCES families(id),
+            name TEXT NOT NULL,
+            currency TEXT NOT NULL,
+            target_amount_minor INTEGER NOT NULL DEFAULT 0,
+            current_amount_minor INTEGER NOT NULL DEFAULT 0,
+            auto_fill_rule TEXT,
+            is_archived INTEGER NOT NULL DEFAULT 0,
+            created_at TIMESTAMP NOT NULL,
+            updated_at TIMESTAMP NOT NULL
+        );`,
+		`CREATE TABLE IF NOT EXISTS envelope_pools (
+            family_id TEXT NOT NULL REFERENCES families(id),
+            currency TEXT NOT NULL,
+            unassigned_minor INTEGER NOT NULL DEFAULT 0,
+            updated_at TIMESTAMP NOT NULL,
+            PRIMARY KEY (family_id, currency)
+        );`,
+		`CREATE TABLE IF NOT EXISTS envelope_movements (
+            id TEXT PRIMARY KEY,
+            family_id TEXT NOT NULL REFERENCES families(id),
+            user_id TEXT NOT NULL REFERENCES users(id),
+            kind TEXT NOT NULL,
+            from_envelope_id TEXT NULL REFERENCES envelopes(id),
+            to_envelope_id TEXT NULL REFERENCES envelopes(id),
+            transaction_id TEXT NULL REFERENCES transactions(id),
+            amount_minor INTEGER NOT NULL,
+            currency TEXT NOT NULL,
+            created_at TIMESTAMP NOT NULL
+        );`,
+		`CREATE TABLE IF NOT EXISTS events (
+            id TEXT PRIMARY KEY,
+            family_id TEXT NOT NULL REFERENCES families(id),
+            event TEXT NOT NULL,
+            version TEXT NOT NULL,
+            payload TEXT NOT NULL,
+            created_at TIMESTAMP NOT NULL
+        );`,
+		`CREATE TABLE IF NOT EXISTS goals (
+            id TEXT PRIMARY KEY,
+            family_id TEXT NOT NULL REFERENCES families(id),
+            name TEXT NOT NULL,
+            currency TEXT NOT NULL,
+            target_amount_minor INTEGER NOT NULL,
+            due_date TIMESTAMP NULL,
+            priority INTEGER NOT NULL DEFAULT 0,
+            funding_mode TEXT NOT NULL,
+            completed_at TIMESTAMP NULL,
+            is_archived INTEGER NOT NULL DEFAULT 0,
+            created_at TIMESTAMP NOT NULL,
+            updated_at TIMESTAMP NOT NULL
+        );`,
+		`CREATE TABLE IF NOT EXISTS goal_accounts (
+            goal_id TEXT NOT NULL REFERENCES goals(id),
+            account_id TEXT NOT NULL REFERENCES accounts(id),
+            PRIMARY KEY (goal_id, account_id)
+        );`,
+		`CREATE TABLE IF NOT EXISTS goal_contributions (
+            id TEXT PRIMARY KEY,
+            goal_id TEXT NOT NULL REFERENCES goals(id),
+            family_id TEXT NOT NULL REFERENCES families(id),
+            user_id TEXT NOT NULL REFERENCES users(id),
+            amount_minor INTEGER NOT NULL,
+            comment TEXT,
+            contributed_at TIMESTAMP NOT NULL,
+            created_at TIMESTAMP NOT NULL
+        );`,
+		`CREATE TABLE IF NOT EXISTS debts (
+            id TEXT PRIMARY KEY,
+            family_id TEXT NOT NULL REFERENCES families(id),
+            counterparty TEXT NOT NULL,
+            direction TEXT NOT NULL,
+            amount_minor INTEGER NOT NULL,
+            currency TEXT NOT NULL,
+            due_date TIMESTAMP NULL,
+            notes TEXT,
+            due_soon_notified_at TIMESTAMP NULL,
+            closed_at TIMESTAMP NULL,
+            created_at TIMESTAMP NOT NULL,
+            updated_at TIMESTAMP NOT NULL
+        );`,
+		`CREATE TABLE IF NOT EXISTS debt_repayments (
+            id TEXT PRIMARY KEY,
+            debt_id TEXT NOT NULL REFERENCES debts(id),
+            family_id TEXT NOT NULL REFERENCES families(id),
+            user_id TEXT NOT NULL REFERENCES users(id),
+            transaction_id TEXT NOT NULL UNIQUE REFERENCES transactions(id),
+            amount_minor INTEGER NOT NULL,
+            created_at TIMESTAMP NOT NULL
+        );`,
+		`CREATE TABLE IF NOT EXISTS expense_splits (
+            id TEXT PRIMARY KEY,
+            family_id TEXT NOT NULL REFERENCES families(id),
+            transaction_id TEXT NOT NULL UNIQUE REFERENCES transactions(id),
+            payer_id TEXT NOT NULL REFERENCES users(id),
+            method TEXT NOT NULL,
+            currency TEXT NOT NULL,
+            total_minor INTEGER NOT NULL,
+            created_by TEXT NOT NULL REFERENCES users(id),
+            created_at TIMESTAMP NOT NULL
+        );`,
+		`CREATE TABLE IF NOT EXISTS expense_split_shares (
+            split_id TEXT NOT NULL REFERENCES expense_splits(id),
+            user_id TEXT NOT NULL REFERENCES users(id),
+            amount_minor INTEGER NOT NULL,
+            PRIMARY KEY (split_id, user_id)
+        );`,
+		`CREATE TABLE IF NOT EXISTS settlements (
+            id TEXT PRIMARY KEY,
+            family_id TEXT NOT NULL REFERENCES families(id),
+            from_user_id TEXT NOT NULL REFERENCES users(id),
+            to_user_id TEXT NOT NULL REFERENCES users(id),
+            currency TEXT NOT NULL,
+            amount_minor INTEGER NOT NULL,
+            created_by TEXT NOT NULL REFERENCES users(id),
+            created_at TIMESTAMP NOT NULL
+        );`,
+		`CREATE TABLE IF NOT EXISTS allowances (
+            id TEXT PRIMARY KEY,
+            family_id TEXT NOT NULL REFERENCES families(id),
+            user_id TEXT NOT NULL REFERENCES users(id),
+            account_id TEXT NOT NULL REFERENCES accounts(id),
+            category_id TEXT NOT NULL REFERENCES categories(id),
+            amount_minor INTEGER NOT NULL,
+            currency TEXT NOT NULL,
+            recurrence TEXT NOT NULL,
+            next_run_at TIMESTAMP NOT NULL,
+            is_active BOOLEAN NOT NULL DEFAULT 1,
+            last_posted_at TIMESTAMP NULL,
+            created_by TEXT NOT NULL REFERENCES users(id),
+            created_at TIMESTAMP NOT NULL,
+            updated_at TIMESTAMP NOT NULL
+        );`,
+		`CREATE TABLE IF NOT EXISTS spending_caps (
+            family_id TEXT NOT NULL REFERENCES families(id),
+            user_id TEXT NOT NULL REFERENCES users(id),
+            category_id TEXT NOT NULL REFERENCES categories(id),
+            period TEXT NOT NULL,
+            amount_minor INTEGER NOT NULL,
+            currency TEXT NOT NULL,
+            updated_at TIMESTAMP NOT NULL,
+            PRIMARY KEY (user_id, category_id)
+        );`,
+		`CREATE TABLE IF NOT EXISTS transaction_approvals (
+            id TEXT PRIMARY KEY,
+            family_id TEXT NOT NULL REFERENCES families(id),
+            status TEXT NOT NULL,
+            reason TEXT NOT NULL,
+            transaction_id TEXT NOT NULL UNIQUE,
+            user_id TEXT NOT NULL REFERENCES users(id),
+            account_id TEXT NOT NULL REFERENCES accounts(id),
+            category_id TEXT NOT NULL REFERENCES categories(id),
+            envelope_id TEXT NULL REFERENCES envelopes(id),
+            type TEXT NOT NULL,
+            amount_minor INTEGER NOT NULL,
+            currency TEXT NOT NULL,
+            comment TEXT,
+            occurred_at TIMESTAMP NOT NULL,
+            reviewer_id TEXT NULL REFERENCES users(id),
+            review_note TEXT,
+            created_at TIMESTAMP NOT NULL,
+            reviewed_at TIMESTAMP NULL
         );`,
 		`CREATE INDEX IF NOT EXISTS idx_transactions_user ON transactions(user_id);`,
 		`CREATE INDEX IF NOT EXISTS idx_accounts_family ON accounts(family_id);`,
 		`CREATE INDEX IF NOT EXISTS idx_categories_family ON categories(family_id);`,
 		`CREATE INDEX IF NOT EXISTS idx_users_family ON users(family_id);`,
+		`CREATE INDEX IF NOT EXISTS idx_planned_operations_family_due ON planned_operations(family_id, is_completed, due_at);`,
+		`CREATE INDEX IF NOT EXISTS idx_envelopes_family ON envelopes(family_id);`,
+		`CREATE INDEX IF NOT EXISTS idx_envelope_movements_family ON envelope_movements(family_id, created_at);`,
+		`CREATE INDEX IF NOT EXISTS idx_events_family ON events(family_id, created_at);`,
+		`CREATE INDEX IF NOT EXISTS idx_goals_family ON goals(family_id);`,
+		`CREATE INDEX IF NOT EXISTS idx_goal_accounts_account ON goal_accounts(account_id);`,
+		`CREATE INDEX IF NOT EXISTS idx_goal_contributions_goal ON goal_contributions(goal_id, contributed_at);`,
+		`CREATE INDEX IF NOT EXISTS idx_debts_family ON debts(family_id, closed_at);`,
+		`CREATE INDEX IF NOT EXISTS idx_debt_repayments_debt ON debt_repayments(debt_id);`,
+		`CREATE INDEX IF NOT EXISTS idx_expense_splits_family ON expense_splits(family_id);`,
+		`CREATE INDEX IF NOT EXISTS idx_settlements_family ON settlements(family_id, created_at);`,
+		`CREATE INDEX IF NOT EXISTS idx_allowances_due ON allowances(is_active, next_run_at);`,
+		`CREATE INDEX IF NOT EXISTS idx_transaction_approvals_family ON transaction_approvals(family_id, status, created_at);`,
+		`CREATE INDEX IF NOT EXISTS idx_planned_occurrences_operation ON planned_occurrences(planned_operation_id, due_at);`,
+		`CREATE INDEX IF NOT EXISTS idx_planned_occurrences_family ON planned_occurrences(family_id, status, due_at);`,
+		`CREATE INDEX IF NOT EXISTS idx_reminder_rules_family ON reminder_rules(family_id);`,
+		`CREATE INDEX IF NOT EXISTS idx_inbox_messages_user ON inbox_messages(user_id, created_at);`,
+		`CREATE INDEX IF NOT EXISTS idx_account_exchanges_account ON account_exchanges(account_id, occurred_at);`,
+		`CREATE INDEX IF NOT EXISTS idx_import_profiles_family ON import_profiles(family_id);`,
+		`CREATE UNIQUE INDEX IF NOT EXISTS idx_imports_idempotency ON imports(family_id, idempotency_key);`,
+		`CREATE INDEX IF NOT EXISTS idx_imported_transactions_hash ON imported_transactions(family_id, hash);`,
 	}
 
 	for _, stmt := range schema {
@@ -97,8 +423,24 @@ func migrate(db *sql.DB) error {
 		`ALTER TABLE categories ADD COLUMN updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP;`,
 		`ALTER TABLE transactions ADD COLUMN account_id TEXT REFERENCES accounts(id);`,
 		`ALTER TABLE transactions ADD COLUMN comment TEXT;`,
+		`ALTER TABLE transactions ADD COLUMN envelope_id TEXT NULL REFERENCES envelopes(id);`,
 		`ALTER TABLE accounts ADD COLUMN is_shared INTEGER NOT NULL DEFAULT 1;`,
+		`ALTER TABLE families ADD COLUMN approval_required INTEGER NOT NULL DEFAULT 0;`,
+		`ALTER TABLE families ADD COLUMN timezone TEXT NOT NULL DEFAULT 'UTC';`,
+		`ALTER TABLE planned_operations ADD COLUMN starts_at TIMESTAMP NULL;`,
+		`ALTER TABLE planned_operations ADD COLUMN auto_post INTEGER NOT NULL DEFAULT 0;`,
+		`ALTER TABLE planned_operations ADD COLUMN snoozed_from TIMESTAMP NULL;`,
+		`ALTER TABLE transactions ADD COLUMN planned_operation_id TEXT NULL;`,
 		`ALTER TABLE users ADD COLUMN display_settings TEXT NOT NULL DEFAULT '{"theme":"system","density":"comfortable","show_archived":false,"show_totals_in_family_currency":true}';`,
+		`ALTER TABLE allowances ADD COLUMN starts_at TIMESTAMP NULL;`,
+		`ALTER TABLE transactions ADD COLUMN original_amount_minor INTEGER NULL;`,
+		`ALTER TABLE transactions ADD COLUMN original_currency TEXT NULL;`,
+		`ALTER TABLE transactions ADD COLUMN exchange_rate REAL NULL;`,
+		`ALTER TABLE transactions ADD COLUMN amount_base_minor INTEGER NULL;`,
+		`ALTER TABLE transaction_approvals ADD COLUMN original_amount_minor INTEGER NULL;`,
+		`ALTER TABLE transaction_approvals ADD COLUMN original_currency TEXT NULL;`,
+		`ALTER TABLE transaction_approvals ADD COLUMN exchange_rate REAL NULL;`,
+		`ALTER TABLE accounts ADD COLUMN multi_currency INTEGER NOT NULL DEFAULT 0;`,
 	}
 
 	for _, stmt := range alterStatements {
@@ -108,6 +450,26 @@ func migrate(db *sql.DB) error {
 			}
 		}
 	}
+
+	// Indexes and backfills of added columns can only run once the columns
+	// exist in databases created before them.
+	postAlterStatements := []string{
+		`CREATE INDEX IF NOT EXISTS idx_planned_operations_auto_post ON planned_operations(auto_post, is_completed, due_at);`,
+		`UPDATE allowances SET starts_at = next_run_at WHERE starts_at IS NULL;`,
+		// Existing income forms the first unassigned pool, once, while no
+		// pool exists yet.
+		`INSERT INTO envelope_pools (family_id, currency, unassigned_minor, updated_at)
+            SELECT family_id, currency, SUM(amount_minor), CURRENT_TIMESTAMP
+            FROM transactions
+            WHERE type = 'income' AND NOT EXISTS (SELECT 1 FROM envelope_pools)
+            GROUP BY family_id, currency
+            ON CONFLICT (family_id, currency) DO NOTHING;`,
+	}
+	for _, stmt := range postAlterStatements {
+		if _, err := db.Exec(stmt); err != nil {
+			return err
+		}
+	}
 	return nil
 }
 
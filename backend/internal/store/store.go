@@ -11,6 +11,7 @@ import (
 	"github.com/google/uuid"
 
 	"familybudget/internal/domain"
+	"familybudget/internal/recurrence"
 )
 
 type Store struct {
@@ -36,6 +37,7 @@ func (s *Store) CreateFamily(ctx context.Context, name, currency string) (*domai
 		ID:           uuid.NewString(),
 		Name:         name,
 		CurrencyBase: currency,
+		Timezone:     "UTC",
 		CreatedAt:    time.Now().UTC(),
 	}
 	_, err := s.db.ExecContext(ctx, `INSERT INTO families (id, name, currency_base, created_at) VALUES (?, ?, ?, ?)`,
@@ -58,14 +60,17 @@ func (s *Store) CreateUser(ctx context.Context, user *domain.User, passwordHash
 }
 
 func (s *Store) CreateAccount(ctx context.Context, account *domain.Account) error {
-	_, err := s.db.ExecContext(ctx, `INSERT INTO accounts (id, family_id, name, type, currency, balance_minor, is_shared, is_archived, created_at, updated_at)
-        VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
-		account.ID, account.FamilyID, account.Name, account.Type, account.Currency, account.BalanceMinor, account.IsShared, account.IsArchived, account.CreatedAt, account.UpdatedAt)
+	_, err := s.db.ExecContext(ctx, `INSERT INTO accounts (id, family_id, name, type, currency, balance_minor, multi_currency, is_shared, is_archived, created_at, updated_at)
+        VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		account.ID, account.FamilyID, account.Name, account.Type, account.Currency, account.BalanceMinor, account.MultiCurrency, account.IsShared, account.IsArchived, account.CreatedAt, account.UpdatedAt)
+	if err == nil && account.MultiCurrency {
+		account.Pockets = []domain.AccountPocket{{Currency: account.Currency, BalanceMinor: account.BalanceMinor}}
+	}
 	return err
 }
 
 func (s *Store) ListAccountsByFamily(ctx context.Context, familyID string) ([]domain.Account, error) {
-	rows, err := s.db.QueryContext(ctx, `SELECT id, family_id, name, type, currency, balance_minor, is_shared, is_archived, created_at, updated_at FROM accounts WHERE family_id = ? ORDER BY created_at`, familyID)
+	rows, err := s.db.QueryContext(ctx, `SELECT id, family_id, name, type, currency, balance_minor, multi_currency, is_shared, is_archived, created_at, updated_at FROM accounts WHERE family_id = ? ORDER BY created_at`, familyID)
 	if err != nil {
 		return nil, err
 	}
@@ -76,22 +81,28 @@ func (s *Store) ListAccountsByFamily(ctx context.Context, familyID string) ([]do
 		var account domain.Account
 		var isShared bool
 		var isArchived bool
-		if err := rows.Scan(&account.ID, &account.FamilyID, &account.Name, &account.Type, &account.Currency, &account.BalanceMinor, &isShared, &isArchived, &account.CreatedAt, &account.UpdatedAt); err != nil {
+		if err := rows.Scan(&account.ID, &account.FamilyID, &account.Name, &account.Type, &account.Currency, &account.BalanceMinor, &account.MultiCurrency, &isShared, &isArchived, &account.CreatedAt, &account.UpdatedAt); err != nil {
 			return nil, err
 		}
 		account.IsShared = isShared
 		account.IsArchived = isArchived
 		accounts = append(accounts, account)
 	}
-	return accounts, rows.Err()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	if err := s.loadAccountPockets(ctx, accounts); err != nil {
+		return nil, err
+	}
+	return accounts, nil
 }
 
 func (s *Store) GetAccount(ctx context.Context, id string) (*domain.Account, error) {
-	row := s.db.QueryRowContext(ctx, `SELECT id, family_id, name, type, currency, balance_minor, is_shared, is_archived, created_at, updated_at FROM accounts WHERE id = ?`, id)
+	row := s.db.QueryRowContext(ctx, `SELECT id, family_id, name, type, currency, balance_minor, multi_currency, is_shared, is_archived, created_at, updated_at FROM accounts WHERE id = ?`, id)
 	var account domain.Account
 	var isShared bool
 	var isArchived bool
-	if err := row.Scan(&account.ID, &account.FamilyID, &account.Name, &account.Type, &account.Currency, &account.BalanceMinor, &isShared, &isArchived, &account.CreatedAt, &account.UpdatedAt); err != nil {
+	if err := row.Scan(&account.ID, &account.FamilyID, &account.Name, &account.Type, &account.Currency, &account.BalanceMinor, &account.MultiCurrency, &isShared, &isArchived, &account.CreatedAt, &account.UpdatedAt); err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
 			return nil, nil
 		}
@@ -99,7 +110,11 @@ func (s *Store) GetAccount(ctx context.Context, id string) (*domain.Account, err
 	}
 	account.IsShared = isShared
 	account.IsArchived = isArchived
-	return &account, nil
+	accounts := []domain.Account{account}
+	if err := s.loadAccountPockets(ctx, accounts); err != nil {
+		return nil, err
+	}
+	return &accounts[0], nil
 }
 
 func (s *Store) ListFamilyMembers(ctx context.Context, familyID string) ([]domain.FamilyMember, error) {
@@ -161,8 +176,66 @@ func (s *Store) UpdateUserSettings(ctx context.Context, userID, locale, currency
 	return s.GetUser(ctx, userID)
 }
 
+func (s *Store) UpdateUserRole(ctx context.Context, userID, role string) (*domain.User, error) {
+	if _, err := s.db.ExecContext(ctx, `UPDATE users SET role = ?, updated_at = ? WHERE id = ?`, role, time.Now().UTC(), userID); err != nil {
+		return nil, err
+	}
+	return s.GetUser(ctx, userID)
+}
+
+func (s *Store) UpdateFamilyApprovalRequired(ctx context.Context, familyID string, required bool) (*domain.Family, error) {
+	if _, err := s.db.ExecContext(ctx, `UPDATE families SET approval_required = ? WHERE id = ?`, required, familyID); err != nil {
+		return nil, err
+	}
+	return s.GetFamily(ctx, familyID)
+}
+
+func (s *Store) UpdateFamilyTimezone(ctx context.Context, familyID, timezone string) (*domain.Family, error) {
+	if _, err := s.db.ExecContext(ctx, `UPDATE families SET timezone = ? WHERE id = ?`, timezone, familyID); err != nil {
+		return nil, err
+	}
+	return s.GetFamily(ctx, familyID)
+}
+
+// FamilyLocation returns the time zone recurrence rules of the family are
+// evaluated in. Unknown or missing zones fall back to UTC.
+func (s *Store) FamilyLocation(ctx context.Context, familyID string) (*time.Location, error) {
+	family, err := s.GetFamily(ctx, familyID)
+	if err != nil {
+		return nil, err
+	}
+	if family == nil {
+		return time.UTC, nil
+	}
+	loc, err := recurrence.LoadLocation(family.Timezone)
+	if err != nil {
+		return time.UTC, nil
+	}
+	return loc, nil
+}
+
+// UpdateFamilyCurrency changes the family currency. Amounts in the old
+// family currency are cleared in the same transaction so that
+// FillTransactionBaseAmounts recomputes them in the new one.
 func (s *Store) UpdateFamilyCurrency(ctx context.Context, familyID, currency string) (*domain.Family, error) {
-	if _, err := s.db.ExecContext(ctx, `UPDATE families SET currency_base = ? WHERE id = ?`, currency, familyID); err != nil {
+	err := s.withTx(ctx, func(dbTx *sql.Tx) error {
+		var current string
+		if err := dbTx.QueryRowContext(ctx, `SELECT currency_base FROM families WHERE id = ?`, familyID).Scan(&current); err != nil {
+			return err
+		}
+		if _, err := dbTx.ExecContext(ctx, `UPDATE families SET currency_base = ? WHERE id = ?`, currency, familyID); err != nil {
+			return err
+		}
+		if current == currency {
+			return nil
+		}
+		_, err := dbTx.ExecContext(ctx, `UPDATE transactions SET amount_base_minor = NULL WHERE family_id = ?`, familyID)
+		return err
+	})
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil
+		}
 		return nil, err
 	}
 	return s.GetFamily(ctx, familyID)
@@ -273,37 +346,49 @@ func nullableTime(value *time.Time) interface{} {
 	return value.UTC()
 }
 
-func (s *Store) CreateTransaction(ctx context.Context, txn *domain.Transaction) error {
+// queryer is satisfied by both *sql.DB and *sql.Tx.
+type queryer interface {
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+	QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row
+}
+
+func (s *Store) withTx(ctx context.Context, fn func(dbTx *sql.Tx) error) error {
 	dbTx, err := s.db.BeginTx(ctx, nil)
 	if err != nil {
 		return err
 	}
-	defer func() {
-		if err != nil {
-			_ = dbTx.Rollback()
-		}
-	}()
+	if err := fn(dbTx); err != nil {
+		_ = dbTx.Rollback()
+		return err
+	}
+	return dbTx.Commit()
+}
 
-	row := dbTx.QueryRowContext(ctx, `SELECT family_id, balance_minor, is_archived FROM accounts WHERE id = ?`, txn.AccountID)
-	var accountFamily string
-	var accountBalance int64
-	var isArchived bool
-	if scanErr := row.Scan(&accountFamily, &accountBalance, &isArchived); scanErr != nil {
-		if errors.Is(scanErr, sql.ErrNoRows) {
-			err = sql.ErrNoRows
-			return err
+func (s *Store) CreateTransaction(ctx context.Context, txn *domain.Transaction) error {
+	return s.withTx(ctx, func(dbTx *sql.Tx) error {
+		return s.createTransactionTx(ctx, dbTx, txn)
+	})
+}
+
+// createTransactionTx moves the account balance and stores the transaction
+// inside an already opened database transaction, so that callers can combine
+// it with their own bookkeeping atomically.
+func (s *Store) createTransactionTx(ctx context.Context, dbTx *sql.Tx, txn *domain.Transaction) error {
+	row := dbTx.QueryRowContext(ctx, `SELECT family_id, currency, multi_currency, is_archived FROM accounts WHERE id = ?`, txn.AccountID)
+	var accountFamily, accountCurrency string
+	var multiCurrency, isArchived bool
+	if err := row.Scan(&accountFamily, &accountCurrency, &multiCurrency, &isArchived); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return sql.ErrNoRows
 		}
-		err = scanErr
 		return err
 	}
-	_ = accountBalance
 	if accountFamily != txn.FamilyID {
-		err = sql.ErrNoRows
-		return err
+		return sql.ErrNoRows
 	}
 	if isArchived {
-		err = ErrAccountArchived
-		return err
+		return ErrAccountArchived
 	}
 
 	delta := txn.AmountMinor
@@ -311,32 +396,33 @@ func (s *Store) CreateTransaction(ctx context.Context, txn *domain.Transaction)
 		delta = -delta
 	}
 
-	res, execErr := dbTx.ExecContext(ctx, `UPDATE accounts SET balance_minor = balance_minor + ?, updated_at = ? WHERE id = ? AND family_id = ?`, delta, txn.UpdatedAt, txn.AccountID, txn.FamilyID)
-	if execErr != nil {
-		err = execErr
-		return err
+	// Multi-currency accounts keep other currencies in their own pockets.
+	pocket := ""
+	if multiCurrency && !strings.EqualFold(txn.Currency, accountCurrency) {
+		pocket = strings.ToUpper(txn.Currency)
 	}
-	if affected, affErr := res.RowsAffected(); affErr != nil {
-		err = affErr
-		return err
-	} else if affected == 0 {
-		err = sql.ErrNoRows
+	if err := moveAccountBalance(ctx, dbTx, txn.FamilyID, txn.AccountID, pocket, delta, txn.UpdatedAt); err != nil {
 		return err
 	}
 
-	_, execErr = dbTx.ExecContext(ctx, `INSERT INTO transactions (id, family_id, user_id, account_id, category_id, type, amount_minor, currency, comment, occurred_at, created_at, updated_at)
-        VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
-		txn.ID, txn.FamilyID, txn.UserID, txn.AccountID, txn.CategoryID, txn.Type, txn.AmountMinor, txn.Currency, nullableString(txn.Comment), txn.OccurredAt, txn.CreatedAt, txn.UpdatedAt)
-	if execErr != nil {
-		err = execErr
+	if txn.AmountBaseMinor == nil {
+		var err error
+		if txn.AmountBaseMinor, err = transactionBaseAmount(ctx, dbTx, txn); err != nil {
+			return err
+		}
+	}
+	if _, err := dbTx.ExecContext(ctx, `INSERT INTO transactions (id, family_id, user_id, account_id, category_id, envelope_id, planned_operation_id, type, amount_minor, currency, comment, occurred_at, created_at, updated_at,
+            original_amount_minor, original_currency, exchange_rate, amount_base_minor)
+        VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		txn.ID, txn.FamilyID, txn.UserID, txn.AccountID, txn.CategoryID, txn.EnvelopeID, txn.PlannedOperationID, txn.Type, txn.AmountMinor, txn.Currency, nullableString(txn.Comment), txn.OccurredAt, txn.CreatedAt, txn.UpdatedAt,
+		txn.OriginalAmountMinor, nullableString(txn.OriginalCurrency), txn.ExchangeRate, txn.AmountBaseMinor); err != nil {
 		return err
 	}
 
-	if commitErr := dbTx.Commit(); commitErr != nil {
-		err = commitErr
+	if err := s.applyEnvelopeEffectsTx(ctx, dbTx, txn); err != nil {
 		return err
 	}
-	return nil
+	return checkAccountGoalsTx(ctx, dbTx, txn.AccountID, txn.UpdatedAt)
 }
 
 type TransactionListFilters struct {
@@ -349,7 +435,8 @@ type TransactionListFilters struct {
 }
 
 func (s *Store) ListTransactionsByFamily(ctx context.Context, familyID string, filters TransactionListFilters) ([]domain.TransactionWithAuthor, error) {
-	baseQuery := `SELECT t.id, t.family_id, t.user_id, t.account_id, t.category_id, t.type, t.amount_minor, t.currency, t.comment, t.occurred_at, t.created_at, t.updated_at,
+	baseQuery := `SELECT t.id, t.family_id, t.user_id, t.account_id, t.category_id, t.envelope_id, t.planned_operation_id, t.type, t.amount_minor, t.currency, t.comment, t.occurred_at, t.created_at, t.updated_at,
+        t.original_amount_minor, t.original_currency, t.exchange_rate, t.amount_base_minor,
         u.id, u.name, u.email, u.role
 FROM transactions t
 JOIN users u ON u.id = t.user_id
@@ -390,11 +477,21 @@ WHERE t.family_id = ?`
 	var txns []domain.TransactionWithAuthor
 	for rows.Next() {
 		var txn domain.TransactionWithAuthor
+		var envelopeID, plannedID sql.NullString
 		var comment sql.NullString
-		if err := rows.Scan(&txn.ID, &txn.FamilyID, &txn.UserID, &txn.AccountID, &txn.CategoryID, &txn.Type, &txn.AmountMinor, &txn.Currency, &comment, &txn.OccurredAt, &txn.CreatedAt, &txn.UpdatedAt,
+		var amounts transactionAmounts
+		if err := rows.Scan(&txn.ID, &txn.FamilyID, &txn.UserID, &txn.AccountID, &txn.CategoryID, &envelopeID, &plannedID, &txn.Type, &txn.AmountMinor, &txn.Currency, &comment, &txn.OccurredAt, &txn.CreatedAt, &txn.UpdatedAt,
+			&amounts.originalAmount, &amounts.originalCurrency, &amounts.rate, &amounts.base,
 			&txn.Author.ID, &txn.Author.Name, &txn.Author.Email, &txn.Author.Role); err != nil {
 			return nil, err
 		}
+		amounts.apply(&txn.Transaction)
+		if envelopeID.Valid {
+			txn.EnvelopeID = &envelopeID.String
+		}
+		if plannedID.Valid {
+			txn.PlannedOperationID = &plannedID.String
+		}
 		if comment.Valid {
 			txn.Comment = comment.String
 		}
@@ -403,10 +500,37 @@ WHERE t.family_id = ?`
 	return txns, rows.Err()
 }
 
+func (s *Store) GetTransaction(ctx context.Context, id string) (*domain.Transaction, error) {
+	row := s.db.QueryRowContext(ctx, `SELECT id, family_id, user_id, account_id, category_id, envelope_id, planned_operation_id, type, amount_minor, currency, comment, occurred_at, created_at, updated_at,
+        original_amount_minor, original_currency, exchange_rate, amount_base_minor FROM transactions WHERE id = ?`, id)
+	var txn domain.Transaction
+	var envelopeID, plannedID sql.NullString
+	var comment sql.NullString
+	var amounts transactionAmounts
+	if err := row.Scan(&txn.ID, &txn.FamilyID, &txn.UserID, &txn.AccountID, &txn.CategoryID, &envelopeID, &plannedID, &txn.Type, &txn.AmountMinor, &txn.Currency, &comment, &txn.OccurredAt, &txn.CreatedAt, &txn.UpdatedAt,
+		&amounts.originalAmount, &amounts.originalCurrency, &amounts.rate, &amounts.base); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	amounts.apply(&txn)
+	if envelopeID.Valid {
+		txn.EnvelopeID = &envelopeID.String
+	}
+	if plannedID.Valid {
+		txn.PlannedOperationID = &plannedID.String
+	}
+	if comment.Valid {
+		txn.Comment = comment.String
+	}
+	return &txn, nil
+}
+
 func (s *Store) GetFamily(ctx context.Context, id string) (*domain.Family, error) {
-	row := s.db.QueryRowContext(ctx, `SELECT id, name, currency_base, created_at FROM families WHERE id = ?`, id)
+	row := s.db.QueryRowContext(ctx, `SELECT id, name, currency_base, timezone, approval_required, created_at FROM families WHERE id = ?`, id)
 	var family domain.Family
-	if err := row.Scan(&family.ID, &family.Name, &family.CurrencyBase, &family.CreatedAt); err != nil {
+	if err := row.Scan(&family.ID, &family.Name, &family.CurrencyBase, &family.Timezone, &family.ApprovalRequired, &family.CreatedAt); err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
 			return nil, nil
 		}
@@ -415,21 +539,22 @@ func (s *Store) GetFamily(ctx context.Context, id string) (*domain.Family, error
 	return &family, nil
 }
 
-func (s *Store) reportByCategory(ctx context.Context, familyID, txnType string, start, end *time.Time) ([]domain.CategoryReportItem, error) {
-	baseQuery := `SELECT c.id, c.name, c.color, t.currency, SUM(t.amount_minor) AS total
+func (s *Store) reportByCategory(ctx context.Context, familyID, txnType string, opts ReportOptions) ([]domain.CategoryReportItem, error) {
+	amount, currency := reportAmountColumns(opts.Amounts)
+	baseQuery := `SELECT c.id, c.name, c.color, ` + currency + `, SUM(` + amount + `) AS total
 FROM transactions t
 JOIN categories c ON c.id = t.category_id
 WHERE t.family_id = ? AND LOWER(t.type) = ?`
 	args := []interface{}{familyID, strings.ToLower(txnType)}
-	if start != nil {
+	if opts.Start != nil {
 		baseQuery += " AND t.occurred_at >= ?"
-		args = append(args, start.UTC())
+		args = append(args, opts.Start.UTC())
 	}
-	if end != nil {
+	if opts.End != nil {
 		baseQuery += " AND t.occurred_at <= ?"
-		args = append(args, end.UTC())
+		args = append(args, opts.End.UTC())
 	}
-	baseQuery += " GROUP BY c.id, c.name, c.color, t.currency ORDER BY total DESC"
+	baseQuery += " GROUP BY c.id, c.name, c.color, " + currency + " ORDER BY total DESC"
 
 	rows, err := s.db.QueryContext(ctx, baseQuery, args...)
 	if err != nil {
@@ -448,20 +573,89 @@ WHERE t.family_id = ? AND LOWER(t.type) = ?`
 	return items, rows.Err()
 }
 
-func (s *Store) reportTotalsByType(ctx context.Context, familyID, txnType string, start, end *time.Time) ([]domain.CurrencyAmount, error) {
-	baseQuery := `SELECT t.currency, SUM(t.amount_minor) AS total
+func (s *Store) reportByMember(ctx context.Context, familyID, txnType string, opts ReportOptions) ([]domain.MemberReportItem, error) {
+	amount, currency := reportAmountColumns(opts.Amounts)
+	baseQuery := `SELECT u.id, u.name, ` + currency + `, SUM(` + amount + `) AS total
+FROM transactions t
+JOIN users u ON u.id = t.user_id
+WHERE t.family_id = ? AND LOWER(t.type) = ?`
+	args := []interface{}{familyID, strings.ToLower(txnType)}
+	if opts.Start != nil {
+		baseQuery += " AND t.occurred_at >= ?"
+		args = append(args, opts.Start.UTC())
+	}
+	if opts.End != nil {
+		baseQuery += " AND t.occurred_at <= ?"
+		args = append(args, opts.End.UTC())
+	}
+	baseQuery += " GROUP BY u.id, u.name, " + currency + " ORDER BY total DESC"
+
+	rows, err := s.db.QueryContext(ctx, baseQuery, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	items := []domain.MemberReportItem{}
+	for rows.Next() {
+		var item domain.MemberReportItem
+		if err := rows.Scan(&item.UserID, &item.UserName, &item.Currency, &item.AmountMinor); err != nil {
+			return nil, err
+		}
+		items = append(items, item)
+	}
+	return items, rows.Err()
+}
+
+func (s *Store) reportByAccount(ctx context.Context, familyID, txnType string, opts ReportOptions) ([]domain.AccountReportItem, error) {
+	amount, currency := reportAmountColumns(opts.Amounts)
+	baseQuery := `SELECT a.id, a.name, ` + currency + `, SUM(` + amount + `) AS total
+FROM transactions t
+JOIN accounts a ON a.id = t.account_id
+WHERE t.family_id = ? AND LOWER(t.type) = ?`
+	args := []interface{}{familyID, strings.ToLower(txnType)}
+	if opts.Start != nil {
+		baseQuery += " AND t.occurred_at >= ?"
+		args = append(args, opts.Start.UTC())
+	}
+	if opts.End != nil {
+		baseQuery += " AND t.occurred_at <= ?"
+		args = append(args, opts.End.UTC())
+	}
+	baseQuery += " GROUP BY a.id, a.name, " + currency + " ORDER BY total DESC"
+
+	rows, err := s.db.QueryContext(ctx, baseQuery, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	items := []domain.AccountReportItem{}
+	for rows.Next() {
+		var item domain.AccountReportItem
+		if err := rows.Scan(&item.AccountID, &item.AccountName, &item.Currency, &item.AmountMinor); err != nil {
+			return nil, err
+		}
+		items = append(items, item)
+	}
+	return items, rows.Err()
+}
+
+func (s *Store) reportTotalsByType(ctx context.Context, familyID, txnType string, opts ReportOptions) ([]domain.CurrencyAmount, error) {
+	amount, currency := reportAmountColumns(opts.Amounts)
+	baseQuery := `SELECT ` + currency + `, SUM(` + amount + `) AS total
 FROM transactions t
 WHERE t.family_id = ? AND LOWER(t.type) = ?`
 	args := []interface{}{familyID, strings.ToLower(txnType)}
-	if start != nil {
+	if opts.Start != nil {
 		baseQuery += " AND t.occurred_at >= ?"
-		args = append(args, start.UTC())
+		args = append(args, opts.Start.UTC())
 	}
-	if end != nil {
+	if opts.End != nil {
 		baseQuery += " AND t.occurred_at <= ?"
-		args = append(args, end.UTC())
+		args = append(args, opts.End.UTC())
 	}
-	baseQuery += " GROUP BY t.currency ORDER BY total DESC"
+	baseQuery += " GROUP BY " + currency + " ORDER BY total DESC"
 
 	rows, err := s.db.QueryContext(ctx, baseQuery, args...)
 	if err != nil {
@@ -480,21 +674,67 @@ WHERE t.family_id = ? AND LOWER(t.type) = ?`
 	return totals, rows.Err()
 }
 
-func (s *Store) GetReportsOverview(ctx context.Context, familyID string, start, end *time.Time) (domain.ReportsOverview, error) {
-	expensesByCategory, err := s.reportByCategory(ctx, familyID, "expense", start, end)
+// ReportOptions select the period of a reports overview and how it is broken
+// down.
+type ReportOptions struct {
+	Start *time.Time
+	End   *time.Time
+	// TreeDepth collapses the category tree below this many levels; zero
+	// keeps every level.
+	TreeDepth int
+	// CrossTabRows and CrossTabColumns request a cross-tab of two report
+	// dimensions, such as member by category.
+	CrossTabRows    string
+	CrossTabColumns string
+	// Converter adds family-currency amounts to every item.
+	Converter *RateConverter
+	// Amounts selects which amount of a transaction paid in another
+	// currency is reported: ReportAmountsAccount (the default) or
+	// ReportAmountsOriginal.
+	Amounts string
+}
+
+func (s *Store) movementReport(ctx context.Context, familyID, txnType string, opts ReportOptions) (domain.MovementReport, error) {
+	byCategory, err := s.reportByCategory(ctx, familyID, txnType, opts)
 	if err != nil {
-		return domain.ReportsOverview{}, err
+		return domain.MovementReport{}, err
 	}
-	expenseTotals, err := s.reportTotalsByType(ctx, familyID, "expense", start, end)
+	totals, err := s.reportTotalsByType(ctx, familyID, txnType, opts)
 	if err != nil {
-		return domain.ReportsOverview{}, err
+		return domain.MovementReport{}, err
+	}
+	byMember, err := s.reportByMember(ctx, familyID, txnType, opts)
+	if err != nil {
+		return domain.MovementReport{}, err
 	}
+	byAccount, err := s.reportByAccount(ctx, familyID, txnType, opts)
+	if err != nil {
+		return domain.MovementReport{}, err
+	}
+	tree, err := s.reportCategoryTree(ctx, familyID, txnType, opts)
+	if err != nil {
+		return domain.MovementReport{}, err
+	}
+	report := domain.MovementReport{Totals: totals, ByCategory: byCategory, ByMember: byMember, ByAccount: byAccount, CategoryTree: tree}
+	if opts.CrossTabRows != "" && opts.CrossTabColumns != "" {
+		if report.CrossTab, err = s.reportCrossTab(ctx, familyID, txnType, opts); err != nil {
+			return domain.MovementReport{}, err
+		}
+	}
+	if opts.Converter != nil {
+		if err := s.convertMovementReport(ctx, familyID, txnType, opts, &report); err != nil {
+			return domain.MovementReport{}, err
+		}
+	}
+	return report, nil
+}
 
-	incomesByCategory, err := s.reportByCategory(ctx, familyID, "income", start, end)
+func (s *Store) GetReportsOverview(ctx context.Context, familyID string, opts ReportOptions) (domain.ReportsOverview, error) {
+	expenses, err := s.movementReport(ctx, familyID, "expense", opts)
 	if err != nil {
 		return domain.ReportsOverview{}, err
 	}
-	incomeTotals, err := s.reportTotalsByType(ctx, familyID, "income", start, end)
+	incomes, err := s.movementReport(ctx, familyID, "income", opts)
 	if err != nil {
 		return domain.ReportsOverview{}, err
 	}
@@ -505,7 +745,7 @@ func (s *Store) GetReportsOverview(ctx context.Context, familyID string, start,
 	}
 	accountReports := make([]domain.AccountBalanceReport, 0, len(accounts))
 	for _, account := range accounts {
-		accountReports = append(accountReports, domain.AccountBalanceReport{
+		report := domain.AccountBalanceReport{
 			AccountID:    account.ID,
 			AccountName:  account.Name,
 			AccountType:  account.Type,
@@ -513,26 +753,33 @@ func (s *Store) GetReportsOverview(ctx context.Context, familyID string, start,
 			BalanceMinor: account.BalanceMinor,
 			IsShared:     account.IsShared,
 			IsArchived:   account.IsArchived,
-		})
+		}
+		for _, pocket := range account.Pockets {
+			report.Pockets = append(report.Pockets, domain.AccountPocketReport{Currency: pocket.Currency, BalanceMinor: pocket.BalanceMinor})
+		}
+		if opts.Converter != nil {
+			if err := convertAccountBalance(ctx, opts.Converter, &report); err != nil {
+				return domain.ReportsOverview{}, err
+			}
+		}
+		accountReports = append(accountReports, report)
 	}
 
 	overview := domain.ReportsOverview{
-		Period: domain.ReportPeriod{Start: start, End: end},
-		Expenses: domain.MovementReport{
-			Totals:     expenseTotals,
-			ByCategory: expensesByCategory,
-		},
-		Incomes: domain.MovementReport{
-			Totals:     incomeTotals,
-			ByCategory: incomesByCategory,
-		},
+		Period:          domain.ReportPeriod{Start: opts.Start, End: opts.End},
+		Expenses:        expenses,
+		Incomes:         incomes,
 		AccountBalances: accountReports,
 	}
 	return overview, nil
 }
 
 func (s *Store) CreatePlannedOperation(ctx context.Context, op *domain.PlannedOperation) error {
-	row := s.db.QueryRowContext(ctx, `SELECT family_id, is_archived FROM accounts WHERE id = ?`, op.AccountID)
+	return createPlannedOperation(ctx, s.db, op)
+}
+
+func createPlannedOperation(ctx context.Context, q queryer, op *domain.PlannedOperation) error {
+	row := q.QueryRowContext(ctx, `SELECT family_id, is_archived FROM accounts WHERE id = ?`, op.AccountID)
 	var accountFamily string
 	var isArchived bool
 	if err := row.Scan(&accountFamily, &isArchived); err != nil {
@@ -548,27 +795,39 @@ func (s *Store) CreatePlannedOperation(ctx context.Context, op *domain.PlannedOp
 		return ErrAccountArchived
 	}
 
-	_, err := s.db.ExecContext(ctx, `INSERT INTO planned_operations (id, family_id, user_id, account_id, category_id, type, title, amount_minor, currency, comment, due_at, recurrence, is_completed, last_completed_at, created_at, updated_at)
-VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
-		op.ID, op.FamilyID, op.UserID, op.AccountID, op.CategoryID, op.Type, op.Title, op.AmountMinor, op.Currency, nullableString(op.Comment), op.DueAt, nullableString(op.Recurrence), op.IsCompleted, nullableTime(op.LastCompletedAt), op.CreatedAt, op.UpdatedAt)
+	_, err := q.ExecContext(ctx, `INSERT INTO planned_operations (id, family_id, user_id, account_id, category_id, type, title, amount_minor, currency, comment, due_at, starts_at, snoozed_from, recurrence, auto_post, is_completed, last_completed_at, created_at, updated_at)
+VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		op.ID, op.FamilyID, op.UserID, op.AccountID, op.CategoryID, op.Type, op.Title, op.AmountMinor, op.Currency, nullableString(op.Comment), op.DueAt, op.StartsAt, nullableTime(op.SnoozedFrom), nullableString(op.Recurrence), op.AutoPost, op.IsCompleted, nullableTime(op.LastCompletedAt), op.CreatedAt, op.UpdatedAt)
 	return err
 }
 
-func (s *Store) GetPlannedOperation(ctx context.Context, id string) (*domain.PlannedOperation, error) {
-	row := s.db.QueryRowContext(ctx, `SELECT id, family_id, user_id, account_id, category_id, type, title, amount_minor, currency, comment, due_at, recurrence, is_completed, last_completed_at, created_at, updated_at FROM planned_operations WHERE id = ?`, id)
-	var op domain.PlannedOperation
+const plannedOperationSelect = `SELECT p.id, p.family_id, p.user_id, p.account_id, p.category_id, p.type, p.title, p.amount_minor, p.currency, p.comment, p.due_at, p.starts_at, p.snoozed_from, p.recurrence, p.auto_post, p.is_completed, p.last_completed_at, p.created_at, p.updated_at,
+        u.id, u.name, u.email, u.role
+FROM planned_operations p
+JOIN users u ON u.id = p.user_id`
+
+func scanPlannedOperation(row rowScanner) (*domain.PlannedOperationWithCreator, error) {
+	var op domain.PlannedOperationWithCreator
 	var comment sql.NullString
+	var startsAt, snoozedFrom sql.NullTime
 	var recurrence sql.NullString
 	var lastCompleted sql.NullTime
-	if err := row.Scan(&op.ID, &op.FamilyID, &op.UserID, &op.AccountID, &op.CategoryID, &op.Type, &op.Title, &op.AmountMinor, &op.Currency, &comment, &op.DueAt, &recurrence, &op.IsCompleted, &lastCompleted, &op.CreatedAt, &op.UpdatedAt); err != nil {
-		if errors.Is(err, sql.ErrNoRows) {
-			return nil, nil
-		}
+	if err := row.Scan(&op.ID, &op.FamilyID, &op.UserID, &op.AccountID, &op.CategoryID, &op.Type, &op.Title, &op.AmountMinor, &op.Currency, &comment, &op.DueAt, &startsAt, &snoozedFrom, &recurrence, &op.AutoPost, &op.IsCompleted, &lastCompleted, &op.CreatedAt, &op.UpdatedAt,
+		&op.Creator.ID, &op.Creator.Name, &op.Creator.Email, &op.Creator.Role); err != nil {
 		return nil, err
 	}
 	if comment.Valid {
 		op.Comment = comment.String
 	}
+	// Series created before starts_at existed are anchored at their current due date.
+	op.StartsAt = op.DueAt
+	if startsAt.Valid {
+		op.StartsAt = startsAt.Time
+	}
+	if snoozedFrom.Valid {
+		t := snoozedFrom.Time
+		op.SnoozedFrom = &t
+	}
 	if recurrence.Valid {
 		op.Recurrence = recurrence.String
 	}
@@ -579,42 +838,27 @@ func (s *Store) GetPlannedOperation(ctx context.Context, id string) (*domain.Pla
 	return &op, nil
 }
 
+func (s *Store) GetPlannedOperation(ctx context.Context, id string) (*domain.PlannedOperation, error) {
+	op, err := s.GetPlannedOperationWithCreator(ctx, id)
+	if err != nil || op == nil {
+		return nil, err
+	}
+	return &op.PlannedOperation, nil
+}
+
 func (s *Store) GetPlannedOperationWithCreator(ctx context.Context, id string) (*domain.PlannedOperationWithCreator, error) {
-	row := s.db.QueryRowContext(ctx, `SELECT p.id, p.family_id, p.user_id, p.account_id, p.category_id, p.type, p.title, p.amount_minor, p.currency, p.comment, p.due_at, p.recurrence, p.is_completed, p.last_completed_at, p.created_at, p.updated_at,
-        u.id, u.name, u.email, u.role
-FROM planned_operations p
-JOIN users u ON u.id = p.user_id
-WHERE p.id = ?`, id)
-	var op domain.PlannedOperationWithCreator
-	var comment sql.NullString
-	var recurrence sql.NullString
-	var lastCompleted sql.NullTime
-	if err := row.Scan(&op.ID, &op.FamilyID, &op.UserID, &op.AccountID, &op.CategoryID, &op.Type, &op.Title, &op.AmountMinor, &op.Currency, &comment, &op.DueAt, &recurrence, &op.IsCompleted, &lastCompleted, &op.CreatedAt, &op.UpdatedAt,
-		&op.Creator.ID, &op.Creator.Name, &op.Creator.Email, &op.Creator.Role); err != nil {
+	op, err := scanPlannedOperation(s.db.QueryRowContext(ctx, plannedOperationSelect+` WHERE p.id = ?`, id))
+	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
 			return nil, nil
 		}
 		return nil, err
 	}
-	if comment.Valid {
-		op.Comment = comment.String
-	}
-	if recurrence.Valid {
-		op.Recurrence = recurrence.String
-	}
-	if lastCompleted.Valid {
-		t := lastCompleted.Time
-		op.LastCompletedAt = &t
-	}
-	return &op, nil
+	return op, nil
 }
 
 func (s *Store) ListPlannedOperationsByFamily(ctx context.Context, familyID string, status PlannedOperationStatus) ([]domain.PlannedOperationWithCreator, error) {
-	baseQuery := `SELECT p.id, p.family_id, p.user_id, p.account_id, p.category_id, p.type, p.title, p.amount_minor, p.currency, p.comment, p.due_at, p.recurrence, p.is_completed, p.last_completed_at, p.created_at, p.updated_at,
-        u.id, u.name, u.email, u.role
-FROM planned_operations p
-JOIN users u ON u.id = p.user_id
-WHERE p.family_id = ?`
+	baseQuery := plannedOperationSelect + ` WHERE p.family_id = ?`
 	args := []interface{}{familyID}
 	switch status {
 	case PlannedOperationStatusPending:
@@ -634,40 +878,11 @@ WHERE p.family_id = ?`
 
 	var ops []domain.PlannedOperationWithCreator
 	for rows.Next() {
-		var item domain.PlannedOperationWithCreator
-		var comment sql.NullString
-		var recurrence sql.NullString
-		var lastCompleted sql.NullTime
-		if err := rows.Scan(&item.ID, &item.FamilyID, &item.UserID, &item.AccountID, &item.CategoryID, &item.Type, &item.Title, &item.AmountMinor, &item.Currency, &comment, &item.DueAt, &recurrence, &item.IsCompleted, &lastCompleted, &item.CreatedAt, &item.UpdatedAt,
-			&item.Creator.ID, &item.Creator.Name, &item.Creator.Email, &item.Creator.Role); err != nil {
+		item, err := scanPlannedOperation(rows)
+		if err != nil {
 			return nil, err
 		}
-		if comment.Valid {
-			item.Comment = comment.String
-		}
-		if recurrence.Valid {
-			item.Recurrence = recurrence.String
-		}
-		if lastCompleted.Valid {
-			t := lastCompleted.Time
-			item.LastCompletedAt = &t
-		}
-		ops = append(ops, item)
+		ops = append(ops, *item)
 	}
 	return ops, rows.Err()
 }
-
-func (s *Store) UpdatePlannedOperationStatus(ctx context.Context, op *domain.PlannedOperation) error {
-	res, err := s.db.ExecContext(ctx, `UPDATE planned_operations SET due_at = ?, is_completed = ?, last_completed_at = ?, updated_at = ? WHERE id = ? AND family_id = ?`, op.DueAt, op.IsCompleted, nullableTime(op.LastCompletedAt), op.UpdatedAt, op.ID, op.FamilyID)
-	if err != nil {
-		return err
-	}
-	rows, err := res.RowsAffected()
-	if err != nil {
-		return err
-	}
-	if rows == 0 {
-		return sql.ErrNoRows
-	}
-	return nil
-}
@@ -0,0 +1,363 @@
+package store
+
+import (
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+
+	"familybudget/internal/domain"
+)
+
+var ErrSubscriptionDecided = errors.New("subscription suggestion was already converted or dismissed")
+
+const (
+	SubscriptionDismissed = "dismissed"
+	SubscriptionConverted = "converted"
+)
+
+// subscriptionLookbackDays covers two yearly charges with some slack.
+const subscriptionLookbackDays = 2*365 + 45
+
+// subscriptionAmountTolerance is how far, in percent, a charge may differ
+// from the median of its group, which allows for price changes.
+const subscriptionAmountTolerance = 20
+
+type subscriptionPeriod struct {
+	name           string
+	days           int
+	toleranceDays  int
+	minOccurrences int
+	months         int
+	rule           string
+}
+
+var subscriptionPeriods = []subscriptionPeriod{
+	{name: "weekly", days: 7, toleranceDays: 1, minOccurrences: 4, rule: "FREQ=WEEKLY"},
+	{name: "monthly", days: 30, toleranceDays: 4, minOccurrences: 3, months: 1, rule: "FREQ=MONTHLY"},
+	{name: "quarterly", days: 91, toleranceDays: 8, minOccurrences: 3, months: 3, rule: "FREQ=MONTHLY;INTERVAL=3"},
+	{name: "yearly", days: 365, toleranceDays: 15, minOccurrences: 2, months: 12, rule: "FREQ=YEARLY"},
+}
+
+func (p subscriptionPeriod) next(t time.Time) time.Time {
+	if p.months == 0 {
+		return t.AddDate(0, 0, p.days)
+	}
+	// Keep month-end charges at the end of shorter months.
+	first := time.Date(t.Year(), t.Month()+time.Month(p.months), 1, t.Hour(), t.Minute(), t.Second(), t.Nanosecond(), t.Location())
+	last := first.AddDate(0, 1, -1).Day()
+	day := t.Day()
+	if day > last {
+		day = last
+	}
+	return first.AddDate(0, 0, day-1)
+}
+
+type subscriptionCharge struct {
+	id         string
+	accountID  string
+	amount     int64
+	comment    string
+	occurredAt time.Time
+}
+
+type subscriptionGroup struct {
+	key        string
+	categoryID string
+	currency   string
+	charges    []subscriptionCharge
+}
+
+// subscriptionKey identifies the merchant of a charge by the letters of its
+// comment, so dates and invoice numbers do not split a series. Charges
+// without a comment are grouped by their exact amount instead.
+func subscriptionKey(comment string, amount int64) string {
+	var b strings.Builder
+	space := false
+	for _, r := range strings.ToLower(comment) {
+		if unicode.IsLetter(r) {
+			if space && b.Len() > 0 {
+				b.WriteByte(' ')
+			}
+			b.WriteRune(r)
+			space = false
+		} else {
+			space = true
+		}
+	}
+	if b.Len() == 0 {
+		return "#" + strconv.FormatInt(amount, 10)
+	}
+	return b.String()
+}
+
+func subscriptionSuggestionID(familyID, categoryID, currency, key string) string {
+	sum := sha256.Sum256([]byte(familyID + "\x00" + categoryID + "\x00" + currency + "\x00" + key))
+	return hex.EncodeToString(sum[:16])
+}
+
+// DetectSubscriptions looks for periodic expenses in the transaction history
+// of a family. Expenses are grouped by merchant (the comment), category and
+// currency; a group is a subscription when its amounts stay close to each
+// other and every gap between charges matches one period. Series that
+// stopped, are already covered by a recurring planned operation or were
+// dismissed or converted are left out.
+func (s *Store) DetectSubscriptions(ctx context.Context, familyID string, now time.Time, loc *time.Location) ([]domain.SubscriptionSuggestion, error) {
+	if loc == nil {
+		loc = time.UTC
+	}
+	rows, err := s.db.QueryContext(ctx, `SELECT id, account_id, category_id, amount_minor, currency, comment, occurred_at FROM transactions
+WHERE family_id = ? AND LOWER(type) = 'expense' AND planned_operation_id IS NULL AND occurred_at >= ? AND occurred_at <= ?
+ORDER BY occurred_at`, familyID, now.AddDate(0, 0, -subscriptionLookbackDays).UTC(), now.UTC())
+	if err != nil {
+		return nil, err
+	}
+	groups := make(map[string]*subscriptionGroup)
+	var order []string
+	for rows.Next() {
+		var charge subscriptionCharge
+		var categoryID, currency string
+		var comment sql.NullString
+		if err := rows.Scan(&charge.id, &charge.accountID, &categoryID, &charge.amount, &currency, &comment, &charge.occurredAt); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		charge.comment = strings.TrimSpace(comment.String)
+		key := subscriptionKey(charge.comment, charge.amount)
+		id := subscriptionSuggestionID(familyID, categoryID, currency, key)
+		group, ok := groups[id]
+		if !ok {
+			group = &subscriptionGroup{key: key, categoryID: categoryID, currency: currency}
+			groups[id] = group
+			order = append(order, id)
+		}
+		group.charges = append(group.charges, charge)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if len(groups) == 0 {
+		return nil, nil
+	}
+
+	decided, err := s.subscriptionDecisions(ctx, familyID)
+	if err != nil {
+		return nil, err
+	}
+	covered, err := s.coveredSubscriptionKeys(ctx, familyID)
+	if err != nil {
+		return nil, err
+	}
+	categories, err := s.ListCategoriesByFamily(ctx, familyID)
+	if err != nil {
+		return nil, err
+	}
+	categoryNames := make(map[string]string, len(categories))
+	for _, category := range categories {
+		categoryNames[category.ID] = category.Name
+	}
+
+	var suggestions []domain.SubscriptionSuggestion
+	for _, id := range order {
+		group := groups[id]
+		if decided[id] || covered[group.categoryID+"\x00"+group.currency+"\x00"+group.key] {
+			continue
+		}
+		suggestion, ok := detectSubscription(group, now, loc)
+		if !ok {
+			continue
+		}
+		suggestion.ID = id
+		if suggestion.Title == "" {
+			suggestion.Title = categoryNames[group.categoryID]
+		}
+		suggestions = append(suggestions, *suggestion)
+	}
+	sort.SliceStable(suggestions, func(i, j int) bool {
+		return suggestions[i].NextExpectedAt.Before(suggestions[j].NextExpectedAt)
+	})
+	return suggestions, nil
+}
+
+// GetSubscriptionSuggestion returns a current suggestion by id, or nil when
+// it is not detected any more.
+func (s *Store) GetSubscriptionSuggestion(ctx context.Context, familyID, id string, now time.Time, loc *time.Location) (*domain.SubscriptionSuggestion, error) {
+	suggestions, err := s.DetectSubscriptions(ctx, familyID, now, loc)
+	if err != nil {
+		return nil, err
+	}
+	for i := range suggestions {
+		if suggestions[i].ID == id {
+			return &suggestions[i], nil
+		}
+	}
+	return nil, nil
+}
+
+func detectSubscription(group *subscriptionGroup, now time.Time, loc *time.Location) (*domain.SubscriptionSuggestion, bool) {
+	charges := group.charges
+	if len(charges) < 2 {
+		return nil, false
+	}
+
+	amounts := make([]int64, len(charges))
+	for i, charge := range charges {
+		amounts[i] = charge.amount
+	}
+	sort.Slice(amounts, func(i, j int) bool { return amounts[i] < amounts[j] })
+	median := amounts[len(amounts)/2]
+	var total int64
+	for _, charge := range charges {
+		diff := charge.amount - median
+		if diff < 0 {
+			diff = -diff
+		}
+		if diff*100 > median*subscriptionAmountTolerance {
+			return nil, false
+		}
+		total += charge.amount
+	}
+
+	gaps := make([]int, len(charges)-1)
+	for i := 1; i < len(charges); i++ {
+		gaps[i-1] = calendarDaysBetween(charges[i-1].occurredAt.In(loc), charges[i].occurredAt.In(loc))
+	}
+	sorted := append([]int(nil), gaps...)
+	sort.Ints(sorted)
+	typical := sorted[len(sorted)/2]
+
+	var period *subscriptionPeriod
+	for i := range subscriptionPeriods {
+		p := &subscriptionPeriods[i]
+		if typical >= p.days-p.toleranceDays && typical <= p.days+p.toleranceDays {
+			period = p
+			break
+		}
+	}
+	if period == nil || len(charges) < period.minOccurrences {
+		return nil, false
+	}
+	for _, gap := range gaps {
+		if gap < period.days-period.toleranceDays || gap > period.days+period.toleranceDays {
+			return nil, false
+		}
+	}
+
+	last := charges[len(charges)-1]
+	next := period.next(last.occurredAt.In(loc))
+	// A charge that is overdue by half a period is treated as cancelled.
+	if calendarDaysBetween(next, now.In(loc)) > period.days/2 {
+		return nil, false
+	}
+
+	suggestion := &domain.SubscriptionSuggestion{
+		AccountID:          last.accountID,
+		CategoryID:         group.categoryID,
+		Type:               "expense",
+		Currency:           group.currency,
+		Period:             period.name,
+		Recurrence:         period.rule,
+		AverageAmountMinor: (total + int64(len(charges))/2) / int64(len(charges)),
+		LastAmountMinor:    last.amount,
+		Occurrences:        len(charges),
+		FirstSeenAt:        charges[0].occurredAt,
+		LastSeenAt:         last.occurredAt,
+		NextExpectedAt:     next.UTC(),
+	}
+	for i := len(charges) - 1; i >= 0; i-- {
+		// Drop trailing dates and invoice numbers.
+		title := strings.TrimRightFunc(charges[i].comment, func(r rune) bool { return !unicode.IsLetter(r) && r != ')' })
+		if title != "" {
+			suggestion.Title = title
+			break
+		}
+	}
+	for _, charge := range charges {
+		suggestion.TransactionIDs = append(suggestion.TransactionIDs, charge.id)
+	}
+	return suggestion, true
+}
+
+// calendarDaysBetween counts the calendar days from a to b in the location
+// of a, ignoring daylight saving shifts.
+func calendarDaysBetween(a, b time.Time) int {
+	b = b.In(a.Location())
+	from := time.Date(a.Year(), a.Month(), a.Day(), 0, 0, 0, 0, time.UTC)
+	to := time.Date(b.Year(), b.Month(), b.Day(), 0, 0, 0, 0, time.UTC)
+	return int(to.Sub(from).Hours() / 24)
+}
+
+func (s *Store) subscriptionDecisions(ctx context.Context, familyID string) (map[string]bool, error) {
+	rows, err := s.db.QueryContext(ctx, `SELECT suggestion_id FROM subscription_decisions WHERE family_id = ?`, familyID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	decided := make(map[string]bool)
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		decided[id] = true
+	}
+	return decided, rows.Err()
+}
+
+// coveredSubscriptionKeys returns the group keys of expenses that pending
+// recurring planned operations already describe, matched by title or
+// comment, or by amount when the charges carry no comment.
+func (s *Store) coveredSubscriptionKeys(ctx context.Context, familyID string) (map[string]bool, error) {
+	ops, err := s.ListPlannedOperationsByFamily(ctx, familyID, PlannedOperationStatusPending)
+	if err != nil {
+		return nil, err
+	}
+	covered := make(map[string]bool)
+	for _, op := range ops {
+		if op.Type != "expense" || strings.TrimSpace(op.Recurrence) == "" {
+			continue
+		}
+		prefix := op.CategoryID + "\x00" + op.Currency + "\x00"
+		covered[prefix+subscriptionKey(op.Title, op.AmountMinor)] = true
+		covered[prefix+subscriptionKey(op.Comment, op.AmountMinor)] = true
+	}
+	return covered, nil
+}
+
+func recordSubscriptionDecision(ctx context.Context, q queryer, familyID, suggestionID, decision string, plannedOperationID *string, userID string, now time.Time) error {
+	var count int
+	if err := q.QueryRowContext(ctx, `SELECT COUNT(*) FROM subscription_decisions WHERE family_id = ? AND suggestion_id = ?`, familyID, suggestionID).Scan(&count); err != nil {
+		return err
+	}
+	if count > 0 {
+		return ErrSubscriptionDecided
+	}
+	_, err := q.ExecContext(ctx, `INSERT INTO subscription_decisions (family_id, suggestion_id, decision, planned_operation_id, decided_by, decided_at) VALUES (?, ?, ?, ?, ?, ?)`,
+		familyID, suggestionID, decision, plannedOperationID, userID, now.UTC())
+	return err
+}
+
+// DismissSubscriptionSuggestion keeps a suggestion from being offered again.
+func (s *Store) DismissSubscriptionSuggestion(ctx context.Context, familyID, suggestionID, userID string, now time.Time) error {
+	return s.withTx(ctx, func(dbTx *sql.Tx) error {
+		return recordSubscriptionDecision(ctx, dbTx, familyID, suggestionID, SubscriptionDismissed, nil, userID, now)
+	})
+}
+
+// ConvertSubscriptionSuggestion creates the planned operation for a
+// suggestion and records the conversion in one transaction.
+func (s *Store) ConvertSubscriptionSuggestion(ctx context.Context, suggestionID string, op *domain.PlannedOperation, now time.Time) error {
+	return s.withTx(ctx, func(dbTx *sql.Tx) error {
+		if err := recordSubscriptionDecision(ctx, dbTx, op.FamilyID, suggestionID, SubscriptionConverted, &op.ID, op.UserID, now); err != nil {
+			return err
+		}
+		return createPlannedOperation(ctx, dbTx, op)
+	})
+}
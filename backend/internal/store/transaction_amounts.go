@@ -0,0 +1,134 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"strings"
+	"time"
+
+	"familybudget/internal/domain"
+	"familybudget/internal/recurrence"
+)
+
+// transactionAmounts holds the nullable currency columns of a transaction
+// row.
+type transactionAmounts struct {
+	originalAmount   sql.NullInt64
+	originalCurrency sql.NullString
+	rate             sql.NullFloat64
+	base             sql.NullInt64
+}
+
+func (a *transactionAmounts) apply(txn *domain.Transaction) {
+	if a.originalAmount.Valid && a.originalCurrency.Valid {
+		amount := a.originalAmount.Int64
+		txn.OriginalAmountMinor = &amount
+		txn.OriginalCurrency = a.originalCurrency.String
+	}
+	if a.rate.Valid {
+		rate := a.rate.Float64
+		txn.ExchangeRate = &rate
+	}
+	if a.base.Valid {
+		base := a.base.Int64
+		txn.AmountBaseMinor = &base
+	}
+}
+
+// transactionBaseAmount returns txn's amount in the family currency: the
+// amount itself or the original amount when either is already in that
+// currency, otherwise the account amount at the rate of the transaction's
+// date in the family time zone. It returns nil when no rate is known yet.
+func transactionBaseAmount(ctx context.Context, q queryer, txn *domain.Transaction) (*int64, error) {
+	var base, timezone string
+	if err := q.QueryRowContext(ctx, `SELECT currency_base, timezone FROM families WHERE id = ?`, txn.FamilyID).Scan(&base, &timezone); err != nil {
+		return nil, err
+	}
+	return baseAmountAt(ctx, q, txn.AmountMinor, txn.Currency, txn.OriginalAmountMinor, txn.OriginalCurrency, txn.OccurredAt, base, timezone)
+}
+
+func baseAmountAt(ctx context.Context, q queryer, amount int64, currency string, originalAmount *int64, originalCurrency string, occurredAt time.Time, base, timezone string) (*int64, error) {
+	base = strings.ToUpper(base)
+	switch {
+	case strings.EqualFold(currency, base):
+		return &amount, nil
+	case originalAmount != nil && strings.EqualFold(originalCurrency, base):
+		original := *originalAmount
+		return &original, nil
+	}
+	loc, err := recurrence.LoadLocation(timezone)
+	if err != nil {
+		loc = time.UTC
+	}
+	rate, err := lookupExchangeRate(ctx, q, currency, base, occurredAt.In(loc).Format(rateDateLayout))
+	if err != nil || rate == nil {
+		return nil, err
+	}
+	converted := domain.ConvertAmount(amount, currency, base, rate.Rate)
+	return &converted, nil
+}
+
+// FillTransactionBaseAmounts sets the family-currency amount of up to limit
+// transactions with ids after cursor that were stored before a rate for
+// their date was known. It returns how many it filled and the cursor of the
+// next batch, which is empty once all transactions were visited. Once set,
+// the amount is only recalculated after UpdateFamilyCurrency or a manual
+// rate clears it.
+func (s *Store) FillTransactionBaseAmounts(ctx context.Context, cursor string, limit int) (int, string, error) {
+	rows, err := s.db.QueryContext(ctx, `SELECT t.id, t.amount_minor, t.currency, t.original_amount_minor, t.original_currency, t.occurred_at, f.currency_base, f.timezone
+FROM transactions t
+JOIN families f ON f.id = t.family_id
+WHERE t.amount_base_minor IS NULL AND t.id > ?
+ORDER BY t.id
+LIMIT ?`, cursor, limit)
+	if err != nil {
+		return 0, "", err
+	}
+	type pending struct {
+		id               string
+		amount           int64
+		currency         string
+		originalAmount   *int64
+		originalCurrency string
+		occurredAt       time.Time
+		base, timezone   string
+	}
+	var txns []pending
+	for rows.Next() {
+		var txn pending
+		var originalAmount sql.NullInt64
+		var originalCurrency sql.NullString
+		if err := rows.Scan(&txn.id, &txn.amount, &txn.currency, &originalAmount, &originalCurrency, &txn.occurredAt, &txn.base, &txn.timezone); err != nil {
+			rows.Close()
+			return 0, "", err
+		}
+		if originalAmount.Valid {
+			txn.originalAmount = &originalAmount.Int64
+			txn.originalCurrency = originalCurrency.String
+		}
+		txns = append(txns, txn)
+	}
+	if err := rows.Close(); err != nil {
+		return 0, "", err
+	}
+
+	next := ""
+	if len(txns) == limit {
+		next = txns[len(txns)-1].id
+	}
+	filled := 0
+	for _, txn := range txns {
+		amount, err := baseAmountAt(ctx, s.db, txn.amount, txn.currency, txn.originalAmount, txn.originalCurrency, txn.occurredAt, txn.base, txn.timezone)
+		if err != nil {
+			return filled, cursor, err
+		}
+		if amount == nil {
+			continue
+		}
+		if _, err := s.db.ExecContext(ctx, `UPDATE transactions SET amount_base_minor = ? WHERE id = ? AND amount_base_minor IS NULL`, *amount, txn.id); err != nil {
+			return filled, cursor, err
+		}
+		filled++
+	}
+	return filled, next, nil
+}
@@ -0,0 +1,49 @@
+-- Конверты и пул нераспределённых средств
+CREATE TABLE IF NOT EXISTS envelopes (
+    id UUID PRIMARY KEY,
+    family_id UUID NOT NULL REFERENCES families(id),
+    name TEXT NOT NULL,
+    currency CHAR(3) NOT NULL,
+    target_amount_minor BIGINT NOT NULL DEFAULT 0,
+    current_amount_minor BIGINT NOT NULL DEFAULT 0,
+    auto_fill_rule JSONB,
+    is_archived BOOLEAN NOT NULL DEFAULT FALSE,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_envelopes_family ON envelopes(family_id);
+
+CREATE TABLE IF NOT EXISTS envelope_pools (
+    family_id UUID NOT NULL REFERENCES families(id),
+    currency CHAR(3) NOT NULL,
+    unassigned_minor BIGINT NOT NULL DEFAULT 0,
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (family_id, currency)
+);
+
+ALTER TABLE transactions
+    ADD COLUMN IF NOT EXISTS envelope_id UUID NULL REFERENCES envelopes(id);
+
+CREATE TABLE IF NOT EXISTS envelope_movements (
+    id UUID PRIMARY KEY,
+    family_id UUID NOT NULL REFERENCES families(id),
+    user_id UUID NOT NULL REFERENCES users(id),
+    kind TEXT NOT NULL,
+    from_envelope_id UUID NULL REFERENCES envelopes(id),
+    to_envelope_id UUID NULL REFERENCES envelopes(id),
+    transaction_id UUID NULL REFERENCES transactions(id),
+    amount_minor BIGINT NOT NULL,
+    currency CHAR(3) NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_envelope_movements_family ON envelope_movements(family_id, created_at);
+
+-- Существующие доходы формируют стартовый пул нераспределённых средств
+INSERT INTO envelope_pools (family_id, currency, unassigned_minor, updated_at)
+SELECT family_id, currency, SUM(amount_minor), NOW()
+FROM transactions
+WHERE type = 'income'
+GROUP BY family_id, currency
+ON CONFLICT (family_id, currency) DO NOTHING;
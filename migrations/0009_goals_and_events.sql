@@ -0,0 +1,50 @@
+-- Журнал доменных событий (outbox)
+CREATE TABLE IF NOT EXISTS events (
+    id UUID PRIMARY KEY,
+    family_id UUID NOT NULL REFERENCES families(id),
+    event TEXT NOT NULL,
+    version TEXT NOT NULL,
+    payload JSONB NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_events_family ON events(family_id, created_at);
+
+-- Цели накоплений
+CREATE TABLE IF NOT EXISTS goals (
+    id UUID PRIMARY KEY,
+    family_id UUID NOT NULL REFERENCES families(id),
+    name TEXT NOT NULL,
+    currency CHAR(3) NOT NULL,
+    target_amount_minor BIGINT NOT NULL,
+    due_date TIMESTAMPTZ,
+    priority INTEGER NOT NULL DEFAULT 0,
+    funding_mode TEXT NOT NULL,
+    completed_at TIMESTAMPTZ,
+    is_archived BOOLEAN NOT NULL DEFAULT FALSE,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_goals_family ON goals(family_id);
+
+CREATE TABLE IF NOT EXISTS goal_accounts (
+    goal_id UUID NOT NULL REFERENCES goals(id),
+    account_id UUID NOT NULL REFERENCES accounts(id),
+    PRIMARY KEY (goal_id, account_id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_goal_accounts_account ON goal_accounts(account_id);
+
+CREATE TABLE IF NOT EXISTS goal_contributions (
+    id UUID PRIMARY KEY,
+    goal_id UUID NOT NULL REFERENCES goals(id),
+    family_id UUID NOT NULL REFERENCES families(id),
+    user_id UUID NOT NULL REFERENCES users(id),
+    amount_minor BIGINT NOT NULL,
+    comment TEXT,
+    contributed_at TIMESTAMPTZ NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_goal_contributions_goal ON goal_contributions(goal_id, contributed_at);
@@ -0,0 +1,29 @@
+-- Долги и займы
+CREATE TABLE IF NOT EXISTS debts (
+    id UUID PRIMARY KEY,
+    family_id UUID NOT NULL REFERENCES families(id),
+    counterparty TEXT NOT NULL,
+    direction TEXT NOT NULL CHECK (direction IN ('we_owe', 'they_owe')),
+    amount_minor BIGINT NOT NULL,
+    currency CHAR(3) NOT NULL,
+    due_date TIMESTAMPTZ,
+    notes TEXT,
+    due_soon_notified_at TIMESTAMPTZ,
+    closed_at TIMESTAMPTZ,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_debts_family ON debts(family_id, closed_at);
+
+CREATE TABLE IF NOT EXISTS debt_repayments (
+    id UUID PRIMARY KEY,
+    debt_id UUID NOT NULL REFERENCES debts(id),
+    family_id UUID NOT NULL REFERENCES families(id),
+    user_id UUID NOT NULL REFERENCES users(id),
+    transaction_id UUID NOT NULL UNIQUE REFERENCES transactions(id),
+    amount_minor BIGINT NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_debt_repayments_debt ON debt_repayments(debt_id);
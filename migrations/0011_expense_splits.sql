@@ -0,0 +1,34 @@
+-- Совместные расходы и взаиморасчёты между участниками семьи
+CREATE TABLE IF NOT EXISTS expense_splits (
+    id UUID PRIMARY KEY,
+    family_id UUID NOT NULL REFERENCES families(id),
+    transaction_id UUID NOT NULL UNIQUE REFERENCES transactions(id),
+    payer_id UUID NOT NULL REFERENCES users(id),
+    method TEXT NOT NULL CHECK (method IN ('equal', 'percent', 'exact')),
+    currency CHAR(3) NOT NULL,
+    total_minor BIGINT NOT NULL,
+    created_by UUID NOT NULL REFERENCES users(id),
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_expense_splits_family ON expense_splits(family_id);
+
+CREATE TABLE IF NOT EXISTS expense_split_shares (
+    split_id UUID NOT NULL REFERENCES expense_splits(id) ON DELETE CASCADE,
+    user_id UUID NOT NULL REFERENCES users(id),
+    amount_minor BIGINT NOT NULL,
+    PRIMARY KEY (split_id, user_id)
+);
+
+CREATE TABLE IF NOT EXISTS settlements (
+    id UUID PRIMARY KEY,
+    family_id UUID NOT NULL REFERENCES families(id),
+    from_user_id UUID NOT NULL REFERENCES users(id),
+    to_user_id UUID NOT NULL REFERENCES users(id),
+    currency CHAR(3) NOT NULL,
+    amount_minor BIGINT NOT NULL CHECK (amount_minor > 0),
+    created_by UUID NOT NULL REFERENCES users(id),
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_settlements_family ON settlements(family_id, created_at);
@@ -0,0 +1,53 @@
+-- Карманные деньги, лимиты расходов младших участников и очередь одобрения
+CREATE TABLE IF NOT EXISTS allowances (
+    id UUID PRIMARY KEY,
+    family_id UUID NOT NULL REFERENCES families(id),
+    user_id UUID NOT NULL REFERENCES users(id),
+    account_id UUID NOT NULL REFERENCES accounts(id),
+    category_id UUID NOT NULL REFERENCES categories(id),
+    amount_minor BIGINT NOT NULL CHECK (amount_minor > 0),
+    currency CHAR(3) NOT NULL,
+    recurrence TEXT NOT NULL,
+    next_run_at TIMESTAMPTZ NOT NULL,
+    is_active BOOLEAN NOT NULL DEFAULT TRUE,
+    last_posted_at TIMESTAMPTZ,
+    created_by UUID NOT NULL REFERENCES users(id),
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_allowances_due ON allowances(is_active, next_run_at);
+
+CREATE TABLE IF NOT EXISTS spending_caps (
+    family_id UUID NOT NULL REFERENCES families(id),
+    user_id UUID NOT NULL REFERENCES users(id),
+    category_id UUID NOT NULL REFERENCES categories(id),
+    period TEXT NOT NULL CHECK (period IN ('weekly', 'monthly')),
+    amount_minor BIGINT NOT NULL CHECK (amount_minor >= 0),
+    currency CHAR(3) NOT NULL,
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (user_id, category_id)
+);
+
+CREATE TABLE IF NOT EXISTS transaction_approvals (
+    id UUID PRIMARY KEY,
+    family_id UUID NOT NULL REFERENCES families(id),
+    status TEXT NOT NULL,
+    reason TEXT NOT NULL,
+    transaction_id UUID NOT NULL UNIQUE,
+    user_id UUID NOT NULL REFERENCES users(id),
+    account_id UUID NOT NULL REFERENCES accounts(id),
+    category_id UUID NOT NULL REFERENCES categories(id),
+    envelope_id UUID REFERENCES envelopes(id),
+    type TEXT NOT NULL CHECK (type IN ('income', 'expense')),
+    amount_minor BIGINT NOT NULL,
+    currency CHAR(3) NOT NULL,
+    comment TEXT,
+    occurred_at TIMESTAMPTZ NOT NULL,
+    reviewer_id UUID REFERENCES users(id),
+    review_note TEXT,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    reviewed_at TIMESTAMPTZ
+);
+
+CREATE INDEX IF NOT EXISTS idx_transaction_approvals_family ON transaction_approvals(family_id, status, created_at);
@@ -0,0 +1,4 @@
+-- Одобрение транзакций младших участников и гостей
+ALTER TABLE families ADD COLUMN IF NOT EXISTS approval_required BOOLEAN NOT NULL DEFAULT FALSE;
+
+ALTER TABLE transaction_approvals ADD CONSTRAINT transaction_approvals_status_check CHECK (status IN ('pending_approval', 'approved', 'rejected'));
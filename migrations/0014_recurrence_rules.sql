@@ -0,0 +1,5 @@
+-- Правила повторения RRULE и часовой пояс семьи
+ALTER TABLE families ADD COLUMN IF NOT EXISTS timezone TEXT NOT NULL DEFAULT 'UTC';
+
+ALTER TABLE planned_operations ADD COLUMN IF NOT EXISTS starts_at TIMESTAMPTZ;
+UPDATE planned_operations SET starts_at = due_at WHERE starts_at IS NULL;
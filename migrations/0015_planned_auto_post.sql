@@ -0,0 +1,4 @@
+-- Автоматическое проведение плановых операций
+ALTER TABLE planned_operations ADD COLUMN IF NOT EXISTS auto_post BOOLEAN NOT NULL DEFAULT FALSE;
+
+CREATE INDEX IF NOT EXISTS idx_planned_operations_auto_post ON planned_operations(due_at) WHERE auto_post AND NOT is_completed;
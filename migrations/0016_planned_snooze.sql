@@ -0,0 +1,2 @@
+-- Перенос отдельного повторения плановой операции
+ALTER TABLE planned_operations ADD COLUMN IF NOT EXISTS snoozed_from TIMESTAMPTZ;
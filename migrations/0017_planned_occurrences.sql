@@ -0,0 +1,18 @@
+-- История повторений плановых операций
+CREATE TABLE IF NOT EXISTS planned_occurrences (
+    id UUID PRIMARY KEY,
+    family_id UUID NOT NULL REFERENCES families(id),
+    planned_operation_id UUID NOT NULL REFERENCES planned_operations(id) ON DELETE CASCADE,
+    due_at TIMESTAMPTZ NOT NULL,
+    status TEXT NOT NULL CHECK (status IN ('paid', 'skipped', 'missed')),
+    transaction_id UUID NULL,
+    amount_minor BIGINT NOT NULL,
+    currency CHAR(3) NOT NULL,
+    user_id UUID NULL REFERENCES users(id),
+    recorded_at TIMESTAMPTZ NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_planned_occurrences_operation ON planned_occurrences(planned_operation_id, due_at);
+CREATE INDEX IF NOT EXISTS idx_planned_occurrences_family ON planned_occurrences(family_id, status, due_at);
+
+ALTER TABLE transactions ADD COLUMN IF NOT EXISTS planned_operation_id UUID NULL;
@@ -0,0 +1,36 @@
+-- Напоминания о плановых операциях и входящие сообщения
+CREATE TABLE IF NOT EXISTS reminder_rules (
+    id UUID PRIMARY KEY,
+    family_id UUID NOT NULL REFERENCES families(id),
+    planned_operation_id UUID NULL REFERENCES planned_operations(id) ON DELETE CASCADE,
+    offset_days INTEGER NOT NULL,
+    channels TEXT NOT NULL,
+    created_by UUID NOT NULL REFERENCES users(id),
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_reminder_rules_family ON reminder_rules(family_id);
+
+CREATE TABLE IF NOT EXISTS reminder_deliveries (
+    planned_operation_id UUID NOT NULL,
+    due_at TIMESTAMPTZ NOT NULL,
+    offset_days INTEGER NOT NULL,
+    user_id UUID NOT NULL REFERENCES users(id),
+    channel TEXT NOT NULL,
+    sent_at TIMESTAMPTZ NOT NULL,
+    PRIMARY KEY (planned_operation_id, due_at, offset_days, user_id, channel)
+);
+
+CREATE TABLE IF NOT EXISTS inbox_messages (
+    id UUID PRIMARY KEY,
+    family_id UUID NOT NULL REFERENCES families(id),
+    user_id UUID NOT NULL REFERENCES users(id),
+    kind TEXT NOT NULL,
+    subject TEXT NOT NULL,
+    body TEXT NOT NULL,
+    planned_operation_id UUID NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    read_at TIMESTAMPTZ NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_inbox_messages_user ON inbox_messages(user_id, created_at);
@@ -0,0 +1,10 @@
+-- Решения по найденным подпискам: отклонённые и превращённые в плановые операции
+CREATE TABLE IF NOT EXISTS subscription_decisions (
+    family_id UUID NOT NULL REFERENCES families(id),
+    suggestion_id TEXT NOT NULL,
+    decision TEXT NOT NULL,
+    planned_operation_id UUID NULL REFERENCES planned_operations(id) ON DELETE SET NULL,
+    decided_by UUID NOT NULL REFERENCES users(id),
+    decided_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (family_id, suggestion_id)
+);
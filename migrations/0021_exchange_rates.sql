@@ -0,0 +1,11 @@
+-- Исторические курсы валют для пересчёта отчётов в валюту семьи
+CREATE TABLE IF NOT EXISTS exchange_rates (
+    id UUID PRIMARY KEY,
+    base CHAR(3) NOT NULL,
+    quote CHAR(3) NOT NULL,
+    rate NUMERIC NOT NULL,
+    as_of DATE NOT NULL,
+    source TEXT NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    UNIQUE (base, quote, as_of)
+);
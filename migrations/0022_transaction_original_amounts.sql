@@ -0,0 +1,9 @@
+-- Операции в валюте, отличной от валюты счёта, и сумма в валюте семьи
+ALTER TABLE transactions ADD COLUMN IF NOT EXISTS original_amount_minor BIGINT NULL;
+ALTER TABLE transactions ADD COLUMN IF NOT EXISTS original_currency CHAR(3) NULL;
+ALTER TABLE transactions ADD COLUMN IF NOT EXISTS exchange_rate NUMERIC NULL;
+ALTER TABLE transactions ADD COLUMN IF NOT EXISTS amount_base_minor BIGINT NULL;
+
+ALTER TABLE transaction_approvals ADD COLUMN IF NOT EXISTS original_amount_minor BIGINT NULL;
+ALTER TABLE transaction_approvals ADD COLUMN IF NOT EXISTS original_currency CHAR(3) NULL;
+ALTER TABLE transaction_approvals ADD COLUMN IF NOT EXISTS exchange_rate NUMERIC NULL;
@@ -0,0 +1,7 @@
+-- Валюты, которые семья включила помимо валют по умолчанию и своей базовой
+CREATE TABLE IF NOT EXISTS family_currencies (
+    family_id UUID NOT NULL REFERENCES families(id),
+    code CHAR(3) NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (family_id, code)
+);
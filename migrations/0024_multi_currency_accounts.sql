@@ -0,0 +1,27 @@
+-- Мультивалютные счета: карманы в валютах, отличных от основной, и обмен внутри счёта
+ALTER TABLE accounts ADD COLUMN IF NOT EXISTS multi_currency BOOLEAN NOT NULL DEFAULT FALSE;
+
+CREATE TABLE IF NOT EXISTS account_pockets (
+    account_id UUID NOT NULL REFERENCES accounts(id),
+    currency CHAR(3) NOT NULL,
+    balance_minor BIGINT NOT NULL DEFAULT 0,
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (account_id, currency)
+);
+
+CREATE TABLE IF NOT EXISTS account_exchanges (
+    id UUID PRIMARY KEY,
+    family_id UUID NOT NULL REFERENCES families(id),
+    account_id UUID NOT NULL REFERENCES accounts(id),
+    user_id UUID NOT NULL REFERENCES users(id),
+    from_currency CHAR(3) NOT NULL,
+    from_amount_minor BIGINT NOT NULL,
+    to_currency CHAR(3) NOT NULL,
+    to_amount_minor BIGINT NOT NULL,
+    rate NUMERIC NOT NULL,
+    comment TEXT,
+    occurred_at TIMESTAMPTZ NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_account_exchanges_account ON account_exchanges(account_id, occurred_at);
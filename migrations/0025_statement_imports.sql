@@ -0,0 +1,34 @@
+-- Импорт выписок: сохранённые профили семьи, загрузки на проверке и хэши проведённых строк
+CREATE TABLE IF NOT EXISTS import_profiles (
+    id UUID PRIMARY KEY,
+    family_id UUID NOT NULL REFERENCES families(id),
+    name TEXT NOT NULL,
+    config JSONB NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE TABLE IF NOT EXISTS imports (
+    id UUID PRIMARY KEY,
+    family_id UUID NOT NULL REFERENCES families(id),
+    user_id UUID NOT NULL REFERENCES users(id),
+    account_id UUID REFERENCES accounts(id),
+    file_name TEXT NOT NULL,
+    content BYTEA NOT NULL,
+    profile JSONB NOT NULL,
+    status TEXT NOT NULL,
+    idempotency_key TEXT,
+    transactions INTEGER NOT NULL DEFAULT 0,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    committed_at TIMESTAMPTZ
+);
+
+CREATE TABLE IF NOT EXISTS imported_transactions (
+    transaction_id UUID PRIMARY KEY REFERENCES transactions(id),
+    import_id UUID NOT NULL REFERENCES imports(id),
+    family_id UUID NOT NULL REFERENCES families(id),
+    hash TEXT NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_import_profiles_family ON import_profiles(family_id);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_imports_idempotency ON imports(family_id, idempotency_key);
+CREATE INDEX IF NOT EXISTS idx_imported_transactions_hash ON imported_transactions(family_id, hash);
@@ -0,0 +1,3 @@
+-- Начало серии начислений карманных денег для расписаний RRULE
+ALTER TABLE allowances ADD COLUMN IF NOT EXISTS starts_at TIMESTAMPTZ;
+UPDATE allowances SET starts_at = next_run_at WHERE starts_at IS NULL;